@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/robertlestak/procx/pkg/flags"
+)
+
+// printHelp prints the options of the drivers named in args, along with their
+// environment variables. With no driver, the drivers which have options are
+// listed.
+func printHelp(args []string) error {
+	fs := flag.NewFlagSet("help", flag.ExitOnError)
+	markdown := fs.Bool("markdown", false, "print the driver options as markdown tables")
+	env := fs.Bool("env", false, "print the environment variables of all driver options")
+	fs.Usage = func() {
+		fmt.Printf("Usage: %s help [-markdown] [driver ...]\n", AppName)
+		fmt.Printf("       %s help -env\n", AppName)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	names := fs.Args()
+	if *env {
+		printDriverEnv()
+		return nil
+	}
+	if len(names) == 0 && *markdown {
+		names = flags.DriverNames()
+	}
+	if len(names) == 0 {
+		fs.Usage()
+		fmt.Println("\nDrivers:")
+		for _, n := range flags.DriverNames() {
+			fmt.Printf("  %s\n", n)
+		}
+		return nil
+	}
+	for i, n := range names {
+		o := flags.DriverOptions(n)
+		if o == nil {
+			return fmt.Errorf("driver %s has no options", n)
+		}
+		if *markdown {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("#### %s\n\n", n)
+			o.PrintMarkdown(os.Stdout, EnvKeyPrefix)
+			continue
+		}
+		fmt.Printf("%s options:\n", n)
+		o.PrintDefaults(os.Stdout, EnvKeyPrefix)
+	}
+	return nil
+}
+
+// printDriverEnv prints the sorted environment variables of the options of
+// all drivers, one per line.
+func printDriverEnv() {
+	seen := make(map[string]bool)
+	var envs []string
+	for _, n := range flags.DriverNames() {
+		for _, o := range flags.DriverOptions(n).Options() {
+			e := EnvKeyPrefix + o.Env()
+			if !seen[e] {
+				seen[e] = true
+				envs = append(envs, e)
+			}
+		}
+	}
+	sort.Strings(envs)
+	for _, e := range envs {
+		fmt.Println(e)
+	}
+}
@@ -1,15 +1,34 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/robertlestak/procx/pkg/admin"
+	"github.com/robertlestak/procx/pkg/breaker"
+	"github.com/robertlestak/procx/pkg/delivery"
 	"github.com/robertlestak/procx/pkg/drivers"
 	"github.com/robertlestak/procx/pkg/flags"
+	"github.com/robertlestak/procx/pkg/keda"
+	"github.com/robertlestak/procx/pkg/lock"
 	"github.com/robertlestak/procx/pkg/procx"
+	"github.com/robertlestak/procx/pkg/ratelimit"
+	"github.com/robertlestak/procx/pkg/utils"
+	"github.com/robertlestak/procx/pkg/webhook"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -33,33 +52,58 @@ func printVersion() {
 
 func printUsage() {
 	fmt.Printf("Usage: %s [options] [process]\n", AppName)
+	fmt.Printf("       %s peek [options] [process]\n", AppName)
+	fmt.Printf("       %s depth [options]\n", AppName)
+	fmt.Printf("       %s keda [options]\n", AppName)
+	fmt.Printf("       %s help [-markdown] [driver ...]\n", AppName)
 	flags.FlagSet.PrintDefaults()
 }
 
 func LoadEnv(prefix string) error {
 	if os.Getenv(prefix+"DRIVER") != "" {
 		d := os.Getenv(prefix + "DRIVER")
-		flags.Driver = &d
+		*flags.Driver = d
 	}
 	if os.Getenv(prefix+"HOSTENV") != "" {
 		h := os.Getenv(prefix + "HOSTENV")
-		t := h == "true"
-		flags.HostEnv = &t
+		*flags.HostEnv = h == "true"
+	}
+	if os.Getenv(prefix+"HOSTENV_ALLOW") != "" {
+		r := os.Getenv(prefix + "HOSTENV_ALLOW")
+		*flags.HostEnvAllow = r
+	}
+	if os.Getenv(prefix+"HOSTENV_REGEX") != "" {
+		r := os.Getenv(prefix + "HOSTENV_REGEX")
+		*flags.HostEnvRegex = r
+	}
+	if os.Getenv(prefix+"ENV_FILE") != "" {
+		r := os.Getenv(prefix + "ENV_FILE")
+		*flags.EnvFile = r
+	}
+	if os.Getenv(prefix+"ENV") != "" {
+		for _, kv := range strings.Split(os.Getenv(prefix+"ENV"), ",") {
+			flags.Env.Set(kv)
+		}
 	}
 	if os.Getenv(prefix+"PASS_WORK_AS_ARG") != "" {
 		r := os.Getenv(prefix + "PASS_WORK_AS_ARG")
-		t := r == "true"
-		flags.PassWorkAsArg = &t
+		*flags.PassWorkAsArg = r == "true"
 	}
 	if os.Getenv(prefix+"PASS_WORK_AS_STDIN") != "" {
 		r := os.Getenv(prefix + "PASS_WORK_AS_STDIN")
-		t := r == "true"
-		flags.PassWorkAsStdin = &t
+		*flags.PassWorkAsStdin = r == "true"
+	}
+	if os.Getenv(prefix+"SHELL") != "" {
+		r := os.Getenv(prefix + "SHELL")
+		*flags.Shell = r
+	}
+	if os.Getenv(prefix+"ARGS_ALLOW_MISSING") != "" {
+		r := os.Getenv(prefix + "ARGS_ALLOW_MISSING")
+		*flags.ArgsAllowMissing = r == "true"
 	}
 	if os.Getenv(prefix+"DAEMON") != "" {
 		r := os.Getenv(prefix + "DAEMON")
-		t := r == "true"
-		flags.Daemon = &t
+		*flags.Daemon = r == "true"
 	}
 	if os.Getenv(prefix+"DAEMON_INTERVAL") != "" {
 		r := os.Getenv(prefix + "DAEMON_INTERVAL")
@@ -67,36 +111,864 @@ func LoadEnv(prefix string) error {
 		if err != nil {
 			return err
 		}
-		flags.DaemonInterval = &i
+		*flags.DaemonInterval = i
+	}
+	if os.Getenv(prefix+"SCHEDULE") != "" {
+		r := os.Getenv(prefix + "SCHEDULE")
+		*flags.Schedule = r
+	}
+	if os.Getenv(prefix+"SCHEDULE_TZ") != "" {
+		r := os.Getenv(prefix + "SCHEDULE_TZ")
+		*flags.ScheduleTZ = r
+	}
+	if os.Getenv(prefix+"SCHEDULE_LIMIT") != "" {
+		r := os.Getenv(prefix + "SCHEDULE_LIMIT")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.ScheduleLimit = i
+	}
+	if os.Getenv(prefix+"SCHEDULE_OVERLAP") != "" {
+		r := os.Getenv(prefix + "SCHEDULE_OVERLAP")
+		*flags.ScheduleOverlap = r
+	}
+	if os.Getenv(prefix+"SCHEDULE_POLL_TIMEOUT") != "" {
+		r := os.Getenv(prefix + "SCHEDULE_POLL_TIMEOUT")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.SchedulePollTimeout = i
+	}
+	if os.Getenv(prefix+"ADMIN_ADDR") != "" {
+		r := os.Getenv(prefix + "ADMIN_ADDR")
+		*flags.AdminAddr = r
 	}
 	if os.Getenv(prefix+"PAYLOAD_FILE") != "" {
 		r := os.Getenv(prefix + "PAYLOAD_FILE")
-		flags.PayloadFile = &r
+		*flags.PayloadFile = r
 	}
 	if os.Getenv(prefix+"KEEP_PAYLOAD_FILE") != "" {
 		r := os.Getenv(prefix + "KEEP_PAYLOAD_FILE")
-		t := r == "true"
-		flags.KeepPayloadFile = &t
+		*flags.KeepPayloadFile = r == "true"
+	}
+	if os.Getenv(prefix+"WORKDIR") != "" {
+		r := os.Getenv(prefix + "WORKDIR")
+		*flags.Workdir = r == "true"
+	}
+	if os.Getenv(prefix+"WORKDIR_BASE") != "" {
+		r := os.Getenv(prefix + "WORKDIR_BASE")
+		*flags.WorkdirBase = r
+	}
+	if os.Getenv(prefix+"KEEP_FAILED_WORKDIR") != "" {
+		r := os.Getenv(prefix + "KEEP_FAILED_WORKDIR")
+		*flags.KeepFailedWorkdir = r == "true"
+	}
+	if os.Getenv(prefix+"OUTPUT_DIR") != "" {
+		r := os.Getenv(prefix + "OUTPUT_DIR")
+		*flags.OutputDir = r
+	}
+	if os.Getenv(prefix+"OUTPUT_TEE") != "" {
+		r := os.Getenv(prefix + "OUTPUT_TEE")
+		*flags.OutputTee = r == "true"
+	}
+	if os.Getenv(prefix+"OUTPUT_MAX_SIZE") != "" {
+		r := os.Getenv(prefix + "OUTPUT_MAX_SIZE")
+		i, err := strconv.ParseInt(r, 10, 64)
+		if err != nil {
+			return err
+		}
+		*flags.OutputMaxSize = i
+	}
+	if os.Getenv(prefix+"OUTPUT_RETAIN") != "" {
+		r := os.Getenv(prefix + "OUTPUT_RETAIN")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.OutputRetain = i
+	}
+	if os.Getenv(prefix+"OUTPUT_FORMAT") != "" {
+		r := os.Getenv(prefix + "OUTPUT_FORMAT")
+		*flags.OutputFormat = r
+	}
+	if os.Getenv(prefix+"STDERR_TAIL") != "" {
+		r := os.Getenv(prefix + "STDERR_TAIL")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.StderrTail = i
+	}
+	if os.Getenv(prefix+"UID") != "" {
+		r := os.Getenv(prefix + "UID")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.UID = i
+	}
+	if os.Getenv(prefix+"GID") != "" {
+		r := os.Getenv(prefix + "GID")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.GID = i
+	}
+	if os.Getenv(prefix+"SETPGID") != "" {
+		r := os.Getenv(prefix + "SETPGID")
+		*flags.Setpgid = r == "true"
+	}
+	if os.Getenv(prefix+"SETSID") != "" {
+		r := os.Getenv(prefix + "SETSID")
+		*flags.Setsid = r == "true"
+	}
+	if os.Getenv(prefix+"RLIMIT_CPU") != "" {
+		r := os.Getenv(prefix + "RLIMIT_CPU")
+		i, err := strconv.ParseInt(r, 10, 64)
+		if err != nil {
+			return err
+		}
+		*flags.RlimitCPU = i
+	}
+	if os.Getenv(prefix+"RLIMIT_AS") != "" {
+		r := os.Getenv(prefix + "RLIMIT_AS")
+		i, err := strconv.ParseInt(r, 10, 64)
+		if err != nil {
+			return err
+		}
+		*flags.RlimitAS = i
+	}
+	if os.Getenv(prefix+"RLIMIT_NOFILE") != "" {
+		r := os.Getenv(prefix + "RLIMIT_NOFILE")
+		i, err := strconv.ParseInt(r, 10, 64)
+		if err != nil {
+			return err
+		}
+		*flags.RlimitNofile = i
+	}
+	if os.Getenv(prefix+"RLIMIT_CORE") != "" {
+		r := os.Getenv(prefix + "RLIMIT_CORE")
+		i, err := strconv.ParseInt(r, 10, 64)
+		if err != nil {
+			return err
+		}
+		*flags.RlimitCore = i
+	}
+	if os.Getenv(prefix+"CGROUP_MEMORY") != "" {
+		r := os.Getenv(prefix + "CGROUP_MEMORY")
+		i, err := strconv.ParseInt(r, 10, 64)
+		if err != nil {
+			return err
+		}
+		*flags.CgroupMemory = i
+	}
+	if os.Getenv(prefix+"CGROUP_CPU") != "" {
+		r := os.Getenv(prefix + "CGROUP_CPU")
+		f, err := strconv.ParseFloat(r, 64)
+		if err != nil {
+			return err
+		}
+		*flags.CgroupCPU = f
+	}
+	if os.Getenv(prefix+"SINGLETON") != "" {
+		r := os.Getenv(prefix + "SINGLETON")
+		*flags.Singleton = r == "true"
+	}
+	if os.Getenv(prefix+"SINGLETON_LOCK") != "" {
+		r := os.Getenv(prefix + "SINGLETON_LOCK")
+		*flags.SingletonLock = r
+	}
+	if os.Getenv(prefix+"SINGLETON_KEY") != "" {
+		r := os.Getenv(prefix + "SINGLETON_KEY")
+		*flags.SingletonKey = r
+	}
+	if os.Getenv(prefix+"SINGLETON_TTL") != "" {
+		r := os.Getenv(prefix + "SINGLETON_TTL")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.SingletonTTL = i
+	}
+	if os.Getenv(prefix+"TIMEOUT") != "" {
+		r := os.Getenv(prefix + "TIMEOUT")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.Timeout = i
+	}
+	if os.Getenv(prefix+"RECORD_DIR") != "" {
+		r := os.Getenv(prefix + "RECORD_DIR")
+		*flags.RecordDir = r
+	}
+	if os.Getenv(prefix+"CONCURRENCY") != "" {
+		r := os.Getenv(prefix + "CONCURRENCY")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.Concurrency = i
+	}
+	if os.Getenv(prefix+"RATE") != "" {
+		r := os.Getenv(prefix + "RATE")
+		*flags.Rate = r
+	}
+	if os.Getenv(prefix+"RATE_BURST") != "" {
+		r := os.Getenv(prefix + "RATE_BURST")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.RateBurst = i
+	}
+	if os.Getenv(prefix+"KEY_CONCURRENCY") != "" {
+		r := os.Getenv(prefix + "KEY_CONCURRENCY")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.KeyConcurrency = i
+	}
+	if os.Getenv(prefix+"KEY_CONCURRENCY_PATH") != "" {
+		r := os.Getenv(prefix + "KEY_CONCURRENCY_PATH")
+		*flags.KeyConcurrencyPath = r
+	}
+	if os.Getenv(prefix+"KEY_CONCURRENCY_OP") != "" {
+		r := os.Getenv(prefix + "KEY_CONCURRENCY_OP")
+		*flags.KeyConcurrencyOp = r
+	}
+	if os.Getenv(prefix+"CAPTURE_OUTPUT") != "" {
+		r := os.Getenv(prefix + "CAPTURE_OUTPUT")
+		*flags.CaptureOutput = r == "true"
+	}
+	if os.Getenv(prefix+"CAPTURE_OUTPUT_MAX_SIZE") != "" {
+		r := os.Getenv(prefix + "CAPTURE_OUTPUT_MAX_SIZE")
+		i, err := strconv.ParseInt(r, 10, 64)
+		if err != nil {
+			return err
+		}
+		*flags.CaptureOutputMaxSize = i
+	}
+	if os.Getenv(prefix+"CAPTURE_OUTPUT_JSON") != "" {
+		r := os.Getenv(prefix + "CAPTURE_OUTPUT_JSON")
+		*flags.CaptureOutputJSON = r == "true"
+	}
+	if os.Getenv(prefix+"WEBHOOK_URL") != "" {
+		r := os.Getenv(prefix + "WEBHOOK_URL")
+		*flags.WebhookURL = r
+	}
+	if os.Getenv(prefix+"WEBHOOK_EVENTS") != "" {
+		r := os.Getenv(prefix + "WEBHOOK_EVENTS")
+		*flags.WebhookEvents = r
+	}
+	if os.Getenv(prefix+"WEBHOOK_METHOD") != "" {
+		r := os.Getenv(prefix + "WEBHOOK_METHOD")
+		*flags.WebhookMethod = r
+	}
+	if os.Getenv(prefix+"WEBHOOK_CONTENT_TYPE") != "" {
+		r := os.Getenv(prefix + "WEBHOOK_CONTENT_TYPE")
+		*flags.WebhookContentType = r
+	}
+	if os.Getenv(prefix+"WEBHOOK_HEADERS") != "" {
+		r := os.Getenv(prefix + "WEBHOOK_HEADERS")
+		*flags.WebhookHeaders = r
+	}
+	if os.Getenv(prefix+"WEBHOOK_BODY") != "" {
+		r := os.Getenv(prefix + "WEBHOOK_BODY")
+		*flags.WebhookBody = r
+	}
+	if os.Getenv(prefix+"WEBHOOK_FIELDS") != "" {
+		r := os.Getenv(prefix + "WEBHOOK_FIELDS")
+		*flags.WebhookFields = r
+	}
+	if os.Getenv(prefix+"WEBHOOK_TIMEOUT") != "" {
+		r := os.Getenv(prefix + "WEBHOOK_TIMEOUT")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.WebhookTimeout = i
+	}
+	if os.Getenv(prefix+"WEBHOOK_RETRIES") != "" {
+		r := os.Getenv(prefix + "WEBHOOK_RETRIES")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.WebhookRetries = i
+	}
+	if os.Getenv(prefix+"WEBHOOK_RETRY_INTERVAL") != "" {
+		r := os.Getenv(prefix + "WEBHOOK_RETRY_INTERVAL")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.WebhookRetryInterval = i
+	}
+	if os.Getenv(prefix+"WEBHOOK_ENABLE_TLS") != "" {
+		r := os.Getenv(prefix + "WEBHOOK_ENABLE_TLS")
+		*flags.WebhookEnableTLS = r == "true"
+	}
+	if os.Getenv(prefix+"WEBHOOK_TLS_INSECURE") != "" {
+		r := os.Getenv(prefix + "WEBHOOK_TLS_INSECURE")
+		*flags.WebhookTLSInsecure = r == "true"
+	}
+	if os.Getenv(prefix+"WEBHOOK_TLS_CERT_FILE") != "" {
+		r := os.Getenv(prefix + "WEBHOOK_TLS_CERT_FILE")
+		*flags.WebhookTLSCertFile = r
+	}
+	if os.Getenv(prefix+"WEBHOOK_TLS_KEY_FILE") != "" {
+		r := os.Getenv(prefix + "WEBHOOK_TLS_KEY_FILE")
+		*flags.WebhookTLSKeyFile = r
+	}
+	if os.Getenv(prefix+"WEBHOOK_TLS_CA_FILE") != "" {
+		r := os.Getenv(prefix + "WEBHOOK_TLS_CA_FILE")
+		*flags.WebhookTLSCAFile = r
+	}
+	if os.Getenv(prefix+"BREAKER_THRESHOLD") != "" {
+		r := os.Getenv(prefix + "BREAKER_THRESHOLD")
+		f, err := strconv.ParseFloat(r, 64)
+		if err != nil {
+			return err
+		}
+		*flags.BreakerThreshold = f
+	}
+	if os.Getenv(prefix+"BREAKER_WINDOW") != "" {
+		r := os.Getenv(prefix + "BREAKER_WINDOW")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.BreakerWindow = i
+	}
+	if os.Getenv(prefix+"BREAKER_MIN_JOBS") != "" {
+		r := os.Getenv(prefix + "BREAKER_MIN_JOBS")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.BreakerMinJobs = i
+	}
+	if os.Getenv(prefix+"BREAKER_COOLDOWN") != "" {
+		r := os.Getenv(prefix + "BREAKER_COOLDOWN")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.BreakerCooldown = i
+	}
+	if os.Getenv(prefix+"BREAKER_UNHEALTHY_EXIT_CODES") != "" {
+		r := os.Getenv(prefix + "BREAKER_UNHEALTHY_EXIT_CODES")
+		*flags.BreakerUnhealthyExitCodes = r
+	}
+	if os.Getenv(prefix+"MAX_DELIVERIES") != "" {
+		r := os.Getenv(prefix + "MAX_DELIVERIES")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.MaxDeliveries = i
+	}
+	if os.Getenv(prefix+"DELIVERY_TTL") != "" {
+		r := os.Getenv(prefix + "DELIVERY_TTL")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.DeliveryTTL = i
+	}
+	if os.Getenv(prefix+"POISON_OP") != "" {
+		r := os.Getenv(prefix + "POISON_OP")
+		*flags.PoisonOp = r
+	}
+	if os.Getenv(prefix+"POISON_DRIVER") != "" {
+		r := os.Getenv(prefix + "POISON_DRIVER")
+		*flags.PoisonDriver = r
+	}
+	if os.Getenv(prefix+"RESULT_DRIVER") != "" {
+		r := os.Getenv(prefix + "RESULT_DRIVER")
+		*flags.ResultDriver = r
+	}
+	if os.Getenv(prefix+"RESULT_FAIL_DRIVER") != "" {
+		r := os.Getenv(prefix + "RESULT_FAIL_DRIVER")
+		*flags.ResultFailDriver = r
+	}
+	if os.Getenv(prefix+"RESULT_CORRELATION_PATH") != "" {
+		r := os.Getenv(prefix + "RESULT_CORRELATION_PATH")
+		*flags.ResultCorrelationPath = r
+	}
+	if os.Getenv(prefix+"RESULT_ENVELOPE") != "" {
+		r := os.Getenv(prefix + "RESULT_ENVELOPE")
+		*flags.ResultEnvelope = r == "true"
+	}
+	if os.Getenv(prefix+"KEDA_ADDR") != "" {
+		r := os.Getenv(prefix + "KEDA_ADDR")
+		*flags.KedaAddr = r
+	}
+	if os.Getenv(prefix+"KEDA_METRIC_NAME") != "" {
+		r := os.Getenv(prefix + "KEDA_METRIC_NAME")
+		*flags.KedaMetricName = r
+	}
+	if os.Getenv(prefix+"KEDA_TARGET_SIZE") != "" {
+		r := os.Getenv(prefix + "KEDA_TARGET_SIZE")
+		i, err := strconv.ParseInt(r, 10, 64)
+		if err != nil {
+			return err
+		}
+		*flags.KedaTargetSize = i
+	}
+	if os.Getenv(prefix+"KEDA_ACTIVATION_THRESHOLD") != "" {
+		r := os.Getenv(prefix + "KEDA_ACTIVATION_THRESHOLD")
+		i, err := strconv.ParseInt(r, 10, 64)
+		if err != nil {
+			return err
+		}
+		*flags.KedaActivationThreshold = i
+	}
+	if os.Getenv(prefix+"KEDA_POLL_INTERVAL") != "" {
+		r := os.Getenv(prefix + "KEDA_POLL_INTERVAL")
+		i, err := strconv.Atoi(r)
+		if err != nil {
+			return err
+		}
+		*flags.KedaPollInterval = i
 	}
 	return nil
 }
 
-func run(j *procx.ProcX) {
+var (
+	// hostEnv is the set of environment variables procx was started with,
+	// which take precedence over the config file
+	hostEnv = make(map[string]bool)
+	// configEnv is the set of environment variables set from the config file
+	configEnv = make(map[string]bool)
+)
+
+// loadConfigFile sets the PROCX_ environment variables in the -config file
+// which are not set in the host environment. Variables set by a previous load
+// which have been removed from the file are unset.
+func loadConfigFile(prefix string) error {
+	l := log.WithFields(log.Fields{
+		"app": AppName,
+		"fn":  "loadConfigFile",
+	})
+	if len(hostEnv) == 0 {
+		for _, kv := range os.Environ() {
+			hostEnv[strings.SplitN(kv, "=", 2)[0]] = true
+		}
+	}
+	path := *flags.Config
+	if hostEnv[prefix+"CONFIG"] {
+		path = os.Getenv(prefix + "CONFIG")
+	}
+	var env []string
+	if path != "" {
+		l.Debugf("loading config file %s", path)
+		var err error
+		if env, err = utils.ReadEnvFile(path); err != nil {
+			return err
+		}
+	}
+	loaded := make(map[string]bool)
+	for _, kv := range env {
+		p := strings.SplitN(kv, "=", 2)
+		if len(p) != 2 || hostEnv[p[0]] {
+			continue
+		}
+		os.Setenv(p[0], p[1])
+		loaded[p[0]] = true
+	}
+	for k := range configEnv {
+		if !loaded[k] {
+			os.Unsetenv(k)
+		}
+	}
+	configEnv = loaded
+	return nil
+}
+
+// reloadConfig parses the flags, config file and environment again.
+func reloadConfig(args []string) error {
+	flags.Reset()
+	if err := flags.FlagSet.Parse(args); err != nil {
+		return err
+	}
+	if err := loadConfigFile(EnvKeyPrefix); err != nil {
+		return err
+	}
+	return LoadEnv(EnvKeyPrefix)
+}
+
+// newWorker creates a new ProcX from the parsed flags. Each worker has its own
+// driver instance, as drivers hold the state of the work in progress.
+func newWorker(rl *ratelimit.Limiter, kl *ratelimit.KeyLimiter) (*procx.ProcX, error) {
+	j := &procx.ProcX{
+		DriverName:         drivers.DriverName(*flags.Driver),
+		HostEnv:            *flags.HostEnv,
+		PassWorkAsArg:      *flags.PassWorkAsArg,
+		PassWorkAsStdin:    *flags.PassWorkAsStdin,
+		Shell:              *flags.Shell,
+		ArgsAllowMissing:   *flags.ArgsAllowMissing,
+		PayloadFile:        *flags.PayloadFile,
+		KeepPayloadFile:    *flags.KeepPayloadFile,
+		Workdir:            *flags.Workdir,
+		WorkdirBase:        *flags.WorkdirBase,
+		KeepFailedWorkdir:  *flags.KeepFailedWorkdir,
+		Limits:             processLimits(),
+		Timeout:            time.Duration(*flags.Timeout) * time.Millisecond,
+		RecordDir:          *flags.RecordDir,
+		RateLimiter:        rl,
+		KeyLimiter:         kl,
+		KeyConcurrencyPath: *flags.KeyConcurrencyPath,
+		KeyConcurrencyOp:   procx.KeyConcurrencyOp(*flags.KeyConcurrencyOp),
+
+		ResultDriver:          drivers.DriverName(*flags.ResultDriver),
+		ResultFailDriver:      drivers.DriverName(*flags.ResultFailDriver),
+		ResultCorrelationPath: *flags.ResultCorrelationPath,
+		ResultEnvelope:        *flags.ResultEnvelope,
+
+		MaxDeliveries: *flags.MaxDeliveries,
+		PoisonOp:      procx.PoisonOp(*flags.PoisonOp),
+	}
+	if *flags.Schedule != "" {
+		// a scheduled run ends once the queue is empty, which drivers that
+		// block until work is received only report by timing out
+		j.PollTimeout = time.Duration(*flags.SchedulePollTimeout) * time.Millisecond
+		j.EmptyEOF = true
+	}
+	j.Output = procx.Output{
+		Dir:        *flags.OutputDir,
+		Tee:        *flags.OutputTee,
+		MaxSize:    *flags.OutputMaxSize,
+		Retain:     *flags.OutputRetain,
+		Format:     procx.OutputFormat(*flags.OutputFormat),
+		StderrTail: *flags.StderrTail,
+
+		Capture:        *flags.CaptureOutput || *flags.CaptureOutputJSON,
+		CaptureMaxSize: *flags.CaptureOutputMaxSize,
+		CaptureJSON:    *flags.CaptureOutputJSON,
+	}
+	switch j.Output.Format {
+	case procx.OutputFormatRaw, procx.OutputFormatJSON:
+	default:
+		return nil, errors.New("invalid output-format")
+	}
+	switch j.PoisonOp {
+	case procx.PoisonOpFail, procx.PoisonOpClear:
+	case procx.PoisonOpPublish:
+		if *flags.PoisonDriver == "" {
+			return nil, errors.New("poison-driver is required with poison-op publish")
+		}
+		// the poison driver is only initialized when it is used
+		if j.MaxDeliveries > 0 {
+			j.PoisonDriver = drivers.DriverName(*flags.PoisonDriver)
+		}
+	default:
+		return nil, errors.New("invalid poison-op")
+	}
+	if *flags.HostEnvAllow != "" {
+		j.HostEnvAllow = strings.Split(*flags.HostEnvAllow, ",")
+	}
+	if *flags.HostEnvRegex != "" {
+		re, err := regexp.Compile(*flags.HostEnvRegex)
+		if err != nil {
+			return nil, err
+		}
+		j.HostEnvRegex = re
+	}
+	if *flags.EnvFile != "" {
+		env, err := utils.ReadEnvFile(*flags.EnvFile)
+		if err != nil {
+			return nil, err
+		}
+		j.Env = env
+	}
+	j.Env = append(j.Env, *flags.Env...)
+	if *flags.WebhookURL != "" {
+		wh, err := newWebhook()
+		if err != nil {
+			return nil, err
+		}
+		j.Webhook = wh
+	}
+	return j, nil
+}
+
+// newWebhook creates the job lifecycle webhook from the parsed flags.
+func newWebhook() (*webhook.Webhook, error) {
+	evs, err := webhook.ParseEvents(*flags.WebhookEvents)
+	if err != nil {
+		return nil, err
+	}
+	wh := &webhook.Webhook{
+		URL:           *flags.WebhookURL,
+		Method:        *flags.WebhookMethod,
+		ContentType:   *flags.WebhookContentType,
+		Headers:       webhook.ParseHeaders(*flags.WebhookHeaders),
+		Body:          *flags.WebhookBody,
+		Events:        evs,
+		Timeout:       time.Duration(*flags.WebhookTimeout) * time.Millisecond,
+		Retries:       *flags.WebhookRetries,
+		RetryInterval: time.Duration(*flags.WebhookRetryInterval) * time.Millisecond,
+		EnableTLS:     flags.WebhookEnableTLS,
+		TLSInsecure:   flags.WebhookTLSInsecure,
+		TLSCA:         flags.WebhookTLSCAFile,
+		TLSCert:       flags.WebhookTLSCertFile,
+		TLSKey:        flags.WebhookTLSKeyFile,
+	}
+	if *flags.WebhookFields != "" {
+		wh.Fields = strings.Split(*flags.WebhookFields, ",")
+	}
+	if err := wh.Init(); err != nil {
+		return nil, err
+	}
+	return wh, nil
+}
+
+// processLimits returns the process identity and resource limits, or nil if
+// none are set.
+func processLimits() *procx.Limits {
+	lm := &procx.Limits{
+		Setpgid:      *flags.Setpgid,
+		Setsid:       *flags.Setsid,
+		CgroupMemory: *flags.CgroupMemory,
+		CgroupCPU:    *flags.CgroupCPU,
+	}
+	set := lm.Setpgid || lm.Setsid || lm.CgroupMemory > 0 || lm.CgroupCPU > 0
+	for _, id := range []struct {
+		v   int
+		dst **uint32
+	}{{*flags.UID, &lm.UID}, {*flags.GID, &lm.GID}} {
+		if id.v >= 0 {
+			v := uint32(id.v)
+			*id.dst = &v
+			set = true
+		}
+	}
+	for _, r := range []struct {
+		v   int64
+		dst **uint64
+	}{
+		{*flags.RlimitCPU, &lm.RlimitCPU},
+		{*flags.RlimitAS, &lm.RlimitAS},
+		{*flags.RlimitNofile, &lm.RlimitNofile},
+		{*flags.RlimitCore, &lm.RlimitCore},
+	} {
+		if r.v >= 0 {
+			v := uint64(r.v)
+			*r.dst = &v
+			set = true
+		}
+	}
+	if !set {
+		return nil
+	}
+	return lm
+}
+
+// limiters creates the rate and key concurrency limiters shared by all workers.
+func limiters() (*ratelimit.Limiter, *ratelimit.KeyLimiter, error) {
+	var rl *ratelimit.Limiter
+	var kl *ratelimit.KeyLimiter
+	if *flags.Rate != "" {
+		r, err := ratelimit.ParseRate(*flags.Rate)
+		if err != nil {
+			return nil, nil, err
+		}
+		rl = ratelimit.NewLimiter(r, *flags.RateBurst)
+	}
+	if *flags.KeyConcurrency > 0 {
+		if *flags.KeyConcurrencyPath == "" {
+			return nil, nil, errors.New("key-concurrency-path is required with key-concurrency")
+		}
+		switch procx.KeyConcurrencyOp(*flags.KeyConcurrencyOp) {
+		case procx.KeyConcurrencyOpWait, procx.KeyConcurrencyOpFail:
+		default:
+			return nil, nil, errors.New("invalid key-concurrency-op")
+		}
+		kl = ratelimit.NewKeyLimiter(*flags.KeyConcurrency)
+	}
+	return rl, kl, nil
+}
+
+// newBreaker creates the circuit breaker shared by all workers, or nil if it
+// is disabled.
+func newBreaker() (*breaker.Breaker, error) {
+	codes, err := breaker.ParseExitCodes(*flags.BreakerUnhealthyExitCodes)
+	if err != nil {
+		return nil, err
+	}
+	if *flags.BreakerThreshold == 0 && len(codes) == 0 {
+		return nil, nil
+	}
+	return breaker.New(
+		*flags.BreakerThreshold,
+		*flags.BreakerMinJobs,
+		time.Duration(*flags.BreakerWindow)*time.Millisecond,
+		time.Duration(*flags.BreakerCooldown)*time.Millisecond,
+		codes,
+	)
+}
+
+// config guards the configuration, which is reloaded on SIGHUP.
+type config struct {
+	mu  sync.RWMutex
+	gen int
+	rl  *ratelimit.Limiter
+	kl  *ratelimit.KeyLimiter
+	// drain stops the workers once their in flight work completes, and
+	// failed is set if a worker failed, so procx exits non-zero once drained
+	drain  context.CancelFunc
+	failed int32
+}
+
+// worker is a ProcX and the generation of the configuration it was last
+// reloaded with.
+type worker struct {
+	j   *procx.ProcX
+	gen int
+}
+
+// runJob applies any reloaded configuration to w, then does a single unit of
+// work. It returns the result of run, and the daemon interval to wait before
+// the next job.
+func (c *config) runJob(ctx context.Context, w *worker) (bool, time.Duration) {
+	interval := c.apply(w)
+	return run(ctx, w.j, c.fail), interval
+}
+
+// fail drains the workers after a worker fails, and exits non-zero once
+// they have drained.
+func (c *config) fail() {
+	atomic.StoreInt32(&c.failed, 1)
+	c.drain()
+}
+
+// apply reloads w if the configuration has been reloaded since it was last
+// applied, and returns the daemon interval. The configuration is only locked
+// between jobs, so a reload does not wait for work to be retrieved.
+func (c *config) apply(w *worker) time.Duration {
+	l := log.WithFields(log.Fields{
+		"app": AppName,
+		"fn":  "apply",
+	})
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if w.gen != c.gen {
+		w.gen = c.gen
+		n, err := newWorker(c.rl, c.kl)
+		if err == nil {
+			err = w.j.Reload(n, EnvKeyPrefix)
+		}
+		if err != nil {
+			l.WithError(err).Error("failed to reload worker, keeping current configuration")
+		}
+	}
+	return time.Millisecond * time.Duration(*flags.DaemonInterval)
+}
+
+// run does a single unit of work. It returns false if the driver has no
+// more work to do, or procx is shutting down. If the work fails with an
+// error which is not a job failure, fail is called and it returns false.
+func run(ctx context.Context, j *procx.ProcX, fail func()) bool {
 	l := log.WithFields(log.Fields{
 		"app": AppName,
 		"fn":  "run",
 	})
 	l.Debug("start")
-	if err := j.DoWork(); err != nil {
+	if err := j.DoWorkContext(ctx); err == io.EOF {
+		l.Debug("no more work")
+		return false
+	} else if ctx.Err() != nil {
+		l.Debug("shutting down")
+		return false
+	} else if errors.As(err, new(*procx.JobError)) {
+		// the circuit breaker stops work retrieval if too many jobs fail
 		l.Errorf("failed to do work: %s", err)
-		os.Exit(1)
+	} else if err != nil {
+		l.Errorf("failed to do work: %s", err)
+		// the other workers complete their in flight work before exiting
+		fail()
+		return false
 	}
-	if j.PayloadFile != "" && !j.KeepPayloadFile {
+	// a relative payload file in a job workdir is removed with the workdir
+	if j.PayloadFile != "" && !j.KeepPayloadFile && !(j.Workdir && !filepath.IsAbs(j.PayloadFile)) {
 		l.Debug("removing payload file")
 		if err := os.Remove(j.PayloadFile); err != nil {
 			l.WithError(err).Error("failed to remove payload file")
 		}
 	}
+	return true
+}
+
+// peek retrieves the next work item and prints it, along with the clear and
+// failure actions which would be taken, without executing the process.
+func peek(j *procx.ProcX) error {
+	l := log.WithFields(log.Fields{
+		"app": AppName,
+		"fn":  "peek",
+	})
+	l.Debug("peek")
+	r, err := j.Peek()
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		l.Info("no work")
+		return nil
+	}
+	jd, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(jd))
+	return nil
+}
+
+// depth prints the backlog of work waiting in the driver's source.
+func depth(j *procx.ProcX) error {
+	l := log.WithFields(log.Fields{
+		"app": AppName,
+		"fn":  "depth",
+	})
+	l.Debug("depth")
+	n, err := j.Depth()
+	if err != nil {
+		return err
+	}
+	fmt.Println(n)
+	return nil
+}
+
+// serveKeda serves the KEDA external scaler, reporting the backlog of the
+// driver's source, until SIGINT or SIGTERM.
+func serveKeda(j *procx.ProcX) error {
+	l := log.WithFields(log.Fields{
+		"app": AppName,
+		"fn":  "serveKeda",
+	})
+	l.Debug("serveKeda")
+	if *flags.KedaPollInterval < 1 {
+		return errors.New("keda poll interval must be positive")
+	}
+	ln, err := net.Listen("tcp", *flags.KedaAddr)
+	if err != nil {
+		return err
+	}
+	s := &keda.Scaler{
+		Depth:               j.Depth,
+		MetricName:          *flags.KedaMetricName,
+		TargetSize:          *flags.KedaTargetSize,
+		ActivationThreshold: *flags.KedaActivationThreshold,
+		PollInterval:        time.Duration(*flags.KedaPollInterval) * time.Millisecond,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return s.Serve(ctx, ln)
 }
 
 func cleanup(j *procx.ProcX) error {
@@ -105,7 +977,7 @@ func cleanup(j *procx.ProcX) error {
 		"fn":  "cleanup",
 	})
 	l.Debug("cleanup")
-	if err := j.Driver.Cleanup(); err != nil {
+	if err := j.Close(); err != nil {
 		l.Error(err)
 		return err
 	}
@@ -127,36 +999,263 @@ func main() {
 			printUsage()
 			os.Exit(0)
 		}
+		if os.Args[1] == "help" {
+			if err := printHelp(os.Args[2:]); err != nil {
+				l.Error(err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+	}
+	args := os.Args[1:]
+	var peekMode, depthMode, kedaMode bool
+	if len(args) > 0 {
+		switch args[0] {
+		case "peek":
+			peekMode = true
+			args = args[1:]
+		case "depth":
+			depthMode = true
+			args = args[1:]
+		case "keda":
+			kedaMode = true
+			args = args[1:]
+		}
+	}
+	flags.FlagSet.Parse(args)
+	if err := loadConfigFile(EnvKeyPrefix); err != nil {
+		l.Error(err)
+		os.Exit(1)
 	}
-	flags.FlagSet.Parse(os.Args[1:])
 	if err := LoadEnv(EnvKeyPrefix); err != nil {
 		l.Error(err)
 		os.Exit(1)
 	}
 	l.Debug("parsed flags")
-	j := &procx.ProcX{
-		DriverName:      drivers.DriverName(*flags.Driver),
-		HostEnv:         *flags.HostEnv,
-		PassWorkAsArg:   *flags.PassWorkAsArg,
-		PassWorkAsStdin: *flags.PassWorkAsStdin,
-		PayloadFile:     *flags.PayloadFile,
-		KeepPayloadFile: *flags.KeepPayloadFile,
-	}
-	if err := j.Init(EnvKeyPrefix); err != nil {
-		l.WithError(err).Error("InitDriver")
+	if peekMode {
+		j, err := newWorker(nil, nil)
+		if err != nil {
+			l.WithError(err).Error("newWorker")
+			os.Exit(1)
+		}
+		if err := j.Init(EnvKeyPrefix); err != nil {
+			l.WithError(err).Error("InitDriver")
+			os.Exit(1)
+		}
+		perr := peek(j)
+		if err := cleanup(j); err != nil {
+			l.WithError(err).Error("cleanup")
+		}
+		if perr != nil {
+			l.WithError(perr).Error("peek")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if depthMode || kedaMode {
+		// depth reporting never runs a job, so it does not publish results
+		// nor poison work
+		*flags.ResultDriver = ""
+		*flags.ResultFailDriver = ""
+		*flags.MaxDeliveries = 0
+		j, err := newWorker(nil, nil)
+		if err != nil {
+			l.WithError(err).Error("newWorker")
+			os.Exit(1)
+		}
+		if err := j.Init(EnvKeyPrefix); err != nil {
+			l.WithError(err).Error("InitDriver")
+			os.Exit(1)
+		}
+		var derr error
+		if depthMode {
+			derr = depth(j)
+		} else {
+			derr = serveKeda(j)
+		}
+		if err := cleanup(j); err != nil {
+			l.WithError(err).Error("cleanup")
+		}
+		if derr != nil {
+			l.WithError(derr).Error("depth")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	rl, kl, err := limiters()
+	if err != nil {
+		l.WithError(err).Error("limiters")
+		os.Exit(1)
+	}
+	// deliveries are counted across workers and reloads, for drivers whose
+	// source does not report them
+	dc := delivery.NewCounter(delivery.DefaultSize, time.Duration(*flags.DeliveryTTL)*time.Millisecond)
+	br, err := newBreaker()
+	if err != nil {
+		l.WithError(err).Error("breaker")
+		os.Exit(1)
+	}
+	sched, err := parseSchedule()
+	if err != nil {
+		l.WithError(err).Error("schedule")
 		os.Exit(1)
 	}
-	if *flags.Daemon {
-		l.Debug("running as daemon")
-		for {
-			run(j)
-			time.Sleep(time.Millisecond * time.Duration(*flags.DaemonInterval))
+	// the breaker keeps retrieving work while jobs fail, so it only applies
+	// when running as a daemon or on a schedule, and a failed job otherwise
+	// exits non-zero
+	if !*flags.Daemon && sched == nil {
+		br = nil
+	}
+	if *flags.Concurrency < 1 {
+		*flags.Concurrency = 1
+	}
+	var workers []*procx.ProcX
+	for i := 0; i < *flags.Concurrency; i++ {
+		j, err := newWorker(rl, kl)
+		if err != nil {
+			l.WithError(err).Error("newWorker")
+			os.Exit(1)
+		}
+		j.Breaker = br
+		j.Deliveries = dc
+		if err := j.Init(EnvKeyPrefix); err != nil {
+			l.WithError(err).Error("InitDriver")
+			os.Exit(1)
+		}
+		workers = append(workers, j)
+	}
+	// on SIGINT or SIGTERM, stop waiting for work and let in flight work
+	// complete before cleaning up
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	// the admin API can pause and resume work retrieval, and drain the
+	// workers in the same way as SIGTERM
+	ctx, drain := context.WithCancel(ctx)
+	defer drain()
+	adm := admin.New(workers, drain)
+	adm.Breaker = br
+	if *flags.AdminAddr != "" {
+		ln, err := admin.Listen(*flags.AdminAddr)
+		if err != nil {
+			l.WithError(err).Error("admin")
+			os.Exit(1)
+		}
+		actx, stopAdmin := context.WithCancel(context.Background())
+		served := make(chan struct{})
+		go func() {
+			adm.Serve(actx, ln)
+			close(served)
+		}()
+		defer func() {
+			stopAdmin()
+			<-served
+		}()
+	}
+	// on SIGHUP, reload the configuration. workers apply it before their
+	// next job, and in flight work completes with the old configuration
+	cfg := &config{rl: rl, kl: kl, drain: drain}
+	if *flags.Daemon || sched != nil {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+		go func() {
+			for range hup {
+				l.Info("reloading configuration")
+				cfg.mu.Lock()
+				if err := reloadConfig(args); err != nil {
+					l.WithError(err).Error("failed to reload configuration")
+				} else {
+					cfg.gen++
+				}
+				cfg.mu.Unlock()
+			}
+		}()
+	}
+	// in singleton mode, wait for the lock before consuming work, and drain
+	// if it is lost
+	var lk lock.Locker
+	lockLost := make(chan error, 1)
+	stopHold := func() {}
+	if *flags.Singleton {
+		ttl := time.Duration(*flags.SingletonTTL) * time.Millisecond
+		lk, err = lock.New(lock.Backend(*flags.SingletonLock), *flags.SingletonKey, ttl, EnvKeyPrefix)
+		if err != nil {
+			l.WithError(err).Error("singleton lock")
+			os.Exit(1)
 		}
-	} else {
-		run(j)
+		l.Info("waiting for singleton lock")
+		if err := lk.Acquire(ctx); err != nil && ctx.Err() == nil {
+			l.WithError(err).Error("singleton lock")
+			os.Exit(1)
+		} else if err == nil {
+			l.Info("acquired singleton lock")
+			var hctx context.Context
+			hctx, stopHold = context.WithCancel(ctx)
+			go func() {
+				if err := lock.Hold(hctx, lk, ttl/3, ttl); err != nil {
+					l.WithError(err).Error("lost singleton lock, draining")
+					lockLost <- err
+					adm.Drain()
+				}
+			}()
+		}
+	}
+	var ws []*worker
+	for _, j := range workers {
+		ws = append(ws, &worker{j: j})
+	}
+	var wg sync.WaitGroup
+	if sched != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runSchedule(ctx, sched, cfg, ws)
+		}()
+	}
+	for _, w := range ws {
+		if sched != nil {
+			break
+		}
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			if *flags.Daemon {
+				l.Debug("running as daemon")
+				for {
+					ok, interval := cfg.runJob(ctx, w)
+					if !ok {
+						return
+					}
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(interval):
+					}
+				}
+			} else {
+				cfg.runJob(ctx, w)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if lk != nil {
+		stopHold()
+		if err := lk.Release(context.Background()); err != nil {
+			l.WithError(err).Error("release singleton lock")
+		}
+	}
+	for _, j := range workers {
+		if err := cleanup(j); err != nil {
+			l.WithError(err).Error("cleanup")
+			os.Exit(1)
+		}
+	}
+	select {
+	case <-lockLost:
+		os.Exit(1)
+	default:
 	}
-	if err := cleanup(j); err != nil {
-		l.WithError(err).Error("cleanup")
+	if atomic.LoadInt32(&cfg.failed) != 0 {
 		os.Exit(1)
 	}
 	l.Debug("exited")
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robertlestak/procx/pkg/flags"
+	"github.com/robertlestak/procx/pkg/schedule"
+	log "github.com/sirupsen/logrus"
+)
+
+type ScheduleOverlap string
+
+var (
+	ScheduleOverlapSkip  ScheduleOverlap = "skip"
+	ScheduleOverlapQueue ScheduleOverlap = "queue"
+)
+
+// parseSchedule parses the -schedule flags, returning nil if no schedule is
+// set.
+func parseSchedule() (*schedule.Schedule, error) {
+	if *flags.Schedule == "" {
+		return nil, nil
+	}
+	switch ScheduleOverlap(*flags.ScheduleOverlap) {
+	case ScheduleOverlapSkip, ScheduleOverlapQueue:
+	default:
+		return nil, errors.New("invalid schedule-overlap")
+	}
+	var loc *time.Location
+	if *flags.ScheduleTZ != "" {
+		var err error
+		if loc, err = time.LoadLocation(*flags.ScheduleTZ); err != nil {
+			return nil, err
+		}
+	}
+	return schedule.Parse(*flags.Schedule, loc)
+}
+
+// scheduleSettings returns the -schedule-overlap and -schedule-limit flags,
+// which are rewritten on SIGHUP.
+func (c *config) scheduleSettings() (ScheduleOverlap, int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return ScheduleOverlap(*flags.ScheduleOverlap), *flags.ScheduleLimit
+}
+
+// runSchedule drains the queue with the workers on each tick of sched until
+// ctx is done. If a run is due while the previous run is in progress, it is
+// either skipped, or queued to start once the previous run completes. At most
+// one run is queued.
+func runSchedule(ctx context.Context, sched *schedule.Schedule, cfg *config, ws []*worker) {
+	l := log.WithFields(log.Fields{
+		"app": AppName,
+		"fn":  "runSchedule",
+	})
+	done := make(chan struct{})
+	var running, queued bool
+	start := func() {
+		running = true
+		go func() {
+			drainScheduled(ctx, cfg, ws)
+			done <- struct{}{}
+		}()
+	}
+	next := sched.Next(time.Now())
+	if next.IsZero() {
+		l.Error("schedule has no future runs")
+		return
+	}
+	l.Infof("next scheduled run at %s", next)
+	t := time.NewTimer(time.Until(next))
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if running {
+				<-done
+			}
+			return
+		case <-done:
+			running = false
+			if queued {
+				queued = false
+				l.Info("starting queued run")
+				start()
+			}
+		case <-t.C:
+			overlap, _ := cfg.scheduleSettings()
+			switch {
+			case !running:
+				start()
+			case overlap == ScheduleOverlapQueue:
+				l.Info("previous run in progress, queueing run")
+				queued = true
+			default:
+				l.Warn("previous run in progress, skipping run")
+			}
+			next = sched.Next(time.Now())
+			if next.IsZero() {
+				l.Error("schedule has no future runs")
+				if running {
+					<-done
+				}
+				return
+			}
+			l.Infof("next scheduled run at %s", next)
+			t.Reset(time.Until(next))
+		}
+	}
+}
+
+// drainScheduled processes work with the workers until the queue is empty,
+// -schedule-limit work items have been processed, or ctx is done. A worker
+// treats the queue as empty once the driver has no work for it, or it has
+// waited -schedule-poll-timeout for work.
+func drainScheduled(ctx context.Context, cfg *config, ws []*worker) {
+	l := log.WithFields(log.Fields{
+		"app": AppName,
+		"fn":  "drainScheduled",
+	})
+	l.Info("starting scheduled run")
+	_, limit := cfg.scheduleSettings()
+	var taken, received int64
+	var wg sync.WaitGroup
+	for _, w := range ws {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			before := w.j.Status().Received
+			defer func() {
+				atomic.AddInt64(&received, w.j.Status().Received-before)
+			}()
+			for ctx.Err() == nil {
+				if limit > 0 && atomic.AddInt64(&taken, 1) > int64(limit) {
+					return
+				}
+				// scheduled workers return io.EOF once the queue is empty
+				if ok, _ := cfg.runJob(ctx, w); !ok {
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	l.WithField("received", received).Info("scheduled run completed")
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"net"
-	"os"
 
 	stomp "github.com/go-stomp/stomp/v3"
 	"github.com/robertlestak/procx/pkg/flags"
@@ -28,45 +27,26 @@ type ActiveMQ struct {
 	message     *stomp.Message
 }
 
+// load loads the driver options from the flags or the environment.
+func (d *ActiveMQ) load(v *flags.Values) error {
+	v.String(&d.Address, flags.ActiveMQAddress)
+	v.StringPtr(&d.Type, flags.ActiveMQType)
+	v.StringPtr(&d.Name, flags.ActiveMQName)
+	v.BoolPtr(&d.EnableTLS, flags.ActiveMQEnableTLS)
+	v.BoolPtr(&d.TLSInsecure, flags.ActiveMQTLSInsecure)
+	v.StringPtr(&d.TLSCert, flags.ActiveMQTLSCert)
+	v.StringPtr(&d.TLSKey, flags.ActiveMQTLSKey)
+	v.StringPtr(&d.TLSCA, flags.ActiveMQTLSCA)
+	return v.Err()
+}
+
 func (d *ActiveMQ) LoadEnv(prefix string) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "activemq",
 		"fn":  "LoadEnv",
 	})
 	l.Debug("Loading environment")
-	if os.Getenv(prefix+"ACTIVEMQ_ADDRESS") != "" {
-		d.Address = os.Getenv(prefix + "ACTIVEMQ_ADDRESS")
-	}
-	if os.Getenv(prefix+"ACTIVEMQ_TYPE") != "" {
-		v := os.Getenv(prefix + "ACTIVEMQ_TYPE")
-		d.Type = &v
-	}
-	if os.Getenv(prefix+"ACTIVEMQ_NAME") != "" {
-		v := os.Getenv(prefix + "ACTIVEMQ_NAME")
-		d.Name = &v
-	}
-	if os.Getenv(prefix+"ACTIVEMQ_ENABLE_TLS") != "" {
-		v := os.Getenv(prefix+"ACTIVEMQ_ENABLE_TLS") == "true"
-		d.EnableTLS = &v
-	}
-	if os.Getenv(prefix+"ACTIVEMQ_TLS_INSECURE") != "" {
-		v := os.Getenv(prefix+"ACTIVEMQ_TLS_INSECURE") == "true"
-		d.TLSInsecure = &v
-	}
-	if os.Getenv(prefix+"ACTIVEMQ_TLS_CERT_FILE") != "" {
-		v := os.Getenv(prefix + "ACTIVEMQ_TLS_CERT_FILE")
-		d.TLSCert = &v
-	}
-	if os.Getenv(prefix+"ACTIVEMQ_TLS_KEY_FILE") != "" {
-		v := os.Getenv(prefix + "ACTIVEMQ_TLS_KEY_FILE")
-		d.TLSKey = &v
-	}
-	if os.Getenv(prefix+"ACTIVEMQ_TLS_CA_FILE") != "" {
-		v := os.Getenv(prefix + "ACTIVEMQ_TLS_CA_FILE")
-		d.TLSCA = &v
-	}
-	l.Debug("Loaded environment")
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *ActiveMQ) LoadFlags() error {
@@ -75,16 +55,7 @@ func (d *ActiveMQ) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	d.Address = *flags.ActiveMQAddress
-	d.Type = flags.ActiveMQType
-	d.Name = flags.ActiveMQName
-	d.EnableTLS = flags.ActiveMQEnableTLS
-	d.TLSInsecure = flags.ActiveMQTLSInsecure
-	d.TLSCert = flags.ActiveMQTLSCert
-	d.TLSKey = flags.ActiveMQTLSKey
-	d.TLSCA = flags.ActiveMQTLSCA
-	l.Debug("Loaded flags")
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *ActiveMQ) Init() error {
@@ -185,6 +156,11 @@ func (d *ActiveMQ) HandleFailure() error {
 	return nil
 }
 
+// Redelivers returns true, as HandleFailure nacks the message.
+func (d *ActiveMQ) Redelivers() bool {
+	return true
+}
+
 func (d *ActiveMQ) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "activemq",
@@ -0,0 +1,43 @@
+package activemq
+
+import (
+	"fmt"
+
+	stomp "github.com/go-stomp/stomp/v3"
+	"github.com/go-stomp/stomp/v3/frame"
+	log "github.com/sirupsen/logrus"
+)
+
+func (d *ActiveMQ) InitPublisher() error {
+	return d.Init()
+}
+
+// Publish sends body to the destination with meta as the message headers, and
+// waits for the broker to receive it.
+func (d *ActiveMQ) Publish(body []byte, meta map[string]string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "activemq",
+		"fn":  "Publish",
+	})
+	l.Debug("Publishing to activemq")
+	if d.Type == nil || *d.Type == "" {
+		return fmt.Errorf("ActiveMQ type is not set")
+	}
+	if d.Name == nil || *d.Name == "" {
+		return fmt.Errorf("ActiveMQ name is not set")
+	}
+	p := fmt.Sprintf("/%s/%s", *d.Type, *d.Name)
+	opts := []func(*frame.Frame) error{stomp.SendOpt.Receipt}
+	for k, v := range meta {
+		opts = append(opts, stomp.SendOpt.Header(k, v))
+	}
+	if err := d.Client.Send(p, "", body, opts...); err != nil {
+		l.Errorf("%+v", err)
+		return err
+	}
+	return nil
+}
+
+func (d *ActiveMQ) ClosePublisher() error {
+	return d.Cleanup()
+}
@@ -74,60 +74,45 @@ func (o *S3Op) GetKey() string {
 	return strings.ReplaceAll(o.KeyTemplate, "{{key}}", o.Key)
 }
 
+// load loads the driver options from the flags or the environment.
+func (d *S3) load(v *flags.Values) error {
+	v.String(&d.Region, flags.AWSRegion)
+	v.String(&d.RoleARN, flags.AWSRoleARN)
+	v.String(&d.Bucket, flags.AWSS3Bucket)
+	v.String(&d.Key, flags.AWSS3Key)
+	v.String(&d.KeyRegex, flags.AWSS3KeyRegex)
+	v.String(&d.KeyPrefix, flags.AWSS3KeyPrefix)
+	if d.ClearOp == nil {
+		d.ClearOp = &S3Op{}
+	}
+	v.Func(flags.AWSS3ClearOp, func(s string) error {
+		d.ClearOp.Operation = S3Operation(s)
+		return nil
+	})
+	v.String(&d.ClearOp.Bucket, flags.AWSS3ClearBucket)
+	v.String(&d.ClearOp.Key, flags.AWSS3ClearKey)
+	v.String(&d.ClearOp.KeyTemplate, flags.AWSS3ClearKeyTemplate)
+	if d.FailOp == nil {
+		d.FailOp = &S3Op{}
+	}
+	v.Func(flags.AWSS3FailOp, func(s string) error {
+		d.FailOp.Operation = S3Operation(s)
+		return nil
+	})
+	v.String(&d.FailOp.Bucket, flags.AWSS3FailBucket)
+	v.String(&d.FailOp.Key, flags.AWSS3FailKey)
+	v.String(&d.FailOp.KeyTemplate, flags.AWSS3FailKeyTemplate)
+	loadConfig(v)
+	return v.Err()
+}
+
 func (d *S3) LoadEnv(prefix string) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "aws",
 		"fn":  "LoadEnv",
 	})
 	l.Debug("LoadEnv")
-	if os.Getenv(prefix+"AWS_REGION") != "" {
-		d.Region = os.Getenv(prefix + "AWS_REGION")
-	}
-	if os.Getenv(prefix+"AWS_ROLE_ARN") != "" {
-		d.RoleARN = os.Getenv(prefix + "AWS_ROLE_ARN")
-	}
-	if os.Getenv(prefix+"AWS_S3_BUCKET") != "" {
-		d.Bucket = os.Getenv(prefix + "AWS_S3_BUCKET")
-	}
-	if os.Getenv(prefix+"AWS_S3_KEY") != "" {
-		d.Key = os.Getenv(prefix + "AWS_S3_KEY")
-	}
-	if os.Getenv(prefix+"AWS_S3_KEY_REGEX") != "" {
-		d.KeyRegex = os.Getenv(prefix + "AWS_S3_KEY_REGEX")
-	}
-	if os.Getenv(prefix+"AWS_S3_KEY_PREFIX") != "" {
-		d.KeyPrefix = os.Getenv(prefix + "AWS_S3_KEY_PREFIX")
-	}
-	if os.Getenv(prefix+"AWS_S3_CLEAR_OP") != "" {
-		d.ClearOp = &S3Op{}
-		d.ClearOp.Operation = S3Operation(os.Getenv(prefix + "AWS_S3_CLEAR_OP"))
-	}
-	if os.Getenv(prefix+"AWS_S3_FAIL_OP") != "" {
-		d.FailOp = &S3Op{}
-		d.FailOp.Operation = S3Operation(os.Getenv(prefix + "AWS_S3_FAIL_OP"))
-	}
-	if os.Getenv(prefix+"AWS_S3_CLEAR_BUCKET") != "" {
-		d.ClearOp.Bucket = os.Getenv(prefix + "AWS_S3_CLEAR_BUCKET")
-	}
-	if os.Getenv(prefix+"AWS_S3_CLEAR_KEY") != "" {
-		d.ClearOp.Key = os.Getenv(prefix + "AWS_S3_CLEAR_KEY")
-	}
-	if os.Getenv(prefix+"AWS_S3_CLEAR_KEY_TEMPLATE") != "" {
-		d.ClearOp.KeyTemplate = os.Getenv(prefix + "AWS_S3_CLEAR_KEY_TEMPLATE")
-	}
-	if os.Getenv(prefix+"AWS_S3_FAIL_BUCKET") != "" {
-		d.FailOp.Bucket = os.Getenv(prefix + "AWS_S3_FAIL_BUCKET")
-	}
-	if os.Getenv(prefix+"AWS_S3_FAIL_KEY") != "" {
-		d.FailOp.Key = os.Getenv(prefix + "AWS_S3_FAIL_KEY")
-	}
-	if os.Getenv(prefix+"AWS_S3_FAIL_KEY_TEMPLATE") != "" {
-		d.FailOp.KeyTemplate = os.Getenv(prefix + "AWS_S3_FAIL_KEY_TEMPLATE")
-	}
-	if os.Getenv(prefix+"AWS_LOAD_CONFIG") != "" || os.Getenv("AWS_SDK_LOAD_CONFIG") != "" {
-		os.Setenv("AWS_SDK_LOAD_CONFIG", "1")
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *S3) LoadFlags() error {
@@ -136,34 +121,7 @@ func (d *S3) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("LoadFlags")
-	d.Bucket = *flags.AWSS3Bucket
-	d.Key = *flags.AWSS3Key
-	d.Region = *flags.AWSRegion
-	d.RoleARN = *flags.AWSRoleARN
-	if flags.AWSS3KeyRegex != nil {
-		d.KeyRegex = *flags.AWSS3KeyRegex
-	}
-	if flags.AWSS3KeyPrefix != nil {
-		d.KeyPrefix = *flags.AWSS3KeyPrefix
-	}
-	if flags.AWSS3ClearOp != nil {
-		d.ClearOp = &S3Op{}
-		d.ClearOp.Operation = S3Operation(*flags.AWSS3ClearOp)
-		d.ClearOp.Bucket = *flags.AWSS3ClearBucket
-		d.ClearOp.Key = *flags.AWSS3ClearKey
-		d.ClearOp.KeyTemplate = *flags.AWSS3ClearKeyTemplate
-	}
-	if flags.AWSS3FailOp != nil {
-		d.FailOp = &S3Op{}
-		d.FailOp.Operation = S3Operation(*flags.AWSS3FailOp)
-		d.FailOp.Bucket = *flags.AWSS3FailBucket
-		d.FailOp.Key = *flags.AWSS3FailKey
-		d.FailOp.KeyTemplate = *flags.AWSS3FailKeyTemplate
-	}
-	if flags.AWSLoadConfig != nil && *flags.AWSLoadConfig {
-		os.Setenv("AWS_SDK_LOAD_CONFIG", "1")
-	}
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *S3) Init() error {
@@ -472,6 +430,98 @@ func (d *S3) HandleFailure() error {
 	}
 }
 
+func (d *S3) previewOp(o *S3Op) map[string]any {
+	if o == nil || o.Operation == "" {
+		return nil
+	}
+	m := map[string]any{
+		"op":     string(o.Operation),
+		"bucket": d.Bucket,
+		"key":    d.Key,
+	}
+	if o.Operation == S3OperationMV {
+		op := *o
+		if op.Key == "" {
+			op.Key = d.Key
+		}
+		m["destBucket"] = op.Bucket
+		m["destKey"] = op.GetKey()
+	}
+	return m
+}
+
+func (d *S3) PreviewClearWork() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "aws",
+		"fn":  "PreviewClearWork",
+	})
+	l.Debug("PreviewClearWork")
+	return d.previewOp(d.ClearOp), nil
+}
+
+func (d *S3) PreviewHandleFailure() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "aws",
+		"fn":  "PreviewHandleFailure",
+	})
+	l.Debug("PreviewHandleFailure")
+	return d.previewOp(d.FailOp), nil
+}
+
+// Depth returns the number of objects matching the key prefix or regex, or
+// 1 if a key is set and the object exists.
+func (d *S3) Depth() (int64, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "aws",
+		"fn":  "Depth",
+	})
+	l.Debug("Depth")
+	if d.Bucket == "" {
+		return 0, fmt.Errorf("bucket is required")
+	}
+	in := &s3.ListObjectsV2Input{
+		Bucket: aws.String(d.Bucket),
+	}
+	var re *regexp.Regexp
+	if d.Key != "" {
+		// the key is matched exactly, rather than as a prefix
+		in.Prefix = aws.String(d.Key)
+	} else if d.KeyPrefix != "" {
+		in.Prefix = aws.String(d.KeyPrefix)
+	} else if d.KeyRegex != "" {
+		var err error
+		if re, err = regexp.Compile(d.KeyRegex); err != nil {
+			l.Errorf("%+v", err)
+			return 0, err
+		}
+	} else {
+		return 0, errors.New("no key")
+	}
+	var n int64
+	err := d.Client.ListObjectsV2Pages(in, func(p *s3.ListObjectsV2Output, last bool) bool {
+		for _, c := range p.Contents {
+			switch {
+			case d.Key != "":
+				if *c.Key == d.Key {
+					n++
+				}
+			case re != nil:
+				if re.MatchString(*c.Key) {
+					n++
+				}
+			default:
+				n++
+			}
+		}
+		return true
+	})
+	if err != nil {
+		l.Errorf("%+v", err)
+		return 0, err
+	}
+	return n, nil
+}
+
 func (d *S3) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "aws",
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -21,6 +22,16 @@ type STSSession struct {
 	Config  *aws.Config
 }
 
+// loadConfig enables loading the AWS config from ~/.aws/config if the
+// aws-load-config option is set.
+func loadConfig(v *flags.Values) {
+	var lc bool
+	v.Bool(&lc, flags.AWSLoadConfig)
+	if lc || os.Getenv("AWS_SDK_LOAD_CONFIG") != "" {
+		os.Setenv("AWS_SDK_LOAD_CONFIG", "1")
+	}
+}
+
 type SQS struct {
 	Client        *sqs.SQS
 	sts           *STSSession
@@ -29,6 +40,18 @@ type SQS struct {
 	Region        string
 	RoleARN       string
 	IncludeID     bool
+	messageID     string
+	receiveCount  int
+}
+
+// load loads the driver options from the flags or the environment.
+func (d *SQS) load(v *flags.Values) error {
+	v.String(&d.Region, flags.AWSRegion)
+	v.String(&d.RoleARN, flags.AWSRoleARN)
+	v.String(&d.Queue, flags.SQSQueueURL)
+	v.Bool(&d.IncludeID, flags.AWSSQSIncludeID)
+	loadConfig(v)
+	return v.Err()
 }
 
 func (d *SQS) LoadEnv(prefix string) error {
@@ -37,22 +60,7 @@ func (d *SQS) LoadEnv(prefix string) error {
 		"fn":  "LoadEnv",
 	})
 	l.Debug("LoadEnv")
-	if os.Getenv(prefix+"AWS_REGION") != "" {
-		d.Region = os.Getenv(prefix + "AWS_REGION")
-	}
-	if os.Getenv(prefix+"AWS_ROLE_ARN") != "" {
-		d.RoleARN = os.Getenv(prefix + "AWS_ROLE_ARN")
-	}
-	if os.Getenv(prefix+"AWS_SQS_QUEUE_URL") != "" {
-		d.Queue = os.Getenv(prefix + "AWS_SQS_QUEUE_URL")
-	}
-	if os.Getenv(prefix+"AWS_LOAD_CONFIG") != "" || os.Getenv("AWS_SDK_LOAD_CONFIG") != "" {
-		os.Setenv("AWS_SDK_LOAD_CONFIG", "1")
-	}
-	if os.Getenv(prefix+"AWS_SQS_INCLUDE_ID") == "true" {
-		d.IncludeID = true
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *SQS) LoadFlags() error {
@@ -61,14 +69,7 @@ func (d *SQS) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("LoadFlags")
-	d.Queue = *flags.SQSQueueURL
-	d.Region = *flags.AWSRegion
-	d.RoleARN = *flags.AWSRoleARN
-	d.IncludeID = *flags.AWSSQSIncludeID
-	if flags.AWSLoadConfig != nil && *flags.AWSLoadConfig {
-		os.Setenv("AWS_SDK_LOAD_CONFIG", "1")
-	}
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *SQS) LogIdentity() error {
@@ -189,6 +190,8 @@ func (d *SQS) GetWork() (io.Reader, error) {
 		body = *md.Body
 	}
 	d.ReceiptHandle = *md.ReceiptHandle
+	d.messageID = aws.StringValue(md.MessageId)
+	d.receiveCount, _ = strconv.Atoi(aws.StringValue(md.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount]))
 	return strings.NewReader(body), nil
 }
 
@@ -221,6 +224,94 @@ func (d *SQS) HandleFailure() error {
 	return nil
 }
 
+// Redelivers returns true, as the message is delivered again once its
+// visibility timeout expires.
+func (d *SQS) Redelivers() bool {
+	return true
+}
+
+func (d *SQS) PreviewClearWork() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "aws",
+		"fn":  "PreviewClearWork",
+	})
+	l.Debug("PreviewClearWork")
+	return map[string]any{
+		"op":            "delete",
+		"queue":         d.Queue,
+		"receiptHandle": d.ReceiptHandle,
+	}, nil
+}
+
+func (d *SQS) PreviewHandleFailure() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "aws",
+		"fn":  "PreviewHandleFailure",
+	})
+	l.Debug("PreviewHandleFailure")
+	return nil, nil
+}
+
+// ReleaseWork makes the received message immediately visible to other
+// consumers by resetting its visibility timeout.
+func (d *SQS) ReleaseWork() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "aws",
+		"fn":  "ReleaseWork",
+	})
+	l.Debug("ReleaseWork")
+	if d.ReceiptHandle == "" {
+		return nil
+	}
+	ci := &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(d.Queue),
+		ReceiptHandle:     aws.String(d.ReceiptHandle),
+		VisibilityTimeout: aws.Int64(0),
+	}
+	if _, err := d.Client.ChangeMessageVisibility(ci); err != nil {
+		if err := d.LogIdentity(); err != nil {
+			l.Errorf("%+v", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// Depth returns the approximate number of messages available for retrieval
+// from the queue.
+func (d *SQS) Depth() (int64, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "aws",
+		"fn":  "Depth",
+	})
+	l.Debug("Depth")
+	attr := sqs.QueueAttributeNameApproximateNumberOfMessages
+	out, err := d.Client.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(d.Queue),
+		AttributeNames: []*string{aws.String(attr)},
+	})
+	if err != nil {
+		l.Errorf("%+v", err)
+		return 0, err
+	}
+	v, ok := out.Attributes[attr]
+	if !ok || v == nil {
+		return 0, nil
+	}
+	return strconv.ParseInt(*v, 10, 64)
+}
+
+// DeliveryCount returns the number of times the current message has been
+// received, from its ApproximateReceiveCount attribute.
+func (d *SQS) DeliveryCount() int {
+	return d.receiveCount
+}
+
+// WorkID returns the message ID of the current message.
+func (d *SQS) WorkID() string {
+	return d.messageID
+}
+
 func (d *SQS) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "aws",
@@ -0,0 +1,52 @@
+package aws
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+func (d *SQS) InitPublisher() error {
+	return d.Init()
+}
+
+// Publish sends body to the queue with meta as the message attributes. SQS
+// rejects empty attribute values, so they are not sent. Messages sent to a
+// FIFO queue are deduplicated by a new ID, in a single message group.
+func (d *SQS) Publish(body []byte, meta map[string]string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "aws",
+		"fn":  "Publish",
+	})
+	l.Debug("Publish")
+	in := &sqs.SendMessageInput{
+		QueueUrl:          aws.String(d.Queue),
+		MessageBody:       aws.String(string(body)),
+		MessageAttributes: make(map[string]*sqs.MessageAttributeValue),
+	}
+	for k, v := range meta {
+		if v == "" {
+			continue
+		}
+		in.MessageAttributes[k] = &sqs.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(v),
+		}
+	}
+	if strings.HasSuffix(d.Queue, ".fifo") {
+		in.MessageGroupId = aws.String("procx")
+		in.MessageDeduplicationId = aws.String(uuid.New().String())
+	}
+	if _, err := d.Client.SendMessage(in); err != nil {
+		l.Errorf("%+v", err)
+		return err
+	}
+	return nil
+}
+
+func (d *SQS) ClosePublisher() error {
+	return d.Cleanup()
+}
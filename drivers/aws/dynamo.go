@@ -35,6 +35,7 @@ type Dynamo struct {
 	ClearQuery       *string
 	FailQuery        *string
 	data             []map[string]any
+	result           *schema.JobResult
 }
 
 func (d *Dynamo) LogIdentity() error {
@@ -59,59 +60,36 @@ func (d *Dynamo) LogIdentity() error {
 	return nil
 }
 
+// load loads the driver options from the flags or the environment.
+func (d *Dynamo) load(v *flags.Values) error {
+	v.String(&d.Region, flags.AWSRegion)
+	v.String(&d.RoleARN, flags.AWSRoleARN)
+	v.StringPtr(&d.RetrieveQuery, flags.AWSDynamoRetrieveQuery)
+	v.StringPtr(&d.RetrieveField, flags.AWSDynamoRetrieveField)
+	v.StringPtr(&d.ClearQuery, flags.AWSDynamoClearQuery)
+	v.StringPtr(&d.FailQuery, flags.AWSDynamoFailQuery)
+	v.Bool(&d.UnmarshalJSON, flags.AWSDynamoUnmarshalJSON)
+	v.Bool(&d.IncludeNextToken, flags.AWSDynamoIncludeNextToken)
+	v.Func(flags.AWSDynamoLimit, func(s string) error {
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		d.Limit = &i
+		return nil
+	})
+	v.StringPtr(&d.NextToken, flags.AWSDynamoNextToken)
+	loadConfig(v)
+	return v.Err()
+}
+
 func (d *Dynamo) LoadEnv(prefix string) error {
 	l := log.WithFields(log.Fields{
 		"fn":  "LoadEnv",
 		"pkg": "aws",
 	})
 	l.Debug("LoadEnv")
-	if os.Getenv(prefix+"AWS_REGION") != "" {
-		d.Region = os.Getenv(prefix + "AWS_REGION")
-	}
-	if os.Getenv(prefix+"AWS_ROLE_ARN") != "" {
-		d.RoleARN = os.Getenv(prefix + "AWS_SQS_ROLE_ARN")
-	}
-	if os.Getenv(prefix+"AWS_DYNAMO_RETRIEVE_QUERY") != "" {
-		q := os.Getenv(prefix + "AWS_DYNAMO_RETRIEVE_QUERY")
-		d.RetrieveQuery = &q
-	}
-	if os.Getenv(prefix+"AWS_DYNAMO_CLEAR_QUERY") != "" {
-		q := os.Getenv(prefix + "AWS_DYNAMO_CLEAR_QUERY")
-		d.ClearQuery = &q
-	}
-	if os.Getenv(prefix+"AWS_DYNAMO_FAIL_QUERY") != "" {
-		q := os.Getenv(prefix + "AWS_DYNAMO_FAIL_QUERY")
-		d.FailQuery = &q
-	}
-	if os.Getenv(prefix+"AWS_DYNAMO_RETRIEVE_FIELD") != "" {
-		f := os.Getenv(prefix + "AWS_DYNAMO_RETRIEVE_FIELD")
-		d.RetrieveField = &f
-	}
-	if os.Getenv(prefix+"AWS_DYNAMO_INCLUDE_NEXT_TOKEN") != "" {
-		v := os.Getenv(prefix+"AWS_DYNAMO_INCLUDE_NEXT_TOKEN") == "true"
-		d.IncludeNextToken = v
-	}
-	if os.Getenv(prefix+"AWS_DYNAMO_UNMARSHAL_JSON") != "" {
-		v := os.Getenv(prefix+"AWS_DYNAMO_UNMARSHAL_JSON") == "true"
-		d.UnmarshalJSON = v
-	}
-	if os.Getenv(prefix+"AWS_DYNAMO_LIMIT") != "" {
-		v := os.Getenv(prefix + "AWS_DYNAMO_LIMIT")
-		i, err := strconv.ParseInt(v, 10, 64)
-		if err != nil {
-			l.Errorf("%+v", err)
-			return err
-		}
-		d.Limit = &i
-	}
-	if os.Getenv(prefix+"AWS_DYNAMO_NEXT_TOKEN") != "" {
-		v := os.Getenv(prefix + "AWS_DYNAMO_NEXT_TOKEN")
-		d.NextToken = &v
-	}
-	if os.Getenv(prefix+"AWS_LOAD_CONFIG") != "" || os.Getenv("AWS_SDK_LOAD_CONFIG") != "" {
-		os.Setenv("AWS_SDK_LOAD_CONFIG", "1")
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *Dynamo) LoadFlags() error {
@@ -120,21 +98,7 @@ func (d *Dynamo) LoadFlags() error {
 		"pkg": "aws",
 	})
 	l.Debug("LoadFlags")
-	d.Region = *flags.AWSRegion
-	d.RoleARN = *flags.AWSRoleARN
-	d.RetrieveQuery = flags.AWSDynamoRetrieveQuery
-	d.RetrieveField = flags.AWSDynamoRetrieveField
-	d.ClearQuery = flags.AWSDynamoClearQuery
-	d.FailQuery = flags.AWSDynamoFailQuery
-	d.UnmarshalJSON = *flags.AWSDynamoUnmarshalJSON
-	d.IncludeNextToken = *flags.AWSDynamoIncludeNextToken
-	iv := int64(*flags.AWSDynamoLimit)
-	d.Limit = &iv
-	d.NextToken = flags.AWSDynamoNextToken
-	if flags.AWSLoadConfig != nil && *flags.AWSLoadConfig {
-		os.Setenv("AWS_SDK_LOAD_CONFIG", "1")
-	}
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *Dynamo) Init() error {
@@ -311,6 +275,10 @@ func (d *Dynamo) failQuery() string {
 	return q
 }
 
+func (d *Dynamo) SetResult(r *schema.JobResult) {
+	d.result = r
+}
+
 func (d *Dynamo) ClearWork() error {
 	l := log.WithFields(log.Fields{
 		"fn":  "ClearWork",
@@ -321,7 +289,7 @@ func (d *Dynamo) ClearWork() error {
 		return nil
 	}
 	// replace {{key}} with key
-	q := d.clearQuery()
+	q := d.result.ReplaceString(d.clearQuery())
 	// execute statement
 	statement := dynamodb.ExecuteStatementInput{
 		Statement: &q,
@@ -347,7 +315,7 @@ func (d *Dynamo) HandleFailure() error {
 		return nil
 	}
 	// replace {{key}} with key
-	q := d.failQuery()
+	q := d.result.ReplaceString(d.failQuery())
 	// execute statement
 	statement := dynamodb.ExecuteStatementInput{
 		Statement: &q,
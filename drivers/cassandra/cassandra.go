@@ -3,13 +3,13 @@ package cassandra
 import (
 	"encoding/json"
 	"io"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/gocql/gocql"
 	"github.com/robertlestak/procx/pkg/flags"
 	"github.com/robertlestak/procx/pkg/schema"
+	"github.com/robertlestak/procx/pkg/secrets"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 )
@@ -26,67 +26,42 @@ type Cassandra struct {
 	ClearQuery    *schema.SqlQuery
 	FailQuery     *schema.SqlQuery
 	data          []map[string]any
+	result        *schema.JobResult
 }
 
-func (d *Cassandra) LoadEnv(prefix string) error {
-	l := log.WithFields(log.Fields{
-		"pkg": "cassandra",
-		"fn":  "LoadEnv",
-	})
-	l.Debug("loading env")
-	if os.Getenv(prefix+"CASSANDRA_HOSTS") != "" {
-		d.Hosts = strings.Split(os.Getenv(prefix+"CASSANDRA_HOSTS"), ",")
-	}
-	if os.Getenv(prefix+"CASSANDRA_KEYSPACE") != "" {
-		d.Keyspace = os.Getenv(prefix + "CASSANDRA_KEYSPACE")
-	}
-	if os.Getenv(prefix+"CASSANDRA_USER") != "" {
-		d.User = os.Getenv(prefix + "CASSANDRA_USER")
-	}
-	if os.Getenv(prefix+"CASSANDRA_PASSWORD") != "" {
-		d.Password = os.Getenv(prefix + "CASSANDRA_PASSWORD")
-	}
-	if os.Getenv(prefix+"CASSANDRA_CONSISTENCY") != "" {
-		d.Consistency = os.Getenv(prefix + "CASSANDRA_CONSISTENCY")
-	}
+// load loads the driver options from the flags or the environment.
+func (d *Cassandra) load(v *flags.Values) error {
+	v.Strings(&d.Hosts, flags.CassandraHosts)
+	v.String(&d.User, flags.CassandraUser)
+	v.String(&d.Password, flags.CassandraPassword)
+	v.String(&d.Keyspace, flags.CassandraKeyspace)
+	v.String(&d.Consistency, flags.CassandraConsistency)
+	v.StringPtr(&d.RetrieveField, flags.CassandraRetrieveField)
 	if d.RetrieveQuery == nil {
 		d.RetrieveQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"CASSANDRA_RETRIEVE_QUERY") != "" {
-		d.RetrieveQuery.Query = os.Getenv(prefix + "CASSANDRA_RETRIEVE_QUERY")
-	}
-	if os.Getenv(prefix+"CASSANDRA_RETRIEVE_PARAMS") != "" {
-		for _, s := range strings.Split(os.Getenv(prefix+"CASSANDRA_RETRIEVE_PARAMS"), ",") {
-			d.RetrieveQuery.Params = append(d.RetrieveQuery.Params, s)
-		}
-	}
+	v.String(&d.RetrieveQuery.Query, flags.CassandraRetrieveQuery)
+	v.Params(&d.RetrieveQuery.Params, flags.CassandraRetrieveParams)
 	if d.ClearQuery == nil {
 		d.ClearQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"CASSANDRA_CLEAR_QUERY") != "" {
-		d.ClearQuery.Query = os.Getenv(prefix + "CASSANDRA_CLEAR_QUERY")
-	}
-	if os.Getenv(prefix+"CASSANDRA_CLEAR_PARAMS") != "" {
-		for _, s := range strings.Split(os.Getenv(prefix+"CASSANDRA_CLEAR_PARAMS"), ",") {
-			d.ClearQuery.Params = append(d.ClearQuery.Params, s)
-		}
-	}
+	v.String(&d.ClearQuery.Query, flags.CassandraClearQuery)
+	v.Params(&d.ClearQuery.Params, flags.CassandraClearParams)
 	if d.FailQuery == nil {
 		d.FailQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"CASSANDRA_FAIL_QUERY") != "" {
-		d.FailQuery.Query = os.Getenv(prefix + "CASSANDRA_FAIL_QUERY")
-	}
-	if os.Getenv(prefix+"CASSANDRA_FAIL_PARAMS") != "" {
-		for _, s := range strings.Split(os.Getenv(prefix+"CASSANDRA_FAIL_PARAMS"), ",") {
-			d.FailQuery.Params = append(d.FailQuery.Params, s)
-		}
-	}
-	if os.Getenv(prefix+"CASSANDRA_RETRIEVE_FIELD") != "" {
-		v := os.Getenv(prefix + "CASSANDRA_RETRIEVE_FIELD")
-		d.RetrieveField = &v
-	}
-	return nil
+	v.String(&d.FailQuery.Query, flags.CassandraFailQuery)
+	v.Params(&d.FailQuery.Params, flags.CassandraFailParams)
+	return v.Err()
+}
+
+func (d *Cassandra) LoadEnv(prefix string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "cassandra",
+		"fn":  "LoadEnv",
+	})
+	l.Debug("loading env")
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *Cassandra) LoadFlags() error {
@@ -95,71 +70,7 @@ func (d *Cassandra) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("loading flags")
-	var hosts []string
-	if *flags.CassandraHosts != "" {
-		s := strings.Split(*flags.CassandraHosts, ",")
-		for _, v := range s {
-			v = strings.TrimSpace(v)
-			if v != "" {
-				hosts = append(hosts, v)
-			}
-		}
-	}
-	var rps []any
-	var cps []any
-	var fps []any
-	if *flags.CassandraRetrieveParams != "" {
-		s := strings.Split(*flags.CassandraRetrieveParams, ",")
-		for _, v := range s {
-			rps = append(rps, v)
-		}
-	}
-	if *flags.CassandraClearParams != "" {
-		s := strings.Split(*flags.CassandraClearParams, ",")
-		for _, v := range s {
-			cps = append(cps, v)
-		}
-	}
-	if *flags.CassandraFailParams != "" {
-		s := strings.Split(*flags.CassandraFailParams, ",")
-		for _, v := range s {
-			fps = append(fps, v)
-		}
-	}
-	d.Hosts = hosts
-	d.User = *flags.CassandraUser
-	d.Password = *flags.CassandraPassword
-	d.Keyspace = *flags.CassandraKeyspace
-	d.Consistency = *flags.CassandraConsistency
-	d.RetrieveField = flags.CassandraRetrieveField
-	if d.RetrieveQuery == nil {
-		d.RetrieveQuery = &schema.SqlQuery{}
-	}
-	if *flags.CassandraRetrieveQuery != "" {
-		d.RetrieveQuery.Query = *flags.CassandraRetrieveQuery
-	}
-	if len(rps) > 0 {
-		d.RetrieveQuery.Params = rps
-	}
-	if d.ClearQuery == nil {
-		d.ClearQuery = &schema.SqlQuery{}
-	}
-	if *flags.CassandraClearQuery != "" {
-		d.ClearQuery.Query = *flags.CassandraClearQuery
-	}
-	if len(cps) > 0 {
-		d.ClearQuery.Params = cps
-	}
-	if d.FailQuery == nil {
-		d.FailQuery = &schema.SqlQuery{}
-	}
-	if *flags.CassandraFailQuery != "" {
-		d.FailQuery.Query = *flags.CassandraFailQuery
-	}
-	if len(fps) > 0 {
-		d.FailQuery.Params = fps
-	}
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *Cassandra) Init() error {
@@ -179,7 +90,11 @@ func (d *Cassandra) Init() error {
 	cluster.ProtoVersion = 4
 	cluster.ConnectTimeout = time.Second * 10
 	if d.User != "" || d.Password != "" {
-		cluster.Authenticator = gocql.PasswordAuthenticator{Username: d.User, Password: d.Password}
+		pass, err := secrets.Resolve(d.Password)
+		if err != nil {
+			return err
+		}
+		cluster.Authenticator = gocql.PasswordAuthenticator{Username: d.User, Password: pass}
 	}
 	session, err := cluster.CreateSession()
 	if err != nil {
@@ -234,6 +149,10 @@ func (d *Cassandra) GetWork() (io.Reader, error) {
 	return strings.NewReader(result), nil
 }
 
+func (d *Cassandra) SetResult(r *schema.JobResult) {
+	d.result = r
+}
+
 func (d *Cassandra) ClearWork() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "cassandra",
@@ -247,8 +166,8 @@ func (d *Cassandra) ClearWork() error {
 	if d.ClearQuery.Query == "" {
 		return nil
 	}
-	d.ClearQuery.Params = schema.ReplaceParamsSliceMap(d.data, d.ClearQuery.Params)
-	err = d.Client.Query(d.ClearQuery.Query, d.ClearQuery.Params...).Exec()
+	params := d.ClearQuery.RenderParams(d.data, d.result)
+	err = d.Client.Query(d.ClearQuery.Query, params...).Exec()
 	if err != nil {
 		l.Error(err)
 		return err
@@ -270,8 +189,8 @@ func (d *Cassandra) HandleFailure() error {
 	if d.FailQuery.Query == "" {
 		return nil
 	}
-	d.FailQuery.Params = schema.ReplaceParamsSliceMap(d.data, d.FailQuery.Params)
-	err = d.Client.Query(d.FailQuery.Query, d.FailQuery.Params...).Exec()
+	params := d.FailQuery.RenderParams(d.data, d.result)
+	err = d.Client.Query(d.FailQuery.Query, params...).Exec()
 	if err != nil {
 		l.Error(err)
 		return err
@@ -280,6 +199,24 @@ func (d *Cassandra) HandleFailure() error {
 	return nil
 }
 
+func (d *Cassandra) PreviewClearWork() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "cassandra",
+		"fn":  "PreviewClearWork",
+	})
+	l.Debug("Previewing clear work")
+	return schema.PreviewSqlQuery(d.data, d.ClearQuery), nil
+}
+
+func (d *Cassandra) PreviewHandleFailure() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "cassandra",
+		"fn":  "PreviewHandleFailure",
+	})
+	l.Debug("Previewing handle failure")
+	return schema.PreviewSqlQuery(d.data, d.FailQuery), nil
+}
+
 func (d *Cassandra) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "cassandra",
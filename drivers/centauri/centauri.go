@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"errors"
 	"io"
-	"os"
 	"sort"
 
 	"github.com/robertlestak/centauri/pkg/agent"
@@ -20,33 +19,33 @@ type Centauri struct {
 	Key        *string
 }
 
+// load loads the driver options from the flags or the environment. The
+// base64 key takes precedence over the key.
+func (d *Centauri) load(v *flags.Values) error {
+	v.String(&d.URL, flags.CentauriPeerURL)
+	v.StringPtr(&d.Channel, flags.CentauriChannel)
+	v.Func(flags.CentauriKey, func(s string) error {
+		d.PrivateKey = []byte(s)
+		return nil
+	})
+	v.Func(flags.CentauriKeyBase64, func(s string) error {
+		kd, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		d.PrivateKey = kd
+		return nil
+	})
+	return v.Err()
+}
+
 func (d *Centauri) LoadEnv(prefix string) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "centauri",
 		"fn":  "LoadEnv",
 	})
 	l.Debug("Loading environment")
-	if os.Getenv(prefix+"CENTAURI_PEER_URL") != "" {
-		d.URL = os.Getenv(prefix + "CENTAURI_PEER_URL")
-	}
-	if os.Getenv(prefix+"CENTAURI_CHANNEL") != "" {
-		v := os.Getenv(prefix + "CENTAURI_CHANNEL")
-		d.Channel = &v
-	}
-	if os.Getenv(prefix+"CENTAURI_KEY") != "" {
-		v := os.Getenv(prefix + "CENTAURI_KEY")
-		d.PrivateKey = []byte(v)
-	}
-	if os.Getenv(prefix+"CENTAURI_KEY_BASE64") != "" {
-		v := os.Getenv(prefix + "CENTAURI_KEY_BASE64")
-		dec, err := base64.StdEncoding.DecodeString(v)
-		if err != nil {
-			l.Errorf("error decoding base64: %v", err)
-			return err
-		}
-		d.PrivateKey = dec
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *Centauri) LoadFlags() error {
@@ -55,23 +54,7 @@ func (d *Centauri) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	d.URL = *flags.CentauriPeerURL
-	d.Channel = flags.CentauriChannel
-	if flags.CentauriKeyBase64 != nil && *flags.CentauriKeyBase64 != "" {
-		kd, err := base64.StdEncoding.DecodeString(*flags.CentauriKeyBase64)
-		if err != nil {
-			l.Errorf("error decoding base64: %v", err)
-			return err
-		}
-		d.PrivateKey = kd
-	} else if flags.CentauriKey != nil && *flags.CentauriKey != "" {
-		if flags.CentauriKey == nil || (flags.CentauriKey != nil && *flags.CentauriKey == "") {
-			return errors.New("key required")
-		}
-		kd := []byte(*flags.CentauriKey)
-		d.PrivateKey = kd
-	}
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *Centauri) Init() error {
@@ -6,13 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"strconv"
 	"strings"
 
 	_ "github.com/lib/pq"
 	"github.com/robertlestak/procx/pkg/flags"
 	"github.com/robertlestak/procx/pkg/schema"
+	"github.com/robertlestak/procx/pkg/secrets"
 	"github.com/tidwall/gjson"
 
 	log "github.com/sirupsen/logrus"
@@ -34,183 +33,81 @@ type CockroachDB struct {
 	RetrieveQuery *schema.SqlQuery
 	ClearQuery    *schema.SqlQuery
 	FailQuery     *schema.SqlQuery
+	CountQuery    *schema.SqlQuery
 	data          []map[string]any
+	result        *schema.JobResult
 }
 
-func (d *CockroachDB) LoadEnv(prefix string) error {
-	l := log.WithFields(log.Fields{
-		"pkg": "cockroach",
-		"fn":  "LoadEnv",
-	})
-	l.Debug("Loading environment variables")
-	if os.Getenv(prefix+"COCKROACH_HOST") != "" {
-		d.Host = os.Getenv(prefix + "COCKROACH_HOST")
-	}
-	if os.Getenv(prefix+"COCKROACH_PORT") != "" {
-		pval, err := strconv.Atoi(os.Getenv(prefix + "COCKROACH_PORT"))
-		if err != nil {
-			return err
-		}
-		d.Port = pval
-	}
-	if os.Getenv(prefix+"COCKROACH_USER") != "" {
-		d.User = os.Getenv(prefix + "COCKROACH_USER")
-	}
-	if os.Getenv(prefix+"COCKROACH_PASSWORD") != "" {
-		d.Pass = os.Getenv(prefix + "COCKROACH_PASSWORD")
-	}
-	if os.Getenv(prefix+"COCKROACH_DATABASE") != "" {
-		d.Db = os.Getenv(prefix + "COCKROACH_DATABASE")
-	}
-	if os.Getenv(prefix+"COCKROACH_SSL_MODE") != "" {
-		d.SslMode = os.Getenv(prefix + "COCKROACH_SSL_MODE")
-	}
-	if os.Getenv(prefix+"COCKROACH_TLS_ROOT_CERT") != "" {
-		v := os.Getenv(prefix + "COCKROACH_TLS_ROOT_CERT")
-		d.SSLRootCert = &v
-	}
-	if os.Getenv(prefix+"COCKROACH_TLS_CERT") != "" {
-		v := os.Getenv(prefix + "COCKROACH_TLS_CERT")
-		d.SSLCert = &v
-	}
-	if os.Getenv(prefix+"COCKROACH_TLS_KEY") != "" {
-		v := os.Getenv(prefix + "COCKROACH_TLS_KEY")
-		d.SSLKey = &v
-	}
+// load loads the driver options from the flags or the environment.
+func (d *CockroachDB) load(v *flags.Values) error {
+	v.String(&d.Host, flags.CockroachDBHost)
+	v.Int(&d.Port, flags.CockroachDBPort)
+	v.String(&d.User, flags.CockroachDBUser)
+	v.String(&d.Pass, flags.CockroachDBPassword)
+	v.String(&d.Db, flags.CockroachDBDatabase)
+	v.String(&d.SslMode, flags.CockroachDBSSLMode)
+	v.StringPtr(&d.SSLRootCert, flags.CockroachDBTLSRootCert)
+	v.StringPtr(&d.SSLCert, flags.CockroachDBTLSCert)
+	v.StringPtr(&d.SSLKey, flags.CockroachDBTLSKey)
+	v.StringPtr(&d.RetrieveField, flags.CockroachDBRetrieveField)
+	v.StringPtr(&d.RoutingID, flags.CockroachDBRoutingID)
 	if d.RetrieveQuery == nil {
 		d.RetrieveQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"COCKROACH_RETRIEVE_QUERY") != "" {
-		d.RetrieveQuery.Query = os.Getenv(prefix + "COCKROACH_RETRIEVE_QUERY")
-	}
+	v.String(&d.RetrieveQuery.Query, flags.CockroachDBRetrieveQuery)
+	v.Params(&d.RetrieveQuery.Params, flags.CockroachDBRetrieveParams)
 	if d.ClearQuery == nil {
 		d.ClearQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"COCKROACH_CLEAR_QUERY") != "" {
-		d.ClearQuery.Query = os.Getenv(prefix + "COCKROACH_CLEAR_QUERY")
-	}
+	v.String(&d.ClearQuery.Query, flags.CockroachDBClearQuery)
+	v.Params(&d.ClearQuery.Params, flags.CockroachDBClearParams)
 	if d.FailQuery == nil {
 		d.FailQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"COCKROACH_FAIL_QUERY") != "" {
-		d.FailQuery.Query = os.Getenv(prefix + "COCKROACH_FAIL_QUERY")
-	}
-	if os.Getenv(prefix+"COCKROACH_RETRIEVE_PARAMS") != "" {
-		p := strings.Split(os.Getenv(prefix+"COCKROACH_RETRIEVE_PARAMS"), ",")
-		for _, v := range p {
-			d.RetrieveQuery.Params = append(d.RetrieveQuery.Params, v)
-		}
-	}
-	if os.Getenv(prefix+"COCKROACH_CLEAR_PARAMS") != "" {
-		p := strings.Split(os.Getenv(prefix+"COCKROACH_CLEAR_PARAMS"), ",")
-		for _, v := range p {
-			d.ClearQuery.Params = append(d.ClearQuery.Params, v)
-		}
-	}
-	if os.Getenv(prefix+"COCKROACH_FAIL_PARAMS") != "" {
-		p := strings.Split(os.Getenv(prefix+"COCKROACH_FAIL_PARAMS"), ",")
-		for _, v := range p {
-			d.FailQuery.Params = append(d.FailQuery.Params, v)
-		}
-	}
-	if os.Getenv(prefix+"COCKROACH_RETRIEVE_FIELD") != "" {
-		v := os.Getenv(prefix + "COCKROACH_RETRIEVE_FIELD")
-		d.RetrieveField = &v
-	}
-	if os.Getenv(prefix+"COCKROACH_ROUTING_ID") != "" {
-		v := os.Getenv(prefix + "COCKROACH_ROUTING_ID")
-		d.RoutingID = &v
+	v.String(&d.FailQuery.Query, flags.CockroachDBFailQuery)
+	v.Params(&d.FailQuery.Params, flags.CockroachDBFailParams)
+	if d.CountQuery == nil {
+		d.CountQuery = &schema.SqlQuery{}
 	}
-	return nil
+	v.String(&d.CountQuery.Query, flags.CockroachDBCountQuery)
+	v.Params(&d.CountQuery.Params, flags.CockroachDBCountParams)
+	return v.Err()
 }
 
-func (d *CockroachDB) LoadFlags() error {
+func (d *CockroachDB) LoadEnv(prefix string) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "cockroach",
-		"fn":  "LoadFlags",
+		"fn":  "LoadEnv",
 	})
-	l.Debug("Loading flags")
-	pv, err := strconv.Atoi(*flags.CockroachDBPort)
-	if err != nil {
-		return err
-	}
-	var rps []any
-	var cps []any
-	var fps []any
-	if *flags.CockroachDBRetrieveParams != "" {
-		s := strings.Split(*flags.CockroachDBRetrieveParams, ",")
-		for _, v := range s {
-			rps = append(rps, v)
-		}
-	}
-	if *flags.CockroachDBClearParams != "" {
-		s := strings.Split(*flags.CockroachDBClearParams, ",")
-		for _, v := range s {
-			cps = append(cps, v)
-		}
-	}
-	if *flags.CockroachDBFailParams != "" {
-		s := strings.Split(*flags.CockroachDBFailParams, ",")
-		for _, v := range s {
-			fps = append(fps, v)
-		}
-	}
-	d.Host = *flags.CockroachDBHost
-	d.Port = pv
-	d.User = *flags.CockroachDBUser
-	d.Pass = *flags.CockroachDBPassword
-	d.Db = *flags.CockroachDBDatabase
-	d.SslMode = *flags.CockroachDBSSLMode
-	d.SSLRootCert = flags.CockroachDBTLSRootCert
-	d.SSLCert = flags.CockroachDBTLSCert
-	d.SSLKey = flags.CockroachDBTLSKey
-	d.RetrieveField = flags.CockroachDBRetrieveField
-	if d.RetrieveQuery == nil {
-		d.RetrieveQuery = &schema.SqlQuery{}
-	}
-	if *flags.CockroachDBRetrieveQuery != "" {
-		d.RetrieveQuery.Query = *flags.CockroachDBRetrieveQuery
-	}
-	if len(rps) > 0 {
-		d.RetrieveQuery.Params = rps
-	}
-	if d.ClearQuery == nil {
-		d.ClearQuery = &schema.SqlQuery{}
-	}
-	if *flags.CockroachDBClearQuery != "" {
-		d.ClearQuery.Query = *flags.CockroachDBClearQuery
-	}
-	if len(cps) > 0 {
-		d.ClearQuery.Params = cps
-	}
-	if d.FailQuery == nil {
-		d.FailQuery = &schema.SqlQuery{}
-	}
-	if *flags.CockroachDBFailQuery != "" {
-		d.FailQuery.Query = *flags.CockroachDBFailQuery
-	}
-	if len(fps) > 0 {
-		d.FailQuery.Params = fps
-	}
-	return nil
+	l.Debug("Loading environment variables")
+	return d.load(flags.EnvValues(prefix))
 }
 
-func (d *CockroachDB) Init() error {
+func (d *CockroachDB) LoadFlags() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "cockroach",
-		"fn":  "Init",
+		"fn":  "LoadFlags",
 	})
-	l.Debug("Initializing cockroachdb client")
-	var err error
+	l.Debug("Loading flags")
+	return d.load(flags.FlagValues())
+}
+
+// connStr returns the connection string, resolving the password if it is a
+// secret reference.
+func (d *CockroachDB) connStr() (string, error) {
+	pass, err := secrets.Resolve(d.Pass)
+	if err != nil {
+		return "", err
+	}
 	var opts string
 	var connStr string = "postgresql://"
 	if d.RoutingID != nil && *d.RoutingID != "" {
 		opts = "&options=--cluster%3D" + *d.RoutingID
 	}
-	if d.User != "" && d.Pass != "" {
+	if d.User != "" && pass != "" {
 		connStr += fmt.Sprintf("%s:%s@%s:%d/%s",
-			d.User, d.Pass, d.Host, d.Port, d.Db)
-	} else if d.User != "" && d.Pass == "" {
+			d.User, pass, d.Host, d.Port, d.Db)
+	} else if d.User != "" && pass == "" {
 		connStr += fmt.Sprintf("%s@%s:%d/%s",
 			d.User, d.Host, d.Port, d.Db)
 	}
@@ -225,8 +122,17 @@ func (d *CockroachDB) Init() error {
 		connStr += "&sslkey=" + *d.SSLKey
 	}
 	connStr += opts
-	l.Debugf("Connecting to %s", connStr)
-	d.Client, err = sql.Open("postgres", connStr)
+	return connStr, nil
+}
+
+func (d *CockroachDB) Init() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "cockroach",
+		"fn":  "Init",
+	})
+	l.Debug("Initializing cockroachdb client")
+	var err error
+	d.Client, err = secrets.OpenDB("postgres", d.connStr)
 	if err != nil {
 		l.Error(err)
 		return err
@@ -293,6 +199,10 @@ func (d *CockroachDB) GetWork() (io.Reader, error) {
 	return strings.NewReader(result), nil
 }
 
+func (d *CockroachDB) SetResult(r *schema.JobResult) {
+	d.result = r
+}
+
 func (d *CockroachDB) ClearWork() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "cockroach",
@@ -303,8 +213,8 @@ func (d *CockroachDB) ClearWork() error {
 	if d.ClearQuery == nil || d.ClearQuery.Query == "" {
 		return nil
 	}
-	d.ClearQuery.Params = schema.ReplaceParamsSliceMap(d.data, d.ClearQuery.Params)
-	_, err = d.Client.Exec(d.ClearQuery.Query, d.ClearQuery.Params...)
+	params := d.ClearQuery.RenderParams(d.data, d.result)
+	_, err = d.Client.Exec(d.ClearQuery.Query, params...)
 	if err != nil {
 		l.Error(err)
 		return err
@@ -323,8 +233,8 @@ func (d *CockroachDB) HandleFailure() error {
 	if d.FailQuery == nil || d.FailQuery.Query == "" {
 		return nil
 	}
-	d.FailQuery.Params = schema.ReplaceParamsSliceMap(d.data, d.FailQuery.Params)
-	_, err = d.Client.Exec(d.FailQuery.Query, d.FailQuery.Params...)
+	params := d.FailQuery.RenderParams(d.data, d.result)
+	_, err = d.Client.Exec(d.FailQuery.Query, params...)
 	if err != nil {
 		l.Error(err)
 		return err
@@ -333,6 +243,44 @@ func (d *CockroachDB) HandleFailure() error {
 	return nil
 }
 
+func (d *CockroachDB) PreviewClearWork() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "cockroach",
+		"fn":  "PreviewClearWork",
+	})
+	l.Debug("Previewing clear work")
+	return schema.PreviewSqlQuery(d.data, d.ClearQuery), nil
+}
+
+func (d *CockroachDB) PreviewHandleFailure() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "cockroach",
+		"fn":  "PreviewHandleFailure",
+	})
+	l.Debug("Previewing handle failure")
+	return schema.PreviewSqlQuery(d.data, d.FailQuery), nil
+}
+
+// Depth returns the backlog counted by the count query, which must return a
+// single integer.
+func (d *CockroachDB) Depth() (int64, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "cockroach",
+		"fn":  "Depth",
+	})
+	l.Debug("Counting work")
+	if d.CountQuery == nil || d.CountQuery.Query == "" {
+		l.Error("CountQuery is nil or empty")
+		return 0, errors.New("CountQuery is nil or empty")
+	}
+	var n int64
+	if err := d.Client.QueryRow(d.CountQuery.Query, d.CountQuery.Params...).Scan(&n); err != nil {
+		l.Error(err)
+		return 0, err
+	}
+	return n, nil
+}
+
 func (d *CockroachDB) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "cockroach",
@@ -5,13 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
-	"os"
-	"strings"
 	"time"
 
 	"github.com/couchbase/gocb/v2"
 	"github.com/robertlestak/procx/pkg/flags"
 	"github.com/robertlestak/procx/pkg/schema"
+	"github.com/robertlestak/procx/pkg/secrets"
 	"github.com/robertlestak/procx/pkg/utils"
 	log "github.com/sirupsen/logrus"
 )
@@ -53,125 +52,66 @@ type Couchbase struct {
 	TLSKey      *string
 	TLSCA       *string
 	doc         []map[string]any
+	result      *schema.JobResult
 }
 
-func (d *Couchbase) LoadEnv(prefix string) error {
-	l := log.WithFields(log.Fields{
-		"pkg": "couchbase",
-		"fn":  "LoadEnv",
-	})
-	l.Debug("Loading environment variables")
-	if os.Getenv(prefix+"COUCHBASE_USER") != "" {
-		v := os.Getenv(prefix + "COUCHBASE_USER")
-		d.User = &v
-	}
-	if os.Getenv(prefix+"COUCHBASE_PASSWORD") != "" {
-		v := os.Getenv(prefix + "COUCHBASE_PASSWORD")
-		d.Password = &v
-	}
-	if os.Getenv(prefix+"COUCHBASE_COLLECTION") != "" {
-		c := os.Getenv(prefix + "COUCHBASE_COLLECTION")
-		d.Collection = &c
-	}
-	if os.Getenv(prefix+"COUCHBASE_SCOPE") != "" {
-		s := os.Getenv(prefix + "COUCHBASE_SCOPE")
-		d.Scope = &s
-	}
-	if os.Getenv(prefix+"COUCHBASE_BUCKET_NAME") != "" {
-		b := os.Getenv(prefix + "COUCHBASE_BUCKET_NAME")
-		d.BucketName = &b
-	}
-	if os.Getenv(prefix+"COUCHBASE_ID") != "" {
-		i := os.Getenv(prefix + "COUCHBASE_ID")
-		d.ID = &i
-	}
+// load loads the driver options from the flags or the environment.
+func (d *Couchbase) load(v *flags.Values) error {
+	v.String(&d.Address, flags.CouchbaseAddress)
+	v.StringPtr(&d.User, flags.CouchbaseUser)
+	v.StringPtr(&d.Password, flags.CouchbasePassword)
+	v.StringPtr(&d.BucketName, flags.CouchbaseBucketName)
+	v.StringPtr(&d.Scope, flags.CouchbaseScope)
+	v.StringPtr(&d.Collection, flags.CouchbaseCollection)
+	v.StringPtr(&d.ID, flags.CouchbaseID)
 	if d.RetrieveQuery == nil {
 		d.RetrieveQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"COUCHBASE_RETRIEVE_QUERY") != "" {
-		d.RetrieveQuery.Query = os.Getenv(prefix + "COUCHBASE_RETRIEVE_QUERY")
-	}
-	if os.Getenv(prefix+"COUCHBASE_RETRIEVE_PARAMS") != "" {
-		p := strings.Split(os.Getenv(prefix+"COUCHBASE_RETRIEVE_PARAMS"), ",")
-		for _, v := range p {
-			d.RetrieveQuery.Params = append(d.RetrieveQuery.Params, v)
-		}
-	}
-	if os.Getenv(prefix+"COUCHBASE_ENABLE_TLS") != "" {
-		v := os.Getenv(prefix+"COUCHBASE_ENABLE_TLS") == "true"
-		d.EnableTLS = &v
-	}
-	if os.Getenv(prefix+"COUCHBASE_TLS_INSECURE") != "" {
-		v := os.Getenv(prefix+"COUCHBASE_TLS_INSECURE") == "true"
-		d.TLSInsecure = &v
-	}
-	if os.Getenv(prefix+"COUCHBASE_TLS_CERT_FILE") != "" {
-		v := os.Getenv(prefix + "COUCHBASE_TLS_CERT_FILE")
-		d.TLSCert = &v
-	}
-	if os.Getenv(prefix+"COUCHBASE_TLS_KEY_FILE") != "" {
-		v := os.Getenv(prefix + "COUCHBASE_TLS_KEY_FILE")
-		d.TLSKey = &v
-	}
-	if os.Getenv(prefix+"COUCHBASE_TLS_CA_FILE") != "" {
-		v := os.Getenv(prefix + "COUCHBASE_TLS_CA_FILE")
-		d.TLSCA = &v
-	}
+	v.String(&d.RetrieveQuery.Query, flags.CouchbaseRetrieveQuery)
+	v.Params(&d.RetrieveQuery.Params, flags.CouchbaseRetrieveParams)
+	v.BoolPtr(&d.EnableTLS, flags.CouchbaseEnableTLS)
+	v.BoolPtr(&d.TLSInsecure, flags.CouchbaseTLSInsecure)
+	v.StringPtr(&d.TLSCert, flags.CouchbaseCertFile)
+	v.StringPtr(&d.TLSKey, flags.CouchbaseKeyFile)
+	v.StringPtr(&d.TLSCA, flags.CouchbaseCAFile)
 	if d.Clear == nil {
 		d.Clear = &CouchbaseDoc{}
 	}
-	if os.Getenv(prefix+"COUCHBASE_CLEAR_OP") != "" {
-		d.Clear.Op = CouchbaseOp(os.Getenv(prefix + "COUCHBASE_CLEAR_OP"))
-	}
-	if os.Getenv(prefix+"COUCHBASE_CLEAR_BUCKET") != "" {
-		d.Clear.Bucket = os.Getenv(prefix + "COUCHBASE_CLEAR_BUCKET")
-	}
-	if os.Getenv(prefix+"COUCHBASE_CLEAR_SCOPE") != "" {
-		d.Clear.Scope = os.Getenv(prefix + "COUCHBASE_CLEAR_SCOPE")
-	}
-	if os.Getenv(prefix+"COUCHBASE_CLEAR_COLLECTION") != "" {
-		d.Clear.Collection = os.Getenv(prefix + "COUCHBASE_CLEAR_COLLECTION")
-	}
-	if os.Getenv(prefix+"COUCHBASE_CLEAR_ID") != "" {
-		d.Clear.ID = os.Getenv(prefix + "COUCHBASE_CLEAR_ID")
-	}
-	if os.Getenv(prefix+"COUCHBASE_CLEAR_DOC") != "" {
-		var doc map[string]any
-		err := json.Unmarshal([]byte(os.Getenv(prefix+"COUCHBASE_CLEAR_DOC")), &doc)
-		if err != nil {
-			l.WithError(err).Error("Failed to unmarshal clear doc")
-			return err
-		}
-		d.Clear.Doc = doc
-	}
+	v.Func(flags.CouchbaseClearOp, func(s string) error {
+		d.Clear.Op = CouchbaseOp(s)
+		return nil
+	})
+	v.String(&d.Clear.Bucket, flags.CouchbaseClearBucket)
+	v.String(&d.Clear.Scope, flags.CouchbaseClearScope)
+	v.String(&d.Clear.Collection, flags.CouchbaseClearCollection)
+	v.String(&d.Clear.ID, flags.CouchbaseClearID)
+	v.Func(flags.CouchbaseClearDoc, func(s string) error {
+		return json.Unmarshal([]byte(s), &d.Clear.Doc)
+	})
 	if d.Fail == nil {
 		d.Fail = &CouchbaseDoc{}
 	}
-	if os.Getenv(prefix+"COUCHBASE_FAIL_OP") != "" {
-		d.Fail.Op = CouchbaseOp(os.Getenv(prefix + "COUCHBASE_FAIL_OP"))
-	}
-	if os.Getenv(prefix+"COUCHBASE_FAIL_BUCKET") != "" {
-		d.Fail.Bucket = os.Getenv(prefix + "COUCHBASE_FAIL_BUCKET")
-	}
-	if os.Getenv(prefix+"COUCHBASE_FAIL_SCOPE") != "" {
-		d.Fail.Scope = os.Getenv(prefix + "COUCHBASE_FAIL_SCOPE")
-	}
-	if os.Getenv(prefix+"COUCHBASE_FAIL_COLLECTION") != "" {
-		d.Fail.Collection = os.Getenv(prefix + "COUCHBASE_FAIL_COLLECTION")
-	}
-	if os.Getenv(prefix+"COUCHBASE_FAIL_ID") != "" {
-		d.Fail.ID = os.Getenv(prefix + "COUCHBASE_FAIL_ID")
-	}
-	if os.Getenv(prefix+"COUCHBASE_FAIL_DOC") != "" {
-		var doc map[string]any
-		err := json.Unmarshal([]byte(os.Getenv(prefix+"COUCHBASE_FAIL_DOC")), &doc)
-		if err != nil {
-			l.WithError(err).Error("Failed to unmarshal fail doc")
-			return err
-		}
-		d.Fail.Doc = doc
-	}
-	return nil
+	v.Func(flags.CouchbaseFailOp, func(s string) error {
+		d.Fail.Op = CouchbaseOp(s)
+		return nil
+	})
+	v.String(&d.Fail.Bucket, flags.CouchbaseFailBucket)
+	v.String(&d.Fail.Scope, flags.CouchbaseFailScope)
+	v.String(&d.Fail.Collection, flags.CouchbaseFailCollection)
+	v.String(&d.Fail.ID, flags.CouchbaseFailID)
+	v.Func(flags.CouchbaseFailDoc, func(s string) error {
+		return json.Unmarshal([]byte(s), &d.Fail.Doc)
+	})
+	return v.Err()
+}
+
+func (d *Couchbase) LoadEnv(prefix string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "couchbase",
+		"fn":  "LoadEnv",
+	})
+	l.Debug("Loading environment variables")
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *Couchbase) LoadFlags() error {
@@ -180,63 +120,7 @@ func (d *Couchbase) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	d.User = flags.CouchbaseUser
-	d.Password = flags.CouchbasePassword
-	d.BucketName = flags.CouchbaseBucketName
-	d.Scope = flags.CouchbaseScope
-	d.Address = *flags.CouchbaseAddress
-	d.Collection = flags.CouchbaseCollection
-	d.ID = flags.CouchbaseID
-	var rps []any
-	if *flags.CouchbaseRetrieveParams != "" {
-		s := strings.Split(*flags.CouchbaseRetrieveParams, ",")
-		for _, v := range s {
-			rps = append(rps, v)
-		}
-	}
-	if d.RetrieveQuery == nil {
-		d.RetrieveQuery = &schema.SqlQuery{}
-	}
-	if *flags.CouchbaseRetrieveQuery != "" {
-		d.RetrieveQuery.Query = *flags.CouchbaseRetrieveQuery
-	}
-	d.RetrieveQuery.Params = rps
-	d.EnableTLS = flags.CouchbaseEnableTLS
-	d.TLSInsecure = flags.CouchbaseTLSInsecure
-	d.TLSCert = flags.CouchbaseCertFile
-	d.TLSKey = flags.CouchbaseKeyFile
-	d.TLSCA = flags.CouchbaseCAFile
-	if d.Clear == nil {
-		d.Clear = &CouchbaseDoc{}
-	}
-	d.Clear.Op = CouchbaseOp(*flags.CouchbaseClearOp)
-	d.Clear.Bucket = *flags.CouchbaseClearBucket
-	d.Clear.Scope = *flags.CouchbaseClearScope
-	d.Clear.Collection = *flags.CouchbaseClearCollection
-	d.Clear.ID = *flags.CouchbaseClearID
-	if *flags.CouchbaseClearDoc != "" && len(d.Clear.Doc) == 0 {
-		cd := schema.ReplaceParamsSliceMapString(d.doc, *flags.CouchbaseClearDoc)
-		flags.CouchbaseClearDoc = &cd
-		if err := json.Unmarshal([]byte(*flags.CouchbaseClearDoc), &d.Clear.Doc); err != nil {
-			return err
-		}
-	}
-	if d.Fail == nil {
-		d.Fail = &CouchbaseDoc{}
-	}
-	d.Fail.Op = CouchbaseOp(*flags.CouchbaseFailOp)
-	d.Fail.Bucket = *flags.CouchbaseFailBucket
-	d.Fail.Scope = *flags.CouchbaseFailScope
-	d.Fail.Collection = *flags.CouchbaseFailCollection
-	d.Fail.ID = *flags.CouchbaseFailID
-	if *flags.CouchbaseFailDoc != "" && len(d.Fail.Doc) == 0 {
-		fd := schema.ReplaceParamsSliceMapString(d.doc, *flags.CouchbaseFailDoc)
-		flags.CouchbaseFailDoc = &fd
-		if err := json.Unmarshal([]byte(*flags.CouchbaseFailDoc), &d.Fail.Doc); err != nil {
-			return err
-		}
-	}
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *Couchbase) Init() error {
@@ -247,9 +131,13 @@ func (d *Couchbase) Init() error {
 	l.Debug("Initializing couchbase client")
 	opts := gocb.ClusterOptions{}
 	if d.User != nil && *d.User != "" && d.Password != nil && *d.Password != "" {
+		pass, err := secrets.Resolve(*d.Password)
+		if err != nil {
+			return err
+		}
 		opts.Authenticator = gocb.PasswordAuthenticator{
 			Username: *d.User,
-			Password: *d.Password,
+			Password: pass,
 		}
 	}
 	if d.EnableTLS != nil && *d.EnableTLS {
@@ -445,6 +333,10 @@ func (d *Couchbase) handleOp(doc *CouchbaseDoc) error {
 	}
 }
 
+func (d *Couchbase) SetResult(r *schema.JobResult) {
+	d.result = r
+}
+
 func (d *Couchbase) ClearWork() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "couchbase",
@@ -471,16 +363,19 @@ func (d *Couchbase) ClearWork() error {
 	}
 	var errs []error
 	for _, doc := range d.doc {
-		if len(d.Clear.Doc) > 0 && (d.Clear.Op == CouchbaseOpMerge || d.Clear.Op == CouchbaseOpMV) {
-			for k, v := range d.Clear.Doc {
+		// the configured doc is kept as the template for the next work
+		op := *d.Clear
+		op.Doc = d.result.ReplaceMap(d.Clear.Doc)
+		if len(op.Doc) > 0 && (op.Op == CouchbaseOpMerge || op.Op == CouchbaseOpMV) {
+			for k, v := range op.Doc {
 				doc[k] = v
 			}
-			d.Clear.Doc = doc
+			op.Doc = doc
 		}
-		if len(d.Clear.Doc) == 0 {
-			d.Clear.Doc = doc
+		if len(op.Doc) == 0 {
+			op.Doc = doc
 		}
-		errs = append(errs, d.handleOp(d.Clear))
+		errs = append(errs, d.handleOp(&op))
 	}
 	for _, err := range errs {
 		if err != nil {
@@ -517,16 +412,18 @@ func (d *Couchbase) HandleFailure() error {
 	}
 	var errs []error
 	for _, doc := range d.doc {
-		if len(d.Fail.Doc) > 0 && (d.Fail.Op == CouchbaseOpMerge || d.Fail.Op == CouchbaseOpMV) {
-			for k, v := range d.Fail.Doc {
+		op := *d.Fail
+		op.Doc = d.result.ReplaceMap(d.Fail.Doc)
+		if len(op.Doc) > 0 && (op.Op == CouchbaseOpMerge || op.Op == CouchbaseOpMV) {
+			for k, v := range op.Doc {
 				doc[k] = v
 			}
-			d.Fail.Doc = doc
+			op.Doc = doc
 		}
-		if len(d.Fail.Doc) == 0 {
-			d.Fail.Doc = doc
+		if len(op.Doc) == 0 {
+			op.Doc = doc
 		}
-		errs = append(errs, d.handleOp(d.Fail))
+		errs = append(errs, d.handleOp(&op))
 	}
 	for _, err := range errs {
 		if err != nil {
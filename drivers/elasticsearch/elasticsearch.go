@@ -8,13 +8,13 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"strings"
 
 	elasticsearch8 "github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 	"github.com/robertlestak/procx/pkg/flags"
 	"github.com/robertlestak/procx/pkg/schema"
+	"github.com/robertlestak/procx/pkg/secrets"
 	"github.com/robertlestak/procx/pkg/utils"
 	log "github.com/sirupsen/logrus"
 )
@@ -49,6 +49,32 @@ type Elasticsearch struct {
 	FailOp        CloseOp
 	Key           *string
 	data          []any
+	result        *schema.JobResult
+}
+
+// load loads the driver options from the flags or the environment.
+func (d *Elasticsearch) load(v *flags.Values) error {
+	v.String(&d.Address, flags.ElasticsearchAddress)
+	v.String(&d.Username, flags.ElasticsearchUsername)
+	v.String(&d.Password, flags.ElasticsearchPassword)
+	v.BoolPtr(&d.TLSInsecure, flags.ElasticsearchTLSSkipVerify)
+	v.BoolPtr(&d.EnableTLS, flags.ElasticsearchEnableTLS)
+	v.StringPtr(&d.TLSCert, flags.ElasticsearchCertFile)
+	v.StringPtr(&d.TLSKey, flags.ElasticsearchKeyFile)
+	v.StringPtr(&d.TLSCA, flags.ElasticsearchCAFile)
+	v.String(&d.RetrieveQuery, flags.ElasticsearchRetrieveQuery)
+	v.StringPtr(&d.RetrieveIndex, flags.ElasticsearchRetrieveIndex)
+	v.String(&d.ClearDoc, flags.ElasticsearchClearDoc)
+	v.StringPtr(&d.ClearIndex, flags.ElasticsearchClearIndex)
+	if s, ok := v.Lookup(flags.ElasticsearchClearOp); ok {
+		d.ClearOp = CloseOp(s)
+	}
+	v.String(&d.FailDoc, flags.ElasticsearchFailDoc)
+	v.StringPtr(&d.FailIndex, flags.ElasticsearchFailIndex)
+	if s, ok := v.Lookup(flags.ElasticsearchFailOp); ok {
+		d.FailOp = CloseOp(s)
+	}
+	return v.Err()
 }
 
 func (d *Elasticsearch) LoadEnv(prefix string) error {
@@ -57,63 +83,7 @@ func (d *Elasticsearch) LoadEnv(prefix string) error {
 		"fn":  "LoadEnv",
 	})
 	l.Debug("Loading environment")
-	if os.Getenv(prefix+"ELASTICSEARCH_ADDRESS") != "" {
-		d.Address = os.Getenv(prefix + "ELASTICSEARCH_ADDRESS")
-	}
-	if os.Getenv(prefix+"ELASTICSEARCH_USERNAME") != "" {
-		d.Username = os.Getenv(prefix + "ELASTICSEARCH_USERNAME")
-	}
-	if os.Getenv(prefix+"ELASTICSEARCH_PASSWORD") != "" {
-		d.Password = os.Getenv(prefix + "ELASTICSEARCH_PASSWORD")
-	}
-	if os.Getenv(prefix+"ELASTICSEARCH_TLS_SKIP_VERIFY") != "" {
-		v := os.Getenv(prefix+"ELASTICSEARCH_TLS_SKIP_VERIFY") == "true"
-		d.TLSInsecure = &v
-	}
-	if os.Getenv(prefix+"ELASTICSEARCH_RETRIEVE_QUERY") != "" {
-		d.RetrieveQuery = os.Getenv(prefix + "ELASTICSEARCH_RETRIEVE_QUERY")
-	}
-	if os.Getenv(prefix+"ELASTICSEARCH_RETRIEVE_INDEX") != "" {
-		v := os.Getenv(prefix + "ELASTICSEARCH_RETRIEVE_INDEX")
-		d.RetrieveIndex = &v
-	}
-	if os.Getenv(prefix+"ELASTICSEARCH_CLEAR_DOC") != "" {
-		d.ClearDoc = os.Getenv(prefix + "ELASTICSEARCH_CLEAR_DOC")
-	}
-	if os.Getenv(prefix+"ELASTICSEARCH_CLEAR_INDEX") != "" {
-		v := os.Getenv(prefix + "ELASTICSEARCH_CLEAR_INDEX")
-		d.ClearIndex = &v
-	}
-	if os.Getenv(prefix+"ELASTICSEARCH_CLEAR_OP") != "" {
-		d.ClearOp = CloseOp(os.Getenv(prefix + "ELASTICSEARCH_CLEAR_OP"))
-	}
-	if os.Getenv(prefix+"ELASTICSEARCH_FAIL_DOC") != "" {
-		d.FailDoc = os.Getenv(prefix + "ELASTICSEARCH_FAIL_DOC")
-	}
-	if os.Getenv(prefix+"ELASTICSEARCH_FAIL_INDEX") != "" {
-		v := os.Getenv(prefix + "ELASTICSEARCH_FAIL_INDEX")
-		d.FailIndex = &v
-	}
-	if os.Getenv(prefix+"ELASTICSEARCH_FAIL_OP") != "" {
-		d.FailOp = CloseOp(os.Getenv(prefix + "ELASTICSEARCH_FAIL_OP"))
-	}
-	if os.Getenv(prefix+"ELASTICSEARCH_ENABLE_TLS") != "" {
-		v := os.Getenv(prefix+"ELASTICSEARCH_ENABLE_TLS") == "true"
-		d.EnableTLS = &v
-	}
-	if os.Getenv(prefix+"ELASTICSEARCH_TLS_CA_FILE") != "" {
-		v := os.Getenv(prefix + "ELASTICSEARCH_TLS_CA_FILE")
-		d.TLSCA = &v
-	}
-	if os.Getenv(prefix+"ELASTICSEARCH_TLS_CERT_FILE") != "" {
-		v := os.Getenv(prefix + "ELASTICSEARCH_TLS_CERT_FILE")
-		d.TLSCert = &v
-	}
-	if os.Getenv(prefix+"ELASTICSEARCH_TLS_KEY_FILE") != "" {
-		v := os.Getenv(prefix + "ELASTICSEARCH_TLS_KEY_FILE")
-		d.TLSKey = &v
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *Elasticsearch) LoadFlags() error {
@@ -122,23 +92,7 @@ func (d *Elasticsearch) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	d.Address = *flags.ElasticsearchAddress
-	d.Username = *flags.ElasticsearchUsername
-	d.Password = *flags.ElasticsearchPassword
-	d.TLSInsecure = flags.ElasticsearchTLSSkipVerify
-	d.EnableTLS = flags.ElasticsearchEnableTLS
-	d.TLSCert = flags.ElasticsearchCertFile
-	d.TLSKey = flags.ElasticsearchKeyFile
-	d.TLSCA = flags.ElasticsearchCAFile
-	d.RetrieveQuery = *flags.ElasticsearchRetrieveQuery
-	d.RetrieveIndex = flags.ElasticsearchRetrieveIndex
-	d.ClearDoc = *flags.ElasticsearchClearDoc
-	d.ClearIndex = flags.ElasticsearchClearIndex
-	d.ClearOp = CloseOp(*flags.ElasticsearchClearOp)
-	d.FailDoc = *flags.ElasticsearchFailDoc
-	d.FailIndex = flags.ElasticsearchFailIndex
-	d.FailOp = CloseOp(*flags.ElasticsearchFailOp)
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *Elasticsearch) Init() error {
@@ -151,13 +105,17 @@ func (d *Elasticsearch) Init() error {
 	if err != nil {
 		return err
 	}
+	pass, err := secrets.Resolve(d.Password)
+	if err != nil {
+		return err
+	}
 	client, err := elasticsearch8.NewClient(elasticsearch8.Config{
 		Transport: &http.Transport{
 			TLSClientConfig: tc,
 		},
 		Addresses: []string{d.Address},
 		Username:  d.Username,
-		Password:  d.Password,
+		Password:  pass,
 	})
 	if err != nil {
 		l.Errorf("error creating client: %v", err)
@@ -467,6 +425,10 @@ func (d *Elasticsearch) getKeys() []string {
 	return ks
 }
 
+func (d *Elasticsearch) SetResult(r *schema.JobResult) {
+	d.result = r
+}
+
 func (d *Elasticsearch) ClearWork() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "elasticsearch",
@@ -484,9 +446,9 @@ func (d *Elasticsearch) ClearWork() error {
 		if d.Key != nil && *d.Key != "" {
 			k = *d.Key
 		}
-		return put(d.Client, d.ClearIndex, k, d.ClearDoc)
+		return put(d.Client, d.ClearIndex, k, d.result.ReplaceString(d.ClearDoc))
 	case CloseOpMergePut:
-		return mergePutList(d.Client, d.RetrieveIndex, d.ClearIndex, d.ClearDoc, d.data)
+		return mergePutList(d.Client, d.RetrieveIndex, d.ClearIndex, d.result.ReplaceString(d.ClearDoc), d.data)
 	case CloseOpMove:
 		return moveList(d.Client, d.RetrieveIndex, d.ClearIndex, d.data)
 	}
@@ -511,9 +473,9 @@ func (d *Elasticsearch) HandleFailure() error {
 		if d.Key != nil && *d.Key != "" {
 			k = *d.Key
 		}
-		return put(d.Client, d.FailIndex, k, d.FailDoc)
+		return put(d.Client, d.FailIndex, k, d.result.ReplaceString(d.FailDoc))
 	case CloseOpMergePut:
-		return mergePutList(d.Client, d.RetrieveIndex, d.FailIndex, d.FailDoc, d.data)
+		return mergePutList(d.Client, d.RetrieveIndex, d.FailIndex, d.result.ReplaceString(d.FailDoc), d.data)
 	case CloseOpMove:
 		return moveList(d.Client, d.RetrieveIndex, d.ClearIndex, d.data)
 	}
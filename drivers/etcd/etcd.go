@@ -2,13 +2,12 @@ package etcd
 
 import (
 	"io"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/robertlestak/procx/pkg/flags"
 	"github.com/robertlestak/procx/pkg/schema"
+	"github.com/robertlestak/procx/pkg/secrets"
 	"github.com/robertlestak/procx/pkg/utils"
 	log "github.com/sirupsen/logrus"
 	clientv3 "go.etcd.io/etcd/client/v3"
@@ -42,6 +41,34 @@ type Etcd struct {
 	TLSKey      *string
 	TLSCA       *string
 	data        []byte
+	result      *schema.JobResult
+}
+
+// load loads the driver options from the flags or the environment.
+func (d *Etcd) load(v *flags.Values) error {
+	v.Strings(&d.Hosts, flags.EtcdHosts)
+	v.StringPtr(&d.Username, flags.EtcdUsername)
+	v.StringPtr(&d.Password, flags.EtcdPassword)
+	v.String(&d.Key, flags.EtcdKey)
+	v.BoolPtr(&d.WithPrefix, flags.EtcdWithPrefix)
+	if s, ok := v.Lookup(flags.EtcdClearOp); ok {
+		op := Operation(s)
+		d.ClearOp = &op
+	}
+	v.StringPtr(&d.ClearKey, flags.EtcdClearKey)
+	v.StringPtr(&d.ClearVal, flags.EtcdClearVal)
+	if s, ok := v.Lookup(flags.EtcdFailOp); ok {
+		op := Operation(s)
+		d.FailOp = &op
+	}
+	v.StringPtr(&d.FailKey, flags.EtcdFailKey)
+	v.StringPtr(&d.FailVal, flags.EtcdFailVal)
+	v.BoolPtr(&d.EnableTLS, flags.EtcdTLSEnable)
+	v.BoolPtr(&d.TLSInsecure, flags.EtcdTLSInsecure)
+	v.StringPtr(&d.TLSCert, flags.EtcdTLSCert)
+	v.StringPtr(&d.TLSKey, flags.EtcdTLSKey)
+	v.StringPtr(&d.TLSCA, flags.EtcdTLSCA)
+	return v.Err()
 }
 
 func (d *Etcd) LoadEnv(prefix string) error {
@@ -50,81 +77,7 @@ func (d *Etcd) LoadEnv(prefix string) error {
 		"fn":  "LoadEnv",
 	})
 	l.Debug("LoadEnv")
-	if os.Getenv(prefix+"ETCD_HOSTS") != "" {
-		d.Hosts = strings.Split(os.Getenv(prefix+"ETCD_HOSTS"), ",")
-	}
-	if os.Getenv(prefix+"ETCD_USERNAME") != "" {
-		v := os.Getenv(prefix + "ETCD_USERNAME")
-		d.Username = &v
-	}
-	if os.Getenv(prefix+"ETCD_PASSWORD") != "" {
-		v := os.Getenv(prefix + "ETCD_PASSWORD")
-		d.Password = &v
-	}
-	if os.Getenv(prefix+"ETCD_RETRIEVE_KEY") != "" {
-		d.Key = os.Getenv(prefix + "ETCD_RETRIEVE_KEY")
-	}
-	if os.Getenv(prefix+"ETCD_CLEAR_OP") != "" {
-		v := Operation(os.Getenv(prefix + "ETCD_CLEAR_OP"))
-		d.ClearOp = &v
-	}
-	if os.Getenv(prefix+"ETCD_CLEAR_KEY") != "" {
-		v := os.Getenv(prefix + "ETCD_CLEAR_KEY")
-		d.ClearKey = &v
-	}
-	if os.Getenv(prefix+"ETCD_CLEAR_VAL") != "" {
-		v := os.Getenv(prefix + "ETCD_CLEAR_VAL")
-		d.ClearVal = &v
-	}
-	if os.Getenv(prefix+"ETCD_FAIL_OP") != "" {
-		v := Operation(os.Getenv(prefix + "ETCD_FAIL_OP"))
-		d.FailOp = &v
-	}
-	if os.Getenv(prefix+"ETCD_FAIL_KEY") != "" {
-		v := os.Getenv(prefix + "ETCD_FAIL_KEY")
-		d.FailKey = &v
-	}
-	if os.Getenv(prefix+"ETCD_FAIL_VAL") != "" {
-		v := os.Getenv(prefix + "ETCD_FAIL_VAL")
-		d.FailVal = &v
-	}
-	if os.Getenv(prefix+"ETCD_WITH_PREFIX") != "" {
-		v, err := strconv.ParseBool(os.Getenv(prefix + "ETCD_WITH_PREFIX"))
-		if err != nil {
-			l.Error(err)
-			return err
-		}
-		d.WithPrefix = &v
-	}
-	if os.Getenv(prefix+"ETCD_TLS_ENABLE") != "" {
-		v, err := strconv.ParseBool(os.Getenv(prefix + "ETCD_TLS_ENABLE"))
-		if err != nil {
-			l.Error(err)
-			return err
-		}
-		d.EnableTLS = &v
-	}
-	if os.Getenv(prefix+"ETCD_TLS_INSECURE") != "" {
-		v, err := strconv.ParseBool(os.Getenv(prefix + "ETCD_TLS_INSECURE"))
-		if err != nil {
-			l.Error(err)
-			return err
-		}
-		d.TLSInsecure = &v
-	}
-	if os.Getenv(prefix+"ETCD_TLS_CERT") != "" {
-		v := os.Getenv(prefix + "ETCD_TLS_CERT")
-		d.TLSCert = &v
-	}
-	if os.Getenv(prefix+"ETCD_TLS_KEY") != "" {
-		v := os.Getenv(prefix + "ETCD_TLS_KEY")
-		d.TLSKey = &v
-	}
-	if os.Getenv(prefix+"ETCD_TLS_CA") != "" {
-		v := os.Getenv(prefix + "ETCD_TLS_CA")
-		d.TLSCA = &v
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *Etcd) LoadFlags() error {
@@ -133,25 +86,7 @@ func (d *Etcd) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("LoadFlags")
-	d.Username = flags.EtcdUsername
-	d.Password = flags.EtcdPassword
-	d.Hosts = strings.Split(*flags.EtcdHosts, ",")
-	d.Key = *flags.EtcdKey
-	d.WithPrefix = flags.EtcdWithPrefix
-	op := Operation(*flags.EtcdClearOp)
-	d.ClearOp = &op
-	d.ClearKey = flags.EtcdClearKey
-	d.ClearVal = flags.EtcdClearVal
-	fop := Operation(*flags.EtcdFailOp)
-	d.FailOp = &fop
-	d.FailKey = flags.EtcdFailKey
-	d.FailVal = flags.EtcdFailVal
-	d.EnableTLS = flags.EtcdTLSEnable
-	d.TLSInsecure = flags.EtcdTLSInsecure
-	d.TLSCert = flags.EtcdTLSCert
-	d.TLSKey = flags.EtcdTLSKey
-	d.TLSCA = flags.EtcdTLSCA
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *Etcd) Init() error {
@@ -167,8 +102,13 @@ func (d *Etcd) Init() error {
 		DialTimeout: 5 * time.Second,
 	}
 	if d.Username != nil && *d.Username != "" && d.Password != nil && *d.Password != "" {
+		pass, err := secrets.Resolve(*d.Password)
+		if err != nil {
+			l.Error(err)
+			return err
+		}
 		cfg.Username = *d.Username
-		cfg.Password = *d.Password
+		cfg.Password = pass
 	}
 	if d.EnableTLS != nil && *d.EnableTLS {
 		t, err := utils.TlsConfig(d.EnableTLS, d.TLSInsecure, d.TLSCA, d.TLSCert, d.TLSKey)
@@ -257,6 +197,10 @@ func (d *Etcd) move(destKey string) error {
 	return nil
 }
 
+func (d *Etcd) SetResult(r *schema.JobResult) {
+	d.result = r
+}
+
 func (d *Etcd) ClearWork() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "etcd",
@@ -270,9 +214,9 @@ func (d *Etcd) ClearWork() error {
 	if d.ClearKey == nil || *d.ClearKey == "" {
 		d.ClearKey = &d.Key
 	}
-	if d.ClearVal != nil && *d.ClearVal != "" {
-		v := schema.ReplaceParamsString(d.data, *d.ClearVal)
-		d.ClearVal = &v
+	var val string
+	if d.ClearVal != nil {
+		val = d.result.ReplaceString(schema.ReplaceParamsString(d.data, *d.ClearVal))
 	}
 	switch *d.ClearOp {
 	case OperationRM:
@@ -280,7 +224,7 @@ func (d *Etcd) ClearWork() error {
 	case OperationMV:
 		return d.move(*d.ClearKey)
 	case OperationPut:
-		return d.put(*d.ClearKey, *d.ClearVal)
+		return d.put(*d.ClearKey, val)
 	default:
 		return nil
 	}
@@ -299,9 +243,9 @@ func (d *Etcd) HandleFailure() error {
 	if d.FailKey == nil || *d.FailKey == "" {
 		d.FailKey = &d.Key
 	}
-	if d.FailVal != nil && *d.FailVal != "" {
-		v := schema.ReplaceParamsString(d.data, *d.FailVal)
-		d.FailVal = &v
+	var val string
+	if d.FailVal != nil {
+		val = d.result.ReplaceString(schema.ReplaceParamsString(d.data, *d.FailVal))
 	}
 	switch *d.FailOp {
 	case OperationRM:
@@ -309,12 +253,47 @@ func (d *Etcd) HandleFailure() error {
 	case OperationMV:
 		return d.move(*d.FailKey)
 	case OperationPut:
-		return d.put(*d.FailKey, *d.FailVal)
+		return d.put(*d.FailKey, val)
 	default:
 		return nil
 	}
 }
 
+func (d *Etcd) previewOp(op *Operation, key *string, val *string) map[string]any {
+	if op == nil || *op == "" {
+		return nil
+	}
+	m := map[string]any{
+		"op":  string(*op),
+		"key": d.Key,
+	}
+	if key != nil && *key != "" {
+		m["key"] = *key
+	}
+	if *op == OperationPut && val != nil {
+		m["value"] = schema.ReplaceParamsString(d.data, *val)
+	}
+	return m
+}
+
+func (d *Etcd) PreviewClearWork() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "etcd",
+		"fn":  "PreviewClearWork",
+	})
+	l.Debug("Previewing clear work")
+	return d.previewOp(d.ClearOp, d.ClearKey, d.ClearVal), nil
+}
+
+func (d *Etcd) PreviewHandleFailure() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "etcd",
+		"fn":  "PreviewHandleFailure",
+	})
+	l.Debug("Previewing handle failure")
+	return d.previewOp(d.FailOp, d.FailKey, d.FailVal), nil
+}
+
 func (d *Etcd) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "etcd",
@@ -36,6 +36,9 @@ type FS struct {
 	KeyRegex  string
 	ClearOp   *S3Op
 	FailOp    *S3Op
+	// found is set when Key was found by KeyPrefix or KeyRegex, rather than
+	// configured, so it is searched for again on the next GetWork
+	found bool
 }
 
 func (o *S3Op) GetKey() string {
@@ -45,51 +48,42 @@ func (o *S3Op) GetKey() string {
 	return strings.ReplaceAll(o.KeyTemplate, "{{key}}", o.Key)
 }
 
+// load loads the driver options from the flags or the environment.
+func (d *FS) load(v *flags.Values) error {
+	v.String(&d.Key, flags.FSKey)
+	v.String(&d.KeyPrefix, flags.FSKeyPrefix)
+	v.String(&d.KeyRegex, flags.FSKeyRegex)
+	v.String(&d.Folder, flags.FSFolder)
+	if d.ClearOp == nil {
+		d.ClearOp = &S3Op{}
+	}
+	v.Func(flags.FSClearOp, func(s string) error {
+		d.ClearOp.Operation = S3Operation(s)
+		return nil
+	})
+	v.String(&d.ClearOp.Bucket, flags.FSClearFolder)
+	v.String(&d.ClearOp.Key, flags.FSClearKey)
+	v.String(&d.ClearOp.KeyTemplate, flags.FSClearKeyTemplate)
+	if d.FailOp == nil {
+		d.FailOp = &S3Op{}
+	}
+	v.Func(flags.FSFailOp, func(s string) error {
+		d.FailOp.Operation = S3Operation(s)
+		return nil
+	})
+	v.String(&d.FailOp.Bucket, flags.FSFailFolder)
+	v.String(&d.FailOp.Key, flags.FSFailKey)
+	v.String(&d.FailOp.KeyTemplate, flags.FSFailKeyTemplate)
+	return v.Err()
+}
+
 func (d *FS) LoadEnv(prefix string) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "fs",
 		"fn":  "LoadEnv",
 	})
 	l.Debug("LoadEnv")
-	if os.Getenv(prefix+"FS_KEY") != "" {
-		d.Key = os.Getenv(prefix + "FS_KEY")
-	}
-	if os.Getenv(prefix+"FS_KEY_PREFIX") != "" {
-		d.KeyPrefix = os.Getenv(prefix + "FS_KEY_PREFIX")
-	}
-	if os.Getenv(prefix+"FS_KEY_REGEX") != "" {
-		d.KeyRegex = os.Getenv(prefix + "FS_KEY_REGEX")
-	}
-	if os.Getenv(prefix+"FS_FOLDER") != "" {
-		d.Folder = os.Getenv(prefix + "FS_FOLDER")
-	}
-	if os.Getenv(prefix+"FS_CLEAR_OP") != "" {
-		d.ClearOp = &S3Op{}
-		d.ClearOp.Operation = S3Operation(os.Getenv(prefix + "FS_CLEAR_OP"))
-	}
-	if os.Getenv(prefix+"FS_FAIL_OP") != "" {
-		d.FailOp = &S3Op{}
-		d.FailOp.Operation = S3Operation(os.Getenv(prefix + "FS_FAIL_OP"))
-	}
-	if os.Getenv(prefix+"FS_CLEAR_FOLDER") != "" {
-		d.ClearOp.Bucket = os.Getenv(prefix + "FS_CLEAR_FOLDER")
-	}
-	if os.Getenv(prefix+"FS_CLEAR_KEY") != "" {
-		d.ClearOp.Key = os.Getenv(prefix + "FS_CLEAR_KEY")
-	}
-	if os.Getenv(prefix+"FS_CLEAR_KEY_TEMPLATE") != "" {
-		d.ClearOp.KeyTemplate = os.Getenv(prefix + "FS_CLEAR_KEY_TEMPLATE")
-	}
-	if os.Getenv(prefix+"FS_FAIL_FOLDER") != "" {
-		d.FailOp.Bucket = os.Getenv(prefix + "FS_FAIL_FOLDER")
-	}
-	if os.Getenv(prefix+"FS_FAIL_KEY") != "" {
-		d.FailOp.Key = os.Getenv(prefix + "FS_FAIL_KEY")
-	}
-	if os.Getenv(prefix+"FS_FAIL_KEY_TEMPLATE") != "" {
-		d.FailOp.KeyTemplate = os.Getenv(prefix + "FS_FAIL_KEY_TEMPLATE")
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *FS) LoadFlags() error {
@@ -98,29 +92,7 @@ func (d *FS) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("LoadFlags")
-	d.Folder = *flags.FSFolder
-	d.Key = *flags.FSKey
-	if flags.FSKeyRegex != nil {
-		d.KeyRegex = *flags.FSKeyRegex
-	}
-	if flags.FSKeyPrefix != nil {
-		d.KeyPrefix = *flags.FSKeyPrefix
-	}
-	if flags.FSClearOp != nil {
-		d.ClearOp = &S3Op{}
-		d.ClearOp.Operation = S3Operation(*flags.FSClearOp)
-		d.ClearOp.Bucket = *flags.FSClearFolder
-		d.ClearOp.Key = *flags.FSClearKey
-		d.ClearOp.KeyTemplate = *flags.FSClearKeyTemplate
-	}
-	if flags.FSFailOp != nil {
-		d.FailOp = &S3Op{}
-		d.FailOp.Operation = S3Operation(*flags.FSFailOp)
-		d.FailOp.Bucket = *flags.FSFailFolder
-		d.FailOp.Key = *flags.FSFailKey
-		d.FailOp.KeyTemplate = *flags.FSFailKeyTemplate
-	}
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *FS) Init() error {
@@ -140,6 +112,10 @@ func (d *FS) GetWork() (io.Reader, error) {
 		"fn":  "GetWork",
 	})
 	l.Debug("GetWork")
+	if d.found {
+		d.Key = ""
+		d.found = false
+	}
 	if d.Key != "" {
 		l.Debugf("GetWork key=%s", d.Key)
 		return d.getObject()
@@ -215,6 +191,7 @@ func (d *FS) findObjectByPrefix() (io.Reader, error) {
 	}
 	l.Debugf("findObjectByPrefix key=%s", *key)
 	d.Key = *key
+	d.found = true
 	return d.getObject()
 }
 
@@ -270,6 +247,7 @@ func (d *FS) findObjectByRegex() (io.Reader, error) {
 	}
 	l.Debugf("findObjectByRegex key=%s", *key)
 	d.Key = *key
+	d.found = true
 	return d.getObject()
 }
 
@@ -364,6 +342,94 @@ func (d *FS) HandleFailure() error {
 	}
 }
 
+func (d *FS) previewOp(o *S3Op) map[string]any {
+	if o == nil || o.Operation == "" {
+		return nil
+	}
+	m := map[string]any{
+		"op":     string(o.Operation),
+		"folder": d.Folder,
+		"key":    d.Key,
+	}
+	if o.Operation == S3OperationMV {
+		op := *o
+		if op.Key == "" {
+			op.Key = d.Key
+		}
+		m["destFolder"] = op.Bucket
+		m["destKey"] = op.GetKey()
+	}
+	return m
+}
+
+func (d *FS) PreviewClearWork() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "fs",
+		"fn":  "PreviewClearWork",
+	})
+	l.Debug("PreviewClearWork")
+	return d.previewOp(d.ClearOp), nil
+}
+
+func (d *FS) PreviewHandleFailure() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "fs",
+		"fn":  "PreviewHandleFailure",
+	})
+	l.Debug("PreviewHandleFailure")
+	return d.previewOp(d.FailOp), nil
+}
+
+// Depth returns the number of files in the folder matching the key prefix or
+// regex, or 1 if a key is set and the file exists.
+func (d *FS) Depth() (int64, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "fs",
+		"fn":  "Depth",
+	})
+	l.Debug("Depth")
+	var match func(string) bool
+	if d.Key != "" {
+		if _, err := os.Stat(path.Join(d.Folder, d.Key)); errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		} else if err != nil {
+			return 0, err
+		}
+		return 1, nil
+	} else if d.KeyPrefix != "" {
+		match = func(fn string) bool { return strings.HasPrefix(fn, d.KeyPrefix) }
+	} else if d.KeyRegex != "" {
+		re, err := regexp.Compile(d.KeyRegex)
+		if err != nil {
+			return 0, err
+		}
+		match = re.MatchString
+	} else {
+		return 0, errors.New("no key")
+	}
+	var n int64
+	err := filepath.WalkDir(d.Folder,
+		func(path string, de fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if de.IsDir() {
+				return nil
+			}
+			fn := strings.Replace(path, d.Folder, "", 1)
+			fn = strings.Replace(fn, d.Folder+"/", "", 1)
+			if match(fn) {
+				n++
+			}
+			return nil
+		})
+	if err != nil {
+		l.Errorf("Depth error %s", err)
+		return 0, err
+	}
+	return n, nil
+}
+
 func (d *FS) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "fs",
@@ -0,0 +1,32 @@
+package fs_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/robertlestak/procx/drivers/fs"
+	"github.com/robertlestak/procx/pkg/drivers"
+	"github.com/robertlestak/procx/pkg/drivers/drivertest"
+)
+
+func TestConformance(t *testing.T) {
+	drivertest.Run(t, drivertest.Harness{
+		Setup: func(t testing.TB) (drivers.Driver, drivertest.PushFunc) {
+			dir := t.TempDir()
+			var n int
+			push := func(b []byte) error {
+				n++
+				return os.WriteFile(filepath.Join(dir, "work-"+strconv.Itoa(n)), b, 0644)
+			}
+			return &fs.FS{
+				Folder:    dir + "/",
+				KeyPrefix: "work-",
+				ClearOp:   &fs.S3Op{Operation: fs.S3OperationRM},
+			}, push
+		},
+		Wait: time.Second,
+	})
+}
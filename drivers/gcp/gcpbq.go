@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
-	"os"
 	"strings"
 
 	"cloud.google.com/go/bigquery"
@@ -25,6 +24,26 @@ type BQ struct {
 	ClearQuery    *string
 	FailQuery     *string
 	data          []map[string]bigquery.Value
+	result        *schema.JobResult
+}
+
+// load loads the driver options from the flags or the environment.
+func (d *BQ) load(v *flags.Values) error {
+	v.String(&d.ProjectID, flags.GCPProjectID)
+	v.StringPtr(&d.RetrieveField, flags.GCPBQRetrieveField)
+	v.Func(flags.GCPBQRetrieveQuery, func(s string) error {
+		d.RetrieveQuery = &s
+		return nil
+	})
+	v.Func(flags.GCPBQClearQuery, func(s string) error {
+		d.ClearQuery = &s
+		return nil
+	})
+	v.Func(flags.GCPBQFailQuery, func(s string) error {
+		d.FailQuery = &s
+		return nil
+	})
+	return v.Err()
 }
 
 func (d *BQ) LoadEnv(prefix string) error {
@@ -33,26 +52,7 @@ func (d *BQ) LoadEnv(prefix string) error {
 		"fn":  "LoadEnv",
 	})
 	l.Debug("Loading environment variables")
-	if os.Getenv(prefix+"GCP_BQ_RETRIEVE_QUERY") != "" {
-		q := os.Getenv(prefix + "GCP_BQ_RETRIEVE_QUERY")
-		d.RetrieveQuery = &q
-	}
-	if os.Getenv(prefix+"GCP_BQ_CLEAR_QUERY") != "" {
-		q := os.Getenv(prefix + "GCP_BQ_CLEAR_QUERY")
-		d.ClearQuery = &q
-	}
-	if os.Getenv(prefix+"GCP_BQ_FAIL_QUERY") != "" {
-		q := os.Getenv(prefix + "GCP_BQ_FAIL_QUERY")
-		d.FailQuery = &q
-	}
-	if os.Getenv(prefix+"GCP_BQ_RETRIEVE_FIELD") != "" {
-		f := os.Getenv(prefix + "GCP_BQ_RETRIEVE_FIELD")
-		d.RetrieveField = &f
-	}
-	if os.Getenv(prefix+"GCP_PROJECT_ID") != "" {
-		d.ProjectID = os.Getenv(prefix + "GCP_PROJECT_ID")
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *BQ) LoadFlags() error {
@@ -61,18 +61,7 @@ func (d *BQ) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	d.ProjectID = *flags.GCPProjectID
-	d.RetrieveField = flags.GCPBQRetrieveField
-	if *flags.GCPBQRetrieveQuery != "" {
-		d.RetrieveQuery = flags.GCPBQRetrieveQuery
-	}
-	if *flags.GCPBQClearQuery != "" {
-		d.ClearQuery = flags.GCPBQClearQuery
-	}
-	if *flags.GCPBQFailQuery != "" {
-		d.FailQuery = flags.GCPBQFailQuery
-	}
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *BQ) Init() error {
@@ -181,6 +170,10 @@ func (d *BQ) failQuery() string {
 	return schema.ReplaceParamsSliceMapString(td, *d.FailQuery)
 }
 
+func (d *BQ) SetResult(r *schema.JobResult) {
+	d.result = r
+}
+
 func (d *BQ) ClearWork() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "bq",
@@ -191,7 +184,7 @@ func (d *BQ) ClearWork() error {
 	if d.ClearQuery == nil || *d.ClearQuery == "" {
 		return nil
 	}
-	qry := d.Client.Query(d.clearQuery())
+	qry := d.Client.Query(d.result.ReplaceString(d.clearQuery()))
 	_, err = qry.Read(context.Background())
 	if err != nil {
 		l.Error(err)
@@ -211,7 +204,7 @@ func (d *BQ) HandleFailure() error {
 	if d.FailQuery == nil || *d.FailQuery == "" {
 		return nil
 	}
-	qry := d.Client.Query(d.failQuery())
+	qry := d.Client.Query(d.result.ReplaceString(d.failQuery()))
 	_, err = qry.Read(context.Background())
 	if err != nil {
 		l.Error(err)
@@ -6,13 +6,13 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
-	"os"
 	"strconv"
 	"strings"
 
 	"cloud.google.com/go/firestore"
 	"github.com/google/uuid"
 	"github.com/robertlestak/procx/pkg/flags"
+	"github.com/robertlestak/procx/pkg/schema"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"google.golang.org/api/iterator"
@@ -49,115 +49,80 @@ type GCPFirestore struct {
 	FailCollection          *string
 	ProjectID               string
 	doc                     []map[string]any
+	result                  *schema.JobResult
 }
 
-func (d *GCPFirestore) LoadEnv(prefix string) error {
-	l := log.WithFields(log.Fields{
-		"pkg": "gcp",
-		"fn":  "LoadEnv",
-	})
-	l.Debug("LoadEnv")
-	if os.Getenv(prefix+"GCP_PROJECT_ID") != "" {
-		d.ProjectID = os.Getenv(prefix + "GCP_PROJECT_ID")
-	}
-	if os.Getenv(prefix+"GCP_FIRESTORE_RETRIEVE_COLLECTION") != "" {
-		v := os.Getenv(prefix + "GCP_FIRESTORE_RETRIEVE_COLLECTION")
-		d.RetrieveCollection = &v
-	}
-	if os.Getenv(prefix+"GCP_FIRESTORE_RETRIEVE_DOCUMENT") != "" {
-		v := os.Getenv(prefix + "GCP_FIRESTORE_RETRIEVE_DOCUMENT")
-		d.RetrieveDocument = &v
-	}
-	if os.Getenv(prefix+"GCP_FIRESTORE_RETRIEVE_DOCUMENT_JSON_KEY") != "" {
-		v := os.Getenv(prefix + "GCP_FIRESTORE_RETRIEVE_DOCUMENT_JSON_KEY")
-		d.RetrieveDocumentJSONKey = &v
-	}
+// load loads the driver options from the flags or the environment.
+func (d *GCPFirestore) load(v *flags.Values) error {
+	v.String(&d.ProjectID, flags.GCPProjectID)
+	v.StringPtr(&d.RetrieveCollection, flags.GCPFirestoreRetrieveCollection)
+	v.StringPtr(&d.RetrieveDocument, flags.GCPFirestoreRetrieveDocument)
+	v.StringPtr(&d.RetrieveDocumentJSONKey, flags.GCPFirestoreRetrieveDocumentJSONKey)
 	if d.RetrieveQuery == nil {
-		d.RetrieveQuery = &GCPFirestoreQuery{}
+		desc := firestore.Desc
+		d.RetrieveQuery = &GCPFirestoreQuery{Order: &desc}
 	}
-	if os.Getenv(prefix+"GCP_FIRESTORE_RETRIEVE_QUERY_PATH") != "" {
-		v := os.Getenv(prefix + "GCP_FIRESTORE_RETRIEVE_QUERY_PATH")
-		d.RetrieveQuery.Path = &v
-	}
-	if os.Getenv(prefix+"GCP_FIRESTORE_RETRIEVE_QUERY_OP") != "" {
-		v := os.Getenv(prefix + "GCP_FIRESTORE_RETRIEVE_QUERY_OP")
-		d.RetrieveQuery.Op = &v
-	}
-	if os.Getenv(prefix+"GCP_FIRESTORE_RETRIEVE_QUERY_VALUE") != "" {
-		vs := os.Getenv(prefix + "GCP_FIRESTORE_RETRIEVE_QUERY_VALUE")
-		var v interface{}
-		jd, err := json.Marshal(vs)
-		if err != nil {
-			l.Error(err)
-			return err
-		}
-		err = json.Unmarshal(jd, &v)
-		if err != nil {
-			l.Error(err)
-			return err
-		}
-		d.RetrieveQuery.Value = v
-	}
-	if os.Getenv(prefix+"GCP_FIRESTORE_RETRIEVE_QUERY_ORDER_BY") != "" {
-		v := os.Getenv(prefix + "GCP_FIRESTORE_RETRIEVE_QUERY_ORDER_BY")
-		d.RetrieveQuery.OrderBy = &v
-	}
-	if os.Getenv(prefix+"GCP_FIRESTORE_RETRIEVE_QUERY_ORDER") != "" {
-		v := os.Getenv(prefix + "GCP_FIRESTORE_RETRIEVE_QUERY_ORDER")
-		if strings.EqualFold(v, "asc") {
-			tv := firestore.Asc
-			d.RetrieveQuery.Order = &tv
-		} else {
-			tv := firestore.Desc
-			d.RetrieveQuery.Order = &tv
+	v.StringPtr(&d.RetrieveQuery.Path, flags.GCPFirestoreRetrieveQueryPath)
+	v.StringPtr(&d.RetrieveQuery.Op, flags.GCPFirestoreRetrieveQueryOp)
+	v.Func(flags.GCPFirestoreRetrieveQueryValue, func(s string) error {
+		d.RetrieveQuery.Value = s
+		return nil
+	})
+	v.StringPtr(&d.RetrieveQuery.OrderBy, flags.GCPFirestoreRetrieveQueryOrderBy)
+	v.Func(flags.GCPFirestoreRetrieveQueryOrder, func(s string) error {
+		o := firestore.Desc
+		if strings.EqualFold(s, "asc") {
+			o = firestore.Asc
 		}
-	}
-	if os.Getenv(prefix+"GCP_FIRESTORE_RETRIEVE_LIMIT") != "" {
-		v := os.Getenv(prefix + "GCP_FIRESTORE_RETRIEVE_LIMIT")
-		iv, err := strconv.Atoi(v)
+		d.RetrieveQuery.Order = &o
+		return nil
+	})
+	v.Func(flags.GCPFirestoreRetrieveLimit, func(s string) error {
+		i, err := strconv.Atoi(s)
 		if err != nil {
-			l.Error(err)
 			return err
 		}
-		d.Limit = &iv
-	}
-	if os.Getenv(prefix+"GCP_FIRESTORE_CLEAR_OP") != "" {
-		v := FirestoreOp(os.Getenv(prefix + "GCP_FIRESTORE_CLEAR_OP"))
-		d.ClearOp = &v
-	}
-	if os.Getenv(prefix+"GCP_FIRESTORE_CLEAR_COLLECTION") != "" {
-		v := os.Getenv(prefix + "GCP_FIRESTORE_CLEAR_COLLECTION")
-		d.ClearCollection = &v
-	}
-	if os.Getenv(prefix+"GCP_FIRESTORE_FAIL_OP") != "" {
-		v := FirestoreOp(os.Getenv(prefix + "GCP_FIRESTORE_FAIL_OP"))
-		d.FailOp = &v
-	}
-	if os.Getenv(prefix+"GCP_FIRESTORE_FAIL_COLLECTION") != "" {
-		v := os.Getenv(prefix + "GCP_FIRESTORE_FAIL_COLLECTION")
-		d.FailCollection = &v
-	}
-	if os.Getenv(prefix+"GCP_FIRESTORE_FAIL_UPDATE") != "" {
-		vs := os.Getenv(prefix + "GCP_FIRESTORE_FAIL_UPDATE")
-		var v map[string]interface{}
-		err := json.Unmarshal([]byte(vs), &v)
-		if err != nil {
-			l.Error(err)
+		d.Limit = &i
+		return nil
+	})
+	v.Func(flags.GCPFirestoreClearOp, func(s string) error {
+		o := FirestoreOp(s)
+		d.ClearOp = &o
+		return nil
+	})
+	v.StringPtr(&d.ClearCollection, flags.GCPFirestoreClearCollection)
+	v.Func(flags.GCPFirestoreClearUpdate, func(s string) error {
+		var u map[string]any
+		if err := json.Unmarshal([]byte(s), &u); err != nil {
 			return err
 		}
-		d.FailUpdate = &v
-	}
-	if os.Getenv(prefix+"GCP_FIRESTORE_CLEAR_UPDATE") != "" {
-		vs := os.Getenv(prefix + "GCP_FIRESTORE_CLEAR_UPDATE")
-		var v map[string]interface{}
-		err := json.Unmarshal([]byte(vs), &v)
-		if err != nil {
-			l.Error(err)
+		d.ClearUpdate = &u
+		return nil
+	})
+	v.Func(flags.GCPFirestoreFailOp, func(s string) error {
+		o := FirestoreOp(s)
+		d.FailOp = &o
+		return nil
+	})
+	v.StringPtr(&d.FailCollection, flags.GCPFirestoreFailCollection)
+	v.Func(flags.GCPFirestoreFailUpdate, func(s string) error {
+		var u map[string]any
+		if err := json.Unmarshal([]byte(s), &u); err != nil {
 			return err
 		}
-		d.ClearUpdate = &v
-	}
-	return nil
+		d.FailUpdate = &u
+		return nil
+	})
+	return v.Err()
+}
+
+func (d *GCPFirestore) LoadEnv(prefix string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "gcp",
+		"fn":  "LoadEnv",
+	})
+	l.Debug("LoadEnv")
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *GCPFirestore) LoadFlags() error {
@@ -166,66 +131,7 @@ func (d *GCPFirestore) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("LoadFlags")
-	d.ProjectID = *flags.GCPProjectID
-	d.RetrieveCollection = flags.GCPFirestoreRetrieveCollection
-	d.RetrieveDocument = flags.GCPFirestoreRetrieveDocument
-	d.RetrieveDocumentJSONKey = flags.GCPFirestoreRetrieveDocumentJSONKey
-	d.ClearCollection = flags.GCPFirestoreClearCollection
-	d.FailCollection = flags.GCPFirestoreFailCollection
-	if flags.GCPFirestoreClearUpdate != nil && *flags.GCPFirestoreClearUpdate != "" {
-		var v map[string]interface{}
-		err := json.Unmarshal([]byte(*flags.GCPFirestoreClearUpdate), &v)
-		if err != nil {
-			l.Error(err)
-			return err
-		}
-		d.ClearUpdate = &v
-	}
-	if flags.GCPFirestoreFailUpdate != nil && *flags.GCPFirestoreFailUpdate != "" {
-		var v map[string]interface{}
-		err := json.Unmarshal([]byte(*flags.GCPFirestoreFailUpdate), &v)
-		if err != nil {
-			l.Error(err)
-			return err
-		}
-		d.FailUpdate = &v
-	}
-
-	var v interface{}
-	if flags.GCPFirestoreRetrieveQueryValue != nil && *flags.GCPFirestoreRetrieveQueryValue != "" {
-		jd, err := json.Marshal(*flags.GCPFirestoreRetrieveQueryValue)
-		if err != nil {
-			l.Error(err)
-			return err
-		}
-		err = json.Unmarshal(jd, &v)
-		if err != nil {
-			l.Error(err)
-			return err
-		}
-	}
-	d.RetrieveQuery = &GCPFirestoreQuery{
-		Path:    flags.GCPFirestoreRetrieveQueryPath,
-		Op:      flags.GCPFirestoreRetrieveQueryOp,
-		Value:   v,
-		OrderBy: flags.GCPFirestoreRetrieveQueryOrderBy,
-	}
-	if strings.EqualFold(*flags.GCPFirestoreRetrieveQueryOrder, "asc") {
-		v := firestore.Asc
-		d.RetrieveQuery.Order = &v
-	} else {
-		v := firestore.Desc
-		d.RetrieveQuery.Order = &v
-	}
-	if *flags.GCPFirestoreClearOp != "" {
-		o := FirestoreOp(*flags.GCPFirestoreClearOp)
-		d.ClearOp = &o
-	}
-	if *flags.GCPFirestoreFailOp != "" {
-		o := FirestoreOp(*flags.GCPFirestoreFailOp)
-		d.FailOp = &o
-	}
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *GCPFirestore) Init() error {
@@ -474,6 +380,10 @@ func (d *GCPFirestore) updateDocs(merge map[string]interface{}) error {
 	return nil
 }
 
+func (d *GCPFirestore) SetResult(r *schema.JobResult) {
+	d.result = r
+}
+
 func (d *GCPFirestore) ClearWork() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "gcp",
@@ -483,8 +393,10 @@ func (d *GCPFirestore) ClearWork() error {
 	if d.ClearOp == nil {
 		return nil
 	}
+	var update map[string]any
 	if d.ClearUpdate != nil && len(*d.ClearUpdate) > 0 {
-		if err := d.merge(*d.ClearUpdate); err != nil {
+		update = d.result.ReplaceMap(*d.ClearUpdate)
+		if err := d.merge(update); err != nil {
 			return err
 		}
 	}
@@ -494,7 +406,7 @@ func (d *GCPFirestore) ClearWork() error {
 	case FirestoreMVOp:
 		return d.mvDocs(*d.ClearCollection)
 	case FirestoreUpdateOp:
-		return d.updateDocs(*d.ClearUpdate)
+		return d.updateDocs(update)
 	}
 	return nil
 }
@@ -508,8 +420,10 @@ func (d *GCPFirestore) HandleFailure() error {
 	if d.FailOp == nil {
 		return nil
 	}
+	var update map[string]any
 	if d.FailUpdate != nil && len(*d.FailUpdate) > 0 {
-		if err := d.merge(*d.FailUpdate); err != nil {
+		update = d.result.ReplaceMap(*d.FailUpdate)
+		if err := d.merge(update); err != nil {
 			return err
 		}
 	}
@@ -519,7 +433,7 @@ func (d *GCPFirestore) HandleFailure() error {
 	case FirestoreMVOp:
 		return d.mvDocs(*d.FailCollection)
 	case FirestoreUpdateOp:
-		return d.updateDocs(*d.FailUpdate)
+		return d.updateDocs(update)
 	}
 	return nil
 }
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
 	"regexp"
 	"strings"
 
@@ -46,51 +45,42 @@ func (o *GCSOp) GetKey() string {
 	return strings.ReplaceAll(o.KeyTemplate, "{{key}}", o.Key)
 }
 
+// load loads the driver options from the flags or the environment.
+func (d *GCS) load(v *flags.Values) error {
+	v.String(&d.Bucket, flags.GCPGCSBucket)
+	v.String(&d.Key, flags.GCPGCSKey)
+	v.String(&d.KeyRegex, flags.GCPGCSKeyRegex)
+	v.String(&d.KeyPrefix, flags.GCPGCSKeyPrefix)
+	if d.ClearOp == nil {
+		d.ClearOp = &GCSOp{}
+	}
+	v.Func(flags.GCPGCSClearOp, func(s string) error {
+		d.ClearOp.Operation = GCSOperation(s)
+		return nil
+	})
+	v.String(&d.ClearOp.Bucket, flags.GCPGCSClearBucket)
+	v.String(&d.ClearOp.Key, flags.GCPGCSClearKey)
+	v.String(&d.ClearOp.KeyTemplate, flags.GCPGCSClearKeyTemplate)
+	if d.FailOp == nil {
+		d.FailOp = &GCSOp{}
+	}
+	v.Func(flags.GCPGCSFailOp, func(s string) error {
+		d.FailOp.Operation = GCSOperation(s)
+		return nil
+	})
+	v.String(&d.FailOp.Bucket, flags.GCPGCSFailBucket)
+	v.String(&d.FailOp.Key, flags.GCPGCSFailKey)
+	v.String(&d.FailOp.KeyTemplate, flags.GCPGCSFailKeyTemplate)
+	return v.Err()
+}
+
 func (d *GCS) LoadEnv(prefix string) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "gcp",
 		"fn":  "LoadEnv",
 	})
 	l.Debug("LoadEnv")
-	if os.Getenv(prefix+"GCP_GCS_BUCKET") != "" {
-		d.Bucket = os.Getenv(prefix + "GCP_GCS_BUCKET")
-	}
-	if os.Getenv(prefix+"GCP_GCS_KEY") != "" {
-		d.Key = os.Getenv(prefix + "GCP_GCS_KEY")
-	}
-	if os.Getenv(prefix+"GCP_GCS_KEY_REGEX") != "" {
-		d.KeyRegex = os.Getenv(prefix + "GCP_GCS_KEY_REGEX")
-	}
-	if os.Getenv(prefix+"GCP_GCS_KEY_PREFIX") != "" {
-		d.KeyPrefix = os.Getenv(prefix + "GCP_GCS_KEY_PREFIX")
-	}
-	if os.Getenv(prefix+"GCP_GCS_CLEAR_OP") != "" {
-		d.ClearOp = &GCSOp{}
-		d.ClearOp.Operation = GCSOperation(os.Getenv(prefix + "GCP_GCS_CLEAR_OP"))
-	}
-	if os.Getenv(prefix+"GCP_GCS_FAIL_OP") != "" {
-		d.FailOp = &GCSOp{}
-		d.FailOp.Operation = GCSOperation(os.Getenv(prefix + "GCP_GCS_FAIL_OP"))
-	}
-	if os.Getenv(prefix+"GCP_GCS_CLEAR_BUCKET") != "" {
-		d.ClearOp.Bucket = os.Getenv(prefix + "GCP_GCS_CLEAR_BUCKET")
-	}
-	if os.Getenv(prefix+"GCP_GCS_CLEAR_KEY") != "" {
-		d.ClearOp.Key = os.Getenv(prefix + "GCP_GCS_CLEAR_KEY")
-	}
-	if os.Getenv(prefix+"GCP_GCS_CLEAR_KEY_TEMPLATE") != "" {
-		d.ClearOp.KeyTemplate = os.Getenv(prefix + "GCP_GCS_CLEAR_KEY_TEMPLATE")
-	}
-	if os.Getenv(prefix+"GCP_GCS_FAIL_BUCKET") != "" {
-		d.FailOp.Bucket = os.Getenv(prefix + "GCP_GCS_FAIL_BUCKET")
-	}
-	if os.Getenv(prefix+"GCP_GCS_FAIL_KEY") != "" {
-		d.FailOp.Key = os.Getenv(prefix + "GCP_GCS_FAIL_KEY")
-	}
-	if os.Getenv(prefix+"GCP_GCS_FAIL_KEY_TEMPLATE") != "" {
-		d.FailOp.KeyTemplate = os.Getenv(prefix + "GCP_GCS_FAIL_KEY_TEMPLATE")
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *GCS) LoadFlags() error {
@@ -99,29 +89,7 @@ func (d *GCS) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("LoadFlags")
-	d.Bucket = *flags.GCPGCSBucket
-	d.Key = *flags.GCPGCSKey
-	if flags.GCPGCSKeyRegex != nil {
-		d.KeyRegex = *flags.GCPGCSKeyRegex
-	}
-	if flags.GCPGCSKeyPrefix != nil {
-		d.KeyPrefix = *flags.GCPGCSKeyPrefix
-	}
-	if flags.GCPGCSClearOp != nil {
-		d.ClearOp = &GCSOp{}
-		d.ClearOp.Operation = GCSOperation(*flags.GCPGCSClearOp)
-		d.ClearOp.Bucket = *flags.GCPGCSClearBucket
-		d.ClearOp.Key = *flags.GCPGCSClearKey
-		d.ClearOp.KeyTemplate = *flags.GCPGCSClearKeyTemplate
-	}
-	if flags.GCPGCSFailOp != nil {
-		d.FailOp = &GCSOp{}
-		d.FailOp.Operation = GCSOperation(*flags.GCPGCSFailOp)
-		d.FailOp.Bucket = *flags.GCPGCSFailBucket
-		d.FailOp.Key = *flags.GCPGCSFailKey
-		d.FailOp.KeyTemplate = *flags.GCPGCSFailKeyTemplate
-	}
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *GCS) Init() error {
@@ -356,6 +324,98 @@ func (d *GCS) HandleFailure() error {
 	}
 }
 
+func (d *GCS) previewOp(o *GCSOp) map[string]any {
+	if o == nil || o.Operation == "" {
+		return nil
+	}
+	m := map[string]any{
+		"op":     string(o.Operation),
+		"bucket": d.Bucket,
+		"key":    d.Key,
+	}
+	if o.Operation == GCSOperationMV {
+		op := *o
+		if op.Key == "" {
+			op.Key = d.Key
+		}
+		m["destBucket"] = op.Bucket
+		m["destKey"] = op.GetKey()
+	}
+	return m
+}
+
+func (d *GCS) PreviewClearWork() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "gcp",
+		"fn":  "PreviewClearWork",
+	})
+	l.Debug("PreviewClearWork")
+	return d.previewOp(d.ClearOp), nil
+}
+
+func (d *GCS) PreviewHandleFailure() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "gcp",
+		"fn":  "PreviewHandleFailure",
+	})
+	l.Debug("PreviewHandleFailure")
+	return d.previewOp(d.FailOp), nil
+}
+
+// Depth returns the number of objects matching the key prefix or regex, or
+// 1 if a key is set and the object exists.
+func (d *GCS) Depth() (int64, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "gcp",
+		"fn":  "Depth",
+	})
+	l.Debug("Depth")
+	if d.Bucket == "" {
+		return 0, fmt.Errorf("bucket is required")
+	}
+	ctx := context.Background()
+	if d.Key != "" {
+		_, err := d.Client.Bucket(d.Bucket).Object(d.Key).Attrs(ctx)
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return 0, nil
+		} else if err != nil {
+			l.Errorf("Depth error: %s", err)
+			return 0, err
+		}
+		return 1, nil
+	}
+	// the objects are listed in the same way as GetWork
+	q := &storage.Query{Prefix: d.KeyPrefix}
+	var re *regexp.Regexp
+	if d.KeyPrefix == "" {
+		if d.KeyRegex == "" {
+			return 0, errors.New("no key")
+		}
+		var err error
+		if re, err = regexp.Compile(d.KeyRegex); err != nil {
+			return 0, err
+		}
+		q.Delimiter = "/"
+	}
+	var n int64
+	it := d.Client.Bucket(d.Bucket).Objects(ctx, q)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			l.Errorf("Depth error: %s", err)
+			return 0, err
+		}
+		if attrs.Name == "" || (re != nil && !re.MatchString(attrs.Name)) {
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
 func (d *GCS) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "gcp",
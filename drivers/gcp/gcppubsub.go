@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"io"
-	"os"
 
 	"cloud.google.com/go/pubsub"
 	"github.com/robertlestak/procx/pkg/flags"
@@ -15,6 +14,16 @@ type GCPPubSub struct {
 	Client           *pubsub.Client
 	ProjectID        string
 	SubscriptionName string
+	cancelReceive    context.CancelFunc
+	messageID        string
+	deliveryAttempt  int
+}
+
+// load loads the driver options from the flags or the environment.
+func (d *GCPPubSub) load(v *flags.Values) error {
+	v.String(&d.ProjectID, flags.GCPProjectID)
+	v.String(&d.SubscriptionName, flags.GCPSubscription)
+	return v.Err()
 }
 
 func (d *GCPPubSub) LoadEnv(prefix string) error {
@@ -23,13 +32,7 @@ func (d *GCPPubSub) LoadEnv(prefix string) error {
 		"fn":  "LoadEnv",
 	})
 	l.Debug("LoadEnv")
-	if os.Getenv(prefix+"GCP_PROJECT_ID") != "" {
-		d.ProjectID = os.Getenv(prefix + "GCP_PROJECT_ID")
-	}
-	if os.Getenv(prefix+"GCP_SUBSCRIPTION") != "" {
-		d.SubscriptionName = os.Getenv(prefix + "GCP_SUBSCRIPTION")
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *GCPPubSub) LoadFlags() error {
@@ -38,18 +41,19 @@ func (d *GCPPubSub) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("LoadFlags")
-	d.ProjectID = *flags.GCPProjectID
-	d.SubscriptionName = *flags.GCPSubscription
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *GCPPubSub) Init() error {
+	return d.InitContext(context.Background())
+}
+
+func (d *GCPPubSub) InitContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "gcp",
 		"fn":  "Init",
 	})
 	l.Debug("Initializing gcp pubsub driver")
-	ctx := context.Background()
 	client, err := pubsub.NewClient(ctx, d.ProjectID)
 	if err != nil {
 		return err
@@ -59,33 +63,84 @@ func (d *GCPPubSub) Init() error {
 }
 
 func (d *GCPPubSub) GetWork() (io.Reader, error) {
+	return d.GetWorkContext(context.Background())
+}
+
+func (d *GCPPubSub) GetWorkContext(ctx context.Context) (io.Reader, error) {
 	l := log.WithFields(log.Fields{
 		"pkg": "gcp",
 		"fn":  "GetWork",
 	})
 	l.Debug("Getting work from gcp pubsub driver")
-	ctx := context.Background()
+	d.stopReceive()
+	rctx, cancel := context.WithCancel(context.Background())
+	d.cancelReceive = cancel
 	sub := d.Client.Subscription(d.SubscriptionName)
+	// only a single message is wanted, so the receiver does not lease more
+	// than it hands over
+	sub.ReceiveSettings.MaxOutstandingMessages = 1
+	sub.ReceiveSettings.NumGoroutines = 1
 	var msgData *pubsub.Message
 	msgChan := make(chan *pubsub.Message)
+	done := make(chan struct{})
+	var rerr error
 	// receive first message from subscription
 	go func() {
-		err := sub.Receive(ctx, func(ctx context.Context, m *pubsub.Message) {
+		defer close(done)
+		rerr = sub.Receive(rctx, func(mctx context.Context, m *pubsub.Message) {
 			//m.Ack()
-			msgChan <- m
+			select {
+			case msgChan <- m:
+			case <-mctx.Done():
+				// release messages which were not handed to GetWork
+				m.Nack()
+			}
 		})
-		if err != nil {
-			log.Error(err)
+		if rerr != nil {
+			log.Error(rerr)
 		}
 	}()
-	msgData = <-msgChan
+	select {
+	case msgData = <-msgChan:
+	case <-done:
+		// the receiver failed before a message was received
+		d.stopReceive()
+		return nil, rerr
+	case <-ctx.Done():
+		d.stopReceive()
+		<-done
+		return nil, ctx.Err()
+	}
+	// stop receiving once a message is taken, so that no more messages are
+	// leased while it is processed, and left to expire
+	d.stopReceive()
+	<-done
 	if msgData == nil {
 		return nil, nil
 	}
+	d.messageID = msgData.ID
+	d.deliveryAttempt = 0
+	// the delivery attempt is only set on subscriptions with a dead letter
+	// policy
+	if msgData.DeliveryAttempt != nil {
+		d.deliveryAttempt = *msgData.DeliveryAttempt
+	}
 	return bytes.NewReader(msgData.Data), nil
 }
 
+// stopReceive stops the receiver started by the previous GetWork, if any.
+func (d *GCPPubSub) stopReceive() {
+	if d.cancelReceive != nil {
+		d.cancelReceive()
+		d.cancelReceive = nil
+	}
+}
+
 func (d *GCPPubSub) ClearWork() error {
+	return d.ClearWorkContext(context.Background())
+}
+
+func (d *GCPPubSub) ClearWorkContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "gcp",
 		"fn":  "ClearWork",
@@ -95,6 +150,10 @@ func (d *GCPPubSub) ClearWork() error {
 }
 
 func (d *GCPPubSub) HandleFailure() error {
+	return d.HandleFailureContext(context.Background())
+}
+
+func (d *GCPPubSub) HandleFailureContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "gcp",
 		"fn":  "HandleFailure",
@@ -103,11 +162,27 @@ func (d *GCPPubSub) HandleFailure() error {
 	return nil
 }
 
+// DeliveryCount returns the delivery attempt of the current message, or 0 if
+// the subscription has no dead letter policy.
+func (d *GCPPubSub) DeliveryCount() int {
+	return d.deliveryAttempt
+}
+
+// WorkID returns the message ID of the current message.
+func (d *GCPPubSub) WorkID() string {
+	return d.messageID
+}
+
 func (d *GCPPubSub) Cleanup() error {
+	return d.CleanupContext(context.Background())
+}
+
+func (d *GCPPubSub) CleanupContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "gcp",
 		"fn":  "Cleanup",
 	})
 	l.Debug("Cleaning up gcp pubsub driver")
+	d.stopReceive()
 	return nil
 }
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"io"
-	"os"
 	"path"
 	"regexp"
 	"strings"
@@ -13,6 +12,7 @@ import (
 	"github.com/google/go-github/v35/github"
 	"github.com/google/uuid"
 	"github.com/robertlestak/procx/pkg/flags"
+	"github.com/robertlestak/procx/pkg/secrets"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
 )
@@ -48,85 +48,43 @@ type GitHub struct {
 	data            string
 }
 
+// load loads the driver options from the flags or the environment.
+func (d *GitHub) load(v *flags.Values) error {
+	v.String(&d.Repo, flags.GitHubRepo)
+	v.String(&d.Owner, flags.GitHubOwner)
+	v.String(&d.Token, flags.GitHubToken)
+	v.String(&d.File, flags.GitHubFile)
+	v.StringPtr(&d.FilePrefix, flags.GitHubFilePrefix)
+	v.StringPtr(&d.FileRegex, flags.GitHubFileRegex)
+	v.StringPtr(&d.Ref, flags.GitHubRef)
+	v.Bool(&d.OpenPR, flags.GitHubOpenPR)
+	v.StringPtr(&d.BaseBranch, flags.GitHubBaseBranch)
+	v.StringPtr(&d.Branch, flags.GitHubBranch)
+	v.StringPtr(&d.CommitName, flags.GitHubCommitName)
+	v.StringPtr(&d.CommitEmail, flags.GitHubCommitEmail)
+	v.StringPtr(&d.CommitMessage, flags.GitHubCommitMessage)
+	v.StringPtr(&d.PRTitle, flags.GitHubPRTitle)
+	v.StringPtr(&d.PRBody, flags.GitHubPRBody)
+	if s, ok := v.Lookup(flags.GitHubClearOp); ok {
+		o := GitHubOp(s)
+		d.ClearOp = &o
+	}
+	v.StringPtr(&d.ClearOpLocation, flags.GitHubClearOpLocation)
+	if s, ok := v.Lookup(flags.GitHubFailOp); ok {
+		o := GitHubOp(s)
+		d.FailOp = &o
+	}
+	v.StringPtr(&d.FailOpLocation, flags.GitHubFailOpLocation)
+	return v.Err()
+}
+
 func (d *GitHub) LoadEnv(prefix string) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "github",
 		"fn":  "LoadEnv",
 	})
 	l.Debug("Loading environment")
-	if os.Getenv(prefix+"GITHUB_REPO") != "" {
-		d.Repo = os.Getenv(prefix + "GITHUB_REPO")
-	}
-	if os.Getenv(prefix+"GITHUB_OWNER") != "" {
-		d.Owner = os.Getenv(prefix + "GITHUB_OWNER")
-	}
-	if os.Getenv(prefix+"GITHUB_TOKEN") != "" {
-		d.Token = os.Getenv(prefix + "GITHUB_TOKEN")
-	}
-	if os.Getenv(prefix+"GITHUB_FILE") != "" {
-		d.File = os.Getenv(prefix + "GITHUB_FILE")
-	}
-	if os.Getenv(prefix+"GITHUB_FILE_PREFIX") != "" {
-		v := os.Getenv(prefix + "GITHUB_FILE_PREFIX")
-		d.FilePrefix = &v
-	}
-	if os.Getenv(prefix+"GITHUB_FILE_REGEX") != "" {
-		v := os.Getenv(prefix + "GITHUB_FILE_REGEX")
-		d.FileRegex = &v
-	}
-	if os.Getenv(prefix+"GITHUB_REF") != "" {
-		v := os.Getenv(prefix + "GITHUB_REF")
-		d.Ref = &v
-	}
-	if os.Getenv(prefix+"GITHUB_OPEN_PR") != "" {
-		v := os.Getenv(prefix + "GITHUB_OPEN_PR")
-		d.OpenPR = v == "true"
-	}
-	if os.Getenv(prefix+"GITHUB_BASE_BRANCH") != "" {
-		v := os.Getenv(prefix + "GITHUB_BASE_BRANCH")
-		d.BaseBranch = &v
-	}
-	if os.Getenv(prefix+"GITHUB_BRANCH") != "" {
-		v := os.Getenv(prefix + "GITHUB_BRANCH")
-		d.Branch = &v
-	}
-	if os.Getenv(prefix+"GITHUB_COMMIT_NAME") != "" {
-		v := os.Getenv(prefix + "GITHUB_COMMIT_NAME")
-		d.CommitName = &v
-	}
-	if os.Getenv(prefix+"GITHUB_COMMIT_EMAIL") != "" {
-		v := os.Getenv(prefix + "GITHUB_COMMIT_EMAIL")
-		d.CommitEmail = &v
-	}
-	if os.Getenv(prefix+"GITHUB_COMMIT_MESSAGE") != "" {
-		v := os.Getenv(prefix + "GITHUB_COMMIT_MESSAGE")
-		d.CommitMessage = &v
-	}
-	if os.Getenv(prefix+"GITHUB_PR_TITLE") != "" {
-		v := os.Getenv(prefix + "GITHUB_PR_TITLE")
-		d.PRTitle = &v
-	}
-	if os.Getenv(prefix+"GITHUB_PR_BODY") != "" {
-		v := os.Getenv(prefix + "GITHUB_PR_BODY")
-		d.PRBody = &v
-	}
-	if os.Getenv(prefix+"GITHUB_CLEAR_OP") != "" {
-		v := GitHubOp(os.Getenv(prefix + "GITHUB_CLEAR_OP"))
-		d.ClearOp = &v
-	}
-	if os.Getenv(prefix+"GITHUB_CLEAR_OP_LOCATION") != "" {
-		v := os.Getenv(prefix + "GITHUB_CLEAR_OP_LOCATION")
-		d.ClearOpLocation = &v
-	}
-	if os.Getenv(prefix+"GITHUB_FAIL_OP") != "" {
-		v := GitHubOp(os.Getenv(prefix + "GITHUB_FAIL_OP"))
-		d.FailOp = &v
-	}
-	if os.Getenv(prefix+"GITHUB_FAIL_OP_LOCATION") != "" {
-		v := os.Getenv(prefix + "GITHUB_FAIL_OP_LOCATION")
-		d.FailOpLocation = &v
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *GitHub) LoadFlags() error {
@@ -135,28 +93,7 @@ func (d *GitHub) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	d.Repo = *flags.GitHubRepo
-	d.Owner = *flags.GitHubOwner
-	d.Token = *flags.GitHubToken
-	d.File = *flags.GitHubFile
-	d.FilePrefix = flags.GitHubFilePrefix
-	d.FileRegex = flags.GitHubFileRegex
-	d.Ref = flags.GitHubRef
-	d.OpenPR = *flags.GitHubOpenPR
-	d.BaseBranch = flags.GitHubBaseBranch
-	d.Branch = flags.GitHubBranch
-	d.CommitName = flags.GitHubCommitName
-	d.CommitEmail = flags.GitHubCommitEmail
-	d.CommitMessage = flags.GitHubCommitMessage
-	d.PRTitle = flags.GitHubPRTitle
-	d.PRBody = flags.GitHubPRBody
-	o := GitHubOp(*flags.GitHubClearOp)
-	d.ClearOp = &o
-	d.ClearOpLocation = flags.GitHubClearOpLocation
-	fo := GitHubOp(*flags.GitHubFailOp)
-	d.FailOp = &fo
-	d.FailOpLocation = flags.GitHubFailOpLocation
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *GitHub) Init() error {
@@ -166,8 +103,12 @@ func (d *GitHub) Init() error {
 	})
 	l.Debug("Initializing github driver")
 	ctx := context.Background()
+	token, err := secrets.Resolve(d.Token)
+	if err != nil {
+		return err
+	}
 	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: d.Token},
+		&oauth2.Token{AccessToken: token},
 	)
 	tc := oauth2.NewClient(ctx, ts)
 	d.Client = github.NewClient(tc)
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/robertlestak/procx/pkg/flags"
+	"github.com/robertlestak/procx/pkg/schema"
 	"github.com/robertlestak/procx/pkg/utils"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
@@ -42,14 +43,39 @@ type HTTP struct {
 	ClearRequest    *HTTPRequest
 	FailRequest     *HTTPRequest
 	Key             *string
+	result          *schema.JobResult
 }
 
-func (d *HTTP) LoadEnv(prefix string) error {
-	l := log.WithFields(log.Fields{
-		"pkg": "http",
-		"fn":  "LoadEnv",
+// loadRequest loads the options of a request from the flags or the
+// environment.
+func loadRequest(v *flags.Values, r *HTTPRequest, method, url, contentType, codes, headers, bodyFile, body *string) {
+	v.String(&r.Method, method)
+	v.String(&r.URL, url)
+	v.String(&r.ContentType, contentType)
+	v.Func(codes, func(s string) error {
+		r.SuccessfulStatusCodes = parseIntSlice(s)
+		return nil
 	})
-	l.Debug("Loading environment")
+	v.Func(headers, func(s string) error {
+		r.Headers = parseHeaderMap(s)
+		return nil
+	})
+	v.Func(bodyFile, func(s string) error {
+		f, err := os.Open(s)
+		if err != nil {
+			return err
+		}
+		r.Body = f
+		return nil
+	})
+	v.Func(body, func(s string) error {
+		r.Body = bytes.NewBufferString(s)
+		return nil
+	})
+}
+
+// load loads the driver options from the flags or the environment.
+func (d *HTTP) load(v *flags.Values) error {
 	if d.RetrieveRequest == nil {
 		d.RetrieveRequest = &RetrieveRequest{}
 	}
@@ -59,104 +85,59 @@ func (d *HTTP) LoadEnv(prefix string) error {
 	if d.FailRequest == nil {
 		d.FailRequest = &HTTPRequest{}
 	}
-	if os.Getenv(prefix+"HTTP_RETRIEVE_METHOD") != "" {
-		d.RetrieveRequest.Method = os.Getenv(prefix + "HTTP_RETRIEVE_METHOD")
-	}
-	if os.Getenv(prefix+"HTTP_RETRIEVE_URL") != "" {
-		d.RetrieveRequest.URL = os.Getenv(prefix + "HTTP_RETRIEVE_URL")
-	}
-	if os.Getenv(prefix+"HTTP_RETRIEVE_CONTENT_TYPE") != "" {
-		d.RetrieveRequest.ContentType = os.Getenv(prefix + "HTTP_RETRIEVE_CONTENT_TYPE")
-	}
-	if os.Getenv(prefix+"HTTP_RETRIEVE_SUCCESSFUL_STATUS_CODES") != "" {
-		d.RetrieveRequest.SuccessfulStatusCodes = parseIntSlice(os.Getenv(prefix + "HTTP_RETRIEVE_SUCCESSFUL_STATUS_CODES"))
-	}
-	if os.Getenv(prefix+"HTTP_RETRIEVE_HEADERS") != "" {
-		d.RetrieveRequest.Headers = parseHeaderMap(os.Getenv(prefix + "HTTP_RETRIEVE_HEADERS"))
-	}
-	if os.Getenv(prefix+"HTTP_RETRIEVE_KEY_JSON_SELECTOR") != "" {
-		d.RetrieveRequest.KeyJSONSelector = os.Getenv(prefix + "HTTP_RETRIEVE_KEY_JSON_SELECTOR")
-	}
-	if os.Getenv(prefix+"HTTP_RETRIEVE_WORK_JSON_SELECTOR") != "" {
-		d.RetrieveRequest.WorkJSONSelector = os.Getenv(prefix + "HTTP_RETRIEVE_WORK_JSON_SELECTOR")
-	}
-	if os.Getenv(prefix+"HTTP_RETRIEVE_BODY_FILE") != "" {
-		var err error
-		d.RetrieveRequest.Body, err = os.Open(os.Getenv(prefix + "HTTP_RETRIEVE_BODY_FILE"))
-		if err != nil {
-			return err
-		}
-	}
-	if os.Getenv(prefix+"HTTP_RETRIEVE_BODY") != "" {
-		d.RetrieveRequest.Body = bytes.NewBufferString(os.Getenv(prefix + "HTTP_RETRIEVE_BODY"))
-	}
-	if os.Getenv(prefix+"HTTP_CLEAR_METHOD") != "" {
-		d.ClearRequest.Method = os.Getenv(prefix + "HTTP_CLEAR_METHOD")
-	}
-	if os.Getenv(prefix+"HTTP_CLEAR_URL") != "" {
-		d.ClearRequest.URL = os.Getenv(prefix + "HTTP_CLEAR_URL")
-	}
-	if os.Getenv(prefix+"HTTP_CLEAR_CONTENT_TYPE") != "" {
-		d.ClearRequest.ContentType = os.Getenv(prefix + "HTTP_CLEAR_CONTENT_TYPE")
-	}
-	if os.Getenv(prefix+"HTTP_CLEAR_SUCCESSFUL_STATUS_CODES") != "" {
-		d.ClearRequest.SuccessfulStatusCodes = parseIntSlice(os.Getenv(prefix + "HTTP_CLEAR_SUCCESSFUL_STATUS_CODES"))
-	}
-	if os.Getenv(prefix+"HTTP_CLEAR_HEADERS") != "" {
-		d.ClearRequest.Headers = parseHeaderMap(os.Getenv(prefix + "HTTP_CLEAR_HEADERS"))
-	}
-	if os.Getenv(prefix+"HTTP_CLEAR_BODY_FILE") != "" {
-		var err error
-		d.ClearRequest.Body, err = os.Open(os.Getenv(prefix + "HTTP_CLEAR_BODY_FILE"))
-		if err != nil {
-			return err
-		}
-	}
-	if os.Getenv(prefix+"HTTP_CLEAR_BODY") != "" {
-		d.ClearRequest.Body = bytes.NewBufferString(os.Getenv(prefix + "HTTP_CLEAR_BODY"))
-	}
-	if os.Getenv(prefix+"HTTP_FAIL_METHOD") != "" {
-		d.FailRequest.Method = os.Getenv(prefix + "HTTP_FAIL_METHOD")
-	}
-	if os.Getenv(prefix+"HTTP_FAIL_URL") != "" {
-		d.FailRequest.URL = os.Getenv(prefix + "HTTP_FAIL_URL")
-	}
-	if os.Getenv(prefix+"HTTP_FAIL_CONTENT_TYPE") != "" {
-		d.FailRequest.ContentType = os.Getenv(prefix + "HTTP_FAIL_CONTENT_TYPE")
-	}
-	if os.Getenv(prefix+"HTTP_FAIL_SUCCESSFUL_STATUS_CODES") != "" {
-		d.FailRequest.SuccessfulStatusCodes = parseIntSlice(os.Getenv(prefix + "HTTP_FAIL_SUCCESSFUL_STATUS_CODES"))
-	}
-	if os.Getenv(prefix+"HTTP_FAIL_HEADERS") != "" {
-		d.FailRequest.Headers = parseHeaderMap(os.Getenv(prefix + "HTTP_FAIL_HEADERS"))
-	}
-	if os.Getenv(prefix+"HTTP_FAIL_BODY_FILE") != "" {
-		var err error
-		d.FailRequest.Body, err = os.Open(os.Getenv(prefix + "HTTP_FAIL_BODY_FILE"))
-		if err != nil {
-			return err
-		}
-	}
-	if os.Getenv(prefix+"HTTP_FAIL_BODY") != "" {
-		d.FailRequest.Body = bytes.NewBufferString(os.Getenv(prefix + "HTTP_FAIL_BODY"))
-	}
-	if os.Getenv(prefix+"HTTP_ENABLE_TLS") != "" {
-		v := os.Getenv(prefix+"HTTP_ENABLE_TLS") == "true"
-		d.EnableTLS = &v
-	}
-	if os.Getenv(prefix+"HTTP_TLS_CERT_FILE") != "" {
-		v := os.Getenv(prefix + "HTTP_TLS_CERT_FILE")
-		d.TLSCert = &v
-	}
-	if os.Getenv(prefix+"HTTP_TLS_KEY_FILE") != "" {
-		v := os.Getenv(prefix + "HTTP_TLS_KEY_FILE")
-		d.TLSKey = &v
-	}
-	if os.Getenv(prefix+"HTTP_TLS_CA_FILE") != "" {
-		v := os.Getenv(prefix + "HTTP_TLS_CA_FILE")
-		d.TLSCA = &v
-	}
-	return nil
+	loadRequest(v, &d.RetrieveRequest.HTTPRequest,
+		flags.HTTPRetrieveMethod,
+		flags.HTTPRetrieveURL,
+		flags.HTTPRetrieveContentType,
+		flags.HTTPRetrieveSuccessfulStatusCodes,
+		flags.HTTPRetrieveHeaders,
+		flags.HTTPRetrieveBodyFile,
+		flags.HTTPRetrieveBody,
+	)
+	v.String(&d.RetrieveRequest.KeyJSONSelector, flags.HTTPRetrieveKeyJSONSelector)
+	v.String(&d.RetrieveRequest.WorkJSONSelector, flags.HTTPRetrieveWorkJSONSelector)
+	loadRequest(v, d.ClearRequest,
+		flags.HTTPClearMethod,
+		flags.HTTPClearURL,
+		flags.HTTPClearContentType,
+		flags.HTTPClearSuccessfulStatusCodes,
+		flags.HTTPClearHeaders,
+		flags.HTTPClearBodyFile,
+		flags.HTTPClearBody,
+	)
+	loadRequest(v, d.FailRequest,
+		flags.HTTPFailMethod,
+		flags.HTTPFailURL,
+		flags.HTTPFailContentType,
+		flags.HTTPFailSuccessfulStatusCodes,
+		flags.HTTPFailHeaders,
+		flags.HTTPFailBodyFile,
+		flags.HTTPFailBody,
+	)
+	v.BoolPtr(&d.EnableTLS, flags.HTTPEnableTLS)
+	v.BoolPtr(&d.TLSInsecure, flags.HTTPTLSInsecure)
+	v.StringPtr(&d.TLSCert, flags.HTTPTLSCertFile)
+	v.StringPtr(&d.TLSKey, flags.HTTPTLSKeyFile)
+	v.StringPtr(&d.TLSCA, flags.HTTPTLSCAFile)
+	return v.Err()
+}
+
+func (d *HTTP) LoadEnv(prefix string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "http",
+		"fn":  "LoadEnv",
+	})
+	l.Debug("Loading environment")
+	return d.load(flags.EnvValues(prefix))
+}
+
+func (d *HTTP) LoadFlags() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "http",
+		"fn":  "LoadFlags",
+	})
+	l.Debug("Loading flags")
+	return d.load(flags.FlagValues())
 }
 
 func parseIntSlice(s string) []int {
@@ -183,86 +164,6 @@ func parseHeaderMap(s string) map[string]string {
 	return r
 }
 
-func (d *HTTP) LoadFlags() error {
-	l := log.WithFields(log.Fields{
-		"pkg": "http",
-		"fn":  "LoadFlags",
-	})
-	l.Debug("Loading flags")
-	rr := &RetrieveRequest{
-		HTTPRequest: HTTPRequest{
-			Method:                *flags.HTTPRetreieveMethod,
-			URL:                   *flags.HTTPRetrieveURL,
-			ContentType:           *flags.HTTPRetrieveContentType,
-			SuccessfulStatusCodes: parseIntSlice(*flags.HTTPRetrieveSuccessfulStatusCodes),
-			Headers:               parseHeaderMap(*flags.HTTPRetrieveHeaders),
-		},
-		KeyJSONSelector:  *flags.HTTPRetrieveKeyJSONSelector,
-		WorkJSONSelector: *flags.HTTPRetrieveWorkJSONSelector,
-	}
-	if *flags.HTTPRetrieveBodyFile != "" {
-		var err error
-		rr.Body, err = os.Open(*flags.HTTPRetrieveBodyFile)
-		if err != nil {
-			return err
-		}
-	}
-	if *flags.HTTPRetrieveBody != "" {
-		rr.Body = bytes.NewBufferString(*flags.HTTPRetrieveBody)
-	}
-	if rr.HTTPRequest.Method == "" {
-		rr.HTTPRequest.Method = "GET"
-	}
-	d.RetrieveRequest = rr
-	cr := &HTTPRequest{
-		Method:                *flags.HTTPClearMethod,
-		URL:                   *flags.HTTPClearURL,
-		ContentType:           *flags.HTTPClearContentType,
-		SuccessfulStatusCodes: parseIntSlice(*flags.HTTPClearSuccessfulStatusCodes),
-		Headers:               parseHeaderMap(*flags.HTTPClearHeaders),
-	}
-	if cr.Method == "" {
-		cr.Method = "GET"
-	}
-	if *flags.HTTPClearBodyFile != "" {
-		var err error
-		cr.Body, err = os.Open(*flags.HTTPClearBodyFile)
-		if err != nil {
-			return err
-		}
-	}
-	if *flags.HTTPClearBody != "" {
-		cr.Body = bytes.NewBufferString(*flags.HTTPClearBody)
-	}
-	d.ClearRequest = cr
-	fr := &HTTPRequest{
-		Method:                *flags.HTTPFailMethod,
-		URL:                   *flags.HTTPFailURL,
-		ContentType:           *flags.HTTPFailContentType,
-		SuccessfulStatusCodes: parseIntSlice(*flags.HTTPFailSuccessfulStatusCodes),
-		Headers:               parseHeaderMap(*flags.HTTPFailHeaders),
-	}
-	if fr.Method == "" {
-		fr.Method = "GET"
-	}
-	if *flags.HTTPFailBodyFile != "" {
-		var err error
-		fr.Body, err = os.Open(*flags.HTTPFailBodyFile)
-		if err != nil {
-			return err
-		}
-	}
-	if *flags.HTTPFailBody != "" {
-		fr.Body = bytes.NewBufferString(*flags.HTTPFailBody)
-	}
-	d.FailRequest = fr
-	d.EnableTLS = flags.HTTPEnableTLS
-	d.TLSCert = flags.HTTPTLSCertFile
-	d.TLSKey = flags.HTTPTLSKeyFile
-	d.TLSCA = flags.HTTPTLSCAFile
-	return nil
-}
-
 func (d *HTTP) Init() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "http",
@@ -353,100 +254,82 @@ func (d *HTTP) GetWork() (io.Reader, error) {
 	return resp.Body, nil
 }
 
-func (d *HTTP) ClearWork() error {
+func (d *HTTP) SetResult(r *schema.JobResult) {
+	d.result = r
+}
+
+// closeRequest sends the clear or fail request r for the current work, with
+// {{key}} replaced in its URL and body, and the job result in its body. r is
+// kept as the template for the next work.
+func (d *HTTP) closeRequest(r *HTTPRequest) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "http",
-		"fn":  "ClearWork",
+		"fn":  "closeRequest",
 	})
-	l.Debug("Clearing work from http")
-	if d.ClearRequest == nil {
-		return nil
-	}
-	if d.ClearRequest.URL == "" {
-		return nil
-	}
-	if d.ClearRequest.Method == "" {
-		d.ClearRequest.Method = "GET"
-	}
-	if d.ClearRequest.URL == "" {
-		return errors.New("URL is nil")
+	method := r.Method
+	if method == "" {
+		method = "GET"
+	}
+	url := r.URL
+	var body io.Reader
+	if r.Body != nil {
+		bd, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			l.Errorf("%+v", err)
+			return err
+		}
+		r.Body = bytes.NewReader(bd)
+		b := string(bd)
+		if d.Key != nil && *d.Key != "" {
+			b = strings.Replace(b, "{{key}}", *d.Key, -1)
+		}
+		body = strings.NewReader(d.result.ReplaceString(b))
 	}
 	if d.Key != nil && *d.Key != "" {
-		d.ClearRequest.URL = strings.Replace(d.ClearRequest.URL, "{{key}}", *d.Key, -1)
-		if d.ClearRequest.Body != nil {
-			bd, err := ioutil.ReadAll(d.ClearRequest.Body)
-			if err != nil {
-				l.Errorf("%+v", err)
-				return err
-			}
-			bd = []byte(strings.Replace(string(bd), "{{key}}", *d.Key, -1))
-			d.ClearRequest.Body = ioutil.NopCloser(bytes.NewReader(bd))
-		}
+		url = strings.Replace(url, "{{key}}", *d.Key, -1)
 	}
-	req, err := http.NewRequest(d.ClearRequest.Method, d.ClearRequest.URL, d.ClearRequest.Body)
+	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		l.Errorf("%+v", err)
 		return err
 	}
-	for k, v := range d.ClearRequest.Headers {
+	for k, v := range r.Headers {
 		req.Header.Add(k, v)
 	}
-	if d.ClearRequest.ContentType != "" {
-		req.Header.Add("Content-Type", d.ClearRequest.ContentType)
+	if r.ContentType != "" {
+		req.Header.Add("Content-Type", r.ContentType)
 	}
-	_, err = d.Client.Do(req)
+	resp, err := d.Client.Do(req)
 	if err != nil {
 		l.Errorf("%+v", err)
 		return err
 	}
+	resp.Body.Close()
 	return nil
 }
 
-func (d *HTTP) HandleFailure() error {
+func (d *HTTP) ClearWork() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "http",
 		"fn":  "ClearWork",
 	})
 	l.Debug("Clearing work from http")
-	if d.FailRequest == nil {
+	if d.ClearRequest == nil || d.ClearRequest.URL == "" {
 		return nil
 	}
-	if d.FailRequest.URL == "" {
+	return d.closeRequest(d.ClearRequest)
+}
+
+func (d *HTTP) HandleFailure() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "http",
+		"fn":  "HandleFailure",
+	})
+	l.Debug("Handling failure in http")
+	if d.FailRequest == nil || d.FailRequest.URL == "" {
 		return nil
 	}
-	if d.FailRequest.Method == "" {
-		d.FailRequest.Method = "GET"
-	}
-	if d.FailRequest.URL == "" {
-		return errors.New("URL is nil")
-	}
-	if d.Key != nil && *d.Key != "" {
-		d.FailRequest.URL = strings.Replace(d.FailRequest.URL, "{{key}}", *d.Key, -1)
-		bd, err := ioutil.ReadAll(d.FailRequest.Body)
-		if err != nil {
-			l.Errorf("%+v", err)
-			return err
-		}
-		bd = []byte(strings.Replace(string(bd), "{{key}}", *d.Key, -1))
-		d.FailRequest.Body = ioutil.NopCloser(bytes.NewReader(bd))
-	}
-	req, err := http.NewRequest(d.FailRequest.Method, d.FailRequest.URL, d.FailRequest.Body)
-	if err != nil {
-		l.Errorf("%+v", err)
-		return err
-	}
-	for k, v := range d.FailRequest.Headers {
-		req.Header.Add(k, v)
-	}
-	if d.FailRequest.ContentType != "" {
-		req.Header.Add("Content-Type", d.FailRequest.ContentType)
-	}
-	_, err = d.Client.Do(req)
-	if err != nil {
-		l.Errorf("%+v", err)
-		return err
-	}
-	return nil
+	return d.closeRequest(d.FailRequest)
 }
 
 func (d *HTTP) Cleanup() error {
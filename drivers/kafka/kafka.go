@@ -3,12 +3,13 @@ package kafka
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
-	"os"
-	"strings"
 	"time"
 
 	"github.com/robertlestak/procx/pkg/flags"
+	"github.com/robertlestak/procx/pkg/secrets"
 	"github.com/robertlestak/procx/pkg/utils"
 	kafka "github.com/segmentio/kafka-go"
 	"github.com/segmentio/kafka-go/sasl"
@@ -40,6 +41,28 @@ type Kafka struct {
 	SaslType   *SaslType
 	Username   *string
 	Password   *string
+	writer     *kafka.Writer
+	workID     string
+}
+
+// load loads the driver options from the flags or the environment.
+func (d *Kafka) load(v *flags.Values) error {
+	v.Strings(&d.Brokers, flags.KafkaBrokers)
+	v.StringPtr(&d.Group, flags.KafkaGroup)
+	v.StringPtr(&d.Topic, flags.KafkaTopic)
+	v.BoolPtr(&d.EnableTLS, flags.KafkaEnableTLS)
+	v.BoolPtr(&d.TLSInsecure, flags.KafkaTLSInsecure)
+	v.StringPtr(&d.TLSCert, flags.KafkaCertFile)
+	v.StringPtr(&d.TLSKey, flags.KafkaKeyFile)
+	v.StringPtr(&d.TLSCA, flags.KafkaCAFile)
+	v.BoolPtr(&d.EnableSASL, flags.KafkaEnableSasl)
+	if s, ok := v.Lookup(flags.KafkaSaslType); ok {
+		t := SaslType(s)
+		d.SaslType = &t
+	}
+	v.StringPtr(&d.Username, flags.KafkaSaslUsername)
+	v.StringPtr(&d.Password, flags.KafkaSaslPassword)
+	return v.Err()
 }
 
 func (d *Kafka) LoadEnv(prefix string) error {
@@ -48,55 +71,7 @@ func (d *Kafka) LoadEnv(prefix string) error {
 		"fn":  "LoadEnv",
 	})
 	l.Debug("Loading environment")
-	if os.Getenv(prefix+"KAFKA_BROKERS") != "" {
-		d.Brokers = strings.Split(os.Getenv(prefix+"KAFKA_BROKERS"), ",")
-	}
-	if os.Getenv(prefix+"KAFKA_GROUP") != "" {
-		v := os.Getenv(prefix + "KAFKA_GROUP")
-		d.Group = &v
-	}
-	if os.Getenv(prefix+"KAFKA_TOPIC") != "" {
-		v := os.Getenv(prefix + "KAFKA_TOPIC")
-		d.Topic = &v
-	}
-	if os.Getenv(prefix+"KAFKA_ENABLE_TLS") != "" {
-		v := os.Getenv(prefix+"KAFKA_ENABLE_TLS") == "true"
-		d.EnableTLS = &v
-	}
-	if os.Getenv(prefix+"KAFKA_TLS_INSECURE") != "" {
-		v := os.Getenv(prefix+"KAFKA_TLS_INSECURE") == "true"
-		d.TLSInsecure = &v
-	}
-	if os.Getenv(prefix+"KAFKA_TLS_CERT_FILE") != "" {
-		v := os.Getenv(prefix + "KAFKA_TLS_CERT_FILE")
-		d.TLSCert = &v
-	}
-	if os.Getenv(prefix+"KAFKA_TLS_KEY_FILE") != "" {
-		v := os.Getenv(prefix + "KAFKA_TLS_KEY_FILE")
-		d.TLSKey = &v
-	}
-	if os.Getenv(prefix+"KAFKA_TLS_CA_FILE") != "" {
-		v := os.Getenv(prefix + "KAFKA_TLS_CA_FILE")
-		d.TLSCA = &v
-	}
-	if os.Getenv(prefix+"KAFKA_ENABLE_SASL") != "" {
-		v := os.Getenv(prefix+"KAFKA_ENABLE_SASL") == "true"
-		d.EnableSASL = &v
-	}
-	if os.Getenv(prefix+"KAFKA_SASL_TYPE") != "" {
-		v := SaslType(os.Getenv(prefix + "KAFKA_SASL_TYPE"))
-		d.SaslType = &v
-	}
-	if os.Getenv(prefix+"KAFKA_SASL_USERNAME") != "" {
-		v := os.Getenv(prefix + "KAFKA_SASL_USERNAME")
-		d.Username = &v
-	}
-	if os.Getenv(prefix+"KAFKA_SASL_PASSWORD") != "" {
-		v := os.Getenv(prefix + "KAFKA_SASL_PASSWORD")
-		d.Password = &v
-	}
-	l.Debug("Loaded environment")
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *Kafka) LoadFlags() error {
@@ -105,26 +80,7 @@ func (d *Kafka) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	b := *flags.KafkaBrokers
-	if b != "" {
-		d.Brokers = strings.Split(b, ",")
-	}
-	d.Group = flags.KafkaGroup
-	d.Topic = flags.KafkaTopic
-	d.EnableTLS = flags.KafkaEnableTLS
-	d.TLSInsecure = flags.KafkaTLSInsecure
-	d.TLSCert = flags.KafkaCertFile
-	d.TLSKey = flags.KafkaKeyFile
-	d.TLSCA = flags.KafkaCAFile
-	d.EnableSASL = flags.KafkaEnableSasl
-	if flags.KafkaSaslType != nil {
-		t := SaslType(*flags.KafkaSaslType)
-		d.SaslType = &t
-	}
-	d.Username = flags.KafkaSaslUsername
-	d.Password = flags.KafkaSaslPassword
-	l.Debug("Loaded flags")
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *Kafka) saslConfig() (sasl.Mechanism, error) {
@@ -133,31 +89,93 @@ func (d *Kafka) saslConfig() (sasl.Mechanism, error) {
 		"fn":  "saslConfig",
 	})
 	l.Debug("Loading SASL config")
-	var m sasl.Mechanism
-	var err error
-	if d.SaslType != nil && *d.SaslType == SaslTypePlain {
-		l.Debug("SASL type is PLAIN")
-		m = plain.Mechanism{
-			Username: *d.Username,
-			Password: *d.Password,
-		}
-	} else if d.SaslType != nil && *d.SaslType == SaslTypeScram {
-		l.Debug("SASL type is SCRAM")
-		m, err = scram.Mechanism(scram.SHA512, *d.Username, *d.Password)
-		if err != nil {
-			l.Error(err)
-			return nil, err
-		}
-
-	} else {
+	if d.SaslType == nil || (*d.SaslType != SaslTypePlain && *d.SaslType != SaslTypeScram) {
 		l.Debug("SASL type is NONE")
 		return nil, nil
 	}
+	m := &secretMechanism{
+		saslType: *d.SaslType,
+		username: *d.Username,
+	}
+	if d.Password != nil {
+		m.password = *d.Password
+	}
+	// resolve the password now, so a missing secret fails Init
+	if _, err := m.mechanism(); err != nil {
+		l.Error(err)
+		return nil, err
+	}
 	l.Debug("Loaded SASL config")
 	return m, nil
 }
 
+// secretMechanism is a SASL mechanism which resolves the password secret each
+// time a connection is authenticated, so a rotated password is picked up when
+// the client reconnects.
+type secretMechanism struct {
+	saslType SaslType
+	username string
+	password string
+}
+
+// mechanism returns the SASL mechanism with the password resolved.
+func (m *secretMechanism) mechanism() (sasl.Mechanism, error) {
+	pass, err := secrets.Resolve(m.password)
+	if err != nil {
+		return nil, err
+	}
+	if m.saslType == SaslTypePlain {
+		return plain.Mechanism{
+			Username: m.username,
+			Password: pass,
+		}, nil
+	}
+	return scram.Mechanism(scram.SHA512, m.username, pass)
+}
+
+func (m *secretMechanism) Name() string {
+	if m.saslType == SaslTypePlain {
+		return plain.Mechanism{}.Name()
+	}
+	return scram.SHA512.Name()
+}
+
+func (m *secretMechanism) Start(ctx context.Context) (sasl.StateMachine, []byte, error) {
+	mech, err := m.mechanism()
+	if err != nil {
+		return nil, nil, err
+	}
+	return mech.Start(ctx)
+}
+
+// transport returns the transport for the kafka client and writer, with the
+// TLS and SASL settings of the driver.
+func (d *Kafka) transport() (*kafka.Transport, error) {
+	t := &kafka.Transport{
+		DialTimeout: 10 * time.Second,
+	}
+	if d.EnableTLS != nil && *d.EnableTLS {
+		tc, err := utils.TlsConfig(d.EnableTLS, d.TLSInsecure, d.TLSCA, d.TLSCert, d.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		t.TLS = tc
+	}
+	if d.EnableSASL != nil && *d.EnableSASL {
+		m, err := d.saslConfig()
+		if err != nil {
+			return nil, err
+		}
+		t.SASL = m
+	}
+	return t, nil
+}
+
 func (d *Kafka) Init() error {
+	return d.InitContext(context.Background())
+}
+
+func (d *Kafka) InitContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "kafka",
 		"fn":  "Init",
@@ -189,7 +207,7 @@ func (d *Kafka) Init() error {
 			return err
 		}
 		if m != nil {
-			kc.Dialer.SASLMechanism = m
+			dialer.SASLMechanism = m
 		}
 	}
 	kc.Dialer = dialer
@@ -198,21 +216,34 @@ func (d *Kafka) Init() error {
 }
 
 func (d *Kafka) GetWork() (io.Reader, error) {
+	return d.GetWorkContext(context.Background())
+}
+
+func (d *Kafka) GetWorkContext(ctx context.Context) (io.Reader, error) {
 	l := log.WithFields(log.Fields{
 		"pkg": "kafka",
 		"fn":  "GetWork",
 	})
 	l.Debug("Getting work from kafka")
-	m, err := d.Client.ReadMessage(context.Background())
+	m, err := d.Client.ReadMessage(ctx)
+	if ctx.Err() != nil {
+		l.Debug("Context done")
+		return nil, ctx.Err()
+	}
 	if err != nil {
 		l.Error(err)
 		return nil, err
 	}
 	l.Debug("Got work")
+	d.workID = fmt.Sprintf("%s:%d:%d", m.Topic, m.Partition, m.Offset)
 	return bytes.NewReader(m.Value), nil
 }
 
 func (d *Kafka) ClearWork() error {
+	return d.ClearWorkContext(context.Background())
+}
+
+func (d *Kafka) ClearWorkContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "kafka",
 		"fn":  "ClearWork",
@@ -223,6 +254,10 @@ func (d *Kafka) ClearWork() error {
 }
 
 func (d *Kafka) HandleFailure() error {
+	return d.HandleFailureContext(context.Background())
+}
+
+func (d *Kafka) HandleFailureContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "kafka",
 		"fn":  "HandleFailure",
@@ -232,7 +267,99 @@ func (d *Kafka) HandleFailure() error {
 	return nil
 }
 
+// Depth returns the consumer group lag, the number of messages in the topic
+// after the group's committed offset, summed over the partitions. Partitions
+// without a committed offset are counted from their first offset.
+func (d *Kafka) Depth() (int64, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "kafka",
+		"fn":  "Depth",
+	})
+	l.Debug("Getting kafka consumer lag")
+	if d.Topic == nil || *d.Topic == "" || d.Group == nil || *d.Group == "" {
+		return 0, errors.New("depth requires a topic and group")
+	}
+	t, err := d.transport()
+	if err != nil {
+		return 0, err
+	}
+	ctx := context.Background()
+	c := &kafka.Client{
+		Addr:      kafka.TCP(d.Brokers...),
+		Timeout:   10 * time.Second,
+		Transport: t,
+	}
+	md, err := c.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{*d.Topic}})
+	if err != nil {
+		l.Error(err)
+		return 0, err
+	}
+	var parts []int
+	var reqs []kafka.OffsetRequest
+	for _, mt := range md.Topics {
+		if mt.Error != nil {
+			l.Error(mt.Error)
+			return 0, mt.Error
+		}
+		for _, p := range mt.Partitions {
+			parts = append(parts, p.ID)
+			reqs = append(reqs, kafka.FirstOffsetOf(p.ID), kafka.LastOffsetOf(p.ID))
+		}
+	}
+	lo, err := c.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{*d.Topic: reqs},
+	})
+	if err != nil {
+		l.Error(err)
+		return 0, err
+	}
+	of, err := c.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: *d.Group,
+		Topics:  map[string][]int{*d.Topic: parts},
+	})
+	if err != nil {
+		l.Error(err)
+		return 0, err
+	}
+	if of.Error != nil {
+		l.Error(of.Error)
+		return 0, of.Error
+	}
+	committed := make(map[int]int64)
+	for _, p := range of.Topics[*d.Topic] {
+		if p.Error != nil {
+			l.Error(p.Error)
+			return 0, p.Error
+		}
+		committed[p.Partition] = p.CommittedOffset
+	}
+	var lag int64
+	for _, p := range lo.Topics[*d.Topic] {
+		if p.Error != nil {
+			l.Error(p.Error)
+			return 0, p.Error
+		}
+		off, ok := committed[p.Partition]
+		if !ok || off < p.FirstOffset {
+			off = p.FirstOffset
+		}
+		if p.LastOffset > off {
+			lag += p.LastOffset - off
+		}
+	}
+	return lag, nil
+}
+
+// WorkID returns the topic, partition and offset of the current message.
+func (d *Kafka) WorkID() string {
+	return d.workID
+}
+
 func (d *Kafka) Cleanup() error {
+	return d.CleanupContext(context.Background())
+}
+
+func (d *Kafka) CleanupContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "kafka",
 		"fn":  "Cleanup",
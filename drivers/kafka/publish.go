@@ -0,0 +1,62 @@
+package kafka
+
+import (
+	"context"
+
+	kafka "github.com/segmentio/kafka-go"
+	log "github.com/sirupsen/logrus"
+)
+
+func (d *Kafka) InitPublisher() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "kafka",
+		"fn":  "InitPublisher",
+	})
+	l.Debug("Initializing kafka publisher")
+	t, err := d.transport()
+	if err != nil {
+		return err
+	}
+	d.writer = &kafka.Writer{
+		Addr:         kafka.TCP(d.Brokers...),
+		Balancer:     &kafka.LeastBytes{},
+		RequiredAcks: kafka.RequireAll,
+		Transport:    t,
+	}
+	if d.Topic != nil {
+		d.writer.Topic = *d.Topic
+	}
+	return nil
+}
+
+// Publish writes a message with body as its value and meta as its headers to
+// the topic.
+func (d *Kafka) Publish(body []byte, meta map[string]string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "kafka",
+		"fn":  "Publish",
+	})
+	l.Debug("Publishing to kafka")
+	m := kafka.Message{Value: body}
+	for k, v := range meta {
+		m.Headers = append(m.Headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	if err := d.writer.WriteMessages(context.Background(), m); err != nil {
+		l.WithError(err).Error("Failed to publish message")
+		return err
+	}
+	return nil
+}
+
+func (d *Kafka) ClosePublisher() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "kafka",
+		"fn":  "ClosePublisher",
+	})
+	l.Debug("Closing kafka publisher")
+	if err := d.writer.Close(); err != nil {
+		l.Error(err)
+		return err
+	}
+	return nil
+}
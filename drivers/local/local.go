@@ -1,14 +1,98 @@
 package local
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
+	"github.com/robertlestak/procx/pkg/flags"
 	log "github.com/sirupsen/logrus"
 )
 
+type Split string
+
+var (
+	SplitNone  = Split("")
+	SplitLines = Split("lines")
+)
+
+// Local reads work from the PROCX_PAYLOAD environment variable, a file, a
+// directory of files, or stdin.
 type Local struct {
+	File      string
+	Dir       string
+	Split     Split
+	ClearFile string
+	FailFile  string
+	source    *source
+	data      []byte
+}
+
+// source is the files, or stdin, the work is read from. It is shared by the
+// drivers of all workers with the same settings, so each job is read once.
+type source struct {
+	mu      sync.Mutex
+	key     string
+	refs    int
+	split   Split
+	sources []string
+	src     io.ReadCloser
+	reader  *bufio.Reader
+}
+
+var (
+	sourcesMu sync.Mutex
+	sources   = make(map[string]*source)
+)
+
+// acquireSource returns the shared source of key, creating it with the
+// sources listed by list if it does not exist.
+func acquireSource(key string, split Split, list func() ([]string, error)) (*source, error) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	if s, ok := sources[key]; ok {
+		s.refs++
+		return s, nil
+	}
+	files, err := list()
+	if err != nil {
+		return nil, err
+	}
+	s := &source{key: key, refs: 1, split: split, sources: files}
+	sources[key] = s
+	return s, nil
+}
+
+// release releases a reference to s, closing it once it is no longer used.
+func (s *source) release() error {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	s.refs--
+	if s.refs > 0 {
+		return nil
+	}
+	delete(sources, s.key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeSource()
+}
+
+// load loads the driver options from the flags or the environment.
+func (d *Local) load(v *flags.Values) error {
+	v.String(&d.File, flags.LocalFile)
+	v.String(&d.Dir, flags.LocalDir)
+	if s, ok := v.Lookup(flags.LocalSplit); ok {
+		d.Split = Split(s)
+	}
+	v.String(&d.ClearFile, flags.LocalClearFile)
+	v.String(&d.FailFile, flags.LocalFailFile)
+	return v.Err()
 }
 
 func (d *Local) LoadEnv(prefix string) error {
@@ -17,7 +101,7 @@ func (d *Local) LoadEnv(prefix string) error {
 		"fn":  "LoadEnv",
 	})
 	l.Debug("Loading environment")
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *Local) LoadFlags() error {
@@ -26,7 +110,7 @@ func (d *Local) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *Local) Init() error {
@@ -35,20 +119,175 @@ func (d *Local) Init() error {
 		"fn":  "Init",
 	})
 	l.Debug("Initializing local driver")
+	if d.Split != SplitNone && d.Split != SplitLines {
+		return errors.New("invalid split mode")
+	}
+	if d.File == "" && d.Dir == "" {
+		return nil
+	}
+	if d.source != nil {
+		return nil
+	}
+	key := strings.Join([]string{d.File, d.Dir, string(d.Split)}, "\x00")
+	src, err := acquireSource(key, d.Split, d.list)
+	if err != nil {
+		l.WithError(err).Error("Failed to read directory")
+		return err
+	}
+	d.source = src
 	return nil
 }
 
+// list returns the file, and the files in the directory, work is read from.
+func (d *Local) list() ([]string, error) {
+	var files []string
+	if d.File != "" {
+		files = append(files, d.File)
+	}
+	if d.Dir != "" {
+		entries, err := os.ReadDir(d.Dir)
+		if err != nil {
+			return nil, err
+		}
+		var dir []string
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			dir = append(dir, filepath.Join(d.Dir, e.Name()))
+		}
+		sort.Strings(dir)
+		files = append(files, dir...)
+	}
+	return files, nil
+}
+
+// open opens the next source, returning false if there are no more sources.
+// s.mu must be held.
+func (s *source) open() (bool, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "local",
+		"fn":  "open",
+	})
+	if len(s.sources) == 0 {
+		return false, nil
+	}
+	name := s.sources[0]
+	s.sources = s.sources[1:]
+	l.WithField("source", name).Debug("Opening source")
+	if name == "-" {
+		s.src = os.Stdin
+	} else {
+		f, err := os.Open(name)
+		if err != nil {
+			l.WithError(err).Error("Failed to open source")
+			return false, err
+		}
+		s.src = f
+	}
+	s.reader = bufio.NewReader(s.src)
+	return true, nil
+}
+
+// closeSource closes the current source. s.mu must be held.
+func (s *source) closeSource() error {
+	if s.src == nil {
+		return nil
+	}
+	var err error
+	if s.src != os.Stdin {
+		err = s.src.Close()
+	}
+	s.src = nil
+	s.reader = nil
+	return err
+}
+
+// next returns the next job from the sources, or nil if the sources are
+// exhausted.
+func (s *source) next() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		if s.reader == nil {
+			ok, err := s.open()
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, nil
+			}
+		}
+		if s.split == SplitNone {
+			bd, err := io.ReadAll(s.reader)
+			if cerr := s.closeSource(); cerr != nil && err == nil {
+				err = cerr
+			}
+			return bd, err
+		}
+		line, err := s.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err == io.EOF {
+			if cerr := s.closeSource(); cerr != nil {
+				return nil, cerr
+			}
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) > 0 {
+			return line, nil
+		}
+	}
+}
+
 func (d *Local) GetWork() (io.Reader, error) {
 	l := log.WithFields(log.Fields{
 		"pkg": "local",
 		"fn":  "GetWork",
 	})
 	l.Debug("Getting work from local")
-	w := os.Getenv("PROCX_PAYLOAD")
-	if w == "" {
-		return nil, nil
+	d.data = nil
+	if d.File == "" && d.Dir == "" {
+		w := os.Getenv("PROCX_PAYLOAD")
+		if w == "" {
+			return nil, nil
+		}
+		d.data = []byte(w)
+		return strings.NewReader(w), nil
+	}
+	bd, err := d.source.next()
+	if err != nil {
+		l.WithError(err).Error("Failed to read work")
+		return nil, err
+	}
+	if bd == nil {
+		l.Debug("No more work")
+		return nil, io.EOF
 	}
-	return strings.NewReader(w), nil
+	d.data = bd
+	return bytes.NewReader(bd), nil
+}
+
+// appendTo appends the current work to the file, as a single line.
+func (d *Local) appendTo(file string) error {
+	l := log.WithFields(log.Fields{
+		"pkg":  "local",
+		"fn":   "appendTo",
+		"file": file,
+	})
+	l.Debug("Appending work to file")
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		l.WithError(err).Error("Failed to open file")
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(bytes.TrimRight(d.data, "\r\n"), '\n')); err != nil {
+		l.WithError(err).Error("Failed to write file")
+		return err
+	}
+	return nil
 }
 
 func (d *Local) ClearWork() error {
@@ -57,18 +296,52 @@ func (d *Local) ClearWork() error {
 		"fn":  "ClearWork",
 	})
 	l.Debug("Clearing work from local")
-	return nil
+	if d.ClearFile == "" {
+		return nil
+	}
+	return d.appendTo(d.ClearFile)
 }
 
 func (d *Local) HandleFailure() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "local",
-		"fn":  "ClearWork",
+		"fn":  "HandleFailure",
 	})
-	l.Debug("Clearing work from local")
-	return nil
+	l.Debug("Handling failure from local")
+	if d.FailFile == "" {
+		return nil
+	}
+	return d.appendTo(d.FailFile)
+}
+
+// Redelivers returns false, as the source is read once.
+func (d *Local) Redelivers() bool {
+	return false
+}
+
+func previewAppend(file string) map[string]any {
+	if file == "" {
+		return nil
+	}
+	return map[string]any{
+		"op":   "append",
+		"file": file,
+	}
+}
+
+func (d *Local) PreviewClearWork() (map[string]any, error) {
+	return previewAppend(d.ClearFile), nil
+}
+
+func (d *Local) PreviewHandleFailure() (map[string]any, error) {
+	return previewAppend(d.FailFile), nil
 }
 
 func (d *Local) Cleanup() error {
-	return nil
+	if d.source == nil {
+		return nil
+	}
+	s := d.source
+	d.source = nil
+	return s.release()
 }
@@ -0,0 +1,34 @@
+package local_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robertlestak/procx/drivers/local"
+	"github.com/robertlestak/procx/pkg/drivers"
+	"github.com/robertlestak/procx/pkg/drivers/drivertest"
+)
+
+func TestConformance(t *testing.T) {
+	drivertest.Run(t, drivertest.Harness{
+		Setup: func(t testing.TB) (drivers.Driver, drivertest.PushFunc) {
+			f := filepath.Join(t.TempDir(), "work")
+			if err := os.WriteFile(f, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			push := func(b []byte) error {
+				fh, err := os.OpenFile(f, os.O_APPEND|os.O_WRONLY, 0644)
+				if err != nil {
+					return err
+				}
+				defer fh.Close()
+				_, err = fh.Write(append(b, '\n'))
+				return err
+			}
+			return &local.Local{File: f, Split: local.SplitLines}, push
+		},
+		Wait: time.Second,
+	})
+}
@@ -0,0 +1,58 @@
+package local
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func (d *Local) InitPublisher() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "local",
+		"fn":  "InitPublisher",
+	})
+	l.Debug("Initializing local publisher")
+	if d.File == "" && d.Dir == "" {
+		return errors.New("local file or dir required")
+	}
+	return nil
+}
+
+// Publish appends body to the file as a single line, or writes it to stdout
+// if the file is -. If a directory is set instead, body is written to a new
+// file in it, named so the files sort in the order they were published.
+// Local messages have no metadata, so meta is not written.
+func (d *Local) Publish(body []byte, meta map[string]string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "local",
+		"fn":  "Publish",
+	})
+	l.Debug("Publishing to local")
+	if d.File == "-" {
+		_, err := os.Stdout.Write(append(bytes.TrimRight(body, "\r\n"), '\n'))
+		return err
+	}
+	if d.File != "" {
+		d.data = body
+		defer func() { d.data = nil }()
+		return d.appendTo(d.File)
+	}
+	f, err := os.CreateTemp(d.Dir, time.Now().UTC().Format("20060102T150405.000000000")+"-*")
+	if err != nil {
+		l.WithError(err).Error("Failed to create file")
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(body); err != nil {
+		l.WithError(err).Error("Failed to write file")
+		return err
+	}
+	return nil
+}
+
+func (d *Local) ClosePublisher() error {
+	return nil
+}
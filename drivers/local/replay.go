@@ -0,0 +1,177 @@
+package local
+
+import (
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/robertlestak/procx/pkg/flags"
+	"github.com/robertlestak/procx/pkg/record"
+	log "github.com/sirupsen/logrus"
+)
+
+// Replay is a local driver which feeds work recorded with -record-dir back
+// through the process, in the order it was recorded.
+type Replay struct {
+	Local
+	Dir     string
+	records *records
+	current *record.Record
+}
+
+// records are the record files left to replay. They are shared by the
+// drivers of all workers replaying the same directory, so each record is
+// replayed once.
+type records struct {
+	mu    sync.Mutex
+	dir   string
+	refs  int
+	files []string
+}
+
+var (
+	recordsMu sync.Mutex
+	replays   = make(map[string]*records)
+)
+
+// acquireRecords returns the shared records of dir, listing them if they do
+// not exist.
+func acquireRecords(dir string) (*records, error) {
+	recordsMu.Lock()
+	defer recordsMu.Unlock()
+	if r, ok := replays[dir]; ok {
+		r.refs++
+		return r, nil
+	}
+	files, err := record.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	log.WithFields(log.Fields{
+		"pkg": "local",
+		"fn":  "acquireRecords",
+		"dir": dir,
+	}).Debugf("found %d records", len(files))
+	r := &records{dir: dir, refs: 1, files: files}
+	replays[dir] = r
+	return r, nil
+}
+
+// release releases a reference to r.
+func (r *records) release() {
+	recordsMu.Lock()
+	defer recordsMu.Unlock()
+	r.refs--
+	if r.refs == 0 {
+		delete(replays, r.dir)
+	}
+}
+
+// next returns the next record file, or an empty string if all have been
+// replayed.
+func (r *records) next() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.files) == 0 {
+		return ""
+	}
+	f := r.files[0]
+	r.files = r.files[1:]
+	return f
+}
+
+// load loads the driver options from the flags or the environment.
+func (d *Replay) load(v *flags.Values) error {
+	v.String(&d.Dir, flags.ReplayDir)
+	return v.Err()
+}
+
+func (d *Replay) LoadEnv(prefix string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "local",
+		"fn":  "LoadEnv",
+	})
+	l.Debug("Loading environment")
+	return d.load(flags.EnvValues(prefix))
+}
+
+func (d *Replay) LoadFlags() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "local",
+		"fn":  "LoadFlags",
+	})
+	l.Debug("Loading flags")
+	return d.load(flags.FlagValues())
+}
+
+func (d *Replay) Init() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "local",
+		"fn":  "Init",
+		"dir": d.Dir,
+	})
+	l.Debug("Initializing replay driver")
+	if d.records != nil {
+		return nil
+	}
+	r, err := acquireRecords(d.Dir)
+	if err != nil {
+		l.WithError(err).Error("Failed to list records")
+		return err
+	}
+	d.records = r
+	return nil
+}
+
+func (d *Replay) GetWork() (io.Reader, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "local",
+		"fn":  "GetWork",
+	})
+	l.Debug("Getting work from replay")
+	d.current = nil
+	f := d.records.next()
+	if f == "" {
+		l.Debug("no more records")
+		return nil, io.EOF
+	}
+	r, err := record.Read(f)
+	if err != nil {
+		l.WithError(err).Error("Failed to read record")
+		return nil, err
+	}
+	l.WithField("id", r.ID).Debug("replaying record")
+	d.current = r
+	return strings.NewReader(r.Payload), nil
+}
+
+// ReplayRecord returns the record of the current work.
+func (d *Replay) ReplayRecord() *record.Record {
+	return d.current
+}
+
+func (d *Replay) ClearWork() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "local",
+		"fn":  "ClearWork",
+	})
+	l.Debug("Clearing work from replay")
+	return nil
+}
+
+func (d *Replay) HandleFailure() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "local",
+		"fn":  "HandleFailure",
+	})
+	l.Debug("Handling failure from replay")
+	return nil
+}
+
+func (d *Replay) Cleanup() error {
+	if d.records != nil {
+		d.records.release()
+		d.records = nil
+	}
+	return nil
+}
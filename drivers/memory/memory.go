@@ -0,0 +1,277 @@
+// Package memory is an in-memory reference driver with visibility timeout,
+// redelivery and acknowledgement semantics. It is intended for tests and for
+// embedding procx, and is not available from the command line as its queues
+// only live as long as the process.
+package memory
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrNotInFlight = errors.New("message is not in flight")
+
+	queuesMu sync.Mutex
+	queues   = make(map[string]*Queue)
+)
+
+type message struct {
+	id         string
+	data       []byte
+	deadline   time.Time
+	deliveries int
+}
+
+// Queue is an in-memory queue. Received messages are hidden from other
+// receivers until they are acked, nacked, or their visibility timeout
+// expires, after which they are redelivered.
+type Queue struct {
+	mu                sync.Mutex
+	VisibilityTimeout time.Duration
+	ready             []*message
+	inflight          map[string]*message
+	nextID            int
+}
+
+// NewQueue returns an empty queue. A visibility timeout of 0 keeps received
+// messages in flight until they are acked or nacked.
+func NewQueue(visibilityTimeout time.Duration) *Queue {
+	return &Queue{
+		VisibilityTimeout: visibilityTimeout,
+		inflight:          make(map[string]*message),
+	}
+}
+
+// GetQueue returns the named queue shared by all drivers in the process,
+// creating it with the given visibility timeout if it does not exist.
+func GetQueue(name string, visibilityTimeout time.Duration) *Queue {
+	queuesMu.Lock()
+	defer queuesMu.Unlock()
+	q, ok := queues[name]
+	if !ok {
+		q = NewQueue(visibilityTimeout)
+		queues[name] = q
+	}
+	return q
+}
+
+// Push adds a message to the back of the queue and returns its ID.
+func (q *Queue) Push(data []byte) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nextID++
+	m := &message{
+		id:   strconv.Itoa(q.nextID),
+		data: append([]byte{}, data...),
+	}
+	q.ready = append(q.ready, m)
+	return m.id
+}
+
+// requeueExpired moves in flight messages whose visibility timeout has
+// expired back to the queue. q.mu must be held.
+func (q *Queue) requeueExpired() {
+	now := time.Now()
+	for id, m := range q.inflight {
+		if !m.deadline.IsZero() && now.After(m.deadline) {
+			delete(q.inflight, id)
+			q.ready = append(q.ready, m)
+		}
+	}
+}
+
+// Receive returns the ID, data and delivery count of the next message and
+// marks it in flight. ok is false if there are no messages ready.
+func (q *Queue) Receive() (id string, data []byte, deliveries int, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.requeueExpired()
+	if len(q.ready) == 0 {
+		return "", nil, 0, false
+	}
+	m := q.ready[0]
+	q.ready = q.ready[1:]
+	m.deliveries++
+	m.deadline = time.Time{}
+	if q.VisibilityTimeout > 0 {
+		m.deadline = time.Now().Add(q.VisibilityTimeout)
+	}
+	q.inflight[m.id] = m
+	return m.id, append([]byte{}, m.data...), m.deliveries, true
+}
+
+// Ack removes an in flight message from the queue.
+func (q *Queue) Ack(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.inflight[id]; !ok {
+		return ErrNotInFlight
+	}
+	delete(q.inflight, id)
+	return nil
+}
+
+// Nack returns an in flight message to the front of the queue for immediate
+// redelivery.
+func (q *Queue) Nack(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	m, ok := q.inflight[id]
+	if !ok {
+		return ErrNotInFlight
+	}
+	delete(q.inflight, id)
+	q.ready = append([]*message{m}, q.ready...)
+	return nil
+}
+
+// Len returns the number of messages in the queue, including those in flight.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.ready) + len(q.inflight)
+}
+
+// Ready returns the number of messages ready to be received.
+func (q *Queue) Ready() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.requeueExpired()
+	return len(q.ready)
+}
+
+// Memory is a driver which consumes from an in-memory Queue. If Queue is nil
+// on Init, the named queue is used.
+type Memory struct {
+	Queue             *Queue
+	Name              string
+	VisibilityTimeout time.Duration
+	id                string
+}
+
+func (d *Memory) LoadEnv(prefix string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "memory",
+		"fn":  "LoadEnv",
+	})
+	l.Debug("Loading environment")
+	return nil
+}
+
+func (d *Memory) LoadFlags() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "memory",
+		"fn":  "LoadFlags",
+	})
+	l.Debug("Loading flags")
+	return nil
+}
+
+func (d *Memory) Init() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "memory",
+		"fn":  "Init",
+	})
+	l.Debug("Initializing memory driver")
+	if d.Queue == nil {
+		d.Queue = GetQueue(d.Name, d.VisibilityTimeout)
+	}
+	return nil
+}
+
+func (d *Memory) GetWork() (io.Reader, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "memory",
+		"fn":  "GetWork",
+	})
+	l.Debug("Getting work from memory")
+	d.id = ""
+	id, data, _, ok := d.Queue.Receive()
+	if !ok {
+		l.Debug("Queue is empty")
+		return nil, nil
+	}
+	d.id = id
+	return bytes.NewReader(data), nil
+}
+
+func (d *Memory) ClearWork() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "memory",
+		"fn":  "ClearWork",
+		"id":  d.id,
+	})
+	l.Debug("Clearing work from memory")
+	if d.id == "" {
+		return nil
+	}
+	if err := d.Queue.Ack(d.id); err != nil {
+		l.WithError(err).Error("Failed to ack message")
+		return err
+	}
+	d.id = ""
+	return nil
+}
+
+func (d *Memory) HandleFailure() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "memory",
+		"fn":  "HandleFailure",
+		"id":  d.id,
+	})
+	l.Debug("Handling failure")
+	return d.ReleaseWork()
+}
+
+// Redelivers returns true, as HandleFailure returns the message to the queue.
+func (d *Memory) Redelivers() bool {
+	return true
+}
+
+// ReleaseWork returns the current message to the queue for redelivery.
+func (d *Memory) ReleaseWork() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "memory",
+		"fn":  "ReleaseWork",
+		"id":  d.id,
+	})
+	l.Debug("Releasing work to memory")
+	if d.id == "" {
+		return nil
+	}
+	if err := d.Queue.Nack(d.id); err != nil {
+		l.WithError(err).Error("Failed to nack message")
+		return err
+	}
+	d.id = ""
+	return nil
+}
+
+func (d *Memory) PreviewClearWork() (map[string]any, error) {
+	return map[string]any{"op": "ack", "id": d.id}, nil
+}
+
+func (d *Memory) PreviewHandleFailure() (map[string]any, error) {
+	return map[string]any{"op": "nack", "id": d.id}, nil
+}
+
+// Depth returns the number of messages ready to be received.
+func (d *Memory) Depth() (int64, error) {
+	return int64(d.Queue.Ready()), nil
+}
+
+func (d *Memory) Cleanup() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "memory",
+		"fn":  "Cleanup",
+	})
+	l.Debug("Cleaning up")
+	return nil
+}
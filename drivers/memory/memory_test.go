@@ -0,0 +1,25 @@
+package memory_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robertlestak/procx/drivers/memory"
+	"github.com/robertlestak/procx/pkg/drivers"
+	"github.com/robertlestak/procx/pkg/drivers/drivertest"
+)
+
+func TestConformance(t *testing.T) {
+	drivertest.Run(t, drivertest.Harness{
+		Setup: func(t testing.TB) (drivers.Driver, drivertest.PushFunc) {
+			q := memory.NewQueue(time.Second)
+			push := func(b []byte) error {
+				q.Push(b)
+				return nil
+			}
+			return &memory.Memory{Queue: q}, push
+		},
+		Redelivers: true,
+		Wait:       time.Second,
+	})
+}
@@ -7,12 +7,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
 	"strconv"
 	"strings"
 
 	"github.com/robertlestak/procx/pkg/flags"
 	"github.com/robertlestak/procx/pkg/schema"
+	"github.com/robertlestak/procx/pkg/secrets"
 	"github.com/robertlestak/procx/pkg/utils"
 	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
@@ -40,6 +40,38 @@ type Mongo struct {
 	TLSKey      *string
 	TLSCA       *string
 	data        []bson.M
+	result      *schema.JobResult
+}
+
+// load loads the driver options from the flags or the environment.
+func (d *Mongo) load(v *flags.Values) error {
+	v.String(&d.Host, flags.MongoHost)
+	v.Int(&d.Port, flags.MongoPort)
+	v.String(&d.User, flags.MongoUser)
+	v.String(&d.Password, flags.MongoPassword)
+	v.String(&d.DB, flags.MongoDatabase)
+	v.Func(flags.MongoCollection, func(s string) error {
+		d.Collection = strings.TrimSpace(s)
+		return nil
+	})
+	v.StringPtr(&d.RetrieveQuery, flags.MongoRetrieveQuery)
+	v.StringPtr(&d.ClearQuery, flags.MongoClearQuery)
+	v.StringPtr(&d.FailQuery, flags.MongoFailQuery)
+	v.Func(flags.MongoLimit, func(s string) error {
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		d.Limit = &i
+		return nil
+	})
+	v.BoolPtr(&d.EnableTLS, flags.MongoEnableTLS)
+	v.BoolPtr(&d.TLSInsecure, flags.MongoTLSInsecure)
+	v.StringPtr(&d.TLSCert, flags.MongoCertFile)
+	v.StringPtr(&d.TLSKey, flags.MongoKeyFile)
+	v.StringPtr(&d.TLSCA, flags.MongoCAFile)
+	v.StringPtr(&d.AuthSource, flags.MongoAuthSource)
+	return v.Err()
 }
 
 func (d *Mongo) LoadEnv(prefix string) error {
@@ -48,74 +80,7 @@ func (d *Mongo) LoadEnv(prefix string) error {
 		"fn":  "LoadEnv",
 	})
 	l.Debug("Loading environment variables")
-	if os.Getenv(prefix+"MONGO_HOST") != "" {
-		d.Host = os.Getenv(prefix + "MONGO_HOST")
-	}
-	if os.Getenv(prefix+"MONGO_PORT") != "" {
-		pval, err := strconv.Atoi(os.Getenv(prefix + "MONGO_PORT"))
-		if err != nil {
-			return err
-		}
-		d.Port = pval
-	}
-	if os.Getenv(prefix+"MONGO_USER") != "" {
-		d.User = os.Getenv(prefix + "MONGO_USER")
-	}
-	if os.Getenv(prefix+"MONGO_PASSWORD") != "" {
-		d.Password = os.Getenv(prefix + "MONGO_PASSWORD")
-	}
-	if os.Getenv(prefix+"MONGO_DATABASE") != "" {
-		d.DB = os.Getenv(prefix + "MONGO_DATABASE")
-	}
-	if os.Getenv(prefix+"MONGO_RETRIEVE_QUERY") != "" {
-		qv := os.Getenv(prefix + "MONGO_RETRIEVE_QUERY")
-		d.RetrieveQuery = &qv
-	}
-	if os.Getenv(prefix+"MONGO_CLEAR_QUERY") != "" {
-		qv := os.Getenv(prefix + "MONGO_CLEAR_QUERY")
-		d.ClearQuery = &qv
-	}
-	if os.Getenv(prefix+"MONGO_FAIL_QUERY") != "" {
-		qv := os.Getenv(prefix + "MONGO_FAIL_QUERY")
-		d.FailQuery = &qv
-	}
-	if os.Getenv(prefix+"MONGO_COLLECTION") != "" {
-		c := os.Getenv(prefix + "MONGO_COLLECTION")
-		d.Collection = strings.TrimSpace(c)
-	}
-	if os.Getenv(prefix+"MONGO_ENABLE_TLS") != "" {
-		v := os.Getenv(prefix+"MONGO_ENABLE_TLS") == "true"
-		d.EnableTLS = &v
-	}
-	if os.Getenv(prefix+"MONGO_TLS_INSECURE") != "" {
-		v := os.Getenv(prefix+"MONGO_TLS_INSECURE") == "true"
-		d.TLSInsecure = &v
-	}
-	if os.Getenv(prefix+"MONGO_TLS_CERT_FILE") != "" {
-		v := os.Getenv(prefix + "MONGO_TLS_CERT_FILE")
-		d.TLSCert = &v
-	}
-	if os.Getenv(prefix+"MONGO_TLS_KEY_FILE") != "" {
-		v := os.Getenv(prefix + "MONGO_TLS_KEY_FILE")
-		d.TLSKey = &v
-	}
-	if os.Getenv(prefix+"MONGO_TLS_CA_FILE") != "" {
-		v := os.Getenv(prefix + "MONGO_TLS_CA_FILE")
-		d.TLSCA = &v
-	}
-	if os.Getenv(prefix+"MONGO_AUTH_SOURCE") != "" {
-		v := os.Getenv(prefix + "MONGO_AUTH_SOURCE")
-		d.AuthSource = &v
-	}
-	if os.Getenv(prefix+"MONGO_LIMIT") != "" {
-		v, err := strconv.Atoi(os.Getenv(prefix + "MONGO_LIMIT"))
-		if err != nil {
-			return err
-		}
-		iv := int64(v)
-		d.Limit = &iv
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *Mongo) LoadFlags() error {
@@ -124,28 +89,7 @@ func (d *Mongo) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	pv, err := strconv.Atoi(*flags.MongoPort)
-	if err != nil {
-		return err
-	}
-	d.Host = *flags.MongoHost
-	d.Port = pv
-	d.User = *flags.MongoUser
-	d.Password = *flags.MongoPassword
-	d.DB = *flags.MongoDatabase
-	iv := int64(*flags.MongoLimit)
-	d.Limit = &iv
-	d.Collection = *flags.MongoCollection
-	d.RetrieveQuery = flags.MongoRetrieveQuery
-	d.ClearQuery = flags.MongoClearQuery
-	d.FailQuery = flags.MongoFailQuery
-	d.EnableTLS = flags.MongoEnableTLS
-	d.TLSInsecure = flags.MongoTLSInsecure
-	d.TLSCert = flags.MongoCertFile
-	d.TLSKey = flags.MongoKeyFile
-	d.TLSCA = flags.MongoCAFile
-	d.AuthSource = flags.MongoAuthSource
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *Mongo) Init() error {
@@ -156,12 +100,16 @@ func (d *Mongo) Init() error {
 	l.Debug("Initializing mongo client")
 	var err error
 	var uri string
+	pass, err := secrets.Resolve(d.Password)
+	if err != nil {
+		return err
+	}
 	if d.AuthSource != nil && *d.AuthSource != "" {
-		uri = fmt.Sprintf("mongodb://%s:%s@%s:%d/%s?authSource=%s", d.User, d.Password, d.Host, d.Port, d.DB, *d.AuthSource)
+		uri = fmt.Sprintf("mongodb://%s:%s@%s:%d/%s?authSource=%s", d.User, pass, d.Host, d.Port, d.DB, *d.AuthSource)
 	} else {
-		uri = fmt.Sprintf("mongodb://%s:%s@%s:%d/%s", d.User, d.Password, d.Host, d.Port, d.DB)
+		uri = fmt.Sprintf("mongodb://%s:%s@%s:%d/%s", d.User, pass, d.Host, d.Port, d.DB)
 	}
-	l.Debug("uri: ", uri)
+	l.Debugf("uri: mongodb://%s@%s:%d/%s", d.User, d.Host, d.Port, d.DB)
 	opts := options.Client().ApplyURI(uri)
 	if d.EnableTLS != nil && *d.EnableTLS {
 		l.Debug("TLS enabled")
@@ -243,6 +191,10 @@ func (d *Mongo) GetWork() (io.Reader, error) {
 	return bytes.NewReader(jd), nil
 }
 
+func (d *Mongo) SetResult(r *schema.JobResult) {
+	d.result = r
+}
+
 func (d *Mongo) ClearWork() error {
 	l := log.WithFields(log.Fields{
 		"pkg":   "mongo",
@@ -261,7 +213,7 @@ func (d *Mongo) ClearWork() error {
 		l.Error(err)
 		return err
 	}
-	query := schema.ReplaceParamsString(jd, *d.ClearQuery)
+	query := d.result.ReplaceString(schema.ReplaceParamsString(jd, *d.ClearQuery))
 	l = l.WithField("newQuery", query)
 	var command bson.D
 	err = bson.UnmarshalExtJSON([]byte(query), true, &command)
@@ -300,7 +252,7 @@ func (d *Mongo) HandleFailure() error {
 		l.Error(err)
 		return err
 	}
-	query := schema.ReplaceParamsString(jd, *d.FailQuery)
+	query := d.result.ReplaceString(schema.ReplaceParamsString(jd, *d.FailQuery))
 	l = l.WithField("newQuery", query)
 	var command bson.D
 	err = bson.UnmarshalExtJSON([]byte(query), true, &command)
@@ -321,6 +273,38 @@ func (d *Mongo) HandleFailure() error {
 	return nil
 }
 
+func (d *Mongo) previewQuery(q *string) (map[string]any, error) {
+	if q == nil || *q == "" {
+		return nil, nil
+	}
+	jd, err := json.Marshal(d.data)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"database": d.DB,
+		"command":  schema.ReplaceParamsString(jd, *q),
+	}, nil
+}
+
+func (d *Mongo) PreviewClearWork() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "mongo",
+		"fn":  "PreviewClearWork",
+	})
+	l.Debug("Previewing clear work")
+	return d.previewQuery(d.ClearQuery)
+}
+
+func (d *Mongo) PreviewHandleFailure() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "mongo",
+		"fn":  "PreviewHandleFailure",
+	})
+	l.Debug("Previewing handle failure")
+	return d.previewQuery(d.FailQuery)
+}
+
 func (d *Mongo) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "mongo",
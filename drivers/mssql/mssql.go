@@ -6,13 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"strconv"
 	"strings"
 
 	_ "github.com/denisenkom/go-mssqldb"
 	"github.com/robertlestak/procx/pkg/flags"
 	"github.com/robertlestak/procx/pkg/schema"
+	"github.com/robertlestak/procx/pkg/secrets"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 )
@@ -28,75 +27,49 @@ type MSSql struct {
 	RetrieveQuery *schema.SqlQuery
 	ClearQuery    *schema.SqlQuery
 	FailQuery     *schema.SqlQuery
+	CountQuery    *schema.SqlQuery
 	data          []map[string]any
+	result        *schema.JobResult
 }
 
-func (d *MSSql) LoadEnv(prefix string) error {
-	l := log.WithFields(log.Fields{
-		"pkg": "mssql",
-		"fn":  "LoadEnv",
-	})
-	l.Debug("Loading environment variables")
-	if os.Getenv(prefix+"MSSQL_HOST") != "" {
-		d.Host = os.Getenv(prefix + "MSSQL_HOST")
-	}
-	if os.Getenv(prefix+"MSSQL_PORT") != "" {
-		pv, err := strconv.Atoi(os.Getenv(prefix + "MSSQL_PORT"))
-		if err != nil {
-			return err
-		}
-		d.Port = pv
-	}
-	if os.Getenv(prefix+"MSSQL_USER") != "" {
-		d.User = os.Getenv(prefix + "MSSQL_USER")
-	}
-	if os.Getenv(prefix+"MSSQL_PASSWORD") != "" {
-		d.Pass = os.Getenv(prefix + "MSSQL_PASSWORD")
-	}
-	if os.Getenv(prefix+"MSSQL_DATABASE") != "" {
-		d.Db = os.Getenv(prefix + "MSSQL_DATABASE")
-	}
+// load loads the driver options from the flags or the environment.
+func (d *MSSql) load(v *flags.Values) error {
+	v.String(&d.Host, flags.MSSqlHost)
+	v.Int(&d.Port, flags.MSSqlPort)
+	v.String(&d.User, flags.MSSqlUser)
+	v.String(&d.Pass, flags.MSSqlPassword)
+	v.String(&d.Db, flags.MSSqlDatabase)
+	v.StringPtr(&d.RetrieveField, flags.MSSqlRetrieveField)
 	if d.RetrieveQuery == nil {
 		d.RetrieveQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"MSSQL_RETRIEVE_QUERY") != "" {
-		d.RetrieveQuery.Query = os.Getenv(prefix + "MSSQL_RETRIEVE_QUERY")
-	}
+	v.String(&d.RetrieveQuery.Query, flags.MSSqlRetrieveQuery)
+	v.Params(&d.RetrieveQuery.Params, flags.MSSqlRetrieveParams)
 	if d.ClearQuery == nil {
 		d.ClearQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"MSSQL_CLEAR_QUERY") != "" {
-		d.ClearQuery.Query = os.Getenv(prefix + "MSSQL_CLEAR_QUERY")
-	}
+	v.String(&d.ClearQuery.Query, flags.MSSqlClearQuery)
+	v.Params(&d.ClearQuery.Params, flags.MSSqlClearParams)
 	if d.FailQuery == nil {
 		d.FailQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"MSSQL_FAIL_QUERY") != "" {
-		d.FailQuery.Query = os.Getenv(prefix + "MSSQL_FAIL_QUERY")
-	}
-	if os.Getenv(prefix+"MSSQL_RETRIEVE_PARAMS") != "" {
-		p := strings.Split(os.Getenv(prefix+"MSSQL_RETRIEVE_PARAMS"), ",")
-		for _, v := range p {
-			d.RetrieveQuery.Params = append(d.RetrieveQuery.Params, v)
-		}
-	}
-	if os.Getenv(prefix+"MSSQL_CLEAR_PARAMS") != "" {
-		p := strings.Split(os.Getenv(prefix+"MSSQL_CLEAR_PARAMS"), ",")
-		for _, v := range p {
-			d.ClearQuery.Params = append(d.ClearQuery.Params, v)
-		}
-	}
-	if os.Getenv(prefix+"MSSQL_FAIL_PARAMS") != "" {
-		p := strings.Split(os.Getenv(prefix+"MSSQL_FAIL_PARAMS"), ",")
-		for _, v := range p {
-			d.FailQuery.Params = append(d.FailQuery.Params, v)
-		}
-	}
-	if os.Getenv(prefix+"MSSQL_RETRIEVE_FIELD") != "" {
-		v := os.Getenv(prefix + "MSSQL_RETRIEVE_FIELD")
-		d.RetrieveField = &v
+	v.String(&d.FailQuery.Query, flags.MSSqlFailQuery)
+	v.Params(&d.FailQuery.Params, flags.MSSqlFailParams)
+	if d.CountQuery == nil {
+		d.CountQuery = &schema.SqlQuery{}
 	}
-	return nil
+	v.String(&d.CountQuery.Query, flags.MSSqlCountQuery)
+	v.Params(&d.CountQuery.Params, flags.MSSqlCountParams)
+	return v.Err()
+}
+
+func (d *MSSql) LoadEnv(prefix string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "mssql",
+		"fn":  "LoadEnv",
+	})
+	l.Debug("Loading environment variables")
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *MSSql) LoadFlags() error {
@@ -105,65 +78,17 @@ func (d *MSSql) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	pv, err := strconv.Atoi(*flags.MSSqlPort)
+	return d.load(flags.FlagValues())
+}
+
+// connStr returns the connection string, resolving the password if it is a
+// secret reference.
+func (d *MSSql) connStr() (string, error) {
+	pass, err := secrets.Resolve(d.Pass)
 	if err != nil {
-		return err
-	}
-	var rps []any
-	var cps []any
-	var fps []any
-	if *flags.MSSqlRetrieveParams != "" {
-		s := strings.Split(*flags.MSSqlRetrieveParams, ",")
-		for _, v := range s {
-			rps = append(rps, v)
-		}
-	}
-	if *flags.MSSqlClearParams != "" {
-		s := strings.Split(*flags.MSSqlClearParams, ",")
-		for _, v := range s {
-			cps = append(cps, v)
-		}
+		return "", err
 	}
-	if *flags.MSSqlFailParams != "" {
-		s := strings.Split(*flags.MSSqlFailParams, ",")
-		for _, v := range s {
-			fps = append(fps, v)
-		}
-	}
-	d.Host = *flags.MSSqlHost
-	d.Port = pv
-	d.User = *flags.MSSqlUser
-	d.Pass = *flags.MSSqlPassword
-	d.Db = *flags.MSSqlDatabase
-	d.RetrieveField = flags.MSSqlRetrieveField
-	if d.RetrieveQuery == nil {
-		d.RetrieveQuery = &schema.SqlQuery{}
-	}
-	if *flags.MSSqlRetrieveQuery != "" {
-		d.RetrieveQuery.Query = *flags.MSSqlRetrieveQuery
-	}
-	if len(rps) > 0 {
-		d.RetrieveQuery.Params = rps
-	}
-	if d.ClearQuery == nil {
-		d.ClearQuery = &schema.SqlQuery{}
-	}
-	if *flags.MSSqlClearQuery != "" {
-		d.ClearQuery.Query = *flags.MSSqlClearQuery
-	}
-	if len(cps) > 0 {
-		d.ClearQuery.Params = cps
-	}
-	if d.FailQuery == nil {
-		d.FailQuery = &schema.SqlQuery{}
-	}
-	if *flags.MSSqlFailQuery != "" {
-		d.FailQuery.Query = *flags.MSSqlFailQuery
-	}
-	if len(fps) > 0 {
-		d.FailQuery.Params = fps
-	}
-	return nil
+	return fmt.Sprintf("server=%s;user id=%s;password=%s;port=%d;database=%s", d.Host, d.User, pass, d.Port, d.Db), nil
 }
 
 func (d *MSSql) Init() error {
@@ -173,9 +98,8 @@ func (d *MSSql) Init() error {
 	})
 	l.Debug("Initializing mssql client")
 	var err error
-	connStr := fmt.Sprintf("server=%s;user id=%s;password=%s;port=%d;database=%s", d.Host, d.User, d.Pass, d.Port, d.Db)
-	l.Debug("Connecting to mssql: ", connStr)
-	d.Client, err = sql.Open("mssql", connStr)
+	l.Debugf("Connecting to mssql: %s:%d", d.Host, d.Port)
+	d.Client, err = secrets.OpenDB("mssql", d.connStr)
 	if err != nil {
 		l.Error(err)
 		return err
@@ -244,6 +168,10 @@ func (d *MSSql) GetWork() (io.Reader, error) {
 	return strings.NewReader(result), nil
 }
 
+func (d *MSSql) SetResult(r *schema.JobResult) {
+	d.result = r
+}
+
 func (d *MSSql) ClearWork() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "mssql",
@@ -254,8 +182,8 @@ func (d *MSSql) ClearWork() error {
 	if d.ClearQuery == nil || d.ClearQuery.Query == "" {
 		return nil
 	}
-	d.ClearQuery.Params = schema.ReplaceParamsSliceMap(d.data, d.ClearQuery.Params)
-	_, err = d.Client.Exec(d.ClearQuery.Query, d.ClearQuery.Params...)
+	params := d.ClearQuery.RenderParams(d.data, d.result)
+	_, err = d.Client.Exec(d.ClearQuery.Query, params...)
 	if err != nil {
 		l.Error(err)
 		return err
@@ -274,8 +202,8 @@ func (d *MSSql) HandleFailure() error {
 	if d.FailQuery == nil || d.FailQuery.Query == "" {
 		return nil
 	}
-	d.FailQuery.Params = schema.ReplaceParamsSliceMap(d.data, d.FailQuery.Params)
-	_, err = d.Client.Exec(d.FailQuery.Query, d.FailQuery.Params...)
+	params := d.FailQuery.RenderParams(d.data, d.result)
+	_, err = d.Client.Exec(d.FailQuery.Query, params...)
 	if err != nil {
 		l.Error(err)
 		return err
@@ -284,6 +212,44 @@ func (d *MSSql) HandleFailure() error {
 	return nil
 }
 
+func (d *MSSql) PreviewClearWork() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "mssql",
+		"fn":  "PreviewClearWork",
+	})
+	l.Debug("Previewing clear work")
+	return schema.PreviewSqlQuery(d.data, d.ClearQuery), nil
+}
+
+func (d *MSSql) PreviewHandleFailure() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "mssql",
+		"fn":  "PreviewHandleFailure",
+	})
+	l.Debug("Previewing handle failure")
+	return schema.PreviewSqlQuery(d.data, d.FailQuery), nil
+}
+
+// Depth returns the backlog counted by the count query, which must return a
+// single integer.
+func (d *MSSql) Depth() (int64, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "mssql",
+		"fn":  "Depth",
+	})
+	l.Debug("Counting work")
+	if d.CountQuery == nil || d.CountQuery.Query == "" {
+		l.Error("CountQuery is nil or empty")
+		return 0, errors.New("CountQuery is nil or empty")
+	}
+	var n int64
+	if err := d.Client.QueryRow(d.CountQuery.Query, d.CountQuery.Params...).Scan(&n); err != nil {
+		l.Error(err)
+		return 0, err
+	}
+	return n, nil
+}
+
 func (d *MSSql) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "mssql",
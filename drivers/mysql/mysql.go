@@ -6,13 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"strconv"
 	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/robertlestak/procx/pkg/flags"
 	"github.com/robertlestak/procx/pkg/schema"
+	"github.com/robertlestak/procx/pkg/secrets"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 )
@@ -28,75 +27,49 @@ type Mysql struct {
 	RetrieveQuery *schema.SqlQuery
 	ClearQuery    *schema.SqlQuery
 	FailQuery     *schema.SqlQuery
+	CountQuery    *schema.SqlQuery
 	data          []map[string]any
+	result        *schema.JobResult
 }
 
-func (d *Mysql) LoadEnv(prefix string) error {
-	l := log.WithFields(log.Fields{
-		"pkg": "mysql",
-		"fn":  "LoadEnv",
-	})
-	l.Debug("Loading environment variables")
-	if os.Getenv(prefix+"MYSQL_HOST") != "" {
-		d.Host = os.Getenv(prefix + "MYSQL_HOST")
-	}
-	if os.Getenv(prefix+"MYSQL_PORT") != "" {
-		pv, err := strconv.Atoi(os.Getenv(prefix + "MYSQL_PORT"))
-		if err != nil {
-			return err
-		}
-		d.Port = pv
-	}
-	if os.Getenv(prefix+"MYSQL_USER") != "" {
-		d.User = os.Getenv(prefix + "MYSQL_USER")
-	}
-	if os.Getenv(prefix+"MYSQL_PASSWORD") != "" {
-		d.Pass = os.Getenv(prefix + "MYSQL_PASSWORD")
-	}
-	if os.Getenv(prefix+"MYSQL_DATABASE") != "" {
-		d.Db = os.Getenv(prefix + "MYSQL_DATABASE")
-	}
+// load loads the driver options from the flags or the environment.
+func (d *Mysql) load(v *flags.Values) error {
+	v.String(&d.Host, flags.MysqlHost)
+	v.Int(&d.Port, flags.MysqlPort)
+	v.String(&d.User, flags.MysqlUser)
+	v.String(&d.Pass, flags.MysqlPassword)
+	v.String(&d.Db, flags.MysqlDatabase)
+	v.StringPtr(&d.RetrieveField, flags.MysqlRetrieveField)
 	if d.RetrieveQuery == nil {
 		d.RetrieveQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"MYSQL_RETRIEVE_QUERY") != "" {
-		d.RetrieveQuery.Query = os.Getenv(prefix + "MYSQL_RETRIEVE_QUERY")
-	}
+	v.String(&d.RetrieveQuery.Query, flags.MysqlRetrieveQuery)
+	v.Params(&d.RetrieveQuery.Params, flags.MysqlRetrieveParams)
 	if d.ClearQuery == nil {
 		d.ClearQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"MYSQL_CLEAR_QUERY") != "" {
-		d.ClearQuery.Query = os.Getenv(prefix + "MYSQL_CLEAR_QUERY")
-	}
+	v.String(&d.ClearQuery.Query, flags.MysqlClearQuery)
+	v.Params(&d.ClearQuery.Params, flags.MysqlClearParams)
 	if d.FailQuery == nil {
 		d.FailQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"MYSQL_FAIL_QUERY") != "" {
-		d.FailQuery.Query = os.Getenv(prefix + "MYSQL_FAIL_QUERY")
-	}
-	if os.Getenv(prefix+"MYSQL_RETRIEVE_PARAMS") != "" {
-		p := strings.Split(os.Getenv(prefix+"MYSQL_RETRIEVE_PARAMS"), ",")
-		for _, v := range p {
-			d.RetrieveQuery.Params = append(d.RetrieveQuery.Params, v)
-		}
-	}
-	if os.Getenv(prefix+"MYSQL_CLEAR_PARAMS") != "" {
-		p := strings.Split(os.Getenv(prefix+"MYSQL_CLEAR_PARAMS"), ",")
-		for _, v := range p {
-			d.ClearQuery.Params = append(d.ClearQuery.Params, v)
-		}
-	}
-	if os.Getenv(prefix+"MYSQL_FAIL_PARAMS") != "" {
-		p := strings.Split(os.Getenv(prefix+"MYSQL_FAIL_PARAMS"), ",")
-		for _, v := range p {
-			d.FailQuery.Params = append(d.FailQuery.Params, v)
-		}
-	}
-	if os.Getenv(prefix+"MYSQL_RETRIEVE_FIELD") != "" {
-		v := os.Getenv(prefix + "MYSQL_RETRIEVE_FIELD")
-		d.RetrieveField = &v
+	v.String(&d.FailQuery.Query, flags.MysqlFailQuery)
+	v.Params(&d.FailQuery.Params, flags.MysqlFailParams)
+	if d.CountQuery == nil {
+		d.CountQuery = &schema.SqlQuery{}
 	}
-	return nil
+	v.String(&d.CountQuery.Query, flags.MysqlCountQuery)
+	v.Params(&d.CountQuery.Params, flags.MysqlCountParams)
+	return v.Err()
+}
+
+func (d *Mysql) LoadEnv(prefix string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "mysql",
+		"fn":  "LoadEnv",
+	})
+	l.Debug("Loading environment variables")
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *Mysql) LoadFlags() error {
@@ -105,65 +78,17 @@ func (d *Mysql) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	pv, err := strconv.Atoi(*flags.MysqlPort)
+	return d.load(flags.FlagValues())
+}
+
+// connStr returns the connection string, resolving the password if it is a
+// secret reference.
+func (d *Mysql) connStr() (string, error) {
+	pass, err := secrets.Resolve(d.Pass)
 	if err != nil {
-		return err
-	}
-	var rps []any
-	var cps []any
-	var fps []any
-	if *flags.MysqlRetrieveParams != "" {
-		s := strings.Split(*flags.MysqlRetrieveParams, ",")
-		for _, v := range s {
-			rps = append(rps, v)
-		}
-	}
-	if *flags.MysqlClearParams != "" {
-		s := strings.Split(*flags.MysqlClearParams, ",")
-		for _, v := range s {
-			cps = append(cps, v)
-		}
+		return "", err
 	}
-	if *flags.MysqlFailParams != "" {
-		s := strings.Split(*flags.MysqlFailParams, ",")
-		for _, v := range s {
-			fps = append(fps, v)
-		}
-	}
-	d.Host = *flags.MysqlHost
-	d.Port = pv
-	d.User = *flags.MysqlUser
-	d.Pass = *flags.MysqlPassword
-	d.Db = *flags.MysqlDatabase
-	d.RetrieveField = flags.MysqlRetrieveField
-	if d.RetrieveQuery == nil {
-		d.RetrieveQuery = &schema.SqlQuery{}
-	}
-	if *flags.MysqlRetrieveQuery != "" {
-		d.RetrieveQuery.Query = *flags.MysqlRetrieveQuery
-	}
-	if len(rps) > 0 {
-		d.RetrieveQuery.Params = rps
-	}
-	if d.ClearQuery == nil {
-		d.ClearQuery = &schema.SqlQuery{}
-	}
-	if *flags.MysqlClearQuery != "" {
-		d.ClearQuery.Query = *flags.MysqlClearQuery
-	}
-	if len(cps) > 0 {
-		d.ClearQuery.Params = cps
-	}
-	if d.FailQuery == nil {
-		d.FailQuery = &schema.SqlQuery{}
-	}
-	if *flags.MysqlFailQuery != "" {
-		d.FailQuery.Query = *flags.MysqlFailQuery
-	}
-	if len(fps) > 0 {
-		d.FailQuery.Params = fps
-	}
-	return nil
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", d.User, pass, d.Host, d.Port, d.Db), nil
 }
 
 func (d *Mysql) Init() error {
@@ -173,9 +98,8 @@ func (d *Mysql) Init() error {
 	})
 	l.Debug("Initializing mysql client")
 	var err error
-	connStr := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", d.User, d.Pass, d.Host, d.Port, d.Db)
-	l.Debug("Connecting to mysql: ", connStr)
-	d.Client, err = sql.Open("mysql", connStr)
+	l.Debugf("Connecting to mysql: %s:%d", d.Host, d.Port)
+	d.Client, err = secrets.OpenDB("mysql", d.connStr)
 	if err != nil {
 		l.Error(err)
 		return err
@@ -244,6 +168,10 @@ func (d *Mysql) GetWork() (io.Reader, error) {
 	return strings.NewReader(result), nil
 }
 
+func (d *Mysql) SetResult(r *schema.JobResult) {
+	d.result = r
+}
+
 func (d *Mysql) ClearWork() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "mysql",
@@ -254,8 +182,8 @@ func (d *Mysql) ClearWork() error {
 	if d.ClearQuery == nil || d.ClearQuery.Query == "" {
 		return nil
 	}
-	d.ClearQuery.Params = schema.ReplaceParamsSliceMap(d.data, d.ClearQuery.Params)
-	_, err = d.Client.Exec(d.ClearQuery.Query, d.ClearQuery.Params...)
+	params := d.ClearQuery.RenderParams(d.data, d.result)
+	_, err = d.Client.Exec(d.ClearQuery.Query, params...)
 	if err != nil {
 		l.Error(err)
 		return err
@@ -274,8 +202,8 @@ func (d *Mysql) HandleFailure() error {
 	if d.FailQuery == nil || d.FailQuery.Query == "" {
 		return nil
 	}
-	d.FailQuery.Params = schema.ReplaceParamsSliceMap(d.data, d.FailQuery.Params)
-	_, err = d.Client.Exec(d.FailQuery.Query, d.FailQuery.Params...)
+	params := d.FailQuery.RenderParams(d.data, d.result)
+	_, err = d.Client.Exec(d.FailQuery.Query, params...)
 	if err != nil {
 		l.Error(err)
 		return err
@@ -284,6 +212,44 @@ func (d *Mysql) HandleFailure() error {
 	return nil
 }
 
+func (d *Mysql) PreviewClearWork() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "mysql",
+		"fn":  "PreviewClearWork",
+	})
+	l.Debug("Previewing clear work")
+	return schema.PreviewSqlQuery(d.data, d.ClearQuery), nil
+}
+
+func (d *Mysql) PreviewHandleFailure() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "mysql",
+		"fn":  "PreviewHandleFailure",
+	})
+	l.Debug("Previewing handle failure")
+	return schema.PreviewSqlQuery(d.data, d.FailQuery), nil
+}
+
+// Depth returns the backlog counted by the count query, which must return a
+// single integer.
+func (d *Mysql) Depth() (int64, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "mysql",
+		"fn":  "Depth",
+	})
+	l.Debug("Counting work")
+	if d.CountQuery == nil || d.CountQuery.Query == "" {
+		l.Error("CountQuery is nil or empty")
+		return 0, errors.New("CountQuery is nil or empty")
+	}
+	var n int64
+	if err := d.Client.QueryRow(d.CountQuery.Query, d.CountQuery.Params...).Scan(&n); err != nil {
+		l.Error(err)
+		return 0, err
+	}
+	return n, nil
+}
+
 func (d *Mysql) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "mysql",
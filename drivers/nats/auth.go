@@ -0,0 +1,43 @@
+package nats
+
+import (
+	"net"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"github.com/robertlestak/procx/pkg/secrets"
+)
+
+// secretDialer resolves the password secret referenced by ref each time the
+// client connects, so a rotated password is picked up when the client
+// reconnects. The client dials with its lock held, before it sends the
+// credentials in its options, so the resolved password is set in the options
+// of the bound connection.
+type secretDialer struct {
+	ref    string
+	dialer *net.Dialer
+	mu     sync.Mutex
+	nc     *nats.Conn
+}
+
+// bind sets the connection whose password is updated on reconnect. The
+// password of the first connection is set with nats.UserInfo.
+func (s *secretDialer) bind(nc *nats.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nc = nc
+}
+
+func (s *secretDialer) Dial(network, address string) (net.Conn, error) {
+	pass, err := secrets.Resolve(s.ref)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	nc := s.nc
+	s.mu.Unlock()
+	if nc != nil {
+		nc.Opts.Password = pass
+	}
+	return s.dialer.Dial(network, address)
+}
@@ -2,12 +2,15 @@ package nats
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
-	"os"
+	"net"
+	"strconv"
 
 	"github.com/nats-io/nats.go"
 	"github.com/robertlestak/procx/pkg/flags"
+	"github.com/robertlestak/procx/pkg/secrets"
 	"github.com/robertlestak/procx/pkg/utils"
 	log "github.com/sirupsen/logrus"
 )
@@ -31,6 +34,30 @@ type NATS struct {
 	ClearResponse *string
 	FailResponse  *string
 	Key           *string
+	deliveries    int
+	workID        string
+	dialer        *secretDialer
+}
+
+// load loads the driver options from the flags or the environment.
+func (d *NATS) load(v *flags.Values) error {
+	v.String(&d.URL, flags.NATSURL)
+	v.StringPtr(&d.Subject, flags.NATSSubject)
+	v.StringPtr(&d.CredsFile, flags.NATSCredsFile)
+	v.StringPtr(&d.JWTFile, flags.NATSJWTFile)
+	v.StringPtr(&d.NKeyFile, flags.NATSNKeyFile)
+	v.StringPtr(&d.Username, flags.NATSUsername)
+	v.StringPtr(&d.Password, flags.NATSPassword)
+	v.StringPtr(&d.QueueGroup, flags.NATSQueueGroup)
+	v.StringPtr(&d.Token, flags.NATSToken)
+	v.BoolPtr(&d.EnableTLS, flags.NATSEnableTLS)
+	v.BoolPtr(&d.TLSInsecure, flags.NATSTLSInsecure)
+	v.StringPtr(&d.TLSCA, flags.NATSTLSCAFile)
+	v.StringPtr(&d.TLSCert, flags.NATSTLSCertFile)
+	v.StringPtr(&d.TLSKey, flags.NATSTLSKeyFile)
+	v.StringPtr(&d.ClearResponse, flags.NATSClearResponse)
+	v.StringPtr(&d.FailResponse, flags.NATSFailResponse)
+	return v.Err()
 }
 
 func (d *NATS) LoadEnv(prefix string) error {
@@ -39,70 +66,7 @@ func (d *NATS) LoadEnv(prefix string) error {
 		"fn":  "LoadEnv",
 	})
 	l.Debug("Loading environment")
-	if os.Getenv(prefix+"NATS_URL") != "" {
-		d.URL = os.Getenv(prefix + "NATS_URL")
-	}
-	if os.Getenv(prefix+"NATS_SUBJECT") != "" {
-		v := os.Getenv(prefix + "NATS_SUBJECT")
-		d.Subject = &v
-	}
-	if os.Getenv(prefix+"NATS_CREDS_FILE") != "" {
-		v := os.Getenv(prefix + "NATS_CREDS_FILE")
-		d.CredsFile = &v
-	}
-	if os.Getenv(prefix+"NATS_JWT_FILE") != "" {
-		v := os.Getenv(prefix + "NATS_JWT_FILE")
-		d.JWTFile = &v
-	}
-	if os.Getenv(prefix+"NATS_NKEY_FILE") != "" {
-		v := os.Getenv(prefix + "NATS_NKEY_FILE")
-		d.NKeyFile = &v
-	}
-	if os.Getenv(prefix+"NATS_USERNAME") != "" {
-		v := os.Getenv(prefix + "NATS_USERNAME")
-		d.Username = &v
-	}
-	if os.Getenv(prefix+"NATS_PASSWORD") != "" {
-		v := os.Getenv(prefix + "NATS_PASSWORD")
-		d.Password = &v
-	}
-	if os.Getenv(prefix+"NATS_TOKEN") != "" {
-		v := os.Getenv(prefix + "NATS_TOKEN")
-		d.Token = &v
-	}
-	if os.Getenv(prefix+"NATS_ENABLE_TLS") != "" {
-		v := os.Getenv(prefix+"NATS_ENABLE_TLS") == "true"
-		d.EnableTLS = &v
-	}
-	if os.Getenv(prefix+"NATS_TLS_INSECURE") != "" {
-		v := os.Getenv(prefix+"NATS_TLS_INSECURE") == "true"
-		d.TLSInsecure = &v
-	}
-	if os.Getenv(prefix+"NATS_TLS_CA_FILE") != "" {
-		v := os.Getenv(prefix + "NATS_TLS_CA_FILE")
-		d.TLSCA = &v
-	}
-	if os.Getenv(prefix+"NATS_TLS_CERT_FILE") != "" {
-		v := os.Getenv(prefix + "NATS_TLS_CERT_FILE")
-		d.TLSCert = &v
-	}
-	if os.Getenv(prefix+"NATS_TLS_KEY_FILE") != "" {
-		v := os.Getenv(prefix + "NATS_TLS_KEY_FILE")
-		d.TLSKey = &v
-	}
-	if os.Getenv(prefix+"NATS_CLEAR_RESPONSE") != "" {
-		v := os.Getenv(prefix + "NATS_CLEAR_RESPONSE")
-		d.ClearResponse = &v
-	}
-	if os.Getenv(prefix+"NATS_FAIL_RESPONSE") != "" {
-		v := os.Getenv(prefix + "NATS_FAIL_RESPONSE")
-		d.FailResponse = &v
-	}
-	if os.Getenv(prefix+"NATS_QUEUE_GROUP") != "" {
-		v := os.Getenv(prefix + "NATS_QUEUE_GROUP")
-		d.QueueGroup = &v
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *NATS) LoadFlags() error {
@@ -111,54 +75,68 @@ func (d *NATS) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	d.URL = *flags.NATSURL
-	d.Subject = flags.NATSSubject
-	d.CredsFile = flags.NATSCredsFile
-	d.JWTFile = flags.NATSJWTFile
-	d.NKeyFile = flags.NATSNKeyFile
-	d.Username = flags.NATSUsername
-	d.Password = flags.NATSPassword
-	d.QueueGroup = flags.NATSQueueGroup
-	d.Token = flags.NATSToken
-	d.EnableTLS = flags.NATSEnableTLS
-	d.TLSInsecure = flags.NATSTLSInsecure
-	d.TLSCA = flags.NATSTLSCAFile
-	d.TLSCert = flags.NATSTLSCertFile
-	d.TLSKey = flags.NATSTLSKeyFile
-	d.ClearResponse = flags.NATSClearResponse
-	d.FailResponse = flags.NATSFailResponse
-	l.Debug("Loaded flags")
-	return nil
+	return d.load(flags.FlagValues())
 }
 
-func (d *NATS) authOpts() []nats.Option {
+func (d *NATS) authOpts() ([]nats.Option, error) {
 	l := log.WithFields(log.Fields{
 		"pkg": "nats",
 		"fn":  "authOpts",
 	})
 	l.Debug("Creating auth options")
 	opts := []nats.Option{}
+	d.dialer = nil
 	if d.CredsFile != nil && *d.CredsFile != "" {
 		l.Debug("Enabling creds file")
 		opts = append(opts, nats.UserCredentials(*d.CredsFile))
 	}
 	if d.Username != nil && *d.Username != "" {
 		l.Debug("Enabling username")
-		opts = append(opts, nats.UserInfo(*d.Username, *d.Password))
+		pass, err := secrets.ResolvePtr(d.Password)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, nats.UserInfo(*d.Username, *pass))
+		if d.Password != nil && secrets.IsRef(*d.Password) {
+			// resolve the password on each reconnect, so a rotated password
+			// is picked up
+			d.dialer = &secretDialer{
+				ref:    *d.Password,
+				dialer: &net.Dialer{Timeout: nats.GetDefaultOptions().Timeout},
+			}
+			opts = append(opts, nats.SetCustomDialer(d.dialer))
+		}
 	}
 	if d.Token != nil && *d.Token != "" {
 		l.Debug("Enabling token")
-		opts = append(opts, nats.Token(*d.Token))
+		if secrets.IsRef(*d.Token) {
+			// resolve the token on each connect, so a rotated token is
+			// picked up when the client reconnects
+			ref := *d.Token
+			opts = append(opts, nats.TokenHandler(func() string {
+				t, err := secrets.Resolve(ref)
+				if err != nil {
+					l.Errorf("%+v", err)
+				}
+				return t
+			}))
+		} else {
+			opts = append(opts, nats.Token(*d.Token))
+		}
 	}
 	if d.JWTFile != nil && *d.JWTFile != "" && d.NKeyFile != nil && *d.NKeyFile != "" {
 		l.Debug("Enabling JWT file")
 		opts = append(opts, nats.UserCredentials(*d.JWTFile, *d.NKeyFile))
 	}
 	l.Debug("Created auth options")
-	return opts
+	return opts, nil
 }
 
 func (d *NATS) Init() error {
+	return d.InitContext(context.Background())
+}
+
+func (d *NATS) InitContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "nats",
 		"fn":  "Init",
@@ -178,17 +156,29 @@ func (d *NATS) Init() error {
 		}
 		opts = append(opts, nats.Secure(tc))
 	}
-	opts = append(opts, d.authOpts()...)
+	ao, err := d.authOpts()
+	if err != nil {
+		l.Errorf("%+v", err)
+		return err
+	}
+	opts = append(opts, ao...)
 	nc, err := nats.Connect(d.URL, opts...)
 	if err != nil {
 		l.Errorf("error connecting to nats: %v", err)
 		return err
 	}
+	if d.dialer != nil {
+		d.dialer.bind(nc)
+	}
 	d.Client = nc
 	return nil
 }
 
 func (d *NATS) GetWork() (io.Reader, error) {
+	return d.GetWorkContext(context.Background())
+}
+
+func (d *NATS) GetWorkContext(ctx context.Context) (io.Reader, error) {
 	l := log.WithFields(log.Fields{
 		"pkg": "nats",
 		"fn":  "GetWork",
@@ -208,7 +198,13 @@ func (d *NATS) GetWork() (io.Reader, error) {
 		return nil, err
 	}
 	defer sub.Unsubscribe()
-	msg := <-ch
+	var msg *nats.Msg
+	select {
+	case msg = <-ch:
+	case <-ctx.Done():
+		l.Debug("Context done")
+		return nil, ctx.Err()
+	}
 	l.Debug("Got work from nats")
 	if msg == nil {
 		l.Debug("No work found")
@@ -218,10 +214,32 @@ func (d *NATS) GetWork() (io.Reader, error) {
 		v := msg.Reply
 		d.Key = &v
 	}
+	// messages delivered by a JetStream consumer carry their delivery count
+	// and stream sequence in the reply subject
+	d.deliveries, d.workID = 0, ""
+	if md, err := msg.Metadata(); err == nil {
+		d.deliveries = int(md.NumDelivered)
+		d.workID = md.Stream + ":" + strconv.FormatUint(md.Sequence.Stream, 10)
+	}
 	return bytes.NewReader(msg.Data), nil
 }
 
+// DeliveryCount returns the number of times the current JetStream message has
+// been delivered, or 0 if it was not delivered by JetStream.
+func (d *NATS) DeliveryCount() int {
+	return d.deliveries
+}
+
+// WorkID returns the stream and sequence of the current JetStream message.
+func (d *NATS) WorkID() string {
+	return d.workID
+}
+
 func (d *NATS) ClearWork() error {
+	return d.ClearWorkContext(context.Background())
+}
+
+func (d *NATS) ClearWorkContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "nats",
 		"fn":  "ClearWork",
@@ -245,6 +263,10 @@ func (d *NATS) ClearWork() error {
 }
 
 func (d *NATS) HandleFailure() error {
+	return d.HandleFailureContext(context.Background())
+}
+
+func (d *NATS) HandleFailureContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "nats",
 		"fn":  "HandleFailure",
@@ -268,6 +290,10 @@ func (d *NATS) HandleFailure() error {
 }
 
 func (d *NATS) Cleanup() error {
+	return d.CleanupContext(context.Background())
+}
+
+func (d *NATS) CleanupContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "nats",
 		"fn":  "Cleanup",
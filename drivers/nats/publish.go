@@ -0,0 +1,38 @@
+package nats
+
+import (
+	"github.com/nats-io/nats.go"
+	log "github.com/sirupsen/logrus"
+)
+
+func (d *NATS) InitPublisher() error {
+	return d.Init()
+}
+
+// Publish publishes body to the subject with meta as the message headers, and
+// flushes the connection so the message has reached the server.
+func (d *NATS) Publish(body []byte, meta map[string]string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "nats",
+		"fn":  "Publish",
+	})
+	l.Debug("Publishing to nats")
+	m := nats.NewMsg(*d.Subject)
+	m.Data = body
+	for k, v := range meta {
+		m.Header.Set(k, v)
+	}
+	if err := d.Client.PublishMsg(m); err != nil {
+		l.Errorf("%+v", err)
+		return err
+	}
+	if err := d.Client.Flush(); err != nil {
+		l.Errorf("%+v", err)
+		return err
+	}
+	return nil
+}
+
+func (d *NATS) ClosePublisher() error {
+	return d.Cleanup()
+}
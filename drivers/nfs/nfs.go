@@ -54,57 +54,44 @@ func (o *S3Op) GetKey() string {
 	return strings.ReplaceAll(o.KeyTemplate, "{{key}}", o.Key)
 }
 
+// load loads the driver options from the flags or the environment.
+func (d *NFS) load(v *flags.Values) error {
+	v.String(&d.Host, flags.NFSHost)
+	v.String(&d.Target, flags.NFSTarget)
+	v.String(&d.Folder, flags.NFSFolder)
+	v.String(&d.Key, flags.NFSKey)
+	v.String(&d.KeyRegex, flags.NFSKeyRegex)
+	v.String(&d.KeyPrefix, flags.NFSKeyPrefix)
+	if d.ClearOp == nil {
+		d.ClearOp = &S3Op{}
+	}
+	v.Func(flags.NFSClearOp, func(s string) error {
+		d.ClearOp.Operation = S3Operation(s)
+		return nil
+	})
+	v.String(&d.ClearOp.Bucket, flags.NFSClearFolder)
+	v.String(&d.ClearOp.Key, flags.NFSClearKey)
+	v.String(&d.ClearOp.KeyTemplate, flags.NFSClearKeyTemplate)
+	if d.FailOp == nil {
+		d.FailOp = &S3Op{}
+	}
+	v.Func(flags.NFSFailOp, func(s string) error {
+		d.FailOp.Operation = S3Operation(s)
+		return nil
+	})
+	v.String(&d.FailOp.Bucket, flags.NFSFailFolder)
+	v.String(&d.FailOp.Key, flags.NFSFailKey)
+	v.String(&d.FailOp.KeyTemplate, flags.NFSFailKeyTemplate)
+	return v.Err()
+}
+
 func (d *NFS) LoadEnv(prefix string) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "nfs",
 		"fn":  "LoadEnv",
 	})
 	l.Debug("LoadEnv")
-	if os.Getenv(prefix+"NFS_HOST") != "" {
-		d.Host = os.Getenv(prefix + "NFS_HOST")
-	}
-	if os.Getenv(prefix+"NFS_KEY") != "" {
-		d.Key = os.Getenv(prefix + "NFS_KEY")
-	}
-	if os.Getenv(prefix+"NFS_KEY_PREFIX") != "" {
-		d.KeyPrefix = os.Getenv(prefix + "NFS_KEY_PREFIX")
-	}
-	if os.Getenv(prefix+"NFS_KEY_REGEX") != "" {
-		d.KeyRegex = os.Getenv(prefix + "NFS_KEY_REGEX")
-	}
-	if os.Getenv(prefix+"NFS_FOLDER") != "" {
-		d.Folder = os.Getenv(prefix + "NFS_FOLDER")
-	}
-	if os.Getenv(prefix+"NFS_TARGET") != "" {
-		d.Target = os.Getenv(prefix + "NFS_TARGET")
-	}
-	if os.Getenv(prefix+"NFS_CLEAR_OP") != "" {
-		d.ClearOp = &S3Op{}
-		d.ClearOp.Operation = S3Operation(os.Getenv(prefix + "NFS_CLEAR_OP"))
-	}
-	if os.Getenv(prefix+"NFS_FAIL_OP") != "" {
-		d.FailOp = &S3Op{}
-		d.FailOp.Operation = S3Operation(os.Getenv(prefix + "NFS_FAIL_OP"))
-	}
-	if os.Getenv(prefix+"NFS_CLEAR_FOLDER") != "" {
-		d.ClearOp.Bucket = os.Getenv(prefix + "NFS_CLEAR_FOLDER")
-	}
-	if os.Getenv(prefix+"NFS_CLEAR_KEY") != "" {
-		d.ClearOp.Key = os.Getenv(prefix + "NFS_CLEAR_KEY")
-	}
-	if os.Getenv(prefix+"NFS_CLEAR_KEY_TEMPLATE") != "" {
-		d.ClearOp.KeyTemplate = os.Getenv(prefix + "NFS_CLEAR_KEY_TEMPLATE")
-	}
-	if os.Getenv(prefix+"NFS_FAIL_FOLDER") != "" {
-		d.FailOp.Bucket = os.Getenv(prefix + "NFS_FAIL_FOLDER")
-	}
-	if os.Getenv(prefix+"NFS_FAIL_KEY") != "" {
-		d.FailOp.Key = os.Getenv(prefix + "NFS_FAIL_KEY")
-	}
-	if os.Getenv(prefix+"NFS_FAIL_KEY_TEMPLATE") != "" {
-		d.FailOp.KeyTemplate = os.Getenv(prefix + "NFS_FAIL_KEY_TEMPLATE")
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *NFS) LoadFlags() error {
@@ -113,31 +100,7 @@ func (d *NFS) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("LoadFlags")
-	d.Host = *flags.NFSHost
-	d.Target = *flags.NFSTarget
-	d.Folder = *flags.NFSFolder
-	d.Key = *flags.NFSKey
-	if flags.NFSKeyRegex != nil {
-		d.KeyRegex = *flags.NFSKeyRegex
-	}
-	if flags.NFSKeyPrefix != nil {
-		d.KeyPrefix = *flags.NFSKeyPrefix
-	}
-	if flags.NFSClearOp != nil {
-		d.ClearOp = &S3Op{}
-		d.ClearOp.Operation = S3Operation(*flags.NFSClearOp)
-		d.ClearOp.Bucket = *flags.NFSClearFolder
-		d.ClearOp.Key = *flags.NFSClearKey
-		d.ClearOp.KeyTemplate = *flags.NFSClearKeyTemplate
-	}
-	if flags.NFSFailOp != nil {
-		d.FailOp = &S3Op{}
-		d.FailOp.Operation = S3Operation(*flags.NFSFailOp)
-		d.FailOp.Bucket = *flags.NFSFailFolder
-		d.FailOp.Key = *flags.NFSFailKey
-		d.FailOp.KeyTemplate = *flags.NFSFailKeyTemplate
-	}
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func hostname() string {
@@ -2,6 +2,7 @@ package nsq
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"os"
@@ -18,7 +19,11 @@ type NSQ struct {
 	NsqdAddress       *string
 	Topic             *string
 	Channel           *string
-	data              chan []byte
+	data              chan *nsq.Message
+	attempts          int
+	messageID         string
+	done              chan struct{}
+	producer          *nsq.Producer
 	// TLS
 	EnableTLS   *bool
 	TLSInsecure *bool
@@ -27,50 +32,27 @@ type NSQ struct {
 	TLSCA       *string
 }
 
+// load loads the driver options from the flags or the environment.
+func (d *NSQ) load(v *flags.Values) error {
+	v.StringPtr(&d.NsqLookupdAddress, flags.NSQNSQLookupdAddress)
+	v.StringPtr(&d.NsqdAddress, flags.NSQNSQDAddress)
+	v.StringPtr(&d.Topic, flags.NSQTopic)
+	v.StringPtr(&d.Channel, flags.NSQChannel)
+	v.BoolPtr(&d.EnableTLS, flags.NSQEnableTLS)
+	v.BoolPtr(&d.TLSInsecure, flags.NSQTLSInsecure)
+	v.StringPtr(&d.TLSCert, flags.NSQCertFile)
+	v.StringPtr(&d.TLSKey, flags.NSQKeyFile)
+	v.StringPtr(&d.TLSCA, flags.NSQCAFile)
+	return v.Err()
+}
+
 func (d *NSQ) LoadEnv(prefix string) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "nsq",
 		"fn":  "LoadEnv",
 	})
 	l.Debug("Loading environment")
-	if os.Getenv(prefix+"NSQ_NSQLOOKUPD_ADDRESS") != "" {
-		v := os.Getenv(prefix + "NSQ_NSQLOOKUPD_ADDRESS")
-		d.NsqLookupdAddress = &v
-	}
-	if os.Getenv(prefix+"NSQ_NSQD_ADDRESS") != "" {
-		v := os.Getenv(prefix + "NSQ_NSQD_ADDRESS")
-		d.NsqdAddress = &v
-	}
-	if os.Getenv(prefix+"NSQ_TOPIC") != "" {
-		v := os.Getenv(prefix + "NSQ_TOPIC")
-		d.Topic = &v
-	}
-	if os.Getenv(prefix+"NSQ_CHANNEL") != "" {
-		v := os.Getenv(prefix + "NSQ_CHANNEL")
-		d.Channel = &v
-	}
-	if os.Getenv(prefix+"NSQ_ENABLE_TLS") != "" {
-		v := os.Getenv(prefix+"NSQ_ENABLE_TLS") == "true"
-		d.EnableTLS = &v
-	}
-	if os.Getenv(prefix+"NSQ_TLS_INSECURE") != "" {
-		v := os.Getenv(prefix+"NSQ_TLS_INSECURE") == "true"
-		d.TLSInsecure = &v
-	}
-	if os.Getenv(prefix+"NSQ_TLS_CERT_FILE") != "" {
-		v := os.Getenv(prefix + "NSQ_TLS_CERT_FILE")
-		d.TLSCert = &v
-	}
-	if os.Getenv(prefix+"NSQ_TLS_KEY_FILE") != "" {
-		v := os.Getenv(prefix + "NSQ_TLS_KEY_FILE")
-		d.TLSKey = &v
-	}
-	if os.Getenv(prefix+"NSQ_TLS_CA_FILE") != "" {
-		v := os.Getenv(prefix + "NSQ_TLS_CA_FILE")
-		d.TLSCA = &v
-	}
-	l.Debug("Loaded environment")
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *NSQ) LoadFlags() error {
@@ -79,20 +61,14 @@ func (d *NSQ) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	d.NsqLookupdAddress = flags.NSQNSQLookupdAddress
-	d.NsqdAddress = flags.NSQNSQDAddress
-	d.Topic = flags.NSQTopic
-	d.Channel = flags.NSQChannel
-	d.EnableTLS = flags.NSQEnableTLS
-	d.TLSInsecure = flags.NSQTLSSkipVerify
-	d.TLSCert = flags.NSQCertFile
-	d.TLSKey = flags.NSQKeyFile
-	d.TLSCA = flags.NSQCAFile
-	l.Debug("Loaded flags")
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *NSQ) Init() error {
+	return d.InitContext(context.Background())
+}
+
+func (d *NSQ) InitContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "nsq",
 		"fn":  "Init",
@@ -133,17 +109,39 @@ func (d *NSQ) Init() error {
 }
 
 func (d *NSQ) handleMessage(msg *nsq.Message) error {
-	d.data <- msg.Body
-	return nil
+	select {
+	case d.data <- msg:
+		return nil
+	case <-d.done:
+		// returning an error requeues the message
+		return errors.New("consumer stopped")
+	}
+}
+
+// stop releases any message blocked in handleMessage.
+func (d *NSQ) stop() {
+	if d.done == nil {
+		return
+	}
+	select {
+	case <-d.done:
+	default:
+		close(d.done)
+	}
 }
 
 func (d *NSQ) GetWork() (io.Reader, error) {
+	return d.GetWorkContext(context.Background())
+}
+
+func (d *NSQ) GetWorkContext(ctx context.Context) (io.Reader, error) {
 	l := log.WithFields(log.Fields{
 		"pkg": "nsq",
 		"fn":  "GetWork",
 	})
 	l.Debug("Getting work from nsq")
-	d.data = make(chan []byte)
+	d.data = make(chan *nsq.Message)
+	d.done = make(chan struct{})
 	d.Client.AddHandler(nsq.HandlerFunc(d.handleMessage))
 	var err error
 	if d.NsqLookupdAddress != nil && *d.NsqLookupdAddress != "" {
@@ -157,12 +155,25 @@ func (d *NSQ) GetWork() (io.Reader, error) {
 		l.Errorf("%+v", err)
 		return nil, err
 	}
-	msg := <-d.data
+	var msg *nsq.Message
+	select {
+	case msg = <-d.data:
+	case <-ctx.Done():
+		l.Debug("Context done")
+		d.stop()
+		return nil, ctx.Err()
+	}
 	l.Debug("Got work")
-	return bytes.NewReader(msg), nil
+	d.attempts = int(msg.Attempts)
+	d.messageID = string(msg.ID[:])
+	return bytes.NewReader(msg.Body), nil
 }
 
 func (d *NSQ) ClearWork() error {
+	return d.ClearWorkContext(context.Background())
+}
+
+func (d *NSQ) ClearWorkContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "nsq",
 		"fn":  "ClearWork",
@@ -173,6 +184,10 @@ func (d *NSQ) ClearWork() error {
 }
 
 func (d *NSQ) HandleFailure() error {
+	return d.HandleFailureContext(context.Background())
+}
+
+func (d *NSQ) HandleFailureContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "nsq",
 		"fn":  "HandleFailure",
@@ -182,12 +197,27 @@ func (d *NSQ) HandleFailure() error {
 	return nil
 }
 
+// DeliveryCount returns the number of attempts of the current message.
+func (d *NSQ) DeliveryCount() int {
+	return d.attempts
+}
+
+// WorkID returns the message ID of the current message.
+func (d *NSQ) WorkID() string {
+	return d.messageID
+}
+
 func (d *NSQ) Cleanup() error {
+	return d.CleanupContext(context.Background())
+}
+
+func (d *NSQ) CleanupContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "nsq",
 		"fn":  "Cleanup",
 	})
 	l.Debug("Cleaning up")
+	d.stop()
 	d.Client.Stop()
 	<-d.Client.StopChan
 	l.Debug("Cleaned up")
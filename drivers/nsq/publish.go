@@ -0,0 +1,65 @@
+package nsq
+
+import (
+	"errors"
+
+	nsq "github.com/nsqio/go-nsq"
+	"github.com/robertlestak/procx/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// InitPublisher connects a producer to the nsqd address.
+func (d *NSQ) InitPublisher() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "nsq",
+		"fn":  "InitPublisher",
+	})
+	l.Debug("Initializing nsq publisher")
+	if d.NsqdAddress == nil || *d.NsqdAddress == "" {
+		l.Error("nsqd address is empty")
+		return errors.New("nsqd address is empty")
+	}
+	cfg := nsq.NewConfig()
+	if d.EnableTLS != nil && *d.EnableTLS {
+		cfg.TlsV1 = true
+		t, err := utils.TlsConfig(d.EnableTLS, d.TLSInsecure, d.TLSCA, d.TLSCert, d.TLSKey)
+		if err != nil {
+			l.Errorf("%+v", err)
+			return err
+		}
+		cfg.TlsConfig = t
+	}
+	p, err := nsq.NewProducer(*d.NsqdAddress, cfg)
+	if err != nil {
+		l.Errorf("%+v", err)
+		return err
+	}
+	p.SetLoggerLevel(nsq.LogLevelError)
+	if err := p.Ping(); err != nil {
+		l.Errorf("%+v", err)
+		p.Stop()
+		return err
+	}
+	d.producer = p
+	return nil
+}
+
+// Publish publishes body to the topic. NSQ messages have no metadata, so meta
+// is not sent.
+func (d *NSQ) Publish(body []byte, meta map[string]string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "nsq",
+		"fn":  "Publish",
+	})
+	l.Debug("Publishing to nsq")
+	if err := d.producer.Publish(*d.Topic, body); err != nil {
+		l.Errorf("%+v", err)
+		return err
+	}
+	return nil
+}
+
+func (d *NSQ) ClosePublisher() error {
+	d.producer.Stop()
+	return nil
+}
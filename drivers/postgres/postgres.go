@@ -6,13 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"strconv"
 	"strings"
 
 	_ "github.com/lib/pq"
 	"github.com/robertlestak/procx/pkg/flags"
 	"github.com/robertlestak/procx/pkg/schema"
+	"github.com/robertlestak/procx/pkg/secrets"
 	"github.com/tidwall/gjson"
 
 	log "github.com/sirupsen/logrus"
@@ -33,176 +32,77 @@ type Postgres struct {
 	RetrieveQuery *schema.SqlQuery
 	ClearQuery    *schema.SqlQuery
 	FailQuery     *schema.SqlQuery
+	CountQuery    *schema.SqlQuery
 	data          []map[string]any
+	result        *schema.JobResult
 }
 
-func (d *Postgres) LoadEnv(prefix string) error {
-	l := log.WithFields(log.Fields{
-		"pkg": "postgres",
-		"fn":  "LoadEnv",
-	})
-	l.Debug("Loading environment variables")
-	if os.Getenv(prefix+"PSQL_HOST") != "" {
-		d.Host = os.Getenv(prefix + "PSQL_HOST")
-	}
-	if os.Getenv(prefix+"PSQL_PORT") != "" {
-		pval, err := strconv.Atoi(os.Getenv(prefix + "PSQL_PORT"))
-		if err != nil {
-			return err
-		}
-		d.Port = pval
-	}
-	if os.Getenv(prefix+"PSQL_USER") != "" {
-		d.User = os.Getenv(prefix + "PSQL_USER")
-	}
-	if os.Getenv(prefix+"PSQL_PASSWORD") != "" {
-		d.Pass = os.Getenv(prefix + "PSQL_PASSWORD")
-	}
-	if os.Getenv(prefix+"PSQL_DATABASE") != "" {
-		d.Db = os.Getenv(prefix + "PSQL_DATABASE")
-	}
-	if os.Getenv(prefix+"PSQL_SSL_MODE") != "" {
-		d.SslMode = os.Getenv(prefix + "PSQL_SSL_MODE")
-	}
-	if os.Getenv(prefix+"PSQL_TLS_ROOT_CERT") != "" {
-		v := os.Getenv(prefix + "PSQL_TLS_ROOT_CERT")
-		d.SSLRootCert = &v
-	}
-	if os.Getenv(prefix+"PSQL_TLS_CERT") != "" {
-		v := os.Getenv(prefix + "PSQL_TLS_CERT")
-		d.SSLCert = &v
-	}
-	if os.Getenv(prefix+"PSQL_TLS_KEY") != "" {
-		v := os.Getenv(prefix + "PSQL_TLS_KEY")
-		d.SSLKey = &v
-	}
+// load loads the driver options from the flags or the environment.
+func (d *Postgres) load(v *flags.Values) error {
+	v.String(&d.Host, flags.PsqlHost)
+	v.Int(&d.Port, flags.PsqlPort)
+	v.String(&d.User, flags.PsqlUser)
+	v.String(&d.Pass, flags.PsqlPassword)
+	v.String(&d.Db, flags.PsqlDatabase)
+	v.String(&d.SslMode, flags.PsqlSSLMode)
+	v.StringPtr(&d.SSLRootCert, flags.PsqlTLSRootCert)
+	v.StringPtr(&d.SSLCert, flags.PsqlTLSCert)
+	v.StringPtr(&d.SSLKey, flags.PsqlTLSKey)
+	v.StringPtr(&d.RetrieveField, flags.PsqlRetrieveField)
 	if d.RetrieveQuery == nil {
 		d.RetrieveQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"PSQL_RETRIEVE_QUERY") != "" {
-		d.RetrieveQuery.Query = os.Getenv(prefix + "PSQL_RETRIEVE_QUERY")
-	}
+	v.String(&d.RetrieveQuery.Query, flags.PsqlRetrieveQuery)
+	v.Params(&d.RetrieveQuery.Params, flags.PsqlRetrieveParams)
 	if d.ClearQuery == nil {
 		d.ClearQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"PSQL_CLEAR_QUERY") != "" {
-		d.ClearQuery.Query = os.Getenv(prefix + "PSQL_CLEAR_QUERY")
-	}
+	v.String(&d.ClearQuery.Query, flags.PsqlClearQuery)
+	v.Params(&d.ClearQuery.Params, flags.PsqlClearParams)
 	if d.FailQuery == nil {
 		d.FailQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"PSQL_FAIL_QUERY") != "" {
-		d.FailQuery.Query = os.Getenv(prefix + "PSQL_FAIL_QUERY")
-	}
-	if os.Getenv(prefix+"PSQL_RETRIEVE_PARAMS") != "" {
-		p := strings.Split(os.Getenv(prefix+"PSQL_RETRIEVE_PARAMS"), ",")
-		for _, v := range p {
-			d.RetrieveQuery.Params = append(d.RetrieveQuery.Params, v)
-		}
-	}
-	if os.Getenv(prefix+"PSQL_CLEAR_PARAMS") != "" {
-		p := strings.Split(os.Getenv(prefix+"PSQL_CLEAR_PARAMS"), ",")
-		for _, v := range p {
-			d.ClearQuery.Params = append(d.ClearQuery.Params, v)
-		}
-	}
-	if os.Getenv(prefix+"PSQL_FAIL_PARAMS") != "" {
-		p := strings.Split(os.Getenv(prefix+"PSQL_FAIL_PARAMS"), ",")
-		for _, v := range p {
-			d.FailQuery.Params = append(d.FailQuery.Params, v)
-		}
+	v.String(&d.FailQuery.Query, flags.PsqlFailQuery)
+	v.Params(&d.FailQuery.Params, flags.PsqlFailParams)
+	if d.CountQuery == nil {
+		d.CountQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"PSQL_RETRIEVE_FIELD") != "" {
-		v := os.Getenv(prefix + "PSQL_RETRIEVE_FIELD")
-		d.RetrieveField = &v
-	}
-	return nil
+	v.String(&d.CountQuery.Query, flags.PsqlCountQuery)
+	v.Params(&d.CountQuery.Params, flags.PsqlCountParams)
+	return v.Err()
 }
 
-func (d *Postgres) LoadFlags() error {
+func (d *Postgres) LoadEnv(prefix string) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "postgres",
-		"fn":  "LoadFlags",
+		"fn":  "LoadEnv",
 	})
-	l.Debug("Loading flags")
-	pv, err := strconv.Atoi(*flags.PsqlPort)
-	if err != nil {
-		return err
-	}
-	var rps []any
-	var cps []any
-	var fps []any
-	if *flags.PsqlRetrieveParams != "" {
-		s := strings.Split(*flags.PsqlRetrieveParams, ",")
-		for _, v := range s {
-			rps = append(rps, v)
-		}
-	}
-	if *flags.PsqlClearParams != "" {
-		s := strings.Split(*flags.PsqlClearParams, ",")
-		for _, v := range s {
-			cps = append(cps, v)
-		}
-	}
-	if *flags.PsqlFailParams != "" {
-		s := strings.Split(*flags.PsqlFailParams, ",")
-		for _, v := range s {
-			fps = append(fps, v)
-		}
-	}
-	d.Host = *flags.PsqlHost
-	d.Port = pv
-	d.User = *flags.PsqlUser
-	d.Pass = *flags.PsqlPassword
-	d.Db = *flags.PsqlDatabase
-	d.SslMode = *flags.PsqlSSLMode
-	d.SSLRootCert = flags.PsqlTLSRootCert
-	d.SSLCert = flags.PsqlTLSCert
-	d.SSLKey = flags.PsqlTLSKey
-	d.RetrieveField = flags.PsqlRetrieveField
-	if d.RetrieveQuery == nil {
-		d.RetrieveQuery = &schema.SqlQuery{}
-	}
-	if *flags.PsqlRetrieveQuery != "" {
-		d.RetrieveQuery.Query = *flags.PsqlRetrieveQuery
-	}
-	if len(rps) > 0 {
-		d.RetrieveQuery.Params = rps
-	}
-	if d.ClearQuery == nil {
-		d.ClearQuery = &schema.SqlQuery{}
-	}
-	if *flags.PsqlClearQuery != "" {
-		d.ClearQuery.Query = *flags.PsqlClearQuery
-	}
-	if len(cps) > 0 {
-		d.ClearQuery.Params = cps
-	}
-	if d.FailQuery == nil {
-		d.FailQuery = &schema.SqlQuery{}
-	}
-	if *flags.PsqlFailQuery != "" {
-		d.FailQuery.Query = *flags.PsqlFailQuery
-	}
-	if len(fps) > 0 {
-		d.FailQuery.Params = fps
-	}
-	return nil
+	l.Debug("Loading environment variables")
+	return d.load(flags.EnvValues(prefix))
 }
 
-func (d *Postgres) Init() error {
+func (d *Postgres) LoadFlags() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "postgres",
-		"fn":  "Init",
+		"fn":  "LoadFlags",
 	})
-	l.Debug("Initializing psql client")
-	var err error
+	l.Debug("Loading flags")
+	return d.load(flags.FlagValues())
+}
+
+// connStr returns the connection string, resolving the password if it is a
+// secret reference.
+func (d *Postgres) connStr() (string, error) {
+	pass, err := secrets.Resolve(d.Pass)
+	if err != nil {
+		return "", err
+	}
 	var opts string
 	var connStr string = "postgresql://"
-	if d.User != "" && d.Pass != "" {
+	if d.User != "" && pass != "" {
 		connStr += fmt.Sprintf("%s:%s@%s:%d/%s",
-			d.User, d.Pass, d.Host, d.Port, d.Db)
-	} else if d.User != "" && d.Pass == "" {
+			d.User, pass, d.Host, d.Port, d.Db)
+	} else if d.User != "" && pass == "" {
 		connStr += fmt.Sprintf("%s@%s:%d/%s",
 			d.User, d.Host, d.Port, d.Db)
 	}
@@ -217,7 +117,17 @@ func (d *Postgres) Init() error {
 		connStr += "&sslkey=" + *d.SSLKey
 	}
 	connStr += opts
-	d.Client, err = sql.Open("postgres", connStr)
+	return connStr, nil
+}
+
+func (d *Postgres) Init() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "postgres",
+		"fn":  "Init",
+	})
+	l.Debug("Initializing psql client")
+	var err error
+	d.Client, err = secrets.OpenDB("postgres", d.connStr)
 	if err != nil {
 		l.Error(err)
 		return err
@@ -284,6 +194,10 @@ func (d *Postgres) GetWork() (io.Reader, error) {
 	return strings.NewReader(result), nil
 }
 
+func (d *Postgres) SetResult(r *schema.JobResult) {
+	d.result = r
+}
+
 func (d *Postgres) ClearWork() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "postgres",
@@ -294,8 +208,8 @@ func (d *Postgres) ClearWork() error {
 	if d.ClearQuery == nil || d.ClearQuery.Query == "" {
 		return nil
 	}
-	d.ClearQuery.Params = schema.ReplaceParamsSliceMap(d.data, d.ClearQuery.Params)
-	_, err = d.Client.Exec(d.ClearQuery.Query, d.ClearQuery.Params...)
+	params := d.ClearQuery.RenderParams(d.data, d.result)
+	_, err = d.Client.Exec(d.ClearQuery.Query, params...)
 	if err != nil {
 		l.Error(err)
 		return err
@@ -314,8 +228,8 @@ func (d *Postgres) HandleFailure() error {
 	if d.FailQuery == nil || d.FailQuery.Query == "" {
 		return nil
 	}
-	d.FailQuery.Params = schema.ReplaceParamsSliceMap(d.data, d.FailQuery.Params)
-	_, err = d.Client.Exec(d.FailQuery.Query, d.FailQuery.Params...)
+	params := d.FailQuery.RenderParams(d.data, d.result)
+	_, err = d.Client.Exec(d.FailQuery.Query, params...)
 	if err != nil {
 		l.Error(err)
 		return err
@@ -324,6 +238,44 @@ func (d *Postgres) HandleFailure() error {
 	return nil
 }
 
+func (d *Postgres) PreviewClearWork() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "postgres",
+		"fn":  "PreviewClearWork",
+	})
+	l.Debug("Previewing clear work")
+	return schema.PreviewSqlQuery(d.data, d.ClearQuery), nil
+}
+
+func (d *Postgres) PreviewHandleFailure() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "postgres",
+		"fn":  "PreviewHandleFailure",
+	})
+	l.Debug("Previewing handle failure")
+	return schema.PreviewSqlQuery(d.data, d.FailQuery), nil
+}
+
+// Depth returns the backlog counted by the count query, which must return a
+// single integer.
+func (d *Postgres) Depth() (int64, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "postgres",
+		"fn":  "Depth",
+	})
+	l.Debug("Counting work")
+	if d.CountQuery == nil || d.CountQuery.Query == "" {
+		l.Error("CountQuery is nil or empty")
+		return 0, errors.New("CountQuery is nil or empty")
+	}
+	var n int64
+	if err := d.Client.QueryRow(d.CountQuery.Query, d.CountQuery.Params...).Scan(&n); err != nil {
+		l.Error(err)
+		return 0, err
+	}
+	return n, nil
+}
+
 func (d *Postgres) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "postgres",
@@ -0,0 +1,57 @@
+package pulsar
+
+import (
+	"context"
+	"errors"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	log "github.com/sirupsen/logrus"
+)
+
+// InitPublisher creates a producer for the topic.
+func (d *Pulsar) InitPublisher() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "pulsar",
+		"fn":  "InitPublisher",
+	})
+	l.Debug("Initializing pulsar publisher")
+	if d.Topic == nil || *d.Topic == "" {
+		l.Error("No topic specified")
+		return errors.New("no topic specified")
+	}
+	if err := d.Init(); err != nil {
+		return err
+	}
+	p, err := d.Client.CreateProducer(pulsar.ProducerOptions{
+		Topic: *d.Topic,
+	})
+	if err != nil {
+		l.Errorf("%+v", err)
+		d.Client.Close()
+		return err
+	}
+	d.producer = p
+	return nil
+}
+
+// Publish sends body to the topic with meta as the message properties.
+func (d *Pulsar) Publish(body []byte, meta map[string]string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "pulsar",
+		"fn":  "Publish",
+	})
+	l.Debug("Publishing to pulsar")
+	if _, err := d.producer.Send(context.Background(), &pulsar.ProducerMessage{
+		Payload:    body,
+		Properties: meta,
+	}); err != nil {
+		l.Errorf("%+v", err)
+		return err
+	}
+	return nil
+}
+
+func (d *Pulsar) ClosePublisher() error {
+	d.producer.Close()
+	return d.Cleanup()
+}
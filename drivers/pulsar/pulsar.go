@@ -5,14 +5,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
-	"os"
-	"strings"
 	"time"
 
 	"github.com/apache/pulsar-client-go/pulsar"
 	pulsarlog "github.com/apache/pulsar-client-go/pulsar/log"
 	"github.com/robertlestak/procx/pkg/flags"
+	"github.com/robertlestak/procx/pkg/secrets"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -34,6 +34,32 @@ type Pulsar struct {
 	TLSValidateHostname        *bool
 	message                    pulsar.Message
 	consumer                   pulsar.Consumer
+	producer                   pulsar.Producer
+}
+
+// load loads the driver options from the flags or the environment.
+func (d *Pulsar) load(v *flags.Values) error {
+	v.String(&d.Address, flags.PulsarAddress)
+	v.StringPtr(&d.Subscription, flags.PulsarSubscription)
+	v.StringPtr(&d.Topic, flags.PulsarTopic)
+	v.StringPtr(&d.TopicsPattern, flags.PulsarTopicsPattern)
+	v.Strings(&d.Topics, flags.PulsarTopics)
+	v.StringPtr(&d.TLSTrustCertsFilePath, flags.PulsarTLSTrustCertsFilePath)
+	v.BoolPtr(&d.TLSAllowInsecureConnection, flags.PulsarTLSAllowInsecureConnection)
+	v.BoolPtr(&d.TLSValidateHostname, flags.PulsarTLSValidateHostname)
+	v.StringPtr(&d.AuthToken, flags.PulsarAuthToken)
+	v.StringPtr(&d.AuthTokenFile, flags.PulsarAuthTokenFile)
+	v.StringPtr(&d.AuthCertPath, flags.PulsarAuthCertFile)
+	v.StringPtr(&d.AuthKeyPath, flags.PulsarAuthKeyFile)
+	v.Func(flags.PulsarAuthOAuthParams, func(s string) error {
+		var m map[string]string
+		if err := json.Unmarshal([]byte(s), &m); err != nil {
+			return err
+		}
+		d.AuthOAuthParams = &m
+		return nil
+	})
+	return v.Err()
 }
 
 func (d *Pulsar) LoadEnv(prefix string) error {
@@ -42,63 +68,7 @@ func (d *Pulsar) LoadEnv(prefix string) error {
 		"fn":  "LoadEnv",
 	})
 	l.Debug("Loading environment")
-	if os.Getenv(prefix+"PULSAR_ADDRESS") != "" {
-		d.Address = os.Getenv(prefix + "PULSAR_ADDRESS")
-	}
-	if os.Getenv(prefix+"PULSAR_SUBSCRIPTION") != "" {
-		v := os.Getenv(prefix + "PULSAR_SUBSCRIPTION")
-		d.Subscription = &v
-	}
-	if os.Getenv(prefix+"PULSAR_TOPIC") != "" {
-		v := os.Getenv(prefix + "PULSAR_TOPIC")
-		d.Topic = &v
-	}
-	if os.Getenv(prefix+"PULSAR_TOPICS") != "" {
-		v := os.Getenv(prefix + "PULSAR_TOPICS")
-		d.Topics = strings.Split(v, ",")
-	}
-	if os.Getenv(prefix+"PULSAR_TOPICS_PATTERN") != "" {
-		v := os.Getenv(prefix + "PULSAR_TOPICS_PATTERN")
-		d.TopicsPattern = &v
-	}
-	if os.Getenv(prefix+"PULSAR_TLS_TRUST_CERTS_FILE") != "" {
-		v := os.Getenv(prefix + "PULSAR_TLS_TRUST_CERTS_FILE")
-		d.TLSTrustCertsFilePath = &v
-	}
-	if os.Getenv(prefix+"PULSAR_TLS_ALLOW_INSECURE_CONNECTION") != "" {
-		v := os.Getenv(prefix+"PULSAR_TLS_ALLOW_INSECURE_CONNECTION") == "true"
-		d.TLSAllowInsecureConnection = &v
-	}
-	if os.Getenv(prefix+"PULSAR_TLS_VALIDATE_HOSTNAME") != "" {
-		v := os.Getenv(prefix+"PULSAR_TLS_VALIDATE_HOSTNAME") == "true"
-		d.TLSValidateHostname = &v
-	}
-	if os.Getenv(prefix+"PULSAR_AUTH_TOKEN") != "" {
-		v := os.Getenv(prefix + "PULSAR_AUTH_TOKEN")
-		d.AuthToken = &v
-	}
-	if os.Getenv(prefix+"PULSAR_AUTH_TOKEN_FILE") != "" {
-		v := os.Getenv(prefix + "PULSAR_AUTH_TOKEN_FILE")
-		d.AuthTokenFile = &v
-	}
-	if os.Getenv(prefix+"PULSAR_AUTH_CERT_FILE") != "" {
-		v := os.Getenv(prefix + "PULSAR_AUTH_CERT_FILE")
-		d.AuthCertPath = &v
-	}
-	if os.Getenv(prefix+"PULSAR_AUTH_KEY_FILE") != "" {
-		v := os.Getenv(prefix + "PULSAR_AUTH_KEY_FILE")
-		d.AuthKeyPath = &v
-	}
-	if os.Getenv(prefix+"PULSAR_AUTH_OAUTH_PARAMS") != "" {
-		v := os.Getenv(prefix + "PULSAR_AUTH_OAUTH_PARAMS")
-		var m map[string]string
-		if err := json.Unmarshal([]byte(v), &m); err != nil {
-			return err
-		}
-		d.AuthOAuthParams = &m
-	}
-	l.Debug("Loaded environment")
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *Pulsar) LoadFlags() error {
@@ -107,34 +77,7 @@ func (d *Pulsar) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	d.Address = *flags.PulsarAddress
-	d.Subscription = flags.PulsarSubscription
-	d.Topic = flags.PulsarTopic
-	d.TopicsPattern = flags.PulsarTopicsPattern
-	ts := strings.Split(*flags.PulsarTopics, ",")
-	for _, t := range ts {
-		if t != "" {
-			nt := strings.TrimSpace(t)
-			d.Topics = append(d.Topics, nt)
-		}
-	}
-	d.TLSTrustCertsFilePath = flags.PulsarTLSTrustCertsFilePath
-	d.TLSAllowInsecureConnection = flags.PulsarTLSAllowInsecureConnection
-	d.TLSValidateHostname = flags.PulsarTLSValidateHostname
-	d.AuthToken = flags.PulsarAuthToken
-	d.AuthTokenFile = flags.PulsarAuthTokenFile
-	d.AuthCertPath = flags.PulsarAuthCertFile
-	d.AuthKeyPath = flags.PulsarAuthKeyFile
-	oauthParams := make(map[string]string)
-	if flags.PulsarAuthOAuthParams != nil && *flags.PulsarAuthOAuthParams != "" {
-		if err := json.Unmarshal([]byte(*flags.PulsarAuthOAuthParams), &oauthParams); err != nil {
-			l.WithError(err).Error("Failed to parse PulsarAuthOAuthParams")
-			return err
-		}
-		d.AuthOAuthParams = &oauthParams
-	}
-	l.Debug("Loaded flags")
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *Pulsar) Init() error {
@@ -161,7 +104,16 @@ func (d *Pulsar) Init() error {
 		up = "pulsar+ssl://"
 	}
 	if *d.AuthToken != "" {
-		opts.Authentication = pulsar.NewAuthenticationToken(*d.AuthToken)
+		if secrets.IsRef(*d.AuthToken) {
+			// the token is resolved each time it is used, so a rotated token
+			// is picked up when the client reconnects
+			ref := *d.AuthToken
+			opts.Authentication = pulsar.NewAuthenticationTokenFromSupplier(func() (string, error) {
+				return secrets.Resolve(ref)
+			})
+		} else {
+			opts.Authentication = pulsar.NewAuthenticationToken(*d.AuthToken)
+		}
 	}
 	if *d.AuthTokenFile != "" {
 		opts.Authentication = pulsar.NewAuthenticationTokenFromFile(*d.AuthTokenFile)
@@ -237,6 +189,29 @@ func (d *Pulsar) HandleFailure() error {
 	return nil
 }
 
+// Redelivers returns true, as HandleFailure nacks the message.
+func (d *Pulsar) Redelivers() bool {
+	return true
+}
+
+// DeliveryCount returns the number of times the current message has been
+// delivered, from its redelivery count.
+func (d *Pulsar) DeliveryCount() int {
+	if d.message == nil {
+		return 0
+	}
+	return int(d.message.RedeliveryCount()) + 1
+}
+
+// WorkID returns the message ID of the current message.
+func (d *Pulsar) WorkID() string {
+	if d.message == nil {
+		return ""
+	}
+	id := d.message.ID()
+	return fmt.Sprintf("%d:%d:%d:%d", id.LedgerID(), id.EntryID(), id.PartitionIdx(), id.BatchIdx())
+}
+
 func (d *Pulsar) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "pulsar",
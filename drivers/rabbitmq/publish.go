@@ -0,0 +1,41 @@
+package rabbitmq
+
+import (
+	amqp "github.com/rabbitmq/amqp091-go"
+	log "github.com/sirupsen/logrus"
+)
+
+func (d *RabbitMQ) InitPublisher() error {
+	return d.Init()
+}
+
+// Publish publishes body to the queue through the default exchange, with meta
+// as the message headers. The queue is not declared, and must exist.
+func (d *RabbitMQ) Publish(body []byte, meta map[string]string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "rabbitmq",
+		"fn":  "Publish",
+	})
+	l.Debug("Publishing to rabbitmq")
+	ch, err := d.Client.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	h := make(amqp.Table, len(meta))
+	for k, v := range meta {
+		h[k] = v
+	}
+	if err := ch.Publish("", d.Queue, false, false, amqp.Publishing{
+		Headers: h,
+		Body:    body,
+	}); err != nil {
+		l.WithError(err).Error("Failed to publish message")
+		return err
+	}
+	return nil
+}
+
+func (d *RabbitMQ) ClosePublisher() error {
+	return d.Cleanup()
+}
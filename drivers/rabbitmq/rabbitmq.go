@@ -2,18 +2,28 @@ package rabbitmq
 
 import (
 	"bytes"
+	"context"
 	"io"
-	"os"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/robertlestak/procx/pkg/flags"
+	"github.com/robertlestak/procx/pkg/secrets"
 	log "github.com/sirupsen/logrus"
 )
 
 type RabbitMQ struct {
-	Client *amqp.Connection
-	URL    string
-	Queue  string
+	Client     *amqp.Connection
+	URL        string
+	Queue      string
+	deliveries int
+	messageID  string
+}
+
+// load loads the driver options from the flags or the environment.
+func (d *RabbitMQ) load(v *flags.Values) error {
+	v.String(&d.URL, flags.RabbitMQURL)
+	v.String(&d.Queue, flags.RabbitMQQueue)
+	return v.Err()
 }
 
 func (d *RabbitMQ) LoadEnv(prefix string) error {
@@ -22,13 +32,7 @@ func (d *RabbitMQ) LoadEnv(prefix string) error {
 		"fn":  "LoadEnv",
 	})
 	l.Debug("LoadEnv")
-	if os.Getenv(prefix+"RABBITMQ_URL") != "" {
-		d.URL = os.Getenv(prefix + "RABBITMQ_URL")
-	}
-	if os.Getenv(prefix+"RABBITMQ_QUEUE") != "" {
-		d.Queue = os.Getenv(prefix + "RABBITMQ_QUEUE")
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *RabbitMQ) LoadFlags() error {
@@ -37,18 +41,25 @@ func (d *RabbitMQ) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("LoadFlags")
-	d.URL = *flags.RabbitMQURL
-	d.Queue = *flags.RabbitMQQueue
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *RabbitMQ) Init() error {
+	return d.InitContext(context.Background())
+}
+
+func (d *RabbitMQ) InitContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "rabbitmq",
 		"fn":  "Init",
 	})
 	l.Debug("Initializing rabbitmq driver")
-	conn, err := amqp.Dial(d.URL)
+	// the url may be a secret reference, as it may contain credentials
+	url, err := secrets.Resolve(d.URL)
+	if err != nil {
+		return err
+	}
+	conn, err := amqp.Dial(url)
 	if err != nil {
 		return err
 	}
@@ -57,6 +68,10 @@ func (d *RabbitMQ) Init() error {
 }
 
 func (d *RabbitMQ) GetWork() (io.Reader, error) {
+	return d.GetWorkContext(context.Background())
+}
+
+func (d *RabbitMQ) GetWorkContext(ctx context.Context) (io.Reader, error) {
 	l := log.WithFields(log.Fields{
 		"pkg": "rabbitmq",
 		"fn":  "GetWork",
@@ -75,15 +90,57 @@ func (d *RabbitMQ) GetWork() (io.Reader, error) {
 	if err != nil {
 		return nil, err
 	}
-	msg := <-msgs
+	// unacknowledged deliveries are requeued when the channel is closed
+	var msg amqp.Delivery
+	select {
+	case msg = <-msgs:
+	case <-ctx.Done():
+		l.Debug("Context done")
+		return nil, ctx.Err()
+	}
 	l.Debug("Received message from rabbitmq")
 	if msg.Body == nil {
 		return nil, nil
 	}
+	d.deliveries = deliveryCount(msg)
+	d.messageID = msg.MessageId
 	return bytes.NewReader(msg.Body), nil
 }
 
+// deliveryCount returns the number of times msg has been delivered, from the
+// x-delivery-count header quorum queues set on redelivery, or 0 if it is
+// unknown.
+func deliveryCount(msg amqp.Delivery) int {
+	switch c := msg.Headers["x-delivery-count"].(type) {
+	case int64:
+		return int(c) + 1
+	case int32:
+		return int(c) + 1
+	case int:
+		return c + 1
+	}
+	if !msg.Redelivered {
+		return 1
+	}
+	return 0
+}
+
+// DeliveryCount returns the number of times the current message has been
+// delivered.
+func (d *RabbitMQ) DeliveryCount() int {
+	return d.deliveries
+}
+
+// WorkID returns the message ID of the current message, if it has one.
+func (d *RabbitMQ) WorkID() string {
+	return d.messageID
+}
+
 func (d *RabbitMQ) ClearWork() error {
+	return d.ClearWorkContext(context.Background())
+}
+
+func (d *RabbitMQ) ClearWorkContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "rabbitmq",
 		"fn":  "ClearWork",
@@ -93,6 +150,10 @@ func (d *RabbitMQ) ClearWork() error {
 }
 
 func (d *RabbitMQ) HandleFailure() error {
+	return d.HandleFailureContext(context.Background())
+}
+
+func (d *RabbitMQ) HandleFailureContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "rabbitmq",
 		"fn":  "HandleFailure",
@@ -102,6 +163,10 @@ func (d *RabbitMQ) HandleFailure() error {
 }
 
 func (d *RabbitMQ) Cleanup() error {
+	return d.CleanupContext(context.Background())
+}
+
+func (d *RabbitMQ) CleanupContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "rabbitmq",
 		"fn":  "Cleanup",
@@ -0,0 +1,25 @@
+package redis
+
+import (
+	"github.com/go-redis/redis"
+	"github.com/robertlestak/procx/pkg/secrets"
+)
+
+// secretAuth authenticates each new connection with the password secret
+// referenced by cfg.Password, so a rotated password is picked up when the
+// client reconnects. Passwords which are not secret references are left to
+// the client.
+func secretAuth(cfg *redis.Options) {
+	if !secrets.IsRef(cfg.Password) {
+		return
+	}
+	ref := cfg.Password
+	cfg.Password = ""
+	cfg.OnConnect = func(cn *redis.Conn) error {
+		pw, err := secrets.Resolve(ref)
+		if err != nil {
+			return err
+		}
+		return cn.Auth(pw).Err()
+	}
+}
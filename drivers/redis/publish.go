@@ -0,0 +1,83 @@
+package redis
+
+import (
+	"github.com/go-redis/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+func (d *RedisList) InitPublisher() error {
+	return d.Init()
+}
+
+// Publish pushes body to the tail of the list. The list has no message
+// metadata, so meta is not sent.
+func (d *RedisList) Publish(body []byte, meta map[string]string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "redis",
+		"fn":  "Publish",
+	})
+	l.Debug("Publishing to redis list")
+	if err := d.Client.RPush(d.Key, body).Err(); err != nil {
+		l.WithError(err).Error("Failed to publish message")
+		return err
+	}
+	return nil
+}
+
+func (d *RedisList) ClosePublisher() error {
+	return d.Cleanup()
+}
+
+func (d *RedisPubSub) InitPublisher() error {
+	return d.Init()
+}
+
+// Publish publishes body to the channel. The channel has no message
+// metadata, so meta is not sent.
+func (d *RedisPubSub) Publish(body []byte, meta map[string]string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "redis",
+		"fn":  "Publish",
+	})
+	l.Debug("Publishing to redis pub/sub")
+	if err := d.Client.Publish(d.Key, body).Err(); err != nil {
+		l.WithError(err).Error("Failed to publish message")
+		return err
+	}
+	return nil
+}
+
+func (d *RedisPubSub) ClosePublisher() error {
+	return d.Cleanup()
+}
+
+func (d *RedisStream) InitPublisher() error {
+	return d.Init()
+}
+
+// Publish adds a message to the stream with body in the output field, and
+// meta as the other fields.
+func (d *RedisStream) Publish(body []byte, meta map[string]string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "redis",
+		"fn":  "Publish",
+	})
+	l.Debug("Publishing to redis stream")
+	vals := make(map[string]interface{}, len(meta)+1)
+	for k, v := range meta {
+		vals[k] = v
+	}
+	vals["output"] = body
+	if err := d.Client.XAdd(&redis.XAddArgs{
+		Stream: d.Key,
+		Values: vals,
+	}).Err(); err != nil {
+		l.WithError(err).Error("Failed to publish message")
+		return err
+	}
+	return nil
+}
+
+func (d *RedisStream) ClosePublisher() error {
+	return d.Cleanup()
+}
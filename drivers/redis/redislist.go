@@ -3,7 +3,6 @@ package redis
 import (
 	"fmt"
 	"io"
-	"os"
 	"strings"
 	"time"
 
@@ -25,6 +24,21 @@ type RedisList struct {
 	TLSCert     *string
 	TLSKey      *string
 	TLSCA       *string
+	msg         *string
+}
+
+// load loads the driver options from the flags or the environment.
+func (d *RedisList) load(v *flags.Values) error {
+	v.String(&d.Host, flags.RedisHost)
+	v.String(&d.Port, flags.RedisPort)
+	v.String(&d.Password, flags.RedisPassword)
+	v.String(&d.Key, flags.RedisKey)
+	v.BoolPtr(&d.EnableTLS, flags.RedisEnableTLS)
+	v.BoolPtr(&d.TLSInsecure, flags.RedisTLSInsecure)
+	v.StringPtr(&d.TLSCert, flags.RedisCertFile)
+	v.StringPtr(&d.TLSKey, flags.RedisKeyFile)
+	v.StringPtr(&d.TLSCA, flags.RedisCAFile)
+	return v.Err()
 }
 
 func (d *RedisList) LoadEnv(prefix string) error {
@@ -33,39 +47,7 @@ func (d *RedisList) LoadEnv(prefix string) error {
 		"fn":  "LoadEnv",
 	})
 	l.Debug("Loading environment variables")
-	if os.Getenv(prefix+"REDIS_HOST") != "" {
-		d.Host = os.Getenv(prefix + "REDIS_HOST")
-	}
-	if os.Getenv(prefix+"REDIS_PORT") != "" {
-		d.Port = os.Getenv(prefix + "REDIS_PORT")
-	}
-	if os.Getenv(prefix+"REDIS_PASSWORD") != "" {
-		d.Password = os.Getenv(prefix + "REDIS_PASSWORD")
-	}
-	if os.Getenv(prefix+"REDIS_KEY") != "" {
-		d.Key = os.Getenv(prefix + "REDIS_KEY")
-	}
-	if os.Getenv(prefix+"REDIS_ENABLE_TLS") != "" {
-		v := os.Getenv(prefix+"REDIS_ENABLE_TLS") == "true"
-		d.EnableTLS = &v
-	}
-	if os.Getenv(prefix+"REDIS_TLS_INSECURE") != "" {
-		v := os.Getenv(prefix+"REDIS_TLS_INSECURE") == "true"
-		d.TLSInsecure = &v
-	}
-	if os.Getenv(prefix+"REDIS_TLS_CERT_FILE") != "" {
-		v := os.Getenv(prefix + "REDIS_TLS_CERT_FILE")
-		d.TLSCert = &v
-	}
-	if os.Getenv(prefix+"REDIS_TLS_KEY_FILE") != "" {
-		v := os.Getenv(prefix + "REDIS_TLS_KEY_FILE")
-		d.TLSKey = &v
-	}
-	if os.Getenv(prefix+"REDIS_TLS_CA_FILE") != "" {
-		v := os.Getenv(prefix + "REDIS_TLS_CA_FILE")
-		d.TLSCA = &v
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *RedisList) LoadFlags() error {
@@ -74,16 +56,7 @@ func (d *RedisList) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	d.Host = *flags.RedisHost
-	d.Port = *flags.RedisPort
-	d.Password = *flags.RedisPassword
-	d.Key = *flags.RedisKey
-	d.EnableTLS = flags.RedisEnableTLS
-	d.TLSInsecure = flags.RedisTLSSkipVerify
-	d.TLSCert = flags.RedisCertFile
-	d.TLSKey = flags.RedisKeyFile
-	d.TLSCA = flags.RedisCAFile
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *RedisList) Init() error {
@@ -99,6 +72,7 @@ func (d *RedisList) Init() error {
 		DialTimeout: 30 * time.Second,
 		ReadTimeout: 30 * time.Second,
 	}
+	secretAuth(cfg)
 	if d.EnableTLS != nil && *d.EnableTLS {
 		tc, err := utils.TlsConfig(d.EnableTLS, d.TLSInsecure, d.TLSCA, d.TLSCert, d.TLSKey)
 		if err != nil {
@@ -134,6 +108,7 @@ func (d *RedisList) GetWork() (io.Reader, error) {
 		return nil, err
 	}
 	l.Debug("Received message")
+	d.msg = &msg
 	return strings.NewReader(msg), nil
 }
 
@@ -155,6 +130,62 @@ func (d *RedisList) HandleFailure() error {
 	return nil
 }
 
+// Redelivers returns false, as the message was popped from the list.
+func (d *RedisList) Redelivers() bool {
+	return false
+}
+
+func (d *RedisList) PreviewClearWork() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "redis",
+		"fn":  "PreviewClearWork",
+	})
+	l.Debug("Previewing clear work")
+	return nil, nil
+}
+
+func (d *RedisList) PreviewHandleFailure() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "redis",
+		"fn":  "PreviewHandleFailure",
+	})
+	l.Debug("Previewing handle failure")
+	return nil, nil
+}
+
+// ReleaseWork pushes the popped message back to the head of the list.
+func (d *RedisList) ReleaseWork() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "redis",
+		"fn":  "ReleaseWork",
+	})
+	l.Debug("Releasing work to redis list")
+	if d.msg == nil {
+		return nil
+	}
+	if err := d.Client.LPush(d.Key, *d.msg).Err(); err != nil {
+		l.WithError(err).Error("Failed to release message")
+		return err
+	}
+	d.msg = nil
+	return nil
+}
+
+// Depth returns the length of the list.
+func (d *RedisList) Depth() (int64, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "redis",
+		"fn":  "Depth",
+	})
+	l.Debug("Getting redis list length")
+	n, err := d.Client.LLen(d.Key).Result()
+	if err != nil {
+		l.WithError(err).Error("Failed to get list length")
+		return 0, err
+	}
+	return n, nil
+}
+
 func (d *RedisList) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "redis",
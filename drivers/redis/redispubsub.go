@@ -3,7 +3,6 @@ package redis
 import (
 	"fmt"
 	"io"
-	"os"
 	"strings"
 	"time"
 
@@ -27,45 +26,27 @@ type RedisPubSub struct {
 	TLSCA       *string
 }
 
+// load loads the driver options from the flags or the environment.
+func (d *RedisPubSub) load(v *flags.Values) error {
+	v.String(&d.Host, flags.RedisHost)
+	v.String(&d.Port, flags.RedisPort)
+	v.String(&d.Password, flags.RedisPassword)
+	v.String(&d.Key, flags.RedisKey)
+	v.BoolPtr(&d.EnableTLS, flags.RedisEnableTLS)
+	v.BoolPtr(&d.TLSInsecure, flags.RedisTLSInsecure)
+	v.StringPtr(&d.TLSCert, flags.RedisCertFile)
+	v.StringPtr(&d.TLSKey, flags.RedisKeyFile)
+	v.StringPtr(&d.TLSCA, flags.RedisCAFile)
+	return v.Err()
+}
+
 func (d *RedisPubSub) LoadEnv(prefix string) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "redis",
 		"fn":  "LoadEnv",
 	})
 	l.Debug("Loading environment variables")
-	if os.Getenv(prefix+"REDIS_HOST") != "" {
-		d.Host = os.Getenv(prefix + "REDIS_HOST")
-	}
-	if os.Getenv(prefix+"REDIS_PORT") != "" {
-		d.Port = os.Getenv(prefix + "REDIS_PORT")
-	}
-	if os.Getenv(prefix+"REDIS_PASSWORD") != "" {
-		d.Password = os.Getenv(prefix + "REDIS_PASSWORD")
-	}
-	if os.Getenv(prefix+"REDIS_KEY") != "" {
-		d.Key = os.Getenv(prefix + "REDIS_KEY")
-	}
-	if os.Getenv(prefix+"REDIS_ENABLE_TLS") != "" {
-		v := os.Getenv(prefix+"REDIS_ENABLE_TLS") == "true"
-		d.EnableTLS = &v
-	}
-	if os.Getenv(prefix+"REDIS_TLS_INSECURE") != "" {
-		v := os.Getenv(prefix+"REDIS_TLS_INSECURE") == "true"
-		d.TLSInsecure = &v
-	}
-	if os.Getenv(prefix+"REDIS_TLS_CERT_FILE") != "" {
-		v := os.Getenv(prefix + "REDIS_TLS_CERT_FILE")
-		d.TLSCert = &v
-	}
-	if os.Getenv(prefix+"REDIS_TLS_KEY_FILE") != "" {
-		v := os.Getenv(prefix + "REDIS_TLS_KEY_FILE")
-		d.TLSKey = &v
-	}
-	if os.Getenv(prefix+"REDIS_TLS_CA_FILE") != "" {
-		v := os.Getenv(prefix + "REDIS_TLS_CA_FILE")
-		d.TLSCA = &v
-	}
-	return nil
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *RedisPubSub) LoadFlags() error {
@@ -74,16 +55,7 @@ func (d *RedisPubSub) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	d.Host = *flags.RedisHost
-	d.Port = *flags.RedisPort
-	d.Password = *flags.RedisPassword
-	d.Key = *flags.RedisKey
-	d.EnableTLS = flags.RedisEnableTLS
-	d.TLSInsecure = flags.RedisTLSSkipVerify
-	d.TLSCert = flags.RedisCertFile
-	d.TLSKey = flags.RedisKeyFile
-	d.TLSCA = flags.RedisCAFile
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *RedisPubSub) Init() error {
@@ -98,6 +70,7 @@ func (d *RedisPubSub) Init() error {
 		DialTimeout: 30 * time.Second,
 		ReadTimeout: 30 * time.Second,
 	}
+	secretAuth(cfg)
 	if d.EnableTLS != nil && *d.EnableTLS {
 		tc, err := utils.TlsConfig(d.EnableTLS, d.TLSInsecure, d.TLSCA, d.TLSCert, d.TLSKey)
 		if err != nil {
@@ -6,8 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"strings"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -44,75 +42,42 @@ type RedisStream struct {
 	TLSCA       *string
 }
 
+// load loads the driver options from the flags or the environment.
+func (d *RedisStream) load(v *flags.Values) error {
+	v.String(&d.Host, flags.RedisHost)
+	v.String(&d.Port, flags.RedisPort)
+	v.String(&d.Password, flags.RedisPassword)
+	v.String(&d.Key, flags.RedisKey)
+	v.BoolPtr(&d.EnableTLS, flags.RedisEnableTLS)
+	v.BoolPtr(&d.TLSInsecure, flags.RedisTLSInsecure)
+	v.StringPtr(&d.TLSCert, flags.RedisCertFile)
+	v.StringPtr(&d.TLSKey, flags.RedisKeyFile)
+	v.StringPtr(&d.TLSCA, flags.RedisCAFile)
+	v.Strings(&d.ValueKeys, flags.RedisValueKeys)
+	v.StringPtr(&d.ConsumerGroup, flags.RedisConsumerGroup)
+	v.StringPtr(&d.ConsumerName, flags.RedisConsumerName)
+	if d.ConsumerName == nil || *d.ConsumerName == "" {
+		n := uuid.New().String()
+		d.ConsumerName = &n
+	}
+	if s, ok := v.Lookup(flags.RedisClearOp); ok {
+		op := StreamOp(s)
+		d.ClearOp = &op
+	}
+	if s, ok := v.Lookup(flags.RedisFailOp); ok {
+		op := StreamOp(s)
+		d.FailOp = &op
+	}
+	return v.Err()
+}
+
 func (d *RedisStream) LoadEnv(prefix string) error {
 	l := log.WithFields(log.Fields{
 		"pkg": "redis",
 		"fn":  "LoadEnv",
 	})
 	l.Debug("Loading environment variables")
-	if os.Getenv(prefix+"REDIS_HOST") != "" {
-		d.Host = os.Getenv(prefix + "REDIS_HOST")
-	}
-	if os.Getenv(prefix+"REDIS_PORT") != "" {
-		d.Port = os.Getenv(prefix + "REDIS_PORT")
-	}
-	if os.Getenv(prefix+"REDIS_PASSWORD") != "" {
-		d.Password = os.Getenv(prefix + "REDIS_PASSWORD")
-	}
-	if os.Getenv(prefix+"REDIS_KEY") != "" {
-		d.Key = os.Getenv(prefix + "REDIS_KEY")
-	}
-	if os.Getenv(prefix+"REDIS_STREAM_CONSUMER_GROUP") != "" {
-		v := os.Getenv(prefix + "REDIS_STREAM_CONSUMER_GROUP")
-		d.ConsumerGroup = &v
-	}
-	if os.Getenv(prefix+"REDIS_STREAM_CONSUMER_NAME") != "" {
-		v := os.Getenv(prefix + "REDIS_STREAM_CONSUMER_NAME")
-		d.ConsumerName = &v
-	}
-	if os.Getenv(prefix+"REDIS_STREAM_CLEAR_OP") != "" {
-		v := StreamOp(os.Getenv(prefix + "REDIS_STREAM_CLEAR_OP"))
-		d.ClearOp = &v
-	}
-	if os.Getenv(prefix+"REDIS_STREAM_FAIL_OP") != "" {
-		v := StreamOp(os.Getenv(prefix + "REDIS_STREAM_FAIL_OP"))
-		d.FailOp = &v
-	}
-	if os.Getenv(prefix+"REDIS_ENABLE_TLS") != "" {
-		v := os.Getenv(prefix+"REDIS_ENABLE_TLS") == "true"
-		d.EnableTLS = &v
-	}
-	if os.Getenv(prefix+"REDIS_TLS_INSECURE") != "" {
-		v := os.Getenv(prefix+"REDIS_TLS_INSECURE") == "true"
-		d.TLSInsecure = &v
-	}
-	if os.Getenv(prefix+"REDIS_TLS_CERT_FILE") != "" {
-		v := os.Getenv(prefix + "REDIS_TLS_CERT_FILE")
-		d.TLSCert = &v
-	}
-	if os.Getenv(prefix+"REDIS_TLS_KEY_FILE") != "" {
-		v := os.Getenv(prefix + "REDIS_TLS_KEY_FILE")
-		d.TLSKey = &v
-	}
-	if os.Getenv(prefix+"REDIS_TLS_CA_FILE") != "" {
-		v := os.Getenv(prefix + "REDIS_TLS_CA_FILE")
-		d.TLSCA = &v
-	}
-	if os.Getenv(prefix+"REDIS_STREAM_VALUE_KEYS") != "" {
-		v := strings.Split(os.Getenv(prefix+"REDIS_STREAM_VALUE_KEYS"), ",")
-		d.ValueKeys = v
-	}
-	return nil
-}
-
-func cleanStringSlice(s []string) []string {
-	var r []string
-	for _, v := range s {
-		if v != "" {
-			r = append(r, v)
-		}
-	}
-	return r
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *RedisStream) LoadFlags() error {
@@ -121,34 +86,7 @@ func (d *RedisStream) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("Loading flags")
-	d.Host = *flags.RedisHost
-	d.Port = *flags.RedisPort
-	d.Password = *flags.RedisPassword
-	d.Key = *flags.RedisKey
-	d.ValueKeys = cleanStringSlice(strings.Split(*flags.RedisValueKeys, ","))
-	d.EnableTLS = flags.RedisEnableTLS
-	d.TLSInsecure = flags.RedisTLSSkipVerify
-	d.TLSCert = flags.RedisCertFile
-	d.TLSKey = flags.RedisKeyFile
-	d.TLSCA = flags.RedisCAFile
-	if flags.RedisConsumerGroup != nil {
-		d.ConsumerGroup = flags.RedisConsumerGroup
-	}
-	if flags.RedisConsumerName != nil {
-		d.ConsumerName = flags.RedisConsumerName
-	} else {
-		v := uuid.New().String()
-		d.ConsumerName = &v
-	}
-	if flags.RedisClearOp != nil {
-		v := StreamOp(*flags.RedisClearOp)
-		d.ClearOp = &v
-	}
-	if flags.RedisFailOp != nil {
-		v := StreamOp(*flags.RedisFailOp)
-		d.FailOp = &v
-	}
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *RedisStream) Init() error {
@@ -164,6 +102,7 @@ func (d *RedisStream) Init() error {
 		DialTimeout: 30 * time.Second,
 		ReadTimeout: 30 * time.Second,
 	}
+	secretAuth(cfg)
 	if d.EnableTLS != nil && *d.EnableTLS {
 		tc, err := utils.TlsConfig(d.EnableTLS, d.TLSInsecure, d.TLSCA, d.TLSCert, d.TLSKey)
 		if err != nil {
@@ -327,6 +266,92 @@ func (d *RedisStream) HandleFailure() error {
 	}
 }
 
+// Depth returns the number of entries which have not yet been delivered to
+// the consumer group. Pending entries which have been delivered but not acked
+// are not counted, as they are not redelivered.
+func (d *RedisStream) Depth() (int64, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "redis",
+		"fn":  "Depth",
+	})
+	l.Debug("Getting redis stream depth")
+	if d.ConsumerGroup == nil || *d.ConsumerGroup == "" {
+		return 0, errors.New("depth requires a consumer group")
+	}
+	res, err := d.Client.Do("XINFO", "GROUPS", d.Key).Result()
+	if err != nil {
+		l.WithError(err).Error("Failed to get stream groups")
+		return 0, err
+	}
+	groups, _ := res.([]interface{})
+	for _, g := range groups {
+		fields, _ := g.([]interface{})
+		info := make(map[string]interface{})
+		for i := 0; i+1 < len(fields); i += 2 {
+			if k, ok := fields[i].(string); ok {
+				info[k] = fields[i+1]
+			}
+		}
+		if info["name"] != *d.ConsumerGroup {
+			continue
+		}
+		// the lag is reported by redis 7 and later, and is nil if it cannot
+		// be determined
+		if lag, ok := info["lag"].(int64); ok {
+			return lag, nil
+		}
+		last, _ := info["last-delivered-id"].(string)
+		msgs, err := d.Client.XRange(d.Key, last, "+").Result()
+		if err != nil {
+			l.WithError(err).Error("Failed to read stream")
+			return 0, err
+		}
+		n := int64(len(msgs))
+		if n > 0 && msgs[0].ID == last {
+			n--
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("consumer group %s not found", *d.ConsumerGroup)
+}
+
+// DeliveryCount returns the number of times the current message has been
+// delivered to the consumer group, from XPENDING, or 0 without a consumer
+// group.
+func (d *RedisStream) DeliveryCount() int {
+	l := log.WithFields(log.Fields{
+		"pkg": "redis",
+		"fn":  "DeliveryCount",
+	})
+	l.Debug("Getting redis stream delivery count")
+	if d.ConsumerGroup == nil || *d.ConsumerGroup == "" || d.MessageID == nil {
+		return 0
+	}
+	res, err := d.Client.XPendingExt(&redis.XPendingExtArgs{
+		Stream: d.Key,
+		Group:  *d.ConsumerGroup,
+		Start:  *d.MessageID,
+		End:    *d.MessageID,
+		Count:  1,
+	}).Result()
+	if err != nil {
+		l.WithError(err).Error("Failed to get pending message")
+		return 0
+	}
+	if len(res) == 0 {
+		return 0
+	}
+	return int(res[0].RetryCount)
+}
+
+// WorkID returns the ID of the current message.
+func (d *RedisStream) WorkID() string {
+	if d.MessageID == nil {
+		return ""
+	}
+	return *d.MessageID
+}
+
 func (d *RedisStream) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "redis",
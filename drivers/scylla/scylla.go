@@ -3,13 +3,13 @@ package scylla
 import (
 	"encoding/json"
 	"io"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/gocql/gocql"
 	"github.com/robertlestak/procx/pkg/flags"
 	"github.com/robertlestak/procx/pkg/schema"
+	"github.com/robertlestak/procx/pkg/secrets"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 )
@@ -27,71 +27,43 @@ type Scylla struct {
 	ClearQuery    *schema.SqlQuery
 	FailQuery     *schema.SqlQuery
 	data          []map[string]any
+	result        *schema.JobResult
 }
 
-func (d *Scylla) LoadEnv(prefix string) error {
-	l := log.WithFields(log.Fields{
-		"pkg": "scylla",
-		"fn":  "LoadEnv",
-	})
-	l.Debug("loading env")
-	if os.Getenv(prefix+"SCYLLA_HOSTS") != "" {
-		d.Hosts = strings.Split(os.Getenv(prefix+"SCYLLA_HOSTS"), ",")
-	}
-	if os.Getenv(prefix+"SCYLLA_KEYSPACE") != "" {
-		d.Keyspace = os.Getenv(prefix + "SCYLLA_KEYSPACE")
-	}
-	if os.Getenv(prefix+"SCYLLA_USER") != "" {
-		d.User = os.Getenv(prefix + "SCYLLA_USER")
-	}
-	if os.Getenv(prefix+"SCYLLA_PASSWORD") != "" {
-		d.Password = os.Getenv(prefix + "SCYLLA_PASSWORD")
-	}
-	if os.Getenv(prefix+"SCYLLA_CONSISTENCY") != "" {
-		d.Consistency = os.Getenv(prefix + "SCYLLA_CONSISTENCY")
-	}
+// load loads the driver options from the flags or the environment.
+func (d *Scylla) load(v *flags.Values) error {
+	v.Strings(&d.Hosts, flags.ScyllaHosts)
+	v.String(&d.Keyspace, flags.ScyllaKeyspace)
+	v.String(&d.User, flags.ScyllaUser)
+	v.String(&d.Password, flags.ScyllaPassword)
+	v.String(&d.Consistency, flags.ScyllaConsistency)
+	v.StringPtr(&d.LocalDC, flags.ScyllaLocalDC)
+	v.StringPtr(&d.RetrieveField, flags.ScyllaRetrieveField)
 	if d.RetrieveQuery == nil {
 		d.RetrieveQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"SCYLLA_RETRIEVE_QUERY") != "" {
-		d.RetrieveQuery.Query = os.Getenv(prefix + "SCYLLA_RETRIEVE_QUERY")
-	}
-	if os.Getenv(prefix+"SCYLLA_RETRIEVE_PARAMS") != "" {
-		for _, s := range strings.Split(os.Getenv(prefix+"SCYLLA_RETRIEVE_PARAMS"), ",") {
-			d.RetrieveQuery.Params = append(d.RetrieveQuery.Params, s)
-		}
-	}
+	v.String(&d.RetrieveQuery.Query, flags.ScyllaRetrieveQuery)
+	v.Params(&d.RetrieveQuery.Params, flags.ScyllaRetrieveParams)
 	if d.ClearQuery == nil {
 		d.ClearQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"SCYLLA_CLEAR_QUERY") != "" {
-		d.ClearQuery.Query = os.Getenv(prefix + "SCYLLA_CLEAR_QUERY")
-	}
-	if os.Getenv(prefix+"SCYLLA_CLEAR_PARAMS") != "" {
-		for _, s := range strings.Split(os.Getenv(prefix+"SCYLLA_CLEAR_PARAMS"), ",") {
-			d.ClearQuery.Params = append(d.ClearQuery.Params, s)
-		}
-	}
+	v.String(&d.ClearQuery.Query, flags.ScyllaClearQuery)
+	v.Params(&d.ClearQuery.Params, flags.ScyllaClearParams)
 	if d.FailQuery == nil {
 		d.FailQuery = &schema.SqlQuery{}
 	}
-	if os.Getenv(prefix+"SCYLLA_FAIL_QUERY") != "" {
-		d.FailQuery.Query = os.Getenv(prefix + "SCYLLA_FAIL_QUERY")
-	}
-	if os.Getenv(prefix+"SCYLLA_FAIL_PARAMS") != "" {
-		for _, s := range strings.Split(os.Getenv(prefix+"SCYLLA_FAIL_PARAMS"), ",") {
-			d.FailQuery.Params = append(d.FailQuery.Params, s)
-		}
-	}
-	if os.Getenv(prefix+"SCYLLA_RETRIEVE_FIELD") != "" {
-		v := os.Getenv(prefix + "SCYLLA_RETRIEVE_FIELD")
-		d.RetrieveField = &v
-	}
-	if os.Getenv(prefix+"SCYLLA_LOCAL_DC") != "" {
-		v := os.Getenv(prefix + "SCYLLA_LOCAL_DC")
-		d.LocalDC = &v
-	}
-	return nil
+	v.String(&d.FailQuery.Query, flags.ScyllaFailQuery)
+	v.Params(&d.FailQuery.Params, flags.ScyllaFailParams)
+	return v.Err()
+}
+
+func (d *Scylla) LoadEnv(prefix string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "scylla",
+		"fn":  "LoadEnv",
+	})
+	l.Debug("loading env")
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *Scylla) LoadFlags() error {
@@ -100,72 +72,7 @@ func (d *Scylla) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("loading flags")
-	var hosts []string
-	if *flags.ScyllaHosts != "" {
-		s := strings.Split(*flags.ScyllaHosts, ",")
-		for _, v := range s {
-			v = strings.TrimSpace(v)
-			if v != "" {
-				hosts = append(hosts, v)
-			}
-		}
-	}
-	var rps []any
-	var cps []any
-	var fps []any
-	if *flags.ScyllaRetrieveParams != "" {
-		s := strings.Split(*flags.ScyllaRetrieveParams, ",")
-		for _, v := range s {
-			rps = append(rps, v)
-		}
-	}
-	if *flags.ScyllaClearParams != "" {
-		s := strings.Split(*flags.ScyllaClearParams, ",")
-		for _, v := range s {
-			cps = append(cps, v)
-		}
-	}
-	if *flags.ScyllaFailParams != "" {
-		s := strings.Split(*flags.ScyllaFailParams, ",")
-		for _, v := range s {
-			fps = append(fps, v)
-		}
-	}
-	d.Hosts = hosts
-	d.User = *flags.ScyllaUser
-	d.Password = *flags.ScyllaPassword
-	d.Keyspace = *flags.ScyllaKeyspace
-	d.Consistency = *flags.ScyllaConsistency
-	d.LocalDC = flags.ScyllaLocalDC
-	d.RetrieveField = flags.ScyllaRetrieveField
-	if d.RetrieveQuery == nil {
-		d.RetrieveQuery = &schema.SqlQuery{}
-	}
-	if *flags.ScyllaRetrieveQuery != "" {
-		d.RetrieveQuery.Query = *flags.ScyllaRetrieveQuery
-	}
-	if len(rps) > 0 {
-		d.RetrieveQuery.Params = rps
-	}
-	if d.ClearQuery == nil {
-		d.ClearQuery = &schema.SqlQuery{}
-	}
-	if *flags.ScyllaClearQuery != "" {
-		d.ClearQuery.Query = *flags.ScyllaClearQuery
-	}
-	if len(cps) > 0 {
-		d.ClearQuery.Params = cps
-	}
-	if d.FailQuery == nil {
-		d.FailQuery = &schema.SqlQuery{}
-	}
-	if *flags.ScyllaFailQuery != "" {
-		d.FailQuery.Query = *flags.ScyllaFailQuery
-	}
-	if len(fps) > 0 {
-		d.FailQuery.Params = fps
-	}
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *Scylla) Init() error {
@@ -185,7 +92,11 @@ func (d *Scylla) Init() error {
 	cluster.ProtoVersion = 4
 	cluster.ConnectTimeout = time.Second * 10
 	if d.User != "" || d.Password != "" {
-		cluster.Authenticator = gocql.PasswordAuthenticator{Username: d.User, Password: d.Password}
+		pass, err := secrets.Resolve(d.Password)
+		if err != nil {
+			return err
+		}
+		cluster.Authenticator = gocql.PasswordAuthenticator{Username: d.User, Password: pass}
 	}
 	fallback := gocql.RoundRobinHostPolicy()
 	if d.LocalDC != nil && *d.LocalDC != "" {
@@ -248,6 +159,10 @@ func (d *Scylla) GetWork() (io.Reader, error) {
 	return strings.NewReader(result), nil
 }
 
+func (d *Scylla) SetResult(r *schema.JobResult) {
+	d.result = r
+}
+
 func (d *Scylla) ClearWork() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "scylla",
@@ -261,8 +176,8 @@ func (d *Scylla) ClearWork() error {
 	if d.ClearQuery.Query == "" {
 		return nil
 	}
-	d.ClearQuery.Params = schema.ReplaceParamsSliceMap(d.data, d.ClearQuery.Params)
-	err = d.Client.Query(d.ClearQuery.Query, d.ClearQuery.Params...).Exec()
+	params := d.ClearQuery.RenderParams(d.data, d.result)
+	err = d.Client.Query(d.ClearQuery.Query, params...).Exec()
 	if err != nil {
 		l.Error(err)
 		return err
@@ -284,8 +199,8 @@ func (d *Scylla) HandleFailure() error {
 	if d.FailQuery.Query == "" {
 		return nil
 	}
-	d.FailQuery.Params = schema.ReplaceParamsSliceMap(d.data, d.FailQuery.Params)
-	err = d.Client.Query(d.FailQuery.Query, d.FailQuery.Params...).Exec()
+	params := d.FailQuery.RenderParams(d.data, d.result)
+	err = d.Client.Query(d.FailQuery.Query, params...).Exec()
 	if err != nil {
 		l.Error(err)
 		return err
@@ -294,6 +209,24 @@ func (d *Scylla) HandleFailure() error {
 	return nil
 }
 
+func (d *Scylla) PreviewClearWork() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "scylla",
+		"fn":  "PreviewClearWork",
+	})
+	l.Debug("Previewing clear work")
+	return schema.PreviewSqlQuery(d.data, d.ClearQuery), nil
+}
+
+func (d *Scylla) PreviewHandleFailure() (map[string]any, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "scylla",
+		"fn":  "PreviewHandleFailure",
+	})
+	l.Debug("Previewing handle failure")
+	return schema.PreviewSqlQuery(d.data, d.FailQuery), nil
+}
+
 func (d *Scylla) Cleanup() error {
 	l := log.WithFields(log.Fields{
 		"pkg": "scylla",
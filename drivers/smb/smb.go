@@ -6,12 +6,11 @@ import (
 	"io"
 	iofs "io/fs"
 	"net"
-	"os"
-	"strconv"
 	"strings"
 
 	"github.com/hirochachacha/go-smb2"
 	"github.com/robertlestak/procx/pkg/flags"
+	"github.com/robertlestak/procx/pkg/secrets"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -64,67 +63,41 @@ func (o *S3Op) GetKey() string {
 	return strings.ReplaceAll(o.KeyTemplate, "{{key}}", baseKey)
 }
 
-func (d *SMB) LoadEnv(prefix string) error {
-	l := log.WithFields(log.Fields{
-		"pkg": "nfs",
-		"fn":  "LoadEnv",
-	})
-	l.Debug("LoadEnv")
-	if os.Getenv(prefix+"SMB_HOST") != "" {
-		d.Host = os.Getenv(prefix + "SMB_HOST")
-	}
-	if os.Getenv(prefix+"SMB_PORT") != "" {
-		pv, err := strconv.Atoi(os.Getenv(prefix + "SMB_PORT"))
-		if err != nil {
-			l.Errorf("%+v", err)
-			return err
-		}
-		d.Port = pv
-	}
-	if os.Getenv(prefix+"SMB_USER") != "" {
-		v := os.Getenv(prefix + "SMB_USER")
-		d.Username = &v
-	}
-	if os.Getenv(prefix+"SMB_PASS") != "" {
-		v := os.Getenv(prefix + "SMB_PASS")
-		d.Password = &v
-	}
-	if os.Getenv(prefix+"SMB_SHARE") != "" {
-		v := os.Getenv(prefix + "SMB_SHARE")
-		d.Share = &v
-	}
-	if os.Getenv(prefix+"SMB_KEY") != "" {
-		d.Key = os.Getenv(prefix + "SMB_KEY")
-	}
-	if os.Getenv(prefix+"SMB_KEY_GLOB") != "" {
-		v := os.Getenv(prefix + "SMB_KEY_GLOB")
-		d.KeyGlob = &v
-	}
+// load loads the driver options from the flags or the environment.
+func (d *SMB) load(v *flags.Values) error {
+	v.String(&d.Host, flags.SMBHost)
+	v.Int(&d.Port, flags.SMBPort)
+	v.StringPtr(&d.Username, flags.SMBUser)
+	v.StringPtr(&d.Password, flags.SMBPass)
+	v.StringPtr(&d.Share, flags.SMBShare)
+	v.String(&d.Key, flags.SMBKey)
+	v.StringPtr(&d.KeyGlob, flags.SMBKeyGlob)
 	if d.ClearOp == nil {
 		d.ClearOp = &S3Op{}
 	}
 	if d.FailOp == nil {
 		d.FailOp = &S3Op{}
 	}
-	if os.Getenv(prefix+"SMB_CLEAR_OP") != "" {
-		d.ClearOp.Operation = S3Operation(os.Getenv(prefix + "SMB_CLEAR_OP"))
-	}
-	if os.Getenv(prefix+"SMB_CLEAR_KEY") != "" {
-		d.ClearOp.Key = os.Getenv(prefix + "SMB_CLEAR_KEY")
-	}
-	if os.Getenv(prefix+"SMB_CLEAR_KEY_TEMPLATE") != "" {
-		d.ClearOp.KeyTemplate = os.Getenv(prefix + "SMB_CLEAR_KEY_TEMPLATE")
-	}
-	if os.Getenv(prefix+"SMB_FAIL_OP") != "" {
-		d.FailOp.Operation = S3Operation(os.Getenv(prefix + "SMB_FAIL_OP"))
-	}
-	if os.Getenv(prefix+"SMB_FAIL_KEY") != "" {
-		d.FailOp.Key = os.Getenv(prefix + "SMB_FAIL_KEY")
+	if s, ok := v.Lookup(flags.SMBClearOp); ok {
+		d.ClearOp.Operation = S3Operation(s)
 	}
-	if os.Getenv(prefix+"SMB_FAIL_KEY_TEMPLATE") != "" {
-		d.FailOp.KeyTemplate = os.Getenv(prefix + "SMB_FAIL_KEY_TEMPLATE")
+	v.String(&d.ClearOp.Key, flags.SMBClearKey)
+	v.String(&d.ClearOp.KeyTemplate, flags.SMBClearKeyTemplate)
+	if s, ok := v.Lookup(flags.SMBFailOp); ok {
+		d.FailOp.Operation = S3Operation(s)
 	}
-	return nil
+	v.String(&d.FailOp.Key, flags.SMBFailKey)
+	v.String(&d.FailOp.KeyTemplate, flags.SMBFailKeyTemplate)
+	return v.Err()
+}
+
+func (d *SMB) LoadEnv(prefix string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "nfs",
+		"fn":  "LoadEnv",
+	})
+	l.Debug("LoadEnv")
+	return d.load(flags.EnvValues(prefix))
 }
 
 func (d *SMB) LoadFlags() error {
@@ -133,26 +106,7 @@ func (d *SMB) LoadFlags() error {
 		"fn":  "LoadFlags",
 	})
 	l.Debug("LoadFlags")
-	d.Host = *flags.SMBHost
-	d.Port = *flags.SMBPort
-	d.Username = flags.SMBUser
-	d.Password = flags.SMBPass
-	d.Key = *flags.SMBKey
-	d.Share = flags.SMBShare
-	if d.ClearOp == nil {
-		d.ClearOp = &S3Op{}
-	}
-	if d.FailOp == nil {
-		d.FailOp = &S3Op{}
-	}
-	d.KeyGlob = flags.SMBKeyGlob
-	d.ClearOp.Operation = S3Operation(*flags.SMBClearOp)
-	d.ClearOp.Key = *flags.SMBClearKey
-	d.ClearOp.KeyTemplate = *flags.SMBClearKeyTemplate
-	d.FailOp.Operation = S3Operation(*flags.SMBFailOp)
-	d.FailOp.Key = *flags.SMBFailKey
-	d.FailOp.KeyTemplate = *flags.SMBFailKeyTemplate
-	return nil
+	return d.load(flags.FlagValues())
 }
 
 func (d *SMB) Init() error {
@@ -166,6 +120,11 @@ func (d *SMB) Init() error {
 	if d.Host == "" || d.Port == 0 || d.Username == nil || d.Password == nil || d.Share == nil {
 		return errors.New("invalid SMB configuration")
 	}
+	pass, err := secrets.Resolve(*d.Password)
+	if err != nil {
+		l.Errorf("%+v", err)
+		return err
+	}
 	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", d.Host, d.Port))
 	if err != nil {
 		l.Errorf("%+v", err)
@@ -174,7 +133,7 @@ func (d *SMB) Init() error {
 	sd := &smb2.Dialer{
 		Initiator: &smb2.NTLMInitiator{
 			User:     *d.Username,
-			Password: *d.Password,
+			Password: pass,
 		},
 	}
 	s, err := sd.Dial(conn)
@@ -0,0 +1,218 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robertlestak/procx/pkg/breaker"
+	"github.com/robertlestak/procx/pkg/procx"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrInvalidAddr = errors.New("invalid admin address")
+)
+
+// Admin serves the admin control API for a set of workers, to pause and
+// resume work retrieval, drain the workers, and report their status.
+type Admin struct {
+	Control *procx.Control
+	// Breaker, if set, is the circuit breaker shared by the workers
+	Breaker *breaker.Breaker
+	workers []*procx.ProcX
+	started time.Time
+	// drain cancels the workers' context, to exit once in flight work has
+	// completed
+	drain    context.CancelFunc
+	mu       sync.Mutex
+	draining bool
+}
+
+// WorkerStatus is the status of a single worker.
+type WorkerStatus struct {
+	procx.Status
+	// JobAge is the time in milliseconds the current job has been running
+	JobAge int64 `json:"jobAgeMs,omitempty"`
+}
+
+// Status is the status of procx and its workers.
+type Status struct {
+	Started   time.Time `json:"started"`
+	Uptime    int64     `json:"uptimeMs"`
+	Paused    bool      `json:"paused"`
+	Draining  bool      `json:"draining"`
+	Received  int64     `json:"received"`
+	Succeeded int64     `json:"succeeded"`
+	Failed    int64     `json:"failed"`
+	Poisoned  int64     `json:"poisoned"`
+	// LastError is the most recent error across all workers
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorTime *time.Time `json:"lastErrorTime,omitempty"`
+	// Breaker is the state of the circuit breaker, if enabled
+	Breaker breaker.State  `json:"breaker,omitempty"`
+	Workers []WorkerStatus `json:"workers"`
+}
+
+// New creates an Admin for workers, setting a shared Control on each. drain
+// is called to drain the workers, and should cancel the context they process
+// work with.
+func New(workers []*procx.ProcX, drain context.CancelFunc) *Admin {
+	a := &Admin{
+		Control: procx.NewControl(),
+		workers: workers,
+		started: time.Now(),
+		drain:   drain,
+	}
+	for _, j := range workers {
+		j.Control = a.Control
+	}
+	return a
+}
+
+// Pause stops the workers from retrieving new work. Work in progress is
+// completed.
+func (a *Admin) Pause() {
+	l := log.WithFields(log.Fields{
+		"pkg": "admin",
+		"fn":  "Pause",
+	})
+	l.Info("pausing work retrieval")
+	a.Control.Pause()
+}
+
+// Resume resumes work retrieval.
+func (a *Admin) Resume() {
+	l := log.WithFields(log.Fields{
+		"pkg": "admin",
+		"fn":  "Resume",
+	})
+	l.Info("resuming work retrieval")
+	a.Control.Resume()
+}
+
+// Drain stops the workers from retrieving new work, and exits them once work
+// in progress has completed.
+func (a *Admin) Drain() {
+	l := log.WithFields(log.Fields{
+		"pkg": "admin",
+		"fn":  "Drain",
+	})
+	l.Info("draining workers")
+	a.mu.Lock()
+	a.draining = true
+	a.mu.Unlock()
+	a.drain()
+}
+
+// Status returns the status of procx and its workers.
+func (a *Admin) Status() *Status {
+	now := time.Now()
+	a.mu.Lock()
+	s := &Status{
+		Started:  a.started,
+		Uptime:   now.Sub(a.started).Milliseconds(),
+		Paused:   a.Control.Paused(),
+		Draining: a.draining,
+	}
+	a.mu.Unlock()
+	if a.Breaker != nil {
+		s.Breaker = a.Breaker.State()
+	}
+	for _, j := range a.workers {
+		ws := WorkerStatus{Status: j.Status()}
+		if ws.JobStarted != nil {
+			ws.JobAge = now.Sub(*ws.JobStarted).Milliseconds()
+		}
+		s.Received += ws.Received
+		s.Succeeded += ws.Succeeded
+		s.Failed += ws.Failed
+		s.Poisoned += ws.Poisoned
+		if ws.LastErrorTime != nil && (s.LastErrorTime == nil || ws.LastErrorTime.After(*s.LastErrorTime)) {
+			s.LastError = ws.LastError
+			s.LastErrorTime = ws.LastErrorTime
+		}
+		s.Workers = append(s.Workers, ws)
+	}
+	return s
+}
+
+// Handler returns the HTTP handler for the admin API. GET /status returns
+// the status, and POST /pause, /resume and /drain perform the action and
+// return the resulting status.
+func (a *Admin) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", a.handle(http.MethodGet, nil))
+	mux.HandleFunc("/pause", a.handle(http.MethodPost, a.Pause))
+	mux.HandleFunc("/resume", a.handle(http.MethodPost, a.Resume))
+	mux.HandleFunc("/drain", a.handle(http.MethodPost, a.Drain))
+	return mux
+}
+
+// handle returns a handler which runs action, if set, and writes the status.
+func (a *Admin) handle(method string, action func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if action != nil {
+			action()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(a.Status()); err != nil {
+			log.WithFields(log.Fields{
+				"pkg": "admin",
+				"fn":  "handle",
+			}).WithError(err).Error("failed to write status")
+		}
+	}
+}
+
+// Listen listens on addr, which is either a TCP address such as
+// "127.0.0.1:8081", or a Unix socket path prefixed with "unix://". A stale
+// Unix socket at the path is removed before listening.
+func Listen(addr string) (net.Listener, error) {
+	if p := strings.TrimPrefix(addr, "unix://"); p != addr {
+		if p == "" {
+			return nil, ErrInvalidAddr
+		}
+		if fi, err := os.Lstat(p); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(p); err != nil {
+				return nil, err
+			}
+		}
+		return net.Listen("unix", p)
+	}
+	if addr == "" {
+		return nil, ErrInvalidAddr
+	}
+	return net.Listen("tcp", addr)
+}
+
+// Serve serves the admin API on ln until ctx is done.
+func (a *Admin) Serve(ctx context.Context, ln net.Listener) error {
+	l := log.WithFields(log.Fields{
+		"pkg":  "admin",
+		"fn":   "Serve",
+		"addr": ln.Addr().String(),
+	})
+	srv := &http.Server{Handler: a.Handler()}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	l.Info("serving admin API")
+	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+		l.WithError(err).Error("admin API")
+		return err
+	}
+	return nil
+}
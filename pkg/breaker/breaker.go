@@ -0,0 +1,251 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrInvalidThreshold = errors.New("invalid breaker threshold, expected a failure rate between 0 and 1")
+	ErrInvalidExitCodes = errors.New("invalid breaker unhealthy exit codes, expected comma separated integers")
+)
+
+// State is the state of a Breaker.
+type State string
+
+var (
+	// StateClosed retrieves work as normal
+	StateClosed State = "closed"
+	// StateOpen stops work retrieval until the cooldown has elapsed
+	StateOpen State = "open"
+	// StateHalfOpen allows a single trial job, which closes the breaker if
+	// it succeeds, and opens it again if it fails
+	StateHalfOpen State = "half-open"
+)
+
+// ParseExitCodes parses a comma separated list of exit codes.
+func ParseExitCodes(s string) ([]int, error) {
+	var codes []int
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		c, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, ErrInvalidExitCodes
+		}
+		codes = append(codes, c)
+	}
+	return codes, nil
+}
+
+// outcome is the result of a job completed at a time.
+type outcome struct {
+	at     time.Time
+	failed bool
+}
+
+// Breaker is a circuit breaker on the job failure rate, shared by all
+// workers. It opens when the failure rate of the jobs completed in the
+// sliding Window reaches Threshold, once at least MinJobs have completed, or
+// when a process exits with one of the UnhealthyExitCodes. While open, work
+// is not retrieved. After Cooldown, the breaker half opens and a single
+// trial job is run, which closes the breaker if it succeeds.
+type Breaker struct {
+	Threshold          float64
+	MinJobs            int
+	Window             time.Duration
+	Cooldown           time.Duration
+	UnhealthyExitCodes []int
+
+	mu       sync.Mutex
+	state    State
+	until    time.Time
+	outcomes []outcome
+	// changed is closed when the state changes
+	changed chan struct{}
+	// now returns the current time, and is replaced in tests
+	now func() time.Time
+}
+
+// New returns a closed Breaker.
+func New(threshold float64, minJobs int, window time.Duration, cooldown time.Duration, unhealthyExitCodes []int) (*Breaker, error) {
+	if threshold < 0 || threshold > 1 {
+		return nil, ErrInvalidThreshold
+	}
+	if minJobs < 1 {
+		minJobs = 1
+	}
+	return &Breaker{
+		Threshold:          threshold,
+		MinJobs:            minJobs,
+		Window:             window,
+		Cooldown:           cooldown,
+		UnhealthyExitCodes: unhealthyExitCodes,
+		state:              StateClosed,
+		changed:            make(chan struct{}),
+		now:                time.Now,
+	}, nil
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// setState changes the state of the breaker and wakes any waiters. b.mu must
+// be held.
+func (b *Breaker) setState(s State) {
+	b.state = s
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// open opens the breaker for the cooldown. b.mu must be held.
+func (b *Breaker) open(reason string) {
+	l := log.WithFields(log.Fields{
+		"pkg":      "breaker",
+		"fn":       "open",
+		"cooldown": b.Cooldown,
+	})
+	l.Warnf("circuit breaker open: %s", reason)
+	b.until = b.now().Add(b.Cooldown)
+	b.outcomes = nil
+	b.setState(StateOpen)
+}
+
+// Wait blocks while the breaker is open, or while a trial job is in
+// progress, or until ctx is done. It returns true if the caller is given the
+// trial job, in which case it must call Record with the result of the job,
+// or Abort if no job was run.
+func (b *Breaker) Wait(ctx context.Context) (bool, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "breaker",
+		"fn":  "Wait",
+	})
+	for {
+		b.mu.Lock()
+		if b.state == StateClosed {
+			b.mu.Unlock()
+			return false, nil
+		}
+		wait := b.until.Sub(b.now())
+		if b.state == StateOpen && wait <= 0 {
+			l.Info("circuit breaker half open, running a trial job")
+			b.setState(StateHalfOpen)
+			b.mu.Unlock()
+			return true, nil
+		}
+		changed := b.changed
+		b.mu.Unlock()
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return false, ctx.Err()
+		case <-changed:
+		case <-expired:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Abort gives up the trial job, if trial is set, when no job was run. The
+// next retrieval is given the trial.
+func (b *Breaker) Abort(trial bool) {
+	if !trial {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != StateHalfOpen {
+		return
+	}
+	b.until = b.now()
+	b.setState(StateOpen)
+}
+
+// unhealthy returns true if exitCode is one of the unhealthy exit codes.
+func (b *Breaker) unhealthy(exitCode *int) bool {
+	if exitCode == nil {
+		return false
+	}
+	for _, c := range b.UnhealthyExitCodes {
+		if c == *exitCode {
+			return true
+		}
+	}
+	return false
+}
+
+// Record records the result of a completed job. trial is the value returned
+// by Wait before the job was retrieved, and exitCode the exit code of its
+// process, or nil if no process exited.
+func (b *Breaker) Record(trial bool, failed bool, exitCode *int) {
+	l := log.WithFields(log.Fields{
+		"pkg": "breaker",
+		"fn":  "Record",
+	})
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	unhealthy := failed && b.unhealthy(exitCode)
+	if trial {
+		if b.state != StateHalfOpen {
+			return
+		}
+		if failed {
+			b.open("trial job failed")
+			return
+		}
+		l.Info("circuit breaker closed, trial job succeeded")
+		b.outcomes = nil
+		b.setState(StateClosed)
+		return
+	}
+	// jobs retrieved before the breaker opened do not count
+	if b.state != StateClosed {
+		return
+	}
+	if unhealthy {
+		b.open("process exited with unhealthy exit code " + strconv.Itoa(*exitCode))
+		return
+	}
+	now := b.now()
+	b.outcomes = append(b.outcomes, outcome{at: now, failed: failed})
+	i := 0
+	for i < len(b.outcomes) && now.Sub(b.outcomes[i].at) > b.Window {
+		i++
+	}
+	b.outcomes = b.outcomes[i:]
+	if b.Threshold <= 0 || len(b.outcomes) < b.MinJobs {
+		return
+	}
+	var n int
+	for _, o := range b.outcomes {
+		if o.failed {
+			n++
+		}
+	}
+	rate := float64(n) / float64(len(b.outcomes))
+	if rate >= b.Threshold {
+		b.open("failure rate " + strconv.FormatFloat(rate, 'f', 2, 64) + " of " + strconv.Itoa(len(b.outcomes)) + " jobs")
+	}
+}
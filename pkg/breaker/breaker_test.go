@@ -0,0 +1,275 @@
+package breaker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock which only moves when advanced.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// newBreaker returns a Breaker on a fake clock.
+func newBreaker(t *testing.T, threshold float64, minJobs int, codes ...int) (*Breaker, *fakeClock) {
+	t.Helper()
+	b, err := New(threshold, minJobs, time.Minute, 30*time.Second, codes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &fakeClock{t: time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)}
+	b.now = c.now
+	return b, c
+}
+
+// mustWait calls Wait with a context which is already done, so it returns
+// immediately unless the breaker is closed or a trial is available.
+func mustWait(t *testing.T, b *Breaker) (bool, error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return b.Wait(ctx)
+}
+
+func exitCode(c int) *int {
+	return &c
+}
+
+func TestNew(t *testing.T) {
+	for _, th := range []float64{-0.1, 1.1} {
+		if _, err := New(th, 1, time.Minute, time.Second, nil); err != ErrInvalidThreshold {
+			t.Errorf("New(%v) error = %v, want %v", th, err, ErrInvalidThreshold)
+		}
+	}
+	b, err := New(0.5, 0, time.Minute, time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.MinJobs != 1 {
+		t.Errorf("MinJobs = %d, want 1", b.MinJobs)
+	}
+	if b.State() != StateClosed {
+		t.Errorf("State() = %s, want %s", b.State(), StateClosed)
+	}
+}
+
+// job is a job recorded by a test.
+type job struct {
+	// after is how long after the previous job the job completes
+	after  time.Duration
+	failed bool
+	code   *int
+}
+
+// later returns j completing d after the previous job.
+func (j job) later(d time.Duration) job {
+	j.after = d
+	return j
+}
+
+func TestRecord(t *testing.T) {
+	ok := job{after: time.Second}
+	fail := job{after: time.Second, failed: true}
+	tests := []struct {
+		name      string
+		threshold float64
+		minJobs   int
+		codes     []int
+		jobs      []job
+		want      State
+	}{
+		{"all succeed", 0.5, 2, nil, []job{ok, ok, ok}, StateClosed},
+		{"below min jobs", 0.5, 3, nil, []job{fail, fail}, StateClosed},
+		{"at min jobs", 0.5, 3, nil, []job{fail, fail, fail}, StateOpen},
+		{"below threshold", 0.5, 1, nil, []job{ok, ok, fail}, StateClosed},
+		{"at threshold", 0.5, 1, nil, []job{ok, ok, fail, fail}, StateOpen},
+		{"threshold zero never opens", 0, 1, nil, []job{fail, fail, fail}, StateClosed},
+		{"window prunes old failures", 0.6, 2, nil,
+			[]job{fail, fail.later(2 * time.Minute), ok}, StateClosed},
+		{"window prunes old successes", 0.5, 2, nil,
+			[]job{ok, ok, ok, fail.later(2 * time.Minute), fail}, StateOpen},
+		{"pruned jobs do not count towards min jobs", 1, 2, nil,
+			[]job{fail, fail.later(2 * time.Minute)}, StateClosed},
+		{"unhealthy exit code", 0, 1, []int{137},
+			[]job{{after: time.Second, failed: true, code: exitCode(137)}}, StateOpen},
+		{"other exit code", 0, 1, []int{137},
+			[]job{{after: time.Second, failed: true, code: exitCode(1)}}, StateClosed},
+		{"unhealthy exit code of successful job", 0, 1, []int{0},
+			[]job{{after: time.Second, code: exitCode(0)}}, StateClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, c := newBreaker(t, tt.threshold, tt.minJobs, tt.codes...)
+			for _, j := range tt.jobs {
+				c.advance(j.after)
+				b.Record(false, j.failed, j.code)
+			}
+			if got := b.State(); got != tt.want {
+				t.Errorf("State() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	b, c := newBreaker(t, 1, 1)
+	b.Record(false, true, nil)
+	if b.State() != StateOpen {
+		t.Fatalf("State() = %s, want %s", b.State(), StateOpen)
+	}
+	// jobs retrieved before the breaker opened do not count
+	b.Record(false, false, nil)
+	if b.State() != StateOpen {
+		t.Fatalf("State() = %s after a late job, want %s", b.State(), StateOpen)
+	}
+	c.advance(29 * time.Second)
+	if trial, err := mustWait(t, b); trial || err == nil {
+		t.Fatalf("Wait() during cooldown = %v, %v, want false, error", trial, err)
+	}
+	c.advance(time.Second)
+	trial, err := mustWait(t, b)
+	if !trial || err != nil {
+		t.Fatalf("Wait() after cooldown = %v, %v, want true, nil", trial, err)
+	}
+	if b.State() != StateHalfOpen {
+		t.Fatalf("State() = %s, want %s", b.State(), StateHalfOpen)
+	}
+	// only one trial is given
+	if trial, err := mustWait(t, b); trial || err == nil {
+		t.Fatalf("Wait() during trial = %v, %v, want false, error", trial, err)
+	}
+}
+
+func TestTrial(t *testing.T) {
+	tests := []struct {
+		name   string
+		failed bool
+		want   State
+	}{
+		{"success closes", false, StateClosed},
+		{"failure opens", true, StateOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, c := newBreaker(t, 1, 1)
+			b.Record(false, true, nil)
+			c.advance(b.Cooldown)
+			trial, _ := mustWait(t, b)
+			if !trial {
+				t.Fatal("no trial")
+			}
+			// jobs which are not the trial do not change the state
+			b.Record(false, false, nil)
+			if b.State() != StateHalfOpen {
+				t.Fatalf("State() = %s, want %s", b.State(), StateHalfOpen)
+			}
+			b.Record(true, tt.failed, nil)
+			if got := b.State(); got != tt.want {
+				t.Fatalf("State() = %s, want %s", got, tt.want)
+			}
+			if tt.failed {
+				// a failed trial starts a new cooldown
+				if trial, _ := mustWait(t, b); trial {
+					t.Error("trial given before the cooldown")
+				}
+				c.advance(b.Cooldown)
+				if trial, _ := mustWait(t, b); !trial {
+					t.Error("no trial after the cooldown")
+				}
+				return
+			}
+			// the outcomes before the breaker opened are forgotten
+			b.Record(false, false, nil)
+			if b.State() != StateClosed {
+				t.Errorf("State() = %s, want %s", b.State(), StateClosed)
+			}
+		})
+	}
+}
+
+func TestAbort(t *testing.T) {
+	b, c := newBreaker(t, 1, 1)
+	b.Record(false, true, nil)
+	c.advance(b.Cooldown)
+	trial, _ := mustWait(t, b)
+	if !trial {
+		t.Fatal("no trial")
+	}
+	// Abort without the trial does nothing
+	b.Abort(false)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("State() = %s, want %s", b.State(), StateHalfOpen)
+	}
+	// a worker waiting for the trial is given it once it is aborted
+	got := make(chan bool)
+	go func() {
+		trial, _ := b.Wait(context.Background())
+		got <- trial
+	}()
+	b.Abort(true)
+	select {
+	case trial := <-got:
+		if !trial {
+			t.Error("waiting worker was not given the trial")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting worker was not woken by Abort")
+	}
+	// a late Abort after the trial completed does nothing
+	b.Record(true, false, nil)
+	b.Abort(true)
+	if b.State() != StateClosed {
+		t.Errorf("State() = %s, want %s", b.State(), StateClosed)
+	}
+}
+
+func TestWaitClosed(t *testing.T) {
+	b, _ := newBreaker(t, 1, 1)
+	if trial, err := mustWait(t, b); trial || err != nil {
+		t.Errorf("Wait() = %v, %v, want false, nil", trial, err)
+	}
+}
+
+func TestParseExitCodes(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []int
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"1", []int{1}, false},
+		{" 1, 137 ,,", []int{1, 137}, false},
+		{"1,x", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseExitCodes(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseExitCodes(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseExitCodes(%q) = %v, want %v", tt.s, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("ParseExitCodes(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		}
+	}
+}
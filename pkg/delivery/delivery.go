@@ -0,0 +1,95 @@
+package delivery
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultSize is the default number of work items a Counter tracks.
+const DefaultSize = 10000
+
+// Counter counts the deliveries of work items in procx, for drivers whose
+// source does not report them. It is shared by all workers, and tracks up to
+// size items, forgetting the least recently delivered beyond that, and those
+// not delivered again within ttl.
+type Counter struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List
+	items map[string]*list.Element
+	now   func() time.Time
+}
+
+// entry is a work item, its delivery count, and the time of its last
+// delivery.
+type entry struct {
+	key   string
+	count int
+	last  time.Time
+}
+
+// NewCounter returns a Counter which tracks up to size work items, each for
+// ttl after its last delivery. A ttl of 0 keeps items until they are evicted
+// by size.
+func NewCounter(size int, ttl time.Duration) *Counter {
+	if size < 1 {
+		size = DefaultSize
+	}
+	return &Counter{
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+		now:   time.Now,
+	}
+}
+
+// Add counts a delivery of the work item key, and returns the number of
+// times it has been delivered, including this delivery.
+func (c *Counter) Add(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.expire(now)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry)
+		e.count++
+		e.last = now
+		c.order.MoveToFront(el)
+		return e.count
+	}
+	c.items[key] = c.order.PushFront(&entry{key: key, count: 1, last: now})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return 1
+}
+
+// expire forgets the items last delivered more than ttl before now. Items
+// are ordered by their last delivery, so only the expired items are visited.
+// c.mu must be held.
+func (c *Counter) expire(now time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+	for el := c.order.Back(); el != nil && now.Sub(el.Value.(*entry).last) > c.ttl; el = c.order.Back() {
+		c.remove(el)
+	}
+}
+
+// remove forgets the item el. c.mu must be held.
+func (c *Counter) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*entry).key)
+}
+
+// Reset forgets the work item key, once it has been cleared from the source.
+func (c *Counter) Reset(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
@@ -0,0 +1,144 @@
+package delivery
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCounterAdd(t *testing.T) {
+	c := NewCounter(10, 0)
+	for i := 1; i <= 3; i++ {
+		if got := c.Add("a"); got != i {
+			t.Fatalf("Add(a) = %d, want %d", got, i)
+		}
+	}
+	if got := c.Add("b"); got != 1 {
+		t.Fatalf("Add(b) = %d, want 1", got)
+	}
+}
+
+func TestCounterEviction(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		// adds are the keys added in order
+		adds []string
+		// key is added once more after adds, and want is its count
+		key  string
+		want int
+	}{
+		{"within size", 2, []string{"a", "b"}, "a", 2},
+		{"least recent evicted", 2, []string{"a", "b", "c"}, "a", 1},
+		{"more recent kept", 2, []string{"a", "b", "c"}, "b", 2},
+		{"delivery refreshes", 2, []string{"a", "b", "a", "c"}, "a", 3},
+		{"refreshed key not evicted", 2, []string{"a", "b", "a", "c"}, "b", 1},
+		{"size one", 1, []string{"a", "b"}, "a", 1},
+		{"default size", 0, []string{"a", "b", "c"}, "a", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCounter(tt.size, 0)
+			for _, k := range tt.adds {
+				c.Add(k)
+			}
+			if got := c.Add(tt.key); got != tt.want {
+				t.Errorf("Add(%s) = %d, want %d", tt.key, got, tt.want)
+			}
+			if len(c.items) != c.order.Len() || c.order.Len() > c.size {
+				t.Errorf("tracking %d items in a list of %d, size %d", len(c.items), c.order.Len(), c.size)
+			}
+		})
+	}
+}
+
+func TestCounterTTL(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		// adds are the keys added in order, the clock advancing by after
+		// following each
+		adds  []string
+		after time.Duration
+		// key is added once more after adds, and want is its count
+		key  string
+		want int
+	}{
+		{"within ttl", time.Minute, []string{"a", "a"}, 30 * time.Second, "a", 3},
+		{"expired", time.Minute, []string{"a", "b"}, 45 * time.Second, "a", 1},
+		{"delivery refreshes", time.Minute, []string{"a", "a", "a"}, 45 * time.Second, "a", 4},
+		{"refreshed key kept", time.Minute, []string{"a", "b", "b"}, 45 * time.Second, "b", 3},
+		{"no ttl", 0, []string{"a", "b"}, time.Hour, "a", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+			c := NewCounter(10, tt.ttl)
+			c.now = func() time.Time { return now }
+			for _, k := range tt.adds {
+				c.Add(k)
+				now = now.Add(tt.after)
+			}
+			if got := c.Add(tt.key); got != tt.want {
+				t.Errorf("Add(%s) = %d, want %d", tt.key, got, tt.want)
+			}
+		})
+	}
+	// expired items are forgotten on the next delivery of any item
+	now := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+	c := NewCounter(10, time.Minute)
+	c.now = func() time.Time { return now }
+	for i := 0; i < 5; i++ {
+		c.Add(strconv.Itoa(i))
+	}
+	now = now.Add(2 * time.Minute)
+	c.Add("new")
+	if len(c.items) != 1 || c.order.Len() != 1 {
+		t.Errorf("tracking %d items in a list of %d, want 1", len(c.items), c.order.Len())
+	}
+}
+
+func TestCounterReset(t *testing.T) {
+	c := NewCounter(2, 0)
+	c.Add("a")
+	c.Add("a")
+	c.Add("b")
+	c.Reset("a")
+	if got := c.Add("a"); got != 1 {
+		t.Errorf("Add(a) after Reset = %d, want 1", got)
+	}
+	if got := c.Add("b"); got != 2 {
+		t.Errorf("Add(b) = %d, want 2", got)
+	}
+	// resetting an unknown key is a no-op
+	c.Reset("c")
+	if len(c.items) != 2 || c.order.Len() != 2 {
+		t.Errorf("tracking %d items in a list of %d, want 2", len(c.items), c.order.Len())
+	}
+	// a reset frees its slot, so no other key is evicted
+	c.Reset("a")
+	c.Add("c")
+	if got := c.Add("b"); got != 3 {
+		t.Errorf("Add(b) = %d, want 3", got)
+	}
+}
+
+func TestCounterConcurrent(t *testing.T) {
+	c := NewCounter(100, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				c.Add("shared")
+				c.Add(strconv.Itoa(i))
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := c.Add("shared"); got != 1001 {
+		t.Errorf("Add(shared) = %d, want 1001", got)
+	}
+}
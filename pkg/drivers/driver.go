@@ -1,8 +1,15 @@
 package drivers
 
-import "io"
+import (
+	"io"
 
-// Driver is the interface that must be implemented by a driver.
+	"github.com/robertlestak/procx/pkg/record"
+	"github.com/robertlestak/procx/pkg/schema"
+)
+
+// Driver is the interface that must be implemented by a driver. GetWork
+// returns a nil reader and nil error when there is currently no work, and
+// io.EOF when the source is exhausted and will never return more work.
 type Driver interface {
 	LoadEnv(string) error
 	LoadFlags() error
@@ -12,3 +19,124 @@ type Driver interface {
 	HandleFailure() error
 	Cleanup() error
 }
+
+// Previewer is implemented by drivers which can describe the action ClearWork
+// and HandleFailure would take on the current work, with all templates
+// rendered, without performing it. A nil map means no action would be taken.
+type Previewer interface {
+	PreviewClearWork() (map[string]any, error)
+	PreviewHandleFailure() (map[string]any, error)
+}
+
+// Releaser is implemented by drivers whose GetWork claims or removes the work
+// from the source, and which can return the work to the source untouched.
+type Releaser interface {
+	ReleaseWork() error
+}
+
+// Redeliverer is implemented by drivers which report whether HandleFailure
+// leaves the current work in the source to be delivered again, rather than
+// moving, deleting or dropping it.
+type Redeliverer interface {
+	Redelivers() bool
+}
+
+// Redelivers returns true if the current work of d is delivered again after
+// HandleFailure, as reported by d, or because d previews no failure action,
+// so the work is left in the source. It must be called before HandleFailure.
+func Redelivers(d Driver) bool {
+	if r, ok := d.(Redeliverer); ok {
+		return r.Redelivers()
+	}
+	p, ok := d.(Previewer)
+	if !ok {
+		return false
+	}
+	m, err := p.PreviewHandleFailure()
+	return err == nil && m == nil
+}
+
+// Releasable returns true if the current work of d can be returned to the
+// source to be delivered again, as d implements Releaser, or its failure
+// handling redelivers the work. For a MultiDriver, the source of the current
+// work must be releasable, or all the sources if there is no current work.
+func Releasable(d Driver) bool {
+	if md, ok := d.(*MultiDriver); ok {
+		if md.active != nil {
+			return Releasable(md.active.Driver)
+		}
+		for _, s := range md.Sources {
+			if !Releasable(s.Driver) {
+				return false
+			}
+		}
+		return true
+	}
+	if _, ok := d.(Releaser); ok {
+		return true
+	}
+	return Redelivers(d)
+}
+
+// Peekable returns true if work can be retrieved from d without consuming it,
+// as d can preview the actions taken on it, leaving it in the source, or can
+// release it back to the source. All the sources of a MultiDriver must be
+// peekable.
+func Peekable(d Driver) bool {
+	if md, ok := d.(*MultiDriver); ok {
+		for _, s := range md.Sources {
+			if !Peekable(s.Driver) {
+				return false
+			}
+		}
+		return true
+	}
+	_, p := d.(Previewer)
+	_, r := d.(Releaser)
+	return p || r
+}
+
+// Replayer is implemented by drivers which replay recorded work. The record
+// of the current work describes the process invocation it was recorded with.
+type Replayer interface {
+	ReplayRecord() *record.Record
+}
+
+// ResultSetter is implemented by drivers which replace the result of the job
+// in their clear and fail templates. SetResult is called with the result of
+// the current work before ClearWork or HandleFailure.
+type ResultSetter interface {
+	SetResult(r *schema.JobResult)
+}
+
+// Publisher is implemented by drivers which can publish new messages, to pass
+// the result of a job to a downstream driver. InitPublisher initializes the
+// driver to publish rather than consume, and ClosePublisher cleans it up. meta
+// is the correlation metadata of the message, which is sent as message
+// headers or attributes where the driver supports them.
+type Publisher interface {
+	InitPublisher() error
+	Publish(body []byte, meta map[string]string) error
+	ClosePublisher() error
+}
+
+// DepthReporter is implemented by drivers which can report the backlog of
+// work waiting in the source, to drive autoscaling.
+type DepthReporter interface {
+	Depth() (int64, error)
+}
+
+// DeliveryCounter is implemented by drivers whose source reports the number of
+// times the current work has been delivered, including the current delivery.
+// DeliveryCount returns 0 if the source did not report it.
+type DeliveryCounter interface {
+	DeliveryCount() int
+}
+
+// WorkIdentifier is implemented by drivers whose work has an ID which is
+// stable across deliveries, so procx can count the deliveries of work whose
+// source does not. WorkID returns an empty string if the current work has no
+// ID.
+type WorkIdentifier interface {
+	WorkID() string
+}
@@ -67,9 +67,17 @@ var (
 	SMB               DriverName = "smb"
 	Scylla            DriverName = "scylla"
 	Local             DriverName = "local"
+	Multi             DriverName = "multi"
+	Replay            DriverName = "replay"
 	ErrDriverNotFound            = errors.New("driver not found")
 )
 
+var (
+	ErrPublishNotSupported = errors.New("driver does not support publishing")
+	ErrDepthNotSupported   = errors.New("driver does not support depth reporting")
+	ErrReleaseNotSupported = errors.New("driver does not support releasing work")
+)
+
 // Get returns the driver with the given name.
 func GetDriver(name DriverName) Driver {
 	switch name {
@@ -139,6 +147,10 @@ func GetDriver(name DriverName) Driver {
 		return &scylla.Scylla{}
 	case Local:
 		return &local.Local{}
+	case Replay:
+		return &local.Replay{}
+	case Multi:
+		return &MultiDriver{}
 	}
 	return nil
 }
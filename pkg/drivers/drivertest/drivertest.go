@@ -0,0 +1,178 @@
+// Package drivertest provides a conformance suite which can be run against
+// any drivers.Driver, backed by a local stand-in for its data source such as
+// miniredis, an embedded NATS server, or a temp dir. For example:
+//
+//	func TestConformance(t *testing.T) {
+//		drivertest.Run(t, drivertest.Harness{
+//			Setup: func(t testing.TB) (drivers.Driver, drivertest.PushFunc) {
+//				q := memory.NewQueue(time.Second)
+//				push := func(b []byte) error {
+//					q.Push(b)
+//					return nil
+//				}
+//				return &memory.Memory{Queue: q}, push
+//			},
+//			Redelivers: true,
+//		})
+//	}
+package drivertest
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/robertlestak/procx/pkg/drivers"
+)
+
+// PushFunc adds work to the data source under test.
+type PushFunc func(payload []byte) error
+
+// Harness describes the driver under test.
+type Harness struct {
+	// Setup returns a new driver which has had its configuration loaded but
+	// has not been initialized, bound to a fresh, empty data source, and a
+	// function to add work to that data source.
+	Setup func(t testing.TB) (drivers.Driver, PushFunc)
+	// Redelivers reports whether work is made available again after
+	// HandleFailure, for drivers which support redelivery.
+	Redelivers bool
+	// Wait is how long to wait for work to become available after it is
+	// pushed or redelivered. Defaults to 5 seconds.
+	Wait time.Duration
+}
+
+func (h Harness) wait() time.Duration {
+	if h.Wait == 0 {
+		return 5 * time.Second
+	}
+	return h.Wait
+}
+
+// Run runs the conformance suite against the driver described by h.
+func Run(t *testing.T, h Harness) {
+	t.Run("EmptyQueue", func(t *testing.T) { testEmptyQueue(t, h) })
+	t.Run("GetWork", func(t *testing.T) { testGetWork(t, h) })
+	t.Run("ClearRemovesWork", func(t *testing.T) { testClearRemovesWork(t, h) })
+	if h.Redelivers {
+		t.Run("FailureRedelivers", func(t *testing.T) { testFailureRedelivers(t, h) })
+	}
+	t.Run("CleanupIdempotent", func(t *testing.T) { testCleanupIdempotent(t, h) })
+}
+
+// setup creates and initializes the driver under test, and cleans it up at
+// the end of the test.
+func setup(t *testing.T, h Harness) (drivers.Driver, PushFunc) {
+	t.Helper()
+	d, push := h.Setup(t)
+	if err := d.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := d.Cleanup(); err != nil {
+			t.Errorf("Cleanup: %v", err)
+		}
+	})
+	return d, push
+}
+
+// getWork polls the driver until work is returned or the wait expires, and
+// returns the payload, or nil if there was no work.
+func getWork(t *testing.T, d drivers.Driver, wait time.Duration) []byte {
+	t.Helper()
+	deadline := time.Now().Add(wait)
+	for {
+		r, err := d.GetWork()
+		if err != nil && err != io.EOF {
+			t.Fatalf("GetWork: %v", err)
+		}
+		if r != nil {
+			bd, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("reading work: %v", err)
+			}
+			return bd
+		}
+		if err == io.EOF || time.Now().After(deadline) {
+			return nil
+		}
+		time.Sleep(wait / 20)
+	}
+}
+
+func testEmptyQueue(t *testing.T, h Harness) {
+	d, _ := setup(t, h)
+	r, err := d.GetWork()
+	if err != nil && err != io.EOF {
+		t.Fatalf("GetWork on empty source returned error: %v", err)
+	}
+	if r != nil {
+		t.Fatal("GetWork on empty source returned work")
+	}
+}
+
+func testGetWork(t *testing.T, h Harness) {
+	d, push := setup(t, h)
+	want := []byte(`{"id":1,"work":"conformance"}`)
+	if err := push(want); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	got := getWork(t, d, h.wait())
+	if !bytes.Equal(got, want) {
+		t.Fatalf("GetWork returned %q, want %q", got, want)
+	}
+	if err := d.ClearWork(); err != nil {
+		t.Fatalf("ClearWork: %v", err)
+	}
+}
+
+func testClearRemovesWork(t *testing.T, h Harness) {
+	d, push := setup(t, h)
+	if err := push([]byte("clear")); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if got := getWork(t, d, h.wait()); got == nil {
+		t.Fatal("GetWork returned no work")
+	}
+	if err := d.ClearWork(); err != nil {
+		t.Fatalf("ClearWork: %v", err)
+	}
+	if got := getWork(t, d, h.wait()); got != nil {
+		t.Fatalf("GetWork returned cleared work %q", got)
+	}
+}
+
+func testFailureRedelivers(t *testing.T, h Harness) {
+	d, push := setup(t, h)
+	want := []byte("fail")
+	if err := push(want); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if got := getWork(t, d, h.wait()); got == nil {
+		t.Fatal("GetWork returned no work")
+	}
+	if err := d.HandleFailure(); err != nil {
+		t.Fatalf("HandleFailure: %v", err)
+	}
+	got := getWork(t, d, h.wait())
+	if !bytes.Equal(got, want) {
+		t.Fatalf("GetWork after HandleFailure returned %q, want %q", got, want)
+	}
+	if err := d.ClearWork(); err != nil {
+		t.Fatalf("ClearWork: %v", err)
+	}
+}
+
+func testCleanupIdempotent(t *testing.T, h Harness) {
+	d, _ := h.Setup(t)
+	if err := d.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := d.Cleanup(); err != nil {
+		t.Fatalf("first Cleanup: %v", err)
+	}
+	if err := d.Cleanup(); err != nil {
+		t.Fatalf("second Cleanup: %v", err)
+	}
+}
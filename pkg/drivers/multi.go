@@ -0,0 +1,591 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robertlestak/procx/pkg/flags"
+	"github.com/robertlestak/procx/pkg/schema"
+	log "github.com/sirupsen/logrus"
+)
+
+type MultiSelect string
+
+var (
+	MultiSelectPriority MultiSelect = "priority"
+	MultiSelectWeighted MultiSelect = "weighted"
+)
+
+var (
+	ErrInvalidMultiSources = errors.New("invalid multi sources, expected format is name=driver[:weight]")
+	multiSourceName        = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+// MultiSource is a single source of work of the MultiDriver.
+type MultiSource struct {
+	Name       string     `json:"name"`
+	DriverName DriverName `json:"driverName"`
+	Weight     int        `json:"weight"`
+	Driver     Driver     `json:"driver"`
+	// current is the smooth weighted round-robin state of the source
+	current int
+	// done is set once the source returns io.EOF
+	done bool
+	// pending is the GetWork call of a source which does not implement
+	// DriverV2 still in progress after PollTimeout
+	pending *poll
+}
+
+// poll is a GetWork call of a source, which may outlive the PollTimeout it
+// was waited on for.
+type poll struct {
+	done chan struct{}
+	work io.Reader
+	err  error
+}
+
+// MultiDriver consumes work from several sources, selected by strict priority in
+// the order they are configured, or by weighted round-robin. Sources without
+// work are skipped. The source of the current work is kept, so the work is
+// cleared or failed in the source it was received from.
+//
+// When multi is used as a publisher, it publishes to PublishSource, or the
+// first source if it is not set.
+type MultiDriver struct {
+	Sources       []*MultiSource `json:"sources"`
+	Select        MultiSelect    `json:"select"`
+	PollTimeout   time.Duration  `json:"pollTimeout"`
+	PublishSource string         `json:"publishSource"`
+	active        *MultiSource
+	publisher     Publisher
+}
+
+// ParseMultiSources parses a comma separated list of sources in the form
+// name=driver[:weight].
+func ParseMultiSources(s string) ([]*MultiSource, error) {
+	var sources []*MultiSource
+	names := make(map[string]bool)
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 || !multiSourceName.MatchString(kv[0]) || names[strings.ToUpper(kv[0])] {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMultiSources, p)
+		}
+		names[strings.ToUpper(kv[0])] = true
+		src := &MultiSource{Name: kv[0], Weight: 1}
+		dw := strings.SplitN(kv[1], ":", 2)
+		src.DriverName = DriverName(dw[0])
+		if len(dw) == 2 {
+			w, err := strconv.Atoi(dw[1])
+			if err != nil || w < 1 {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidMultiSources, p)
+			}
+			src.Weight = w
+		}
+		if src.DriverName == Multi {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMultiSources, p)
+		}
+		if src.Driver = GetDriver(src.DriverName); src.Driver == nil {
+			return nil, fmt.Errorf("%w: %s", ErrDriverNotFound, src.DriverName)
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// envPrefix returns the environment variable prefix of the source.
+func (s *MultiSource) envPrefix(prefix string) string {
+	return prefix + strings.ToUpper(s.Name) + "_"
+}
+
+// load loads the driver options from the flags or the environment. Sources
+// which are set are parsed, and their drivers load the flags.
+func (d *MultiDriver) load(v *flags.Values) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "multi",
+		"fn":  "load",
+	})
+	if s, ok := v.Lookup(flags.MultiSelect); ok {
+		d.Select = MultiSelect(s)
+	}
+	v.Func(flags.MultiPollTimeout, func(s string) error {
+		ms, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		d.PollTimeout = time.Duration(ms) * time.Millisecond
+		return nil
+	})
+	v.String(&d.PublishSource, flags.MultiPublishSource)
+	if s, ok := v.Lookup(flags.MultiSources); ok && s != "" {
+		sources, err := ParseMultiSources(s)
+		if err != nil {
+			l.Error(err)
+			return err
+		}
+		for _, s := range sources {
+			if err := s.Driver.LoadFlags(); err != nil {
+				return err
+			}
+		}
+		d.Sources = sources
+	}
+	return v.Err()
+}
+
+func (d *MultiDriver) LoadEnv(prefix string) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "multi",
+		"fn":  "LoadEnv",
+	})
+	l.Debug("Loading environment")
+	if err := d.load(flags.EnvValues(prefix)); err != nil {
+		l.Error(err)
+		return err
+	}
+	// each source loads the shared environment, and then its own
+	for _, s := range d.Sources {
+		if err := s.Driver.LoadEnv(prefix); err != nil {
+			return err
+		}
+		if err := s.Driver.LoadEnv(s.envPrefix(prefix)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *MultiDriver) LoadFlags() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "multi",
+		"fn":  "LoadFlags",
+	})
+	l.Debug("Loading flags")
+	d.Sources = nil
+	return d.load(flags.FlagValues())
+}
+
+func (d *MultiDriver) Init() error {
+	return d.InitContext(context.Background())
+}
+
+func (d *MultiDriver) InitContext(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "multi",
+		"fn":  "Init",
+	})
+	l.Debug("Initializing multi driver")
+	if len(d.Sources) == 0 {
+		l.Error(ErrInvalidMultiSources)
+		return ErrInvalidMultiSources
+	}
+	switch d.Select {
+	case MultiSelectPriority, MultiSelectWeighted:
+	default:
+		return errors.New("invalid multi-select")
+	}
+	for i, s := range d.Sources {
+		if err := AsV2(s.Driver).InitContext(ctx); err != nil {
+			l.WithError(err).Errorf("failed to initialize source %s", s.Name)
+			for _, is := range d.Sources[:i] {
+				is.Driver.Cleanup()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// order returns the sources to try for the next work, skipping sources which
+// have no more work.
+func (d *MultiDriver) order() []*MultiSource {
+	var order []*MultiSource
+	for _, s := range d.Sources {
+		if !s.done {
+			order = append(order, s)
+		}
+	}
+	if d.Select != MultiSelectWeighted || len(order) < 2 {
+		return order
+	}
+	// smooth weighted round-robin selects the first source to try, and the
+	// remaining sources are tried in priority order
+	var total int
+	best := 0
+	for i, s := range order {
+		s.current += s.Weight
+		total += s.Weight
+		if s.current > order[best].current {
+			best = i
+		}
+	}
+	order[best].current -= total
+	first := order[best]
+	return append([]*MultiSource{first}, append(order[:best:best], order[best+1:]...)...)
+}
+
+// getWork gets work from s. Sources which block until work is received are
+// waited on for at most PollTimeout, so other sources are not starved.
+func (d *MultiDriver) getWork(ctx context.Context, s *MultiSource) (io.Reader, error) {
+	if d.PollTimeout <= 0 {
+		return AsV2(s.Driver).GetWorkContext(ctx)
+	}
+	pctx, cancel := context.WithTimeout(ctx, d.PollTimeout)
+	defer cancel()
+	var w io.Reader
+	var err error
+	if v2, ok := s.Driver.(DriverV2); ok {
+		w, err = v2.GetWorkContext(pctx)
+	} else {
+		w, err = s.poll(pctx)
+	}
+	if err != nil && ctx.Err() == nil && pctx.Err() != nil {
+		return nil, nil
+	}
+	return w, err
+}
+
+// poll gets work from a source which does not implement DriverV2, and so
+// cannot be cancelled. If ctx is done first, the call is left running, and
+// its result is returned by the next poll of the source, so no work is lost.
+func (s *MultiSource) poll(ctx context.Context) (io.Reader, error) {
+	if s.pending == nil {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		p := &poll{done: make(chan struct{})}
+		go func() {
+			p.work, p.err = s.Driver.GetWork()
+			close(p.done)
+		}()
+		s.pending = p
+	}
+	select {
+	case <-s.pending.done:
+		p := s.pending
+		s.pending = nil
+		return p.work, p.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (d *MultiDriver) GetWork() (io.Reader, error) {
+	return d.GetWorkContext(context.Background())
+}
+
+func (d *MultiDriver) GetWorkContext(ctx context.Context) (io.Reader, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "multi",
+		"fn":  "GetWork",
+	})
+	l.Debug("Getting work")
+	d.active = nil
+	order := d.order()
+	if len(order) == 0 {
+		return nil, io.EOF
+	}
+	var firstErr error
+	for _, s := range order {
+		w, err := d.getWork(ctx, s)
+		if err == io.EOF {
+			l.Debugf("source %s has no more work", s.Name)
+			s.done = true
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			// a failing source does not stop work from the other sources
+			l.WithError(err).Errorf("failed to get work from source %s", s.Name)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if w != nil {
+			l.Debugf("received work from source %s", s.Name)
+			d.active = s
+			return w, nil
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	for _, s := range d.Sources {
+		if !s.done {
+			return nil, nil
+		}
+	}
+	return nil, io.EOF
+}
+
+// Source returns the name of the source the current work was received
+// from, or an empty string if there is no current work.
+func (d *MultiDriver) Source() string {
+	if d.active == nil {
+		return ""
+	}
+	return d.active.Name
+}
+
+func (d *MultiDriver) ClearWork() error {
+	return d.ClearWorkContext(context.Background())
+}
+
+func (d *MultiDriver) ClearWorkContext(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"pkg":    "multi",
+		"fn":     "ClearWork",
+		"source": d.Source(),
+	})
+	l.Debug("Clearing work")
+	if d.active == nil {
+		return nil
+	}
+	return AsV2(d.active.Driver).ClearWorkContext(ctx)
+}
+
+func (d *MultiDriver) HandleFailure() error {
+	return d.HandleFailureContext(context.Background())
+}
+
+func (d *MultiDriver) HandleFailureContext(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"pkg":    "multi",
+		"fn":     "HandleFailure",
+		"source": d.Source(),
+	})
+	l.Debug("Handling failure")
+	if d.active == nil {
+		return nil
+	}
+	return AsV2(d.active.Driver).HandleFailureContext(ctx)
+}
+
+// SetResult passes the job result to the source of the current work.
+func (d *MultiDriver) SetResult(r *schema.JobResult) {
+	if d.active == nil {
+		return
+	}
+	if rs, ok := d.active.Driver.(ResultSetter); ok {
+		rs.SetResult(r)
+	}
+}
+
+// ReleaseWork returns the current work to its source to be delivered again,
+// with the ReleaseWork of the source, or its HandleFailure if the source
+// redelivers failed work. Work from sources which preview their actions is
+// left in the source until it is cleared or failed, so is not released.
+func (d *MultiDriver) ReleaseWork() error {
+	l := log.WithFields(log.Fields{
+		"pkg":    "multi",
+		"fn":     "ReleaseWork",
+		"source": d.Source(),
+	})
+	l.Debug("Releasing work")
+	if d.active == nil {
+		return nil
+	}
+	if r, ok := d.active.Driver.(Releaser); ok {
+		return r.ReleaseWork()
+	}
+	if _, ok := d.active.Driver.(Previewer); ok {
+		return nil
+	}
+	if !Redelivers(d.active.Driver) {
+		return fmt.Errorf("%w: source %s", ErrReleaseNotSupported, d.active.Name)
+	}
+	// the work is released, not failed, so fail templates have no result
+	if rs, ok := d.active.Driver.(ResultSetter); ok {
+		rs.SetResult(nil)
+	}
+	return d.active.Driver.HandleFailure()
+}
+
+// Redelivers returns true if the source of the current work delivers it
+// again after HandleFailure.
+func (d *MultiDriver) Redelivers() bool {
+	if d.active == nil {
+		return false
+	}
+	return Redelivers(d.active.Driver)
+}
+
+// DeliveryCount returns the delivery count of the current work reported by
+// its source, or 0 if the source does not report it.
+func (d *MultiDriver) DeliveryCount() int {
+	if d.active == nil {
+		return 0
+	}
+	if dc, ok := d.active.Driver.(DeliveryCounter); ok {
+		return dc.DeliveryCount()
+	}
+	return 0
+}
+
+// WorkID returns the ID of the current work, prefixed with its source name,
+// or an empty string if the source has no ID for it.
+func (d *MultiDriver) WorkID() string {
+	if d.active == nil {
+		return ""
+	}
+	wi, ok := d.active.Driver.(WorkIdentifier)
+	if !ok {
+		return ""
+	}
+	id := wi.WorkID()
+	if id == "" {
+		return ""
+	}
+	return d.active.Name + ":" + id
+}
+
+// Depth returns the sum of the depths of the sources. All sources must
+// report their depth.
+func (d *MultiDriver) Depth() (int64, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "multi",
+		"fn":  "Depth",
+	})
+	l.Debug("Getting depth")
+	var n int64
+	for _, s := range d.Sources {
+		dr, ok := s.Driver.(DepthReporter)
+		if !ok {
+			return 0, fmt.Errorf("%w: source %s", ErrDepthNotSupported, s.Name)
+		}
+		sn, err := dr.Depth()
+		if err != nil {
+			l.WithError(err).Errorf("failed to get depth of source %s", s.Name)
+			return 0, err
+		}
+		n += sn
+	}
+	return n, nil
+}
+
+// PreviewClearWork returns the clear action of the source of the current
+// work, along with the source name.
+func (d *MultiDriver) PreviewClearWork() (map[string]any, error) {
+	return d.preview(Previewer.PreviewClearWork)
+}
+
+// PreviewHandleFailure returns the failure action of the source of the
+// current work, along with the source name.
+func (d *MultiDriver) PreviewHandleFailure() (map[string]any, error) {
+	return d.preview(Previewer.PreviewHandleFailure)
+}
+
+func (d *MultiDriver) preview(f func(Previewer) (map[string]any, error)) (map[string]any, error) {
+	if d.active == nil {
+		return nil, nil
+	}
+	m := map[string]any{}
+	if p, ok := d.active.Driver.(Previewer); ok {
+		pm, err := f(p)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range pm {
+			m[k] = v
+		}
+	}
+	m["source"] = d.active.Name
+	return m, nil
+}
+
+// InitPublisher initializes PublishSource, or the first source, for
+// publishing.
+func (d *MultiDriver) InitPublisher() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "multi",
+		"fn":  "InitPublisher",
+	})
+	l.Debug("Initializing publisher")
+	if len(d.Sources) == 0 {
+		l.Error(ErrInvalidMultiSources)
+		return ErrInvalidMultiSources
+	}
+	s := d.Sources[0]
+	if d.PublishSource != "" {
+		s = nil
+		for _, ss := range d.Sources {
+			if strings.EqualFold(ss.Name, d.PublishSource) {
+				s = ss
+			}
+		}
+		if s == nil {
+			err := fmt.Errorf("%w: publish source %q not found", ErrInvalidMultiSources, d.PublishSource)
+			l.Error(err)
+			return err
+		}
+	}
+	p, ok := s.Driver.(Publisher)
+	if !ok {
+		err := fmt.Errorf("%w: source %s", ErrPublishNotSupported, s.Name)
+		l.Error(err)
+		return err
+	}
+	if err := p.InitPublisher(); err != nil {
+		l.WithError(err).Errorf("failed to initialize source %s", s.Name)
+		return err
+	}
+	d.publisher = p
+	return nil
+}
+
+// Publish publishes body to the publish source.
+func (d *MultiDriver) Publish(body []byte, meta map[string]string) error {
+	if d.publisher == nil {
+		return ErrPublishNotSupported
+	}
+	return d.publisher.Publish(body, meta)
+}
+
+// ClosePublisher closes the publish source.
+func (d *MultiDriver) ClosePublisher() error {
+	if d.publisher == nil {
+		return nil
+	}
+	p := d.publisher
+	d.publisher = nil
+	return p.ClosePublisher()
+}
+
+func (d *MultiDriver) Cleanup() error {
+	return d.CleanupContext(context.Background())
+}
+
+func (d *MultiDriver) CleanupContext(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "multi",
+		"fn":  "Cleanup",
+	})
+	l.Debug("Cleaning up")
+	var err error
+	for _, s := range d.Sources {
+		// the source may not be cleaned up while it is getting work
+		if s.pending != nil {
+			select {
+			case <-s.pending.done:
+			case <-ctx.Done():
+			}
+			s.pending = nil
+		}
+		if cerr := AsV2(s.Driver).CleanupContext(ctx); cerr != nil {
+			l.WithError(cerr).Errorf("failed to clean up source %s", s.Name)
+			if err == nil {
+				err = cerr
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package drivers
+
+import (
+	"context"
+	"io"
+)
+
+// DriverV2 is a context aware Driver. Cancelling the context passed to
+// GetWorkContext returns promptly, releasing any message which has been
+// received from the source but not yet returned.
+type DriverV2 interface {
+	LoadEnv(string) error
+	LoadFlags() error
+	InitContext(context.Context) error
+	GetWorkContext(context.Context) (io.Reader, error)
+	ClearWorkContext(context.Context) error
+	HandleFailureContext(context.Context) error
+	CleanupContext(context.Context) error
+}
+
+// AsV2 returns d as a DriverV2. Drivers which do not implement DriverV2 are
+// adapted, and only observe the context before each call.
+func AsV2(d Driver) DriverV2 {
+	if v2, ok := d.(DriverV2); ok {
+		return v2
+	}
+	return &legacyDriver{d}
+}
+
+// legacyDriver adapts a Driver to a DriverV2.
+type legacyDriver struct {
+	Driver
+}
+
+func (d *legacyDriver) InitContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.Init()
+}
+
+func (d *legacyDriver) GetWorkContext(ctx context.Context) (io.Reader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return d.GetWork()
+}
+
+func (d *legacyDriver) ClearWorkContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.ClearWork()
+}
+
+func (d *legacyDriver) HandleFailureContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.HandleFailure()
+}
+
+func (d *legacyDriver) CleanupContext(ctx context.Context) error {
+	return d.Cleanup()
+}
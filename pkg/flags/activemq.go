@@ -1,12 +1,14 @@
 package flags
 
 var (
-	ActiveMQAddress     = FlagSet.String("activemq-address", "", "ActiveMQ STOMP address")
-	ActiveMQType        = FlagSet.String("activemq-type", "", "ActiveMQ type. Valid values are: topic, queue")
-	ActiveMQName        = FlagSet.String("activemq-name", "", "ActiveMQ name")
-	ActiveMQEnableTLS   = FlagSet.Bool("activemq-enable-tls", false, "Enable TLS")
-	ActiveMQTLSInsecure = FlagSet.Bool("activemq-tls-insecure", false, "Enable TLS insecure")
-	ActiveMQTLSCA       = FlagSet.String("activemq-tls-ca-file", "", "TLS CA")
-	ActiveMQTLSCert     = FlagSet.String("activemq-tls-cert-file", "", "TLS cert")
-	ActiveMQTLSKey      = FlagSet.String("activemq-tls-key-file", "", "TLS key")
+	activemqOptions = newDriverOptions("activemq")
+
+	ActiveMQAddress     = activemqOptions.String("activemq-address", "", "ActiveMQ STOMP address")
+	ActiveMQType        = activemqOptions.String("activemq-type", "", "ActiveMQ type. Valid values are: topic, queue")
+	ActiveMQName        = activemqOptions.String("activemq-name", "", "ActiveMQ name")
+	ActiveMQEnableTLS   = activemqOptions.Bool("activemq-enable-tls", false, "Enable TLS")
+	ActiveMQTLSInsecure = activemqOptions.Bool("activemq-tls-insecure", false, "Enable TLS insecure")
+	ActiveMQTLSCA       = activemqOptions.String("activemq-tls-ca-file", "", "TLS CA")
+	ActiveMQTLSCert     = activemqOptions.String("activemq-tls-cert-file", "", "TLS cert")
+	ActiveMQTLSKey      = activemqOptions.String("activemq-tls-key-file", "", "TLS key")
 )
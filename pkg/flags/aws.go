@@ -1,30 +1,35 @@
 package flags
 
 var (
-	AWSRegion     = FlagSet.String("aws-region", "", "AWS region")
-	AWSLoadConfig = FlagSet.Bool("aws-load-config", false, "load AWS config from ~/.aws/config")
-	AWSRoleARN    = FlagSet.String("aws-role-arn", "", "AWS role ARN")
-	SQSQueueURL   = FlagSet.String("aws-sqs-queue-url", "", "AWS SQS queue URL")
+	awsOptions       = sharedOptions("aws")
+	awsS3Options     = newDriverOptions("aws-s3", awsOptions)
+	awsSQSOptions    = newDriverOptions("aws-sqs", awsOptions)
+	awsDynamoOptions = newDriverOptions("aws-dynamo", awsOptions)
 
-	AWSDynamoRetrieveField    = FlagSet.String("aws-dynamo-retrieve-field", "", "AWS DynamoDB retrieve field")
-	AWSDynamoRetrieveQuery    = FlagSet.String("aws-dynamo-retrieve-query", "", "AWS DynamoDB retrieve query")
-	AWSDynamoClearQuery       = FlagSet.String("aws-dynamo-clear-query", "", "AWS DynamoDB clear query")
-	AWSDynamoFailQuery        = FlagSet.String("aws-dynamo-fail-query", "", "AWS DynamoDB fail query")
-	AWSDynamoUnmarshalJSON    = FlagSet.Bool("aws-dynamo-unmarshal-json", true, "AWS DynamoDB unmarshal JSON")
-	AWSDynamoLimit            = FlagSet.Int("aws-dynamo-limit", 0, "AWS DynamoDB limit")
-	AWSDynamoNextToken        = FlagSet.String("aws-dynamo-next-token", "", "AWS DynamoDB next token")
-	AWSDynamoIncludeNextToken = FlagSet.Bool("aws-dynamo-include-next-token", false, "AWS DynamoDB include next token as _nextToken in response")
-	AWSS3Bucket               = FlagSet.String("aws-s3-bucket", "", "AWS S3 bucket")
-	AWSS3Key                  = FlagSet.String("aws-s3-key", "", "AWS S3 key")
-	AWSS3KeyRegex             = FlagSet.String("aws-s3-key-regex", "", "AWS S3 key regex")
-	AWSS3KeyPrefix            = FlagSet.String("aws-s3-key-prefix", "", "AWS S3 key prefix")
-	AWSS3ClearOp              = FlagSet.String("aws-s3-clear-op", "", "AWS S3 clear operation. Valid values: mv, rm")
-	AWSS3FailOp               = FlagSet.String("aws-s3-fail-op", "", "AWS S3 fail operation. Valid values: mv, rm")
-	AWSS3ClearBucket          = FlagSet.String("aws-s3-clear-bucket", "", "AWS S3 clear bucket, if clear op is mv")
-	AWSS3ClearKey             = FlagSet.String("aws-s3-clear-key", "", "AWS S3 clear key, if clear op is mv. default is origional key name.")
-	AWSS3ClearKeyTemplate     = FlagSet.String("aws-s3-clear-key-template", "", "AWS S3 clear key template, if clear op is mv.")
-	AWSS3FailBucket           = FlagSet.String("aws-s3-fail-bucket", "", "AWS S3 fail bucket, if fail op is mv")
-	AWSS3FailKey              = FlagSet.String("aws-s3-fail-key", "", "AWS S3 fail key, if fail op is mv. default is original key name.")
-	AWSS3FailKeyTemplate      = FlagSet.String("aws-s3-fail-key-template", "", "AWS S3 fail key template, if fail op is mv.")
-	AWSSQSIncludeID           = FlagSet.Bool("aws-sqs-include-id", false, "AWS SQS include ID in response")
+	AWSRegion     = awsOptions.String("aws-region", "", "AWS region")
+	AWSLoadConfig = awsOptions.Bool("aws-load-config", false, "load AWS config from ~/.aws/config")
+	AWSRoleARN    = awsOptions.String("aws-role-arn", "", "AWS role ARN", EnvAlias("AWS_SQS_ROLE_ARN"))
+	SQSQueueURL   = awsSQSOptions.String("aws-sqs-queue-url", "", "AWS SQS queue URL")
+
+	AWSDynamoRetrieveField    = awsDynamoOptions.String("aws-dynamo-retrieve-field", "", "AWS DynamoDB retrieve field")
+	AWSDynamoRetrieveQuery    = awsDynamoOptions.String("aws-dynamo-retrieve-query", "", "AWS DynamoDB retrieve query")
+	AWSDynamoClearQuery       = awsDynamoOptions.String("aws-dynamo-clear-query", "", "AWS DynamoDB clear query")
+	AWSDynamoFailQuery        = awsDynamoOptions.String("aws-dynamo-fail-query", "", "AWS DynamoDB fail query")
+	AWSDynamoUnmarshalJSON    = awsDynamoOptions.Bool("aws-dynamo-unmarshal-json", true, "AWS DynamoDB unmarshal JSON")
+	AWSDynamoLimit            = awsDynamoOptions.Int("aws-dynamo-limit", 0, "AWS DynamoDB limit")
+	AWSDynamoNextToken        = awsDynamoOptions.String("aws-dynamo-next-token", "", "AWS DynamoDB next token")
+	AWSDynamoIncludeNextToken = awsDynamoOptions.Bool("aws-dynamo-include-next-token", false, "AWS DynamoDB include next token as _nextToken in response")
+	AWSS3Bucket               = awsS3Options.String("aws-s3-bucket", "", "AWS S3 bucket")
+	AWSS3Key                  = awsS3Options.String("aws-s3-key", "", "AWS S3 key")
+	AWSS3KeyRegex             = awsS3Options.String("aws-s3-key-regex", "", "AWS S3 key regex")
+	AWSS3KeyPrefix            = awsS3Options.String("aws-s3-key-prefix", "", "AWS S3 key prefix")
+	AWSS3ClearOp              = awsS3Options.String("aws-s3-clear-op", "", "AWS S3 clear operation. Valid values: mv, rm")
+	AWSS3FailOp               = awsS3Options.String("aws-s3-fail-op", "", "AWS S3 fail operation. Valid values: mv, rm")
+	AWSS3ClearBucket          = awsS3Options.String("aws-s3-clear-bucket", "", "AWS S3 clear bucket, if clear op is mv")
+	AWSS3ClearKey             = awsS3Options.String("aws-s3-clear-key", "", "AWS S3 clear key, if clear op is mv. default is origional key name.")
+	AWSS3ClearKeyTemplate     = awsS3Options.String("aws-s3-clear-key-template", "", "AWS S3 clear key template, if clear op is mv.")
+	AWSS3FailBucket           = awsS3Options.String("aws-s3-fail-bucket", "", "AWS S3 fail bucket, if fail op is mv")
+	AWSS3FailKey              = awsS3Options.String("aws-s3-fail-key", "", "AWS S3 fail key, if fail op is mv. default is original key name.")
+	AWSS3FailKeyTemplate      = awsS3Options.String("aws-s3-fail-key-template", "", "AWS S3 fail key template, if fail op is mv.")
+	AWSSQSIncludeID           = awsSQSOptions.Bool("aws-sqs-include-id", false, "AWS SQS include ID in response")
 )
@@ -0,0 +1,9 @@
+package flags
+
+var (
+	BreakerThreshold          = FlagSet.Float64("breaker-threshold", 0, "job failure rate, between 0 and 1, over breaker-window at which the circuit breaker opens and work retrieval stops. default is disabled")
+	BreakerWindow             = FlagSet.Int("breaker-window", 60000, "sliding window in milliseconds over which the job failure rate is measured")
+	BreakerMinJobs            = FlagSet.Int("breaker-min-jobs", 10, "minimum number of jobs completed in breaker-window before the failure rate can open the circuit breaker")
+	BreakerCooldown           = FlagSet.Int("breaker-cooldown", 30000, "time in milliseconds the circuit breaker stays open before a single trial job is run")
+	BreakerUnhealthyExitCodes = FlagSet.String("breaker-unhealthy-exit-codes", "", "process exit codes which signal an unhealthy downstream and open the circuit breaker immediately, comma separated")
+)
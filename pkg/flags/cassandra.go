@@ -1,16 +1,18 @@
 package flags
 
 var (
-	CassandraHosts          = FlagSet.String("cassandra-hosts", "", "Cassandra hosts")
-	CassandraUser           = FlagSet.String("cassandra-user", "", "Cassandra user")
-	CassandraPassword       = FlagSet.String("cassandra-password", "", "Cassandra password")
-	CassandraKeyspace       = FlagSet.String("cassandra-keyspace", "", "Cassandra keyspace")
-	CassandraConsistency    = FlagSet.String("cassandra-consistency", "QUORUM", "Cassandra consistency")
-	CassandraRetrieveQuery  = FlagSet.String("cassandra-retrieve-query", "", "Cassandra retrieve query")
-	CassandraRetrieveParams = FlagSet.String("cassandra-retrieve-params", "", "Cassandra retrieve params")
-	CassandraClearQuery     = FlagSet.String("cassandra-clear-query", "", "Cassandra clear query")
-	CassandraClearParams    = FlagSet.String("cassandra-clear-params", "", "Cassandra clear params")
-	CassandraFailQuery      = FlagSet.String("cassandra-fail-query", "", "Cassandra fail query")
-	CassandraFailParams     = FlagSet.String("cassandra-fail-params", "", "Cassandra fail params")
-	CassandraRetrieveField  = FlagSet.String("cassandra-retrieve-field", "", "Cassandra retrieve field. If not set, all fields will be returned as a JSON object")
+	cassandraOptions = newDriverOptions("cassandra")
+
+	CassandraHosts          = cassandraOptions.String("cassandra-hosts", "", "Cassandra hosts")
+	CassandraUser           = cassandraOptions.String("cassandra-user", "", "Cassandra user")
+	CassandraPassword       = cassandraOptions.String("cassandra-password", "", "Cassandra password")
+	CassandraKeyspace       = cassandraOptions.String("cassandra-keyspace", "", "Cassandra keyspace")
+	CassandraConsistency    = cassandraOptions.String("cassandra-consistency", "QUORUM", "Cassandra consistency")
+	CassandraRetrieveQuery  = cassandraOptions.String("cassandra-retrieve-query", "", "Cassandra retrieve query")
+	CassandraRetrieveParams = cassandraOptions.String("cassandra-retrieve-params", "", "Cassandra retrieve params")
+	CassandraClearQuery     = cassandraOptions.String("cassandra-clear-query", "", "Cassandra clear query")
+	CassandraClearParams    = cassandraOptions.String("cassandra-clear-params", "", "Cassandra clear params")
+	CassandraFailQuery      = cassandraOptions.String("cassandra-fail-query", "", "Cassandra fail query")
+	CassandraFailParams     = cassandraOptions.String("cassandra-fail-params", "", "Cassandra fail params")
+	CassandraRetrieveField  = cassandraOptions.String("cassandra-retrieve-field", "", "Cassandra retrieve field. If not set, all fields will be returned as a JSON object")
 )
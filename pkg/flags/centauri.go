@@ -1,8 +1,10 @@
 package flags
 
 var (
-	CentauriPeerURL   = FlagSet.String("centauri-peer-url", "", "Centauri peer URL")
-	CentauriChannel   = FlagSet.String("centauri-channel", "default", "Centauri channel")
-	CentauriKey       = FlagSet.String("centauri-key", "", "Centauri key")
-	CentauriKeyBase64 = FlagSet.String("centauri-key-base64", "", "Centauri key base64")
+	centauriOptions = newDriverOptions("centauri")
+
+	CentauriPeerURL   = centauriOptions.String("centauri-peer-url", "", "Centauri peer URL")
+	CentauriChannel   = centauriOptions.String("centauri-channel", "default", "Centauri channel")
+	CentauriKey       = centauriOptions.String("centauri-key", "", "Centauri key")
+	CentauriKeyBase64 = centauriOptions.String("centauri-key-base64", "", "Centauri key base64")
 )
@@ -0,0 +1,8 @@
+package flags
+
+var (
+	MaxDeliveries = FlagSet.Int("max-deliveries", 0, "number of times work may be delivered before the poison op is taken on it rather than executing it again. default is unlimited")
+	PoisonOp      = FlagSet.String("poison-op", "fail", "action taken on work exceeding max-deliveries. Valid values: fail, clear, publish")
+	PoisonDriver  = FlagSet.String("poison-driver", "", "Driver to publish poison work to with poison-op publish. The driver loads the driver flags, overridden by env vars prefixed with PROCX_POISON_")
+	DeliveryTTL   = FlagSet.Int("delivery-ttl", 86400000, "time in milliseconds after its last delivery that procx forgets the delivery count of work it counts itself. 0 keeps counts until the oldest are evicted beyond 10000 work items")
+)
@@ -1,15 +1,102 @@
 package flags
 
-import "flag"
+import (
+	"flag"
+	"strings"
+)
+
+// StringSlice is a flag value which may be set multiple times.
+type StringSlice []string
+
+func (s *StringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *StringSlice) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// Reset sets all flags back to their default values, so the flags can be
+// parsed again.
+func Reset() {
+	FlagSet.VisitAll(func(f *flag.Flag) {
+		if s, ok := f.Value.(*StringSlice); ok {
+			*s = nil
+			return
+		}
+		f.Value.Set(f.DefValue)
+	})
+}
+
+func stringSlice(name string, usage string) *StringSlice {
+	s := &StringSlice{}
+	FlagSet.Var(s, name, usage)
+	return s
+}
 
 var (
-	FlagSet         = flag.NewFlagSet("procx", flag.ContinueOnError)
-	Driver          = FlagSet.String("driver", "", "driver to use. (activemq, aws-dynamo, aws-s3, aws-sqs, cassandra, centauri, cockroach, couchbase, elasticsearch, etcd, fs, gcp-bq, gcp-firestore, gcp-gcs, gcp-pubsub, github, http, kafka, local, mongodb, mssql, mysql, nats, nfs, nsq, postgres, pulsar, rabbitmq, redis-list, redis-pubsub, redis-stream, scylla, smb)")
-	HostEnv         = FlagSet.Bool("hostenv", false, "use host environment")
-	PassWorkAsArg   = FlagSet.Bool("pass-work-as-arg", false, "pass work as an argument")
-	PassWorkAsStdin = FlagSet.Bool("pass-work-as-stdin", false, "pass work as stdin")
-	PayloadFile     = FlagSet.String("payload-file", "", "file to write payload to")
-	KeepPayloadFile = FlagSet.Bool("keep-payload-file", false, "keep payload file after processing")
-	Daemon          = FlagSet.Bool("daemon", false, "run as daemon")
-	DaemonInterval  = FlagSet.Int("daemon-interval", 0, "daemon interval in milliseconds")
+	FlagSet          = flag.NewFlagSet("procx", flag.ContinueOnError)
+	Driver           = FlagSet.String("driver", "", "driver to use. (activemq, aws-dynamo, aws-s3, aws-sqs, cassandra, centauri, cockroach, couchbase, elasticsearch, etcd, fs, gcp-bq, gcp-firestore, gcp-gcs, gcp-pubsub, github, http, kafka, local, mongodb, mssql, multi, mysql, nats, nfs, nsq, postgres, pulsar, rabbitmq, redis-list, redis-pubsub, redis-stream, replay, scylla, smb)")
+	HostEnv          = FlagSet.Bool("hostenv", false, "use host environment")
+	HostEnvAllow     = FlagSet.String("hostenv-allow", "", "host environment variables to pass to the process, comma separated")
+	HostEnvRegex     = FlagSet.String("hostenv-regex", "", "regex of host environment variable names to pass to the process")
+	EnvFile          = FlagSet.String("env-file", "", "file of KEY=VAL environment variables to pass to the process")
+	Env              = stringSlice("env", "KEY=VAL environment variable to pass to the process. Values may use {{mustache}} payload fields. May be repeated")
+	PassWorkAsArg    = FlagSet.Bool("pass-work-as-arg", false, "pass work as an argument")
+	PassWorkAsStdin  = FlagSet.Bool("pass-work-as-stdin", false, "pass work as stdin")
+	Shell            = FlagSet.String("shell", "", "shell to run the process with, e.g. /bin/sh. The process and args are joined into a script, and {{mustache}} values are shell quoted")
+	ArgsAllowMissing = FlagSet.Bool("args-allow-missing", false, "render missing {{mustache}} payload fields in args as empty rather than failing the job")
+	PayloadFile      = FlagSet.String("payload-file", "", "file to write payload to")
+	KeepPayloadFile  = FlagSet.Bool("keep-payload-file", false, "keep payload file after processing")
+	Daemon           = FlagSet.Bool("daemon", false, "run as daemon")
+	Config           = FlagSet.String("config", "", "file of PROCX_ environment variables to load configuration from. Reloaded on SIGHUP in daemon and schedule mode")
+	Timeout          = FlagSet.Int("timeout", 0, "process timeout in milliseconds. 0 is no timeout")
+	RecordDir        = FlagSet.String("record-dir", "", "directory to record each fetched payload and its metadata to, for use with the replay driver")
+	DaemonInterval   = FlagSet.Int("daemon-interval", 0, "daemon interval in milliseconds")
+	AdminAddr        = FlagSet.String("admin-addr", "", "address to serve the admin API on, e.g. 127.0.0.1:8081 or unix:///var/run/procx.sock. default is disabled")
+
+	Workdir           = FlagSet.Bool("workdir", false, "run each job in a fresh temporary working directory, exported as PROCX_WORKDIR. A relative -payload-file is written inside it")
+	WorkdirBase       = FlagSet.String("workdir-base", "", "directory to create job working directories in. default is the system temp directory")
+	KeepFailedWorkdir = FlagSet.Bool("keep-failed-workdir", false, "keep the working directory of failed jobs")
+
+	Schedule            = FlagSet.String("schedule", "", "cron expression to drain the queue on, e.g. \"*/5 * * * *\", as an alternative to -daemon")
+	ScheduleTZ          = FlagSet.String("schedule-tz", "", "time zone of -schedule, e.g. America/New_York. default is the local time zone")
+	ScheduleLimit       = FlagSet.Int("schedule-limit", 0, "maximum number of work items to process each scheduled run. 0 is unlimited")
+	ScheduleOverlap     = FlagSet.String("schedule-overlap", "skip", "action when a scheduled run is due while the previous run is in progress. Valid values: skip, queue")
+	SchedulePollTimeout = FlagSet.Int("schedule-poll-timeout", 5000, "maximum time in milliseconds a scheduled run waits for work from a driver which blocks until work is received, before treating the queue as empty")
+
+	OutputDir     = FlagSet.String("output-dir", "", "directory to capture the stdout and stderr of each job to, in a directory per job")
+	OutputTee     = FlagSet.Bool("output-tee", false, "also write the process output to stdout and stderr when -output-dir is set")
+	OutputMaxSize = FlagSet.Int64("output-max-size", 0, "maximum size in bytes of each captured output file before it is rotated. 0 is unlimited")
+	OutputRetain  = FlagSet.Int("output-retain", 0, "number of job output directories to keep in -output-dir. 0 keeps all")
+	OutputFormat  = FlagSet.String("output-format", "raw", "format to write the process output to stdout and stderr in. Valid values: raw, json")
+	StderrTail    = FlagSet.Int("stderr-tail", 4096, "number of bytes from the end of the process stderr to log when the process fails")
+
+	CaptureOutput        = FlagSet.Bool("capture-output", false, "capture the process stdout as {{procx_output}} for clear and fail templates")
+	CaptureOutputMaxSize = FlagSet.Int64("capture-output-max-size", 1048576, "maximum size in bytes of the captured stdout. 0 is unlimited")
+	CaptureOutputJSON    = FlagSet.Bool("capture-output-json", false, "capture the process stdout as a JSON result, failing the job if it is not valid JSON. implies -capture-output")
+
+	UID          = FlagSet.Int("uid", -1, "user id to run the process as")
+	GID          = FlagSet.Int("gid", -1, "group id to run the process as")
+	Setpgid      = FlagSet.Bool("setpgid", false, "run the process in its own process group")
+	Setsid       = FlagSet.Bool("setsid", false, "run the process in its own session")
+	RlimitCPU    = FlagSet.Int64("rlimit-cpu", -1, "CPU time limit of the process in seconds")
+	RlimitAS     = FlagSet.Int64("rlimit-as", -1, "address space limit of the process in bytes")
+	RlimitNofile = FlagSet.Int64("rlimit-nofile", -1, "open files limit of the process")
+	RlimitCore   = FlagSet.Int64("rlimit-core", -1, "core file size limit of the process in bytes")
+	CgroupMemory = FlagSet.Int64("cgroup-memory", 0, "memory limit in bytes of the cgroup v2 group created for each job. default is unlimited")
+	CgroupCPU    = FlagSet.Float64("cgroup-cpu", 0, "CPU limit in cores of the cgroup v2 group created for each job, e.g. 0.5. default is unlimited")
+
+	Singleton     = FlagSet.Bool("singleton", false, "acquire a distributed lock before consuming work, so only one procx processes work at a time")
+	SingletonLock = FlagSet.String("singleton-lock", "", "singleton lock backend, using the connection flags of the corresponding driver. Valid values: etcd, redis, postgres")
+	SingletonKey  = FlagSet.String("singleton-key", "procx", "singleton lock key")
+	SingletonTTL  = FlagSet.Int("singleton-ttl", 30000, "singleton lock TTL in milliseconds. The lock is refreshed every third of the TTL")
+
+	Concurrency        = FlagSet.Int("concurrency", 1, "number of jobs to process concurrently")
+	Rate               = FlagSet.String("rate", "", "maximum rate of work retrieval across all workers, e.g. 20/s, 100/m, 5/h. default is unlimited")
+	RateBurst          = FlagSet.Int("rate-burst", 1, "maximum burst of work retrievals allowed by -rate")
+	KeyConcurrency     = FlagSet.Int("key-concurrency", 0, "maximum concurrent jobs per key. 0 is unlimited")
+	KeyConcurrencyPath = FlagSet.String("key-concurrency-path", "", "gjson path of the payload field to use as the concurrency key, e.g. tenant_id")
+	KeyConcurrencyOp   = FlagSet.String("key-concurrency-op", "wait", "action when a key is at its concurrency limit. Valid values: wait, fail")
 )
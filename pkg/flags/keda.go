@@ -0,0 +1,9 @@
+package flags
+
+var (
+	KedaAddr                = FlagSet.String("keda-addr", ":6000", "Address on which procx keda serves the KEDA external scaler")
+	KedaMetricName          = FlagSet.String("keda-metric-name", "procx-depth", "Name of the metric reported to KEDA")
+	KedaTargetSize          = FlagSet.Int64("keda-target-size", 5, "Backlog each replica should handle. Overridden by the ScaledObject targetSize metadata")
+	KedaActivationThreshold = FlagSet.Int64("keda-activation-threshold", 0, "Backlog above which the workload is active. Overridden by the ScaledObject activationThreshold metadata")
+	KedaPollInterval        = FlagSet.Int("keda-poll-interval", 5000, "Interval in milliseconds at which the backlog is checked for StreamIsActive")
+)
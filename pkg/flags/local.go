@@ -0,0 +1,11 @@
+package flags
+
+var (
+	localOptions = newDriverOptions("local")
+
+	LocalFile      = localOptions.String("local-file", "", "Local file to read work from. Use - for stdin")
+	LocalDir       = localOptions.String("local-dir", "", "Local directory to read work from, one job per file")
+	LocalSplit     = localOptions.String("local-split", "", "Local split mode. Valid values: lines. default is one job per file")
+	LocalClearFile = localOptions.String("local-clear-file", "", "Local file to append successful work to")
+	LocalFailFile  = localOptions.String("local-fail-file", "", "Local file to append failed work to")
+)
@@ -1,16 +1,20 @@
 package flags
 
 var (
-	MSSqlHost           = FlagSet.String("mssql-host", "", "MSSQL host")
-	MSSqlPort           = FlagSet.String("mssql-port", "1433", "MSSQL port")
-	MSSqlUser           = FlagSet.String("mssql-user", "", "MSSQL user")
-	MSSqlPassword       = FlagSet.String("mssql-password", "", "MSSQL password")
-	MSSqlDatabase       = FlagSet.String("mssql-database", "", "MSSQL database")
-	MSSqlRetrieveField  = FlagSet.String("mssql-retrieve-field", "", "MSSQL retrieve field. If not set, all fields will be returned as a JSON object")
-	MSSqlRetrieveQuery  = FlagSet.String("mssql-retrieve-query", "", "MSSQL retrieve query")
-	MSSqlRetrieveParams = FlagSet.String("mssql-retrieve-params", "", "MSSQL retrieve params")
-	MSSqlClearQuery     = FlagSet.String("mssql-clear-query", "", "MSSQL clear query")
-	MSSqlClearParams    = FlagSet.String("mssql-clear-params", "", "MSSQL clear params")
-	MSSqlFailQuery      = FlagSet.String("mssql-fail-query", "", "MSSQL fail query")
-	MSSqlFailParams     = FlagSet.String("mssql-fail-params", "", "MSSQL fail params")
+	mssqlOptions = newDriverOptions("mssql")
+
+	MSSqlHost           = mssqlOptions.String("mssql-host", "", "MSSQL host")
+	MSSqlPort           = mssqlOptions.String("mssql-port", "1433", "MSSQL port")
+	MSSqlUser           = mssqlOptions.String("mssql-user", "", "MSSQL user")
+	MSSqlPassword       = mssqlOptions.String("mssql-password", "", "MSSQL password")
+	MSSqlDatabase       = mssqlOptions.String("mssql-database", "", "MSSQL database")
+	MSSqlRetrieveField  = mssqlOptions.String("mssql-retrieve-field", "", "MSSQL retrieve field. If not set, all fields will be returned as a JSON object")
+	MSSqlRetrieveQuery  = mssqlOptions.String("mssql-retrieve-query", "", "MSSQL retrieve query")
+	MSSqlRetrieveParams = mssqlOptions.String("mssql-retrieve-params", "", "MSSQL retrieve params")
+	MSSqlClearQuery     = mssqlOptions.String("mssql-clear-query", "", "MSSQL clear query")
+	MSSqlClearParams    = mssqlOptions.String("mssql-clear-params", "", "MSSQL clear params")
+	MSSqlFailQuery      = mssqlOptions.String("mssql-fail-query", "", "MSSQL fail query")
+	MSSqlFailParams     = mssqlOptions.String("mssql-fail-params", "", "MSSQL fail params")
+	MSSqlCountQuery     = mssqlOptions.String("mssql-count-query", "", "MSSQL count query, returning the number of work items waiting, for depth reporting")
+	MSSqlCountParams    = mssqlOptions.String("mssql-count-params", "", "MSSQL count params")
 )
@@ -0,0 +1,10 @@
+package flags
+
+var (
+	multiOptions = newDriverOptions("multi")
+
+	MultiSources       = multiOptions.String("multi-sources", "", "Multi sources to consume from, comma separated, in the form name=driver[:weight]. Each source loads the driver flags, overridden by env vars prefixed with PROCX_<NAME>_")
+	MultiSelect        = multiOptions.String("multi-select", "priority", "Multi source selection. Valid values: priority, weighted")
+	MultiPollTimeout   = multiOptions.Int("multi-poll-timeout", 1000, "Multi maximum time in milliseconds to wait for work from a source which blocks until work is received, before trying the next source")
+	MultiPublishSource = multiOptions.String("multi-publish-source", "", "Multi source to publish to when multi is used as a result or poison driver. default is the first source")
+)
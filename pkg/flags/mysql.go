@@ -1,16 +1,20 @@
 package flags
 
 var (
-	MysqlHost           = FlagSet.String("mysql-host", "", "MySQL host")
-	MysqlPort           = FlagSet.String("mysql-port", "3306", "MySQL port")
-	MysqlUser           = FlagSet.String("mysql-user", "", "MySQL user")
-	MysqlPassword       = FlagSet.String("mysql-password", "", "MySQL password")
-	MysqlDatabase       = FlagSet.String("mysql-database", "", "MySQL database")
-	MysqlRetrieveField  = FlagSet.String("mysql-retrieve-field", "", "MySQL retrieve field. If not set, all fields will be returned as a JSON object")
-	MysqlRetrieveQuery  = FlagSet.String("mysql-retrieve-query", "", "MySQL retrieve query")
-	MysqlRetrieveParams = FlagSet.String("mysql-retrieve-params", "", "MySQL retrieve params")
-	MysqlClearQuery     = FlagSet.String("mysql-clear-query", "", "MySQL clear query")
-	MysqlClearParams    = FlagSet.String("mysql-clear-params", "", "MySQL clear params")
-	MysqlFailQuery      = FlagSet.String("mysql-fail-query", "", "MySQL fail query")
-	MysqlFailParams     = FlagSet.String("mysql-fail-params", "", "MySQL fail params")
+	mysqlOptions = newDriverOptions("mysql")
+
+	MysqlHost           = mysqlOptions.String("mysql-host", "", "MySQL host")
+	MysqlPort           = mysqlOptions.String("mysql-port", "3306", "MySQL port")
+	MysqlUser           = mysqlOptions.String("mysql-user", "", "MySQL user")
+	MysqlPassword       = mysqlOptions.String("mysql-password", "", "MySQL password")
+	MysqlDatabase       = mysqlOptions.String("mysql-database", "", "MySQL database")
+	MysqlRetrieveField  = mysqlOptions.String("mysql-retrieve-field", "", "MySQL retrieve field. If not set, all fields will be returned as a JSON object")
+	MysqlRetrieveQuery  = mysqlOptions.String("mysql-retrieve-query", "", "MySQL retrieve query")
+	MysqlRetrieveParams = mysqlOptions.String("mysql-retrieve-params", "", "MySQL retrieve params")
+	MysqlClearQuery     = mysqlOptions.String("mysql-clear-query", "", "MySQL clear query")
+	MysqlClearParams    = mysqlOptions.String("mysql-clear-params", "", "MySQL clear params")
+	MysqlFailQuery      = mysqlOptions.String("mysql-fail-query", "", "MySQL fail query")
+	MysqlFailParams     = mysqlOptions.String("mysql-fail-params", "", "MySQL fail params")
+	MysqlCountQuery     = mysqlOptions.String("mysql-count-query", "", "MySQL count query, returning the number of work items waiting, for depth reporting")
+	MysqlCountParams    = mysqlOptions.String("mysql-count-params", "", "MySQL count params")
 )
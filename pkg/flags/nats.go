@@ -1,20 +1,22 @@
 package flags
 
 var (
-	NATSURL           = FlagSet.String("nats-url", "", "NATS URL")
-	NATSSubject       = FlagSet.String("nats-subject", "", "NATS subject")
-	NATSCredsFile     = FlagSet.String("nats-creds-file", "", "NATS creds file")
-	NATSJWTFile       = FlagSet.String("nats-jwt-file", "", "NATS JWT file")
-	NATSNKeyFile      = FlagSet.String("nats-nkey-file", "", "NATS NKey file")
-	NATSUsername      = FlagSet.String("nats-username", "", "NATS username")
-	NATSPassword      = FlagSet.String("nats-password", "", "NATS password")
-	NATSToken         = FlagSet.String("nats-token", "", "NATS token")
-	NATSQueueGroup    = FlagSet.String("nats-queue-group", "", "NATS queue group")
-	NATSEnableTLS     = FlagSet.Bool("nats-enable-tls", false, "NATS enable TLS")
-	NATSTLSInsecure   = FlagSet.Bool("nats-tls-insecure", false, "NATS TLS insecure")
-	NATSTLSCAFile     = FlagSet.String("nats-tls-ca-file", "", "NATS TLS CA file")
-	NATSTLSCertFile   = FlagSet.String("nats-tls-cert-file", "", "NATS TLS cert file")
-	NATSTLSKeyFile    = FlagSet.String("nats-tls-key-file", "", "NATS TLS key file")
-	NATSClearResponse = FlagSet.String("nats-clear-response", "", "NATS clear response")
-	NATSFailResponse  = FlagSet.String("nats-fail-response", "", "NATS fail response")
+	natsOptions = newDriverOptions("nats")
+
+	NATSURL           = natsOptions.String("nats-url", "", "NATS URL")
+	NATSSubject       = natsOptions.String("nats-subject", "", "NATS subject")
+	NATSCredsFile     = natsOptions.String("nats-creds-file", "", "NATS creds file")
+	NATSJWTFile       = natsOptions.String("nats-jwt-file", "", "NATS JWT file")
+	NATSNKeyFile      = natsOptions.String("nats-nkey-file", "", "NATS NKey file")
+	NATSUsername      = natsOptions.String("nats-username", "", "NATS username")
+	NATSPassword      = natsOptions.String("nats-password", "", "NATS password")
+	NATSToken         = natsOptions.String("nats-token", "", "NATS token")
+	NATSQueueGroup    = natsOptions.String("nats-queue-group", "", "NATS queue group")
+	NATSEnableTLS     = natsOptions.Bool("nats-enable-tls", false, "NATS enable TLS")
+	NATSTLSInsecure   = natsOptions.Bool("nats-tls-insecure", false, "NATS TLS insecure")
+	NATSTLSCAFile     = natsOptions.String("nats-tls-ca-file", "", "NATS TLS CA file")
+	NATSTLSCertFile   = natsOptions.String("nats-tls-cert-file", "", "NATS TLS cert file")
+	NATSTLSKeyFile    = natsOptions.String("nats-tls-key-file", "", "NATS TLS key file")
+	NATSClearResponse = natsOptions.String("nats-clear-response", "", "NATS clear response")
+	NATSFailResponse  = natsOptions.String("nats-fail-response", "", "NATS fail response")
 )
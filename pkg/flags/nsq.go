@@ -1,13 +1,15 @@
 package flags
 
 var (
-	NSQNSQLookupdAddress = FlagSet.String("nsq-nsqlookupd-address", "", "NSQ nsqlookupd address")
-	NSQNSQDAddress       = FlagSet.String("nsq-nsqd-address", "", "NSQ nsqd address")
-	NSQTopic             = FlagSet.String("nsq-topic", "", "NSQ topic")
-	NSQChannel           = FlagSet.String("nsq-channel", "", "NSQ channel")
-	NSQEnableTLS         = FlagSet.Bool("nsq-enable-tls", false, "Enable TLS")
-	NSQTLSSkipVerify     = FlagSet.Bool("nsq-tls-skip-verify", false, "NSQ TLS skip verify")
-	NSQCAFile            = FlagSet.String("nsq-tls-ca-file", "", "NSQ TLS CA file")
-	NSQCertFile          = FlagSet.String("nsq-tls-cert-file", "", "NSQ TLS cert file")
-	NSQKeyFile           = FlagSet.String("nsq-tls-key-file", "", "NSQ TLS key file")
+	nsqOptions = newDriverOptions("nsq")
+
+	NSQNSQLookupdAddress = nsqOptions.String("nsq-nsqlookupd-address", "", "NSQ nsqlookupd address")
+	NSQNSQDAddress       = nsqOptions.String("nsq-nsqd-address", "", "NSQ nsqd address")
+	NSQTopic             = nsqOptions.String("nsq-topic", "", "NSQ topic")
+	NSQChannel           = nsqOptions.String("nsq-channel", "", "NSQ channel")
+	NSQEnableTLS         = nsqOptions.Bool("nsq-enable-tls", false, "Enable TLS")
+	NSQTLSInsecure       = nsqOptions.Bool("nsq-tls-insecure", false, "NSQ TLS insecure", FlagAlias("nsq-tls-skip-verify"))
+	NSQCAFile            = nsqOptions.String("nsq-tls-ca-file", "", "NSQ TLS CA file")
+	NSQCertFile          = nsqOptions.String("nsq-tls-cert-file", "", "NSQ TLS cert file")
+	NSQKeyFile           = nsqOptions.String("nsq-tls-key-file", "", "NSQ TLS key file")
 )
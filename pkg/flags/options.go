@@ -0,0 +1,340 @@
+package flags
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Option is a driver option. The flag, the environment variable and the
+// help of the option are all derived from its declaration.
+type Option struct {
+	// Name is the name of the flag, ex. cockroach-host
+	Name string
+	// Usage is the help text of the option
+	Usage       string
+	flag        *flag.Flag
+	flagAliases []string
+	envAliases  []string
+}
+
+// OptionFunc configures an Option when it is declared.
+type OptionFunc func(*Option)
+
+// FlagAlias also accepts the deprecated flag name for the option.
+func FlagAlias(name string) OptionFunc {
+	return func(o *Option) {
+		o.flagAliases = append(o.flagAliases, name)
+	}
+}
+
+// EnvAlias also accepts the deprecated environment variable name, without
+// the prefix, for the option.
+func EnvAlias(name string) OptionFunc {
+	return func(o *Option) {
+		o.envAliases = append(o.envAliases, name)
+	}
+}
+
+// Env returns the name of the environment variable of the option, without
+// the prefix, ex. COCKROACH_HOST.
+func (o *Option) Env() string {
+	return strings.ToUpper(strings.ReplaceAll(o.Name, "-", "_"))
+}
+
+// Default returns the default value of the option.
+func (o *Option) Default() string {
+	return o.flag.DefValue
+}
+
+// Type returns the type of the option value, as shown in the help.
+func (o *Option) Type() string {
+	t, _ := flag.UnquoteUsage(o.flag)
+	return t
+}
+
+// lookupEnv returns the value of the environment variable of the option, or
+// of its deprecated aliases.
+func (o *Option) lookupEnv(prefix string) (string, string) {
+	for _, e := range append([]string{o.Env()}, o.envAliases...) {
+		if v := os.Getenv(prefix + e); v != "" {
+			return prefix + e, v
+		}
+	}
+	return "", ""
+}
+
+// OptionSet is the set of options of a driver, or of options shared by
+// several drivers. Each driver declares its options once, so its flags,
+// environment variables and help cannot disagree.
+type OptionSet struct {
+	// Name is the name of the driver, or of the shared options
+	Name     string
+	includes []*OptionSet
+	options  []*Option
+}
+
+var (
+	driverOptions  = make(map[string]*OptionSet)
+	optionsByValue = make(map[any]*Option)
+)
+
+// sharedOptions returns a set of options shared by several drivers.
+func sharedOptions(name string) *OptionSet {
+	return &OptionSet{Name: name}
+}
+
+// newDriverOptions returns the option set of the driver, which also has the
+// options of the shared sets it includes.
+func newDriverOptions(name string, includes ...*OptionSet) *OptionSet {
+	s := &OptionSet{Name: name, includes: includes}
+	driverOptions[name] = s
+	return s
+}
+
+// DriverOptions returns the option set of the driver, or nil if the driver
+// has no options.
+func DriverOptions(name string) *OptionSet {
+	return driverOptions[name]
+}
+
+// DriverNames returns the sorted names of the drivers which have options.
+func DriverNames() []string {
+	var names []string
+	for n := range driverOptions {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Options returns the options of the set, including those of the sets it
+// includes, sorted by name.
+func (s *OptionSet) Options() []*Option {
+	var opts []*Option
+	for _, i := range s.includes {
+		opts = append(opts, i.Options()...)
+	}
+	opts = append(opts, s.options...)
+	sort.Slice(opts, func(i, j int) bool {
+		return opts[i].Name < opts[j].Name
+	})
+	return opts
+}
+
+func (s *OptionSet) add(p any, name string, usage string, fns []OptionFunc) {
+	o := &Option{
+		Name:  name,
+		Usage: usage,
+		flag:  FlagSet.Lookup(name),
+	}
+	for _, fn := range fns {
+		fn(o)
+	}
+	for _, a := range o.flagAliases {
+		FlagSet.Var(o.flag.Value, a, "deprecated, use -"+name)
+	}
+	s.options = append(s.options, o)
+	optionsByValue[p] = o
+}
+
+// String declares a string option.
+func (s *OptionSet) String(name string, value string, usage string, fns ...OptionFunc) *string {
+	p := FlagSet.String(name, value, usage)
+	s.add(p, name, usage, fns)
+	return p
+}
+
+// Bool declares a bool option.
+func (s *OptionSet) Bool(name string, value bool, usage string, fns ...OptionFunc) *bool {
+	p := FlagSet.Bool(name, value, usage)
+	s.add(p, name, usage, fns)
+	return p
+}
+
+// Int declares an int option.
+func (s *OptionSet) Int(name string, value int, usage string, fns ...OptionFunc) *int {
+	p := FlagSet.Int(name, value, usage)
+	s.add(p, name, usage, fns)
+	return p
+}
+
+// PrintDefaults prints the options of the set, in the same form as
+// FlagSet.PrintDefaults, along with their environment variables.
+func (s *OptionSet) PrintDefaults(w io.Writer, envPrefix string) {
+	for _, o := range s.Options() {
+		fmt.Fprintf(w, "  -%s", o.Name)
+		if t := o.Type(); t != "" {
+			fmt.Fprintf(w, " %s", t)
+		}
+		fmt.Fprintf(w, "\n    \t%s", strings.ReplaceAll(o.Usage, "\n", "\n    \t"))
+		if d := o.Default(); d != "" && d != "false" && d != "0" {
+			fmt.Fprintf(w, " (default %q)", d)
+		}
+		fmt.Fprintf(w, "\n    \tenv: %s%s\n", envPrefix, o.Env())
+	}
+}
+
+// PrintMarkdown prints a markdown table of the options of the set.
+func (s *OptionSet) PrintMarkdown(w io.Writer, envPrefix string) {
+	fmt.Fprintln(w, "| Flag | Environment Variable | Default | Description |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- |")
+	for _, o := range s.Options() {
+		var d string
+		if o.Default() != "" {
+			d = "`" + o.Default() + "`"
+		}
+		fmt.Fprintf(w, "| `-%s` | `%s%s` | %s | %s |\n", o.Name, envPrefix, o.Env(), d, strings.ReplaceAll(o.Usage, "|", "\\|"))
+	}
+}
+
+// Values looks up the values of driver options from either the flags or the
+// environment, so a driver loads both with the same code. A flag is always
+// set, so loading the flags assigns every option, including its default. An
+// environment variable is set if it is not empty.
+type Values struct {
+	prefix string
+	env    bool
+	err    error
+}
+
+// FlagValues returns the Values of the flags.
+func FlagValues() *Values {
+	return &Values{}
+}
+
+// EnvValues returns the Values of the environment variables with the prefix.
+func EnvValues(prefix string) *Values {
+	return &Values{prefix: prefix, env: true}
+}
+
+// lookup returns the value of the option p, the name it was set with, and
+// whether it is set. p must be the value returned when the option was
+// declared.
+func (v *Values) lookup(p any) (string, string, bool) {
+	o, ok := optionsByValue[p]
+	if !ok {
+		panic(fmt.Sprintf("flags: %T %p is not a declared option", p, p))
+	}
+	if !v.env {
+		return o.flag.Value.String(), "-" + o.Name, true
+	}
+	n, s := o.lookupEnv(v.prefix)
+	return s, n, s != ""
+}
+
+// Lookup returns the value of the option p, and whether it is set.
+func (v *Values) Lookup(p any) (string, bool) {
+	s, _, ok := v.lookup(p)
+	return s, ok
+}
+
+// fail records the first error, returned by Err.
+func (v *Values) fail(name string, err error) {
+	if v.err == nil {
+		v.err = fmt.Errorf("%s: %w", name, err)
+	}
+}
+
+// Err returns the first error encountered parsing a value.
+func (v *Values) Err() error {
+	return v.err
+}
+
+// String sets dst to the value of the option p, if it is set.
+func (v *Values) String(dst *string, p *string) {
+	if s, _, ok := v.lookup(p); ok {
+		*dst = s
+	}
+}
+
+// StringPtr sets dst to a pointer to the value of the option p, if it is
+// set.
+func (v *Values) StringPtr(dst **string, p *string) {
+	if s, _, ok := v.lookup(p); ok {
+		*dst = &s
+	}
+}
+
+// parseBool returns the bool value of the option p, and whether it is set. As
+// with the flags, only the value true is true: any other value of the
+// environment variable, including 1 and TRUE, is false.
+func (v *Values) parseBool(p *bool) (bool, bool) {
+	s, _, ok := v.lookup(p)
+	if !ok {
+		return false, false
+	}
+	return s == "true", true
+}
+
+// Bool sets dst to the value of the option p, if it is set.
+func (v *Values) Bool(dst *bool, p *bool) {
+	if b, ok := v.parseBool(p); ok {
+		*dst = b
+	}
+}
+
+// BoolPtr sets dst to a pointer to the value of the option p, if it is set.
+func (v *Values) BoolPtr(dst **bool, p *bool) {
+	if b, ok := v.parseBool(p); ok {
+		*dst = &b
+	}
+}
+
+// Int sets dst to the integer value of the option p, which may be declared as
+// an int or a string, if it is set.
+func (v *Values) Int(dst *int, p any) {
+	s, n, ok := v.lookup(p)
+	if !ok {
+		return
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.fail(n, err)
+		return
+	}
+	*dst = i
+}
+
+// Func calls fn with the value of the option p, if it is set and not empty.
+func (v *Values) Func(p any, fn func(string) error) {
+	s, n, ok := v.lookup(p)
+	if !ok || s == "" {
+		return
+	}
+	if err := fn(s); err != nil {
+		v.fail(n, err)
+	}
+}
+
+// Strings sets dst to the comma separated values of the option p, if it is
+// set and not empty. Values are trimmed, and empty values are dropped.
+func (v *Values) Strings(dst *[]string, p *string) {
+	v.Func(p, func(s string) error {
+		var ss []string
+		for _, sp := range strings.Split(s, ",") {
+			if sp = strings.TrimSpace(sp); sp != "" {
+				ss = append(ss, sp)
+			}
+		}
+		*dst = ss
+		return nil
+	})
+}
+
+// Params sets dst to the comma separated query params of the option p, if it
+// is set and not empty.
+func (v *Values) Params(dst *[]any, p *string) {
+	v.Func(p, func(s string) error {
+		var ps []any
+		for _, sp := range strings.Split(s, ",") {
+			ps = append(ps, sp)
+		}
+		*dst = ps
+		return nil
+	})
+}
@@ -0,0 +1,172 @@
+package flags
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+var (
+	testOptions = newDriverOptions("test")
+
+	testString = testOptions.String("test-string", "default", "test string", FlagAlias("test-old-string"), EnvAlias("TEST_OLD_STRING"))
+	testBool   = testOptions.Bool("test-bool", false, "test bool")
+	testInt    = testOptions.Int("test-int", 1, "test int")
+)
+
+// setFlag sets the flag name to value, and restores its default when the test
+// completes.
+func setFlag(t *testing.T, name, value string) {
+	t.Helper()
+	if err := FlagSet.Set(name, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		FlagSet.Set(name, FlagSet.Lookup(name).DefValue)
+	})
+}
+
+func TestValuesString(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags map[string]string
+		env   map[string]string
+		want  string
+	}{
+		{"default", nil, nil, "default"},
+		{"flag", map[string]string{"test-string": "flag"}, nil, "flag"},
+		{"flag alias", map[string]string{"test-old-string": "alias"}, nil, "alias"},
+		{"env overrides flag",
+			map[string]string{"test-string": "flag"},
+			map[string]string{"PROCX_TEST_STRING": "env"},
+			"env"},
+		{"empty env does not override flag",
+			map[string]string{"test-string": "flag"},
+			map[string]string{"PROCX_TEST_STRING": ""},
+			"flag"},
+		{"env alias",
+			map[string]string{"test-string": "flag"},
+			map[string]string{"PROCX_TEST_OLD_STRING": "alias"},
+			"alias"},
+		{"env overrides env alias", nil,
+			map[string]string{"PROCX_TEST_STRING": "env", "PROCX_TEST_OLD_STRING": "alias"},
+			"env"},
+		{"env without prefix is ignored", nil,
+			map[string]string{"TEST_STRING": "env"},
+			"default"},
+		{"source env overrides shared env", nil,
+			map[string]string{"PROCX_TEST_STRING": "env", "PROCX_SRC_TEST_STRING": "source"},
+			"source"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.flags {
+				setFlag(t, k, v)
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			// drivers load the flags, then the environment, and sources of
+			// the multi driver then load their own environment
+			var got string
+			for _, v := range []*Values{FlagValues(), EnvValues("PROCX_"), EnvValues("PROCX_SRC_")} {
+				v.String(&got, testString)
+				if err := v.Err(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValuesBool(t *testing.T) {
+	tests := []struct {
+		name string
+		flag string
+		env  string
+		want bool
+	}{
+		{"default", "", "", false},
+		{"flag", "true", "", true},
+		{"env true", "", "true", true},
+		{"env false overrides flag", "true", "false", false},
+		{"empty env does not override flag", "true", "", true},
+		{"env 1 is false", "true", "1", false},
+		{"env TRUE is false", "", "TRUE", false},
+		{"env yes is false", "true", "yes", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.flag != "" {
+				setFlag(t, "test-bool", tt.flag)
+			}
+			t.Setenv("PROCX_TEST_BOOL", tt.env)
+			var got bool
+			for _, v := range []*Values{FlagValues(), EnvValues("PROCX_")} {
+				v.Bool(&got, testBool)
+				if err := v.Err(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			var ptr *bool
+			EnvValues("PROCX_").BoolPtr(&ptr, testBool)
+			if (ptr != nil) != (tt.env != "") {
+				t.Errorf("BoolPtr set = %v, want %v", ptr != nil, tt.env != "")
+			}
+		})
+	}
+}
+
+func TestValuesInt(t *testing.T) {
+	tests := []struct {
+		name    string
+		flag    string
+		env     string
+		want    int
+		wantErr bool
+	}{
+		{"default", "", "", 1, false},
+		{"flag", "5", "", 5, false},
+		{"env overrides flag", "5", "7", 7, false},
+		{"invalid env", "5", "seven", 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.flag != "" {
+				setFlag(t, "test-int", tt.flag)
+			}
+			t.Setenv("PROCX_TEST_INT", tt.env)
+			var got int
+			FlagValues().Int(&got, testInt)
+			v := EnvValues("PROCX_")
+			v.Int(&got, testInt)
+			if err := v.Err(); (err != nil) != tt.wantErr {
+				t.Fatalf("Err() = %v, wantErr %v", err, tt.wantErr)
+			} else if err != nil && !errors.Is(err, strconv.ErrSyntax) {
+				t.Errorf("Err() = %v, want %v", err, strconv.ErrSyntax)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptionEnv(t *testing.T) {
+	o := optionsByValue[testString]
+	if got := o.Env(); got != "TEST_STRING" {
+		t.Errorf("Env() = %q, want TEST_STRING", got)
+	}
+	if got := o.Default(); got != "default" {
+		t.Errorf("Default() = %q, want default", got)
+	}
+	if DriverOptions("test") != testOptions {
+		t.Error("DriverOptions(test) is not the declared set")
+	}
+}
@@ -1,21 +1,25 @@
 package flags
 
 var (
-	PsqlHost           = FlagSet.String("psql-host", "", "PostgreSQL host")
-	PsqlPort           = FlagSet.String("psql-port", "5432", "PostgreSQL port")
-	PsqlUser           = FlagSet.String("psql-user", "", "PostgreSQL user")
-	PsqlPassword       = FlagSet.String("psql-password", "", "PostgreSQL password")
-	PsqlDatabase       = FlagSet.String("psql-database", "", "PostgreSQL database")
-	PsqlSSLMode        = FlagSet.String("psql-ssl-mode", "disable", "PostgreSQL SSL mode")
-	PsqlTLSRootCert    = FlagSet.String("psql-tls-root-cert", "", "PostgreSQL SSL root cert")
-	PsqlTLSCert        = FlagSet.String("psql-tls-cert", "", "PostgreSQL TLS cert")
-	PsqlTLSKey         = FlagSet.String("psql-tls-key", "", "PostgreSQL TLS key")
-	PsqlQueryKey       = FlagSet.Bool("psql-query-key", false, "PostgreSQL query returns key as first column and value as second column")
-	PsqlRetrieveField  = FlagSet.String("psql-retrieve-field", "", "PostgreSQL retrieve field. If not set, all fields will be returned as a JSON object")
-	PsqlRetrieveQuery  = FlagSet.String("psql-retrieve-query", "", "PostgreSQL retrieve query")
-	PsqlRetrieveParams = FlagSet.String("psql-retrieve-params", "", "PostgreSQL retrieve params")
-	PsqlClearQuery     = FlagSet.String("psql-clear-query", "", "PostgreSQL clear query")
-	PsqlClearParams    = FlagSet.String("psql-clear-params", "", "PostgreSQL clear params")
-	PsqlFailQuery      = FlagSet.String("psql-fail-query", "", "PostgreSQL fail query")
-	PsqlFailParams     = FlagSet.String("psql-fail-params", "", "PostgreSQL fail params")
+	postgresOptions = newDriverOptions("postgres")
+
+	PsqlHost           = postgresOptions.String("psql-host", "", "PostgreSQL host")
+	PsqlPort           = postgresOptions.String("psql-port", "5432", "PostgreSQL port")
+	PsqlUser           = postgresOptions.String("psql-user", "", "PostgreSQL user")
+	PsqlPassword       = postgresOptions.String("psql-password", "", "PostgreSQL password")
+	PsqlDatabase       = postgresOptions.String("psql-database", "", "PostgreSQL database")
+	PsqlSSLMode        = postgresOptions.String("psql-ssl-mode", "disable", "PostgreSQL SSL mode")
+	PsqlTLSRootCert    = postgresOptions.String("psql-tls-root-cert", "", "PostgreSQL SSL root cert")
+	PsqlTLSCert        = postgresOptions.String("psql-tls-cert", "", "PostgreSQL TLS cert")
+	PsqlTLSKey         = postgresOptions.String("psql-tls-key", "", "PostgreSQL TLS key")
+	PsqlQueryKey       = postgresOptions.Bool("psql-query-key", false, "PostgreSQL query returns key as first column and value as second column")
+	PsqlRetrieveField  = postgresOptions.String("psql-retrieve-field", "", "PostgreSQL retrieve field. If not set, all fields will be returned as a JSON object")
+	PsqlRetrieveQuery  = postgresOptions.String("psql-retrieve-query", "", "PostgreSQL retrieve query")
+	PsqlRetrieveParams = postgresOptions.String("psql-retrieve-params", "", "PostgreSQL retrieve params")
+	PsqlClearQuery     = postgresOptions.String("psql-clear-query", "", "PostgreSQL clear query")
+	PsqlClearParams    = postgresOptions.String("psql-clear-params", "", "PostgreSQL clear params")
+	PsqlFailQuery      = postgresOptions.String("psql-fail-query", "", "PostgreSQL fail query")
+	PsqlFailParams     = postgresOptions.String("psql-fail-params", "", "PostgreSQL fail params")
+	PsqlCountQuery     = postgresOptions.String("psql-count-query", "", "PostgreSQL count query, returning the number of work items waiting, for depth reporting")
+	PsqlCountParams    = postgresOptions.String("psql-count-params", "", "PostgreSQL count params")
 )
@@ -1,6 +1,8 @@
 package flags
 
 var (
-	RabbitMQURL   = FlagSet.String("rabbitmq-url", "", "RabbitMQ URL")
-	RabbitMQQueue = FlagSet.String("rabbitmq-queue", "", "RabbitMQ queue")
+	rabbitmqOptions = newDriverOptions("rabbitmq")
+
+	RabbitMQURL   = rabbitmqOptions.String("rabbitmq-url", "", "RabbitMQ URL")
+	RabbitMQQueue = rabbitmqOptions.String("rabbitmq-queue", "", "RabbitMQ queue")
 )
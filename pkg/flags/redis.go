@@ -1,20 +1,25 @@
 package flags
 
 var (
-	RedisHost     = FlagSet.String("redis-host", "", "Redis host")
-	RedisPort     = FlagSet.String("redis-port", "6379", "Redis port")
-	RedisPassword = FlagSet.String("redis-password", "", "Redis password")
-	RedisKey      = FlagSet.String("redis-key", "", "Redis key")
+	redisOptions       = sharedOptions("redis")
+	redisListOptions   = newDriverOptions("redis-list", redisOptions)
+	redisPubSubOptions = newDriverOptions("redis-pubsub", redisOptions)
+	redisStreamOptions = newDriverOptions("redis-stream", redisOptions)
 
-	RedisEnableTLS     = FlagSet.Bool("redis-enable-tls", false, "Enable TLS")
-	RedisTLSSkipVerify = FlagSet.Bool("redis-tls-skip-verify", false, "Redis TLS skip verify")
-	RedisCAFile        = FlagSet.String("redis-tls-ca-file", "", "Redis TLS CA file")
-	RedisCertFile      = FlagSet.String("redis-tls-cert-file", "", "Redis TLS cert file")
-	RedisKeyFile       = FlagSet.String("redis-tls-key-file", "", "Redis TLS key file")
+	RedisHost     = redisOptions.String("redis-host", "", "Redis host")
+	RedisPort     = redisOptions.String("redis-port", "6379", "Redis port")
+	RedisPassword = redisOptions.String("redis-password", "", "Redis password")
+	RedisKey      = redisOptions.String("redis-key", "", "Redis key")
 
-	RedisConsumerGroup = FlagSet.String("redis-stream-consumer-group", "", "Redis consumer group")
-	RedisConsumerName  = FlagSet.String("redis-steam-consumer-name", "", "Redis consumer name. Default is a random UUID")
-	RedisValueKeys     = FlagSet.String("redis-stream-value-keys", "", "Redis stream value keys to select. Comma separated, default all.")
-	RedisClearOp       = FlagSet.String("redis-stream-clear-op", "", "Redis clear operation. Valid values are 'ack' and 'del'.")
-	RedisFailOp        = FlagSet.String("redis-stream-fail-op", "", "Redis fail operation. Valid values are 'ack' and 'del'.")
+	RedisEnableTLS   = redisOptions.Bool("redis-enable-tls", false, "Enable TLS")
+	RedisTLSInsecure = redisOptions.Bool("redis-tls-insecure", false, "Redis TLS insecure", FlagAlias("redis-tls-skip-verify"))
+	RedisCAFile      = redisOptions.String("redis-tls-ca-file", "", "Redis TLS CA file")
+	RedisCertFile    = redisOptions.String("redis-tls-cert-file", "", "Redis TLS cert file")
+	RedisKeyFile     = redisOptions.String("redis-tls-key-file", "", "Redis TLS key file")
+
+	RedisConsumerGroup = redisStreamOptions.String("redis-stream-consumer-group", "", "Redis consumer group")
+	RedisConsumerName  = redisStreamOptions.String("redis-stream-consumer-name", "", "Redis consumer name. Default is a random UUID", FlagAlias("redis-steam-consumer-name"))
+	RedisValueKeys     = redisStreamOptions.String("redis-stream-value-keys", "", "Redis stream value keys to select. Comma separated, default all.")
+	RedisClearOp       = redisStreamOptions.String("redis-stream-clear-op", "", "Redis clear operation. Valid values are 'ack' and 'del'.")
+	RedisFailOp        = redisStreamOptions.String("redis-stream-fail-op", "", "Redis fail operation. Valid values are 'ack' and 'del'.")
 )
@@ -0,0 +1,7 @@
+package flags
+
+var (
+	replayOptions = newDriverOptions("replay")
+
+	ReplayDir = replayOptions.String("replay-dir", "", "Replay directory of recorded work, as written by -record-dir")
+)
@@ -0,0 +1,8 @@
+package flags
+
+var (
+	ResultDriver          = FlagSet.String("result-driver", "", "Driver to publish the output of each successful job to. The driver loads the driver flags, overridden by env vars prefixed with PROCX_RESULT_")
+	ResultFailDriver      = FlagSet.String("result-fail-driver", "", "Driver to publish the output of each failed job to. The driver loads the driver flags, overridden by env vars prefixed with PROCX_RESULT_FAIL_")
+	ResultCorrelationPath = FlagSet.String("result-correlation-path", "", "gjson path of the payload field published as the correlation ID of the job output")
+	ResultEnvelope        = FlagSet.Bool("result-envelope", false, "Publish the job output in a JSON envelope with its correlation metadata, for drivers without message headers")
+)
@@ -0,0 +1,19 @@
+package flags
+
+var (
+	WebhookURL           = FlagSet.String("webhook-url", "", "URL to send job lifecycle webhooks to")
+	WebhookEvents        = FlagSet.String("webhook-events", "start,success,failure,retry,dead-letter", "job events to send webhooks for, comma separated. Valid values: start, success, failure, retry, dead-letter")
+	WebhookMethod        = FlagSet.String("webhook-method", "POST", "webhook HTTP method")
+	WebhookContentType   = FlagSet.String("webhook-content-type", "application/json", "webhook content type")
+	WebhookHeaders       = FlagSet.String("webhook-headers", "", "webhook headers, newline separated, in the form key:value")
+	WebhookBody          = FlagSet.String("webhook-body", "", "webhook body template. {{procx_*}} job variables and {{mustache}} payload fields are replaced. default is a JSON object of the job")
+	WebhookFields        = FlagSet.String("webhook-fields", "", "gjson paths of payload fields to include in the default webhook body, comma separated")
+	WebhookTimeout       = FlagSet.Int("webhook-timeout", 5000, "webhook request timeout in milliseconds")
+	WebhookRetries       = FlagSet.Int("webhook-retries", 2, "number of times to retry a failed webhook")
+	WebhookRetryInterval = FlagSet.Int("webhook-retry-interval", 1000, "time in milliseconds to wait before the first webhook retry, doubled on each subsequent retry")
+	WebhookEnableTLS     = FlagSet.Bool("webhook-enable-tls", false, "webhook enable tls")
+	WebhookTLSInsecure   = FlagSet.Bool("webhook-tls-insecure", false, "webhook tls insecure")
+	WebhookTLSCertFile   = FlagSet.String("webhook-tls-cert-file", "", "webhook tls cert file")
+	WebhookTLSKeyFile    = FlagSet.String("webhook-tls-key-file", "", "webhook tls key file")
+	WebhookTLSCAFile     = FlagSet.String("webhook-tls-ca-file", "", "webhook tls ca file")
+)
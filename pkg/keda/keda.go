@@ -0,0 +1,209 @@
+// Package keda implements a KEDA external scaler, which reports the depth of
+// a driver's source as the metric the workers are scaled on.
+package keda
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Scaler is a KEDA external scaler. The ScaledObject may override TargetSize
+// and ActivationThreshold with the targetSize and activationThreshold
+// metadata.
+type Scaler struct {
+	// Depth returns the backlog of the source. Calls are serialized, as
+	// drivers are not safe for concurrent use
+	Depth func() (int64, error)
+	// MetricName is the name of the metric reported to KEDA
+	MetricName string
+	// TargetSize is the backlog each replica should handle
+	TargetSize int64
+	// ActivationThreshold is the backlog above which the workload is active,
+	// and scaled up from zero
+	ActivationThreshold int64
+	// PollInterval is the interval at which the depth is checked for
+	// StreamIsActive
+	PollInterval time.Duration
+	mu           sync.Mutex
+}
+
+// externalScaler is the KEDA externalscaler.ExternalScaler service.
+type externalScaler interface {
+	isActive(ctx context.Context, ref protoreflect.Message) (*dynamicpb.Message, error)
+	streamIsActive(ref protoreflect.Message, stream grpc.ServerStream) error
+	getMetricSpec(ctx context.Context, ref protoreflect.Message) (*dynamicpb.Message, error)
+	getMetrics(ctx context.Context, req protoreflect.Message) (*dynamicpb.Message, error)
+}
+
+// unaryHandler returns the handler of the unary method name, which receives
+// a message of type in.
+func unaryHandler(name string, in protoreflect.MessageDescriptor, f func(externalScaler, context.Context, protoreflect.Message) (*dynamicpb.Message, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := dynamicpb.NewMessage(in)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return f(srv.(externalScaler), ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: "/externalscaler.ExternalScaler/" + name,
+			}
+			return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return f(srv.(externalScaler), ctx, req.(*dynamicpb.Message))
+			})
+		},
+	}
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "externalscaler.ExternalScaler",
+	HandlerType: (*externalScaler)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryHandler("IsActive", scaledObjectRef, externalScaler.isActive),
+		unaryHandler("GetMetricSpec", scaledObjectRef, externalScaler.getMetricSpec),
+		unaryHandler("GetMetrics", getMetricsRequest, externalScaler.getMetrics),
+	},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "StreamIsActive",
+		ServerStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			ref := dynamicpb.NewMessage(scaledObjectRef)
+			if err := stream.RecvMsg(ref); err != nil {
+				return err
+			}
+			return srv.(externalScaler).streamIsActive(ref, stream)
+		},
+	}},
+	Metadata: "externalscaler.proto",
+}
+
+// Serve serves the scaler on ln until ctx is cancelled.
+func (s *Scaler) Serve(ctx context.Context, ln net.Listener) error {
+	l := log.WithFields(log.Fields{
+		"pkg":  "keda",
+		"fn":   "Serve",
+		"addr": ln.Addr().String(),
+	})
+	srv := s.server()
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+	l.Info("serving keda external scaler")
+	return srv.Serve(ln)
+}
+
+// server returns a grpc server serving the scaler.
+func (s *Scaler) server() *grpc.Server {
+	srv := grpc.NewServer()
+	srv.RegisterService(&serviceDesc, s)
+	return srv
+}
+
+// depth returns the backlog of the source.
+func (s *Scaler) depth() (int64, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "keda",
+		"fn":  "depth",
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, err := s.Depth()
+	if err != nil {
+		l.WithError(err).Error("failed to get depth")
+		return 0, err
+	}
+	l.WithField("depth", n).Debug("got depth")
+	return n, nil
+}
+
+// metadataInt returns the integer value of the ScaledObject metadata key, or
+// def if it is not set or invalid.
+func metadataInt(ref protoreflect.Message, key string, def int64) int64 {
+	v := get(ref, "scalerMetadata").Map().Get(protoreflect.ValueOfString(key).MapKey())
+	if !v.IsValid() {
+		return def
+	}
+	i, err := strconv.ParseInt(v.String(), 10, 64)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"pkg": "keda",
+			"fn":  "metadataInt",
+			"key": key,
+		}).WithError(err).Warn("invalid scaler metadata, using default")
+		return def
+	}
+	return i
+}
+
+// active returns the IsActive response for the ScaledObject ref.
+func (s *Scaler) active(ref protoreflect.Message) (*dynamicpb.Message, error) {
+	n, err := s.depth()
+	if err != nil {
+		return nil, err
+	}
+	res := dynamicpb.NewMessage(isActiveResponse)
+	set(res, "result", protoreflect.ValueOfBool(n > metadataInt(ref, "activationThreshold", s.ActivationThreshold)))
+	return res, nil
+}
+
+func (s *Scaler) isActive(ctx context.Context, ref protoreflect.Message) (*dynamicpb.Message, error) {
+	return s.active(ref)
+}
+
+// streamIsActive sends the IsActive response every PollInterval until the
+// stream is closed.
+func (s *Scaler) streamIsActive(ref protoreflect.Message, stream grpc.ServerStream) error {
+	t := time.NewTicker(s.PollInterval)
+	defer t.Stop()
+	for {
+		res, err := s.active(ref)
+		if err != nil {
+			return err
+		}
+		if err := stream.SendMsg(res); err != nil {
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+func (s *Scaler) getMetricSpec(ctx context.Context, ref protoreflect.Message) (*dynamicpb.Message, error) {
+	res := dynamicpb.NewMessage(getMetricSpecResponse)
+	ms := appendMessage(res, "metricSpecs")
+	ms.Set(metricSpec.Fields().ByName("metricName"), protoreflect.ValueOfString(s.MetricName))
+	ms.Set(metricSpec.Fields().ByName("targetSize"), protoreflect.ValueOfInt64(metadataInt(ref, "targetSize", s.TargetSize)))
+	return res, nil
+}
+
+func (s *Scaler) getMetrics(ctx context.Context, req protoreflect.Message) (*dynamicpb.Message, error) {
+	n, err := s.depth()
+	if err != nil {
+		return nil, err
+	}
+	name := get(req, "metricName").String()
+	if name == "" {
+		name = s.MetricName
+	}
+	res := dynamicpb.NewMessage(getMetricsResponse)
+	mv := appendMessage(res, "metricValues")
+	mv.Set(metricValue.Fields().ByName("metricName"), protoreflect.ValueOfString(name))
+	mv.Set(metricValue.Fields().ByName("metricValue"), protoreflect.ValueOfInt64(n))
+	return res, nil
+}
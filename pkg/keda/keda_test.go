@@ -0,0 +1,118 @@
+package keda
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// dial serves s on an in-memory listener and returns a client connection to
+// it.
+func dial(t *testing.T, s *Scaler) *grpc.ClientConn {
+	t.Helper()
+	ln := bufconn.Listen(1 << 20)
+	srv := s.server()
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cc, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return cc
+}
+
+// newRef returns a ScaledObjectRef with the scaler metadata md.
+func newRef(md map[string]string) *dynamicpb.Message {
+	ref := dynamicpb.NewMessage(scaledObjectRef)
+	set(ref, "name", protoreflect.ValueOfString("procx"))
+	m := ref.Mutable(scaledObjectRef.Fields().ByName("scalerMetadata")).Map()
+	for k, v := range md {
+		m.Set(protoreflect.ValueOfString(k).MapKey(), protoreflect.ValueOfString(v))
+	}
+	return ref
+}
+
+func TestIsActive(t *testing.T) {
+	cc := dial(t, &Scaler{
+		Depth:      func() (int64, error) { return 7, nil },
+		MetricName: "procx-depth",
+		TargetSize: 5,
+	})
+	tests := []struct {
+		name string
+		md   map[string]string
+		want bool
+	}{
+		{"default threshold", nil, true},
+		{"below threshold", map[string]string{"activationThreshold": "10"}, false},
+		{"above threshold", map[string]string{"activationThreshold": "6"}, true},
+		{"invalid threshold", map[string]string{"activationThreshold": "x"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := dynamicpb.NewMessage(isActiveResponse)
+			err := cc.Invoke(context.Background(), "/externalscaler.ExternalScaler/IsActive", newRef(tt.md), res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := get(res, "result").Bool(); got != tt.want {
+				t.Errorf("result = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMetrics(t *testing.T) {
+	cc := dial(t, &Scaler{
+		Depth:      func() (int64, error) { return 42, nil },
+		MetricName: "procx-depth",
+		TargetSize: 5,
+	})
+	tests := []struct {
+		name       string
+		metricName string
+		want       string
+	}{
+		{"default name", "", "procx-depth"},
+		{"requested name", "s0-procx-depth", "s0-procx-depth"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := dynamicpb.NewMessage(getMetricsRequest)
+			set(req, "scaledObjectRef", protoreflect.ValueOfMessage(newRef(nil)))
+			set(req, "metricName", protoreflect.ValueOfString(tt.metricName))
+			res := dynamicpb.NewMessage(getMetricsResponse)
+			err := cc.Invoke(context.Background(), "/externalscaler.ExternalScaler/GetMetrics", req, res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mvs := get(res, "metricValues").List()
+			if mvs.Len() != 1 {
+				t.Fatalf("got %d metric values, want 1", mvs.Len())
+			}
+			mv := mvs.Get(0).Message()
+			if got := get(mv, "metricName").String(); got != tt.want {
+				t.Errorf("metricName = %q, want %q", got, tt.want)
+			}
+			if got := get(mv, "metricValue").Int(); got != 42 {
+				t.Errorf("metricValue = %d, want 42", got)
+			}
+		})
+	}
+}
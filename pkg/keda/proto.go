@@ -0,0 +1,120 @@
+package keda
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// The messages of the KEDA externalscaler.proto, built at runtime rather than
+// generated, so the scaler does not depend on the KEDA module. They are
+// package variables rather than set in init, so they are initialized before
+// serviceDesc, which depends on them.
+var (
+	externalScalerFile    = newExternalScalerFile()
+	scaledObjectRef       = externalScalerFile.Messages().ByName("ScaledObjectRef")
+	isActiveResponse      = externalScalerFile.Messages().ByName("IsActiveResponse")
+	getMetricSpecResponse = externalScalerFile.Messages().ByName("GetMetricSpecResponse")
+	metricSpec            = externalScalerFile.Messages().ByName("MetricSpec")
+	getMetricsRequest     = externalScalerFile.Messages().ByName("GetMetricsRequest")
+	getMetricsResponse    = externalScalerFile.Messages().ByName("GetMetricsResponse")
+	metricValue           = externalScalerFile.Messages().ByName("MetricValue")
+)
+
+// newExternalScalerFile builds the file descriptor of externalscaler.proto.
+func newExternalScalerFile() protoreflect.FileDescriptor {
+	fd, err := protodesc.NewFile(externalScalerProto(), nil)
+	if err != nil {
+		panic(err)
+	}
+	return fd
+}
+
+func field(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(num),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     typ.Enum(),
+	}
+}
+
+func messageField(name string, num int32, msg string, repeated bool) *descriptorpb.FieldDescriptorProto {
+	f := field(name, num, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	f.TypeName = proto.String(".externalscaler." + msg)
+	if repeated {
+		f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	}
+	return f
+}
+
+// externalScalerProto returns the descriptor of the messages of KEDA's
+// externalscaler.proto.
+func externalScalerProto() *descriptorpb.FileDescriptorProto {
+	var (
+		tString = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		tBool   = descriptorpb.FieldDescriptorProto_TYPE_BOOL
+		tInt64  = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		tDouble = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+	)
+	msg := func(name string, fields ...*descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{Name: proto.String(name), Field: fields}
+	}
+	ref := msg("ScaledObjectRef",
+		field("name", 1, tString),
+		field("namespace", 2, tString),
+		messageField("scalerMetadata", 3, "ScaledObjectRef.ScalerMetadataEntry", true),
+	)
+	ref.NestedType = []*descriptorpb.DescriptorProto{{
+		Name:    proto.String("ScalerMetadataEntry"),
+		Field:   []*descriptorpb.FieldDescriptorProto{field("key", 1, tString), field("value", 2, tString)},
+		Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+	}}
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("externalscaler.proto"),
+		Package: proto.String("externalscaler"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			ref,
+			msg("IsActiveResponse", field("result", 1, tBool)),
+			msg("GetMetricSpecResponse", messageField("metricSpecs", 1, "MetricSpec", true)),
+			msg("MetricSpec",
+				field("metricName", 1, tString),
+				field("targetSize", 2, tInt64),
+				field("targetSizeFloat", 3, tDouble),
+			),
+			msg("GetMetricsRequest",
+				messageField("scaledObjectRef", 1, "ScaledObjectRef", false),
+				field("metricName", 2, tString),
+			),
+			msg("GetMetricsResponse", messageField("metricValues", 1, "MetricValue", true)),
+			msg("MetricValue",
+				field("metricName", 1, tString),
+				field("metricValue", 2, tInt64),
+				field("metricValueFloat", 3, tDouble),
+			),
+		},
+	}
+}
+
+// set sets the field name of m to v.
+func set(m *dynamicpb.Message, name string, v protoreflect.Value) {
+	m.Set(m.Descriptor().Fields().ByName(protoreflect.Name(name)), v)
+}
+
+// get returns the field name of m.
+func get(m protoreflect.Message, name string) protoreflect.Value {
+	return m.Get(m.Descriptor().Fields().ByName(protoreflect.Name(name)))
+}
+
+// appendMessage appends a new message to the repeated message field name of
+// m, and returns it.
+func appendMessage(m *dynamicpb.Message, name string) protoreflect.Message {
+	l := m.Mutable(m.Descriptor().Fields().ByName(protoreflect.Name(name))).List()
+	v := l.NewElement()
+	l.Append(v)
+	return v.Message()
+}
@@ -0,0 +1,94 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/robertlestak/procx/drivers/etcd"
+	log "github.com/sirupsen/logrus"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// etcdLock is a lock on an etcd key prefix, held with a lease which is kept
+// alive by the etcd client.
+type etcdLock struct {
+	d       *etcd.Etcd
+	key     string
+	ttl     time.Duration
+	session *concurrency.Session
+	mutex   *concurrency.Mutex
+}
+
+func newEtcd(key string, ttl time.Duration, envKeyPrefix string) (*etcdLock, error) {
+	d := &etcd.Etcd{}
+	if err := d.LoadFlags(); err != nil {
+		return nil, err
+	}
+	if err := d.LoadEnv(envKeyPrefix); err != nil {
+		return nil, err
+	}
+	if err := d.Init(); err != nil {
+		return nil, err
+	}
+	return &etcdLock{d: d, key: key, ttl: ttl}, nil
+}
+
+func (e *etcdLock) Acquire(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "lock",
+		"fn":  "etcd.Acquire",
+		"key": e.key,
+	})
+	l.Debug("Acquire")
+	// lease TTLs are in whole seconds
+	ttl := int((e.ttl + time.Second - 1) / time.Second)
+	s, err := concurrency.NewSession(e.d.Client, concurrency.WithTTL(ttl))
+	if err != nil {
+		l.Error(err)
+		return err
+	}
+	m := concurrency.NewMutex(s, e.key)
+	if err := m.Lock(ctx); err != nil {
+		s.Close()
+		return err
+	}
+	e.session, e.mutex = s, m
+	return nil
+}
+
+func (e *etcdLock) Refresh(ctx context.Context) error {
+	select {
+	case <-e.session.Done():
+		return ErrLockLost
+	default:
+	}
+	// the lease is kept alive by the session, however the key may have been
+	// removed
+	resp, err := e.d.Client.Txn(ctx).If(e.mutex.IsOwner()).Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (e *etcdLock) Release(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "lock",
+		"fn":  "etcd.Release",
+		"key": e.key,
+	})
+	l.Debug("Release")
+	if e.mutex != nil {
+		if err := e.mutex.Unlock(ctx); err != nil {
+			l.WithError(err).Error("failed to unlock")
+		}
+		// closing the session revokes the lease
+		if err := e.session.Close(); err != nil {
+			l.WithError(err).Error("failed to close session")
+		}
+	}
+	return e.d.Cleanup()
+}
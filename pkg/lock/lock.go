@@ -0,0 +1,131 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type Backend string
+
+var (
+	BackendEtcd     Backend = "etcd"
+	BackendRedis    Backend = "redis"
+	BackendPostgres Backend = "postgres"
+)
+
+var (
+	ErrInvalidBackend = errors.New("invalid lock backend")
+	ErrLockLost       = errors.New("lock lost")
+)
+
+// Locker is a distributed lock held by a single process at a time.
+type Locker interface {
+	// Acquire blocks until the lock is held, or ctx is done
+	Acquire(ctx context.Context) error
+	// Refresh extends the lock, returning ErrLockLost if it is no longer
+	// held
+	Refresh(ctx context.Context) error
+	// Release releases the lock and closes its connection
+	Release(ctx context.Context) error
+}
+
+// New creates a Locker for key with the backend, connecting with the
+// settings of the corresponding driver loaded from the flags and the
+// environment. The lock expires after ttl if it is not refreshed.
+func New(backend Backend, key string, ttl time.Duration, envKeyPrefix string) (Locker, error) {
+	l := log.WithFields(log.Fields{
+		"pkg":     "lock",
+		"fn":      "New",
+		"backend": backend,
+	})
+	l.Debug("New")
+	switch backend {
+	case BackendEtcd:
+		return newEtcd(key, ttl, envKeyPrefix)
+	case BackendRedis:
+		return newRedis(key, ttl, envKeyPrefix)
+	case BackendPostgres:
+		return newPostgres(key, ttl, envKeyPrefix)
+	default:
+		l.Error("invalid lock backend")
+		return nil, ErrInvalidBackend
+	}
+}
+
+// Hold refreshes lk every interval until ctx is done, returning an error if
+// the lock is lost. A refresh which fails without reporting the lock lost is
+// retried with backoff, as the lock is still held until ttl has passed since
+// the last refresh, after which it is treated as lost.
+func Hold(ctx context.Context, lk Locker, interval, ttl time.Duration) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "lock",
+		"fn":  "Hold",
+	})
+	refreshed := time.Now()
+	wait := interval
+	// backoff is the wait between retries of a failed refresh
+	var backoff time.Duration
+	for {
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+		l.Debug("refreshing lock")
+		err := lk.Refresh(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			refreshed = time.Now()
+			wait, backoff = interval, 0
+			continue
+		}
+		if errors.Is(err, ErrLockLost) {
+			l.WithError(err).Error("lock lost")
+			return err
+		}
+		left := ttl - time.Since(refreshed)
+		if left <= 0 {
+			l.WithError(err).Error("failed to refresh lock before it expired")
+			return fmt.Errorf("%w: %v", ErrLockLost, err)
+		}
+		// back off from a short wait, retrying once more before the lock
+		// expires
+		if backoff == 0 {
+			backoff = interval / 8
+		} else if backoff *= 2; backoff > interval {
+			backoff = interval
+		}
+		wait = backoff
+		if wait > left {
+			wait = left
+		}
+		l.WithError(err).Warnf("failed to refresh lock, retrying in %s", wait)
+	}
+}
+
+// retry calls try every interval until it returns true or an error, or ctx
+// is done.
+func retry(ctx context.Context, interval time.Duration, try func() (bool, error)) error {
+	for {
+		ok, err := try()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLocker is a Locker whose refreshes return the errors in errs in turn,
+// and then nil.
+type fakeLocker struct {
+	mu        sync.Mutex
+	errs      []error
+	refreshes int
+}
+
+func (f *fakeLocker) Acquire(ctx context.Context) error { return nil }
+func (f *fakeLocker) Release(ctx context.Context) error { return nil }
+
+func (f *fakeLocker) Refresh(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refreshes++
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func TestHold(t *testing.T) {
+	errRefresh := errors.New("connection refused")
+	const (
+		interval = 20 * time.Millisecond
+		ttl      = 100 * time.Millisecond
+	)
+	tests := []struct {
+		name string
+		errs []error
+		// lost is true if Hold returns before the context is done
+		lost bool
+	}{
+		{"refreshed", nil, false},
+		{"transient failure", []error{errRefresh, errRefresh, errRefresh}, false},
+		{"failures after a refresh", []error{errRefresh, errRefresh, nil, errRefresh, errRefresh, errRefresh}, false},
+		{"lost", []error{ErrLockLost}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lk := &fakeLocker{errs: tt.errs}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*ttl)
+			defer cancel()
+			start := time.Now()
+			err := Hold(ctx, lk, interval, ttl)
+			if lost := ctx.Err() == nil; lost != tt.lost {
+				t.Fatalf("Hold() = %v after %s, want lost %v", err, time.Since(start), tt.lost)
+			}
+			if tt.lost != (err != nil) {
+				t.Fatalf("Hold() = %v, want lost %v", err, tt.lost)
+			}
+			if err != nil && !errors.Is(err, ErrLockLost) {
+				t.Errorf("Hold() = %v, want %v", err, ErrLockLost)
+			}
+		})
+	}
+}
+
+func TestHoldRetriesUntilExpired(t *testing.T) {
+	errRefresh := errors.New("connection refused")
+	var errs []error
+	for i := 0; i < 100; i++ {
+		errs = append(errs, errRefresh)
+	}
+	lk := &fakeLocker{errs: errs}
+	const (
+		interval = 20 * time.Millisecond
+		ttl      = 100 * time.Millisecond
+	)
+	start := time.Now()
+	err := Hold(context.Background(), lk, interval, ttl)
+	if !errors.Is(err, ErrLockLost) {
+		t.Fatalf("Hold() = %v, want %v", err, ErrLockLost)
+	}
+	// the lock is held until ttl after it was last refreshed, when Hold
+	// was called
+	if d := time.Since(start); d < ttl {
+		t.Errorf("Hold() gave up after %s, before the lock expired after %s", d, ttl)
+	}
+	// the failed refresh is retried more often than every interval
+	if lk.refreshes <= int(ttl/interval) {
+		t.Errorf("refreshed %d times, want more than %d", lk.refreshes, ttl/interval)
+	}
+}
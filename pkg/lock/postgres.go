@@ -0,0 +1,97 @@
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/robertlestak/procx/drivers/postgres"
+	log "github.com/sirupsen/logrus"
+)
+
+// postgresLock is a session level advisory lock, held for as long as its
+// connection is open.
+type postgresLock struct {
+	d    *postgres.Postgres
+	key  string
+	id   int64
+	ttl  time.Duration
+	conn *sql.Conn
+}
+
+func newPostgres(key string, ttl time.Duration, envKeyPrefix string) (*postgresLock, error) {
+	d := &postgres.Postgres{}
+	if err := d.LoadFlags(); err != nil {
+		return nil, err
+	}
+	if err := d.LoadEnv(envKeyPrefix); err != nil {
+		return nil, err
+	}
+	if err := d.Init(); err != nil {
+		return nil, err
+	}
+	// advisory locks are identified by a 64 bit integer
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return &postgresLock{d: d, key: key, id: int64(h.Sum64()), ttl: ttl}, nil
+}
+
+func (p *postgresLock) Acquire(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "lock",
+		"fn":  "postgres.Acquire",
+		"key": p.key,
+	})
+	l.Debug("Acquire")
+	conn, err := p.d.Client.Conn(ctx)
+	if err != nil {
+		l.Error(err)
+		return err
+	}
+	err = retry(ctx, p.ttl/3, func() (bool, error) {
+		var ok bool
+		err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", p.id).Scan(&ok)
+		if err != nil {
+			l.Error(err)
+		}
+		return ok, err
+	})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	p.conn = conn
+	return nil
+}
+
+func (p *postgresLock) Refresh(ctx context.Context) error {
+	// the lock is held until the connection it was acquired on is closed
+	ctx, cancel := context.WithTimeout(ctx, p.ttl)
+	defer cancel()
+	err := p.conn.PingContext(ctx)
+	if errors.Is(err, sql.ErrConnDone) || errors.Is(err, driver.ErrBadConn) {
+		// the connection, and so the lock, is gone
+		return fmt.Errorf("%w: %v", ErrLockLost, err)
+	}
+	return err
+}
+
+func (p *postgresLock) Release(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "lock",
+		"fn":  "postgres.Release",
+		"key": p.key,
+	})
+	l.Debug("Release")
+	if p.conn != nil {
+		if _, err := p.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", p.id); err != nil {
+			l.WithError(err).Error("failed to unlock")
+		}
+		p.conn.Close()
+	}
+	return p.d.Cleanup()
+}
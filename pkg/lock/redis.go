@@ -0,0 +1,80 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robertlestak/procx/drivers/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+// redisRefresh and redisRelease only extend or remove the lock if it is
+// still held with the token of this process.
+const (
+	redisRefresh = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	redisRelease = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+)
+
+// redisLock is a lock on a Redis key set with SET NX PX, holding a random
+// token.
+type redisLock struct {
+	d     *redis.RedisList
+	key   string
+	ttl   time.Duration
+	token string
+}
+
+func newRedis(key string, ttl time.Duration, envKeyPrefix string) (*redisLock, error) {
+	d := &redis.RedisList{}
+	if err := d.LoadFlags(); err != nil {
+		return nil, err
+	}
+	if err := d.LoadEnv(envKeyPrefix); err != nil {
+		return nil, err
+	}
+	if err := d.Init(); err != nil {
+		return nil, err
+	}
+	return &redisLock{d: d, key: key, ttl: ttl, token: uuid.New().String()}, nil
+}
+
+func (r *redisLock) Acquire(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "lock",
+		"fn":  "redis.Acquire",
+		"key": r.key,
+	})
+	l.Debug("Acquire")
+	return retry(ctx, r.ttl/3, func() (bool, error) {
+		ok, err := r.d.Client.SetNX(r.key, r.token, r.ttl).Result()
+		if err != nil {
+			l.Error(err)
+		}
+		return ok, err
+	})
+}
+
+func (r *redisLock) Refresh(ctx context.Context) error {
+	n, err := r.d.Client.Eval(redisRefresh, []string{r.key}, r.token, r.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (r *redisLock) Release(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "lock",
+		"fn":  "redis.Release",
+		"key": r.key,
+	})
+	l.Debug("Release")
+	if err := r.d.Client.Eval(redisRelease, []string{r.key}, r.token).Err(); err != nil {
+		l.WithError(err).Error("failed to release lock")
+	}
+	return r.d.Cleanup()
+}
@@ -0,0 +1,12 @@
+//go:build linux && go1.20
+
+package procx
+
+import "syscall"
+
+// useCgroupFD starts the process in the cgroup directory fd.
+func useCgroupFD(a *syscall.SysProcAttr, fd int) bool {
+	a.UseCgroupFD = true
+	a.CgroupFD = fd
+	return true
+}
@@ -0,0 +1,11 @@
+//go:build linux && !go1.20
+
+package procx
+
+import "syscall"
+
+// useCgroupFD is not supported before go1.20, so the process is moved into
+// its cgroup once it has started.
+func useCgroupFD(a *syscall.SysProcAttr, fd int) bool {
+	return false
+}
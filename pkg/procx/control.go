@@ -0,0 +1,173 @@
+package procx
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Control pauses and resumes work retrieval for the ProcX it is set on. A
+// single Control may be shared by multiple ProcX.
+type Control struct {
+	mu     sync.Mutex
+	paused bool
+	// resume is closed when paused work retrieval is resumed
+	resume chan struct{}
+	// pause is closed when work retrieval is paused
+	pause chan struct{}
+}
+
+// NewControl creates a new Control with work retrieval running.
+func NewControl() *Control {
+	return &Control{
+		pause: make(chan struct{}),
+	}
+}
+
+// Pause stops new work from being retrieved. Work which is in progress is
+// completed, and retrievals which are waiting for work are cancelled.
+func (c *Control) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		return
+	}
+	c.paused = true
+	c.resume = make(chan struct{})
+	close(c.pause)
+}
+
+// Resume resumes work retrieval.
+func (c *Control) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.paused {
+		return
+	}
+	c.paused = false
+	c.pause = make(chan struct{})
+	close(c.resume)
+}
+
+// Paused returns true if work retrieval is paused.
+func (c *Control) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// wait blocks while work retrieval is paused, or until ctx is done.
+func (c *Control) wait(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		paused, resume := c.paused, c.resume
+		c.mu.Unlock()
+		if !paused {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-resume:
+		}
+	}
+}
+
+// fetchContext returns a context for waiting for work which is cancelled
+// when work retrieval is paused.
+func (c *Control) fetchContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	fctx, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	pause := c.pause
+	c.mu.Unlock()
+	go func() {
+		select {
+		case <-pause:
+			cancel()
+		case <-fctx.Done():
+		}
+	}()
+	return fctx, cancel
+}
+
+// Status is the state of a ProcX and its current job.
+type Status struct {
+	// JobID, JobStarted and PID are set while a job is in progress. PID is
+	// the process ID of the job's process, once it has started
+	JobID      string     `json:"jobID,omitempty"`
+	JobStarted *time.Time `json:"jobStarted,omitempty"`
+	PID        int        `json:"pid,omitempty"`
+	// Received is the number of work items received, and Succeeded and
+	// Failed the number of jobs completed
+	Received  int64 `json:"received"`
+	Succeeded int64 `json:"succeeded"`
+	Failed    int64 `json:"failed"`
+	// Poisoned is the number of work items given to the poison op after
+	// exceeding the max deliveries
+	Poisoned int64 `json:"poisoned"`
+	// LastError is the most recent error, and LastErrorTime when it occurred
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorTime *time.Time `json:"lastErrorTime,omitempty"`
+}
+
+// statusMu guards the status of all ProcX, which is read while work is in
+// progress.
+var statusMu sync.Mutex
+
+// Status returns the state of j and its current job. It is safe to call
+// while j is processing work.
+func (j *ProcX) Status() Status {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	return j.status
+}
+
+// setStatus updates the status of j with f.
+func (j *ProcX) setStatus(f func(s *Status)) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	f(&j.status)
+}
+
+// jobStarted records the start of the current job.
+func (j *ProcX) jobStarted() {
+	now := time.Now()
+	j.setStatus(func(s *Status) {
+		s.JobID = j.jobID
+		s.JobStarted = &now
+		s.PID = 0
+		s.Received++
+	})
+}
+
+// jobEnded clears the current job from the status of j.
+func (j *ProcX) jobEnded() {
+	j.setStatus(func(s *Status) {
+		s.JobID = ""
+		s.JobStarted = nil
+		s.PID = 0
+	})
+}
+
+// jobResult counts the completed job, recording err if it failed.
+func (j *ProcX) jobResult(err error) {
+	if j.Breaker != nil {
+		j.Breaker.Record(j.trial, err != nil, j.exitCode(err))
+		j.trial = false
+	}
+	j.setStatus(func(s *Status) {
+		if err == nil {
+			s.Succeeded++
+			return
+		}
+		s.Failed++
+		s.setError(err)
+	})
+}
+
+// setError records err as the last error.
+func (s *Status) setError(err error) {
+	now := time.Now()
+	s.LastError = err.Error()
+	s.LastErrorTime = &now
+}
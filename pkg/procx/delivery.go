@@ -0,0 +1,148 @@
+package procx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/robertlestak/procx/pkg/drivers"
+	"github.com/robertlestak/procx/pkg/webhook"
+	log "github.com/sirupsen/logrus"
+)
+
+// PoisonOp is the action taken on work delivered more than MaxDeliveries
+// times.
+type PoisonOp string
+
+var (
+	// PoisonOpFail hands the work to the driver's failure handler, which
+	// should move it out of the way, as with the S3 and FS mv fail op
+	PoisonOpFail PoisonOp = "fail"
+	// PoisonOpClear clears the work with the driver, dropping it
+	PoisonOpClear PoisonOp = "clear"
+	// PoisonOpPublish publishes the payload to PoisonDriver, then clears the
+	// work with the driver
+	PoisonOpPublish PoisonOp = "publish"
+)
+
+var (
+	ErrMaxDeliveries = errors.New("work exceeded max deliveries")
+)
+
+// DeliveryCount returns the number of times the current work has been
+// delivered, including the current delivery, or 0 if it is unknown.
+func (j *ProcX) DeliveryCount() int {
+	return j.deliveryCount
+}
+
+// countDelivery sets the delivery count of the current work, as reported by
+// the driver, or counted in Deliveries by its ID, or a hash of its payload if
+// the driver has no ID for it. Work counted by its payload cannot be told
+// apart from other work with the same payload, so until it is cleared, each
+// is counted as a delivery of the same work.
+func (j *ProcX) countDelivery() {
+	j.deliveryCount, j.deliveryKey = 0, ""
+	if dc, ok := j.Driver.(drivers.DeliveryCounter); ok {
+		if n := dc.DeliveryCount(); n > 0 {
+			j.deliveryCount = n
+			return
+		}
+	}
+	if j.Deliveries == nil {
+		return
+	}
+	if wi, ok := j.Driver.(drivers.WorkIdentifier); ok {
+		j.deliveryKey = wi.WorkID()
+	}
+	if j.deliveryKey == "" {
+		h := sha256.Sum256([]byte(j.PayloadString()))
+		j.deliveryKey = hex.EncodeToString(h[:])
+	}
+	j.deliveryKey = string(j.DriverName) + ":" + j.deliveryKey
+	j.deliveryCount = j.Deliveries.Add(j.deliveryKey)
+}
+
+// identifiesWork returns true if the delivery count of the work of d is
+// reported by its source, or counted by its ID. All the sources of a
+// MultiDriver must identify their work.
+func identifiesWork(d drivers.Driver) bool {
+	if md, ok := d.(*drivers.MultiDriver); ok {
+		for _, s := range md.Sources {
+			if !identifiesWork(s.Driver) {
+				return false
+			}
+		}
+		return true
+	}
+	_, dc := d.(drivers.DeliveryCounter)
+	_, wi := d.(drivers.WorkIdentifier)
+	return dc || wi
+}
+
+// checkDeliveries warns if MaxDeliveries is set and deliveries are counted by
+// the payload of the work, so work with identical payloads shares a count.
+func (j *ProcX) checkDeliveries() {
+	if j.MaxDeliveries <= 0 || j.Deliveries == nil || identifiesWork(j.Driver) {
+		return
+	}
+	log.WithFields(log.Fields{
+		"fn":     "checkDeliveries",
+		"driver": j.DriverName,
+	}).Warn("driver has no ID for its work, so deliveries are counted by payload, and work with identical payloads shares a delivery count")
+}
+
+// resetDelivery forgets the deliveries of the current work counted in
+// Deliveries, once it has been cleared.
+func (j *ProcX) resetDelivery() {
+	if j.Deliveries != nil && j.deliveryKey != "" {
+		j.Deliveries.Reset(j.deliveryKey)
+	}
+}
+
+// poisoned returns true if the current work has been delivered more than
+// MaxDeliveries times.
+func (j *ProcX) poisoned() bool {
+	return j.MaxDeliveries > 0 && j.deliveryCount > j.MaxDeliveries
+}
+
+// poison takes the PoisonOp on the current work rather than executing it.
+func (j *ProcX) poison() error {
+	l := log.WithFields(log.Fields{
+		"fn":         "poison",
+		"job":        j.jobID,
+		"deliveries": j.deliveryCount,
+		"op":         j.PoisonOp,
+	})
+	err := fmt.Errorf("%w: delivered %d times", ErrMaxDeliveries, j.deliveryCount)
+	l.Warn(err)
+	j.setResult(j.result(err))
+	var perr error
+	switch j.PoisonOp {
+	case PoisonOpClear:
+		perr = j.Driver.ClearWork()
+	case PoisonOpPublish:
+		l.Debug("publishing poison work")
+		if perr = j.poisonPub.Publish([]byte(j.PayloadString()), j.resultMeta(err)); perr == nil {
+			perr = j.Driver.ClearWork()
+		}
+	default:
+		perr = j.Driver.HandleFailure()
+	}
+	if perr != nil {
+		l.WithError(perr).Error("failed to handle poison work")
+		j.setStatus(func(s *Status) { s.setError(perr) })
+		return perr
+	}
+	// work given to the failure handler may be delivered again, and is
+	// still poison
+	if j.PoisonOp == PoisonOpClear || j.PoisonOp == PoisonOpPublish {
+		j.resetDelivery()
+	}
+	j.setStatus(func(s *Status) {
+		s.Poisoned++
+		s.setError(err)
+	})
+	j.notify(webhook.EventDeadLetter, err)
+	return nil
+}
@@ -0,0 +1,201 @@
+package procx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/robertlestak/procx/pkg/delivery"
+	"github.com/robertlestak/procx/pkg/drivers"
+)
+
+// fakeDriver delivers the same payload every time, and counts how its work
+// is cleared and failed.
+type fakeDriver struct {
+	payload         string
+	cleared, failed int
+}
+
+func (d *fakeDriver) LoadEnv(string) error { return nil }
+func (d *fakeDriver) LoadFlags() error     { return nil }
+func (d *fakeDriver) Init() error          { return nil }
+func (d *fakeDriver) Cleanup() error       { return nil }
+
+func (d *fakeDriver) GetWork() (io.Reader, error) {
+	return bytes.NewBufferString(d.payload), nil
+}
+
+func (d *fakeDriver) ClearWork() error {
+	d.cleared++
+	return nil
+}
+
+func (d *fakeDriver) HandleFailure() error {
+	d.failed++
+	return nil
+}
+
+// idDriver is a fakeDriver whose work has an ID.
+type idDriver struct {
+	fakeDriver
+	id string
+}
+
+func (d *idDriver) WorkID() string { return d.id }
+
+// fakePublisher records the payloads published to it.
+type fakePublisher struct {
+	published []string
+}
+
+func (p *fakePublisher) InitPublisher() error  { return nil }
+func (p *fakePublisher) ClosePublisher() error { return nil }
+
+func (p *fakePublisher) Publish(body []byte, meta map[string]string) error {
+	p.published = append(p.published, string(body))
+	return nil
+}
+
+func TestPoisoned(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxDeliveries int
+		deliveryCount int
+		want          bool
+	}{
+		{"unlimited", 0, 100, false},
+		{"unknown count", 3, 0, false},
+		{"below limit", 3, 2, false},
+		{"at limit", 3, 3, false},
+		{"above limit", 3, 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &ProcX{MaxDeliveries: tt.maxDeliveries, deliveryCount: tt.deliveryCount}
+			if got := j.poisoned(); got != tt.want {
+				t.Errorf("poisoned() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoison(t *testing.T) {
+	errJob := errors.New("job failed")
+	tests := []struct {
+		op PoisonOp
+		// cleared and failed are the driver calls after the work fails once
+		// and is then poisoned
+		cleared, failed int
+		published       int
+		// reset is true if the delivery count is forgotten, so the work is
+		// executed again on its next delivery
+		reset bool
+	}{
+		{PoisonOpFail, 0, 2, 0, false},
+		{PoisonOpClear, 1, 1, 0, true},
+		{PoisonOpPublish, 1, 1, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.op), func(t *testing.T) {
+			d := &fakeDriver{payload: `{"id":1}`}
+			var calls int
+			j, err := New(d, WithHandler(func(ctx context.Context, w Work) (Result, error) {
+				calls++
+				return Result{}, errJob
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub := &fakePublisher{}
+			j.MaxDeliveries = 1
+			j.PoisonOp = tt.op
+			j.Deliveries = delivery.NewCounter(0, 0)
+			j.poisonPub = pub
+			if err := j.DoWork(); !errors.Is(err, errJob) {
+				t.Fatalf("first delivery: DoWork() = %v, want %v", err, errJob)
+			}
+			if err := j.DoWork(); err != nil {
+				t.Fatalf("second delivery: DoWork() = %v", err)
+			}
+			if calls != 1 {
+				t.Errorf("handler called %d times, want 1", calls)
+			}
+			if d.cleared != tt.cleared || d.failed != tt.failed {
+				t.Errorf("cleared %d, failed %d, want %d, %d", d.cleared, d.failed, tt.cleared, tt.failed)
+			}
+			if len(pub.published) != tt.published {
+				t.Errorf("published %d, want %d", len(pub.published), tt.published)
+			} else if tt.published > 0 && pub.published[0] != d.payload {
+				t.Errorf("published %q, want %q", pub.published[0], d.payload)
+			}
+			if s := j.Status(); s.Poisoned != 1 {
+				t.Errorf("Poisoned = %d, want 1", s.Poisoned)
+			}
+			j.DoWork()
+			if reset := calls == 2; reset != tt.reset {
+				t.Errorf("executed on next delivery = %v, want %v", reset, tt.reset)
+			}
+		})
+	}
+}
+
+func TestCountDelivery(t *testing.T) {
+	// work without an ID is counted by its payload, so different work with
+	// the same payload shares a count
+	t.Run("payload", func(t *testing.T) {
+		j := &ProcX{DriverName: "fake", Deliveries: delivery.NewCounter(0, 0)}
+		j.Driver = &fakeDriver{}
+		for i, payload := range []string{"a", "a", "b"} {
+			j.work = bytes.NewBufferString(payload)
+			j.countDelivery()
+			if want := []int{1, 2, 1}[i]; j.deliveryCount != want {
+				t.Errorf("delivery %d of %q counted %d, want %d", i, payload, j.deliveryCount, want)
+			}
+		}
+	})
+	t.Run("id", func(t *testing.T) {
+		d := &idDriver{}
+		j := &ProcX{DriverName: "fake", Driver: d, Deliveries: delivery.NewCounter(0, 0)}
+		for i, id := range []string{"1", "2", "1"} {
+			d.id = id
+			j.work = bytes.NewBufferString("a")
+			j.countDelivery()
+			if want := []int{1, 1, 2}[i]; j.deliveryCount != want {
+				t.Errorf("delivery %d of %s counted %d, want %d", i, id, j.deliveryCount, want)
+			}
+		}
+	})
+	t.Run("reset", func(t *testing.T) {
+		j := &ProcX{DriverName: "fake", Driver: &fakeDriver{}, Deliveries: delivery.NewCounter(0, 0)}
+		for i := 0; i < 2; i++ {
+			j.work = bytes.NewBufferString("a")
+			j.countDelivery()
+			if j.deliveryCount != 1 {
+				t.Errorf("delivery after reset counted %d, want 1", j.deliveryCount)
+			}
+			j.resetDelivery()
+		}
+	})
+}
+
+func TestIdentifiesWork(t *testing.T) {
+	if identifiesWork(&fakeDriver{}) {
+		t.Error("fakeDriver identifies work, want false")
+	}
+	if !identifiesWork(&idDriver{}) {
+		t.Error("idDriver does not identify work, want true")
+	}
+	md := &drivers.MultiDriver{Sources: []*drivers.MultiSource{
+		{Name: "a", Driver: &idDriver{}},
+		{Name: "b", Driver: &fakeDriver{}},
+	}}
+	if identifiesWork(md) {
+		t.Error("multi with a source without IDs identifies work, want false")
+	}
+	md.Sources = md.Sources[:1]
+	if !identifiesWork(md) {
+		t.Error("multi with sources with IDs does not identify work, want true")
+	}
+}
@@ -0,0 +1,27 @@
+package procx
+
+import (
+	"github.com/robertlestak/procx/pkg/drivers"
+	log "github.com/sirupsen/logrus"
+)
+
+// Depth returns the backlog of work waiting in the driver's source, if the
+// driver implements drivers.DepthReporter.
+func (j *ProcX) Depth() (int64, error) {
+	l := log.WithFields(log.Fields{
+		"fn":     "Depth",
+		"driver": j.DriverName,
+	})
+	l.Debug("Depth")
+	dr, ok := j.Driver.(drivers.DepthReporter)
+	if !ok {
+		l.Error(drivers.ErrDepthNotSupported)
+		return 0, drivers.ErrDepthNotSupported
+	}
+	n, err := dr.Depth()
+	if err != nil {
+		l.Error(err)
+		return 0, err
+	}
+	return n, nil
+}
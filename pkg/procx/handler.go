@@ -0,0 +1,154 @@
+package procx
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/robertlestak/procx/pkg/drivers"
+	"github.com/robertlestak/procx/pkg/ratelimit"
+	log "github.com/sirupsen/logrus"
+)
+
+// Work is a single unit of work passed to a Handler.
+type Work struct {
+	Driver  drivers.DriverName
+	Payload []byte
+	// DeliveryCount is the number of times the work has been delivered,
+	// including this delivery, or 0 if it is unknown
+	DeliveryCount int
+}
+
+// Result is the result of a Handler.
+type Result struct {
+	Output []byte
+}
+
+// Handler processes a single unit of work in process. If the Handler returns
+// an error, the work is failed with the driver, otherwise it is cleared.
+type Handler func(ctx context.Context, w Work) (Result, error)
+
+// Option configures a ProcX created with New.
+type Option func(*ProcX)
+
+// WithHandler sets the Handler called with each unit of work.
+func WithHandler(h Handler) Option {
+	return func(j *ProcX) {
+		j.Handler = h
+	}
+}
+
+// WithDriverName sets the driver name reported in logs and passed to the
+// Handler.
+func WithDriverName(n drivers.DriverName) Option {
+	return func(j *ProcX) {
+		j.DriverName = n
+	}
+}
+
+// WithRateLimit limits work retrieval to rate per second, with the given burst.
+func WithRateLimit(rate float64, burst int) Option {
+	return func(j *ProcX) {
+		j.RateLimiter = ratelimit.NewLimiter(rate, burst)
+	}
+}
+
+// WithKeyConcurrency caps the number of concurrent jobs which share the value
+// at the gjson path in their payload. The limiter should be shared between all
+// ProcX consuming the same work.
+func WithKeyConcurrency(kl *ratelimit.KeyLimiter, path string, op KeyConcurrencyOp) Option {
+	return func(j *ProcX) {
+		j.KeyLimiter = kl
+		j.KeyConcurrencyPath = path
+		j.KeyConcurrencyOp = op
+	}
+}
+
+// WithRecordDir records each unit of work to dir.
+func WithRecordDir(dir string) Option {
+	return func(j *ProcX) {
+		j.RecordDir = dir
+	}
+}
+
+// WithInterval sets how long Run waits between units of work.
+func WithInterval(d time.Duration) Option {
+	return func(j *ProcX) {
+		j.interval = d
+	}
+}
+
+// New creates a ProcX which consumes from d, without reading the command line
+// flags or environment. d must be configured by the caller, and is initialized
+// by New.
+func New(d drivers.Driver, opts ...Option) (*ProcX, error) {
+	l := log.WithFields(log.Fields{
+		"fn": "New",
+	})
+	l.Debug("New")
+	j := &ProcX{
+		Driver: d,
+	}
+	for _, o := range opts {
+		o(j)
+	}
+	if err := j.Driver.Init(); err != nil {
+		l.WithError(err).Error("Init")
+		return nil, err
+	}
+	if err := j.checkKeyConcurrency(); err != nil {
+		l.Error(err)
+		j.Driver.Cleanup()
+		return nil, err
+	}
+	return j, nil
+}
+
+// Run processes work until ctx is done, the driver has no more work, or a
+// unit of work fails.
+func (j *ProcX) Run(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"fn":     "Run",
+		"driver": j.DriverName,
+	})
+	l.Debug("Run")
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := j.DoWorkContext(ctx); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if j.interval > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(j.interval):
+			}
+		}
+	}
+}
+
+// Close cleans up the driver and the result publishers, and closes the
+// Webhook once its queued events have been sent.
+func (j *ProcX) Close() error {
+	j.closePublishers()
+	closeWebhook(j.Webhook)
+	return j.Driver.Cleanup()
+}
+
+// handle passes the current work to the Handler.
+func (j *ProcX) handle(ctx context.Context) (Result, error) {
+	l := log.WithFields(log.Fields{
+		"fn":     "handle",
+		"driver": j.DriverName,
+	})
+	l.Debug("handle")
+	return j.Handler(ctx, Work{
+		Driver:        j.DriverName,
+		Payload:       []byte(j.PayloadString()),
+		DeliveryCount: j.deliveryCount,
+	})
+}
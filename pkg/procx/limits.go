@@ -0,0 +1,90 @@
+package procx
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrLimitsUnsupported = errors.New("process limits are not supported on this platform")
+)
+
+// Limits are the identity and resource limits the process is run with. Nil
+// fields are not applied.
+type Limits struct {
+	// UID and GID run the process as the given user and group
+	UID *uint32 `json:"uid"`
+	GID *uint32 `json:"gid"`
+	// Setpgid runs the process in its own process group, and Setsid in its
+	// own session. Any processes left in the group when the process exits
+	// are killed
+	Setpgid bool `json:"setpgid"`
+	Setsid  bool `json:"setsid"`
+	// RlimitCPU is the CPU time limit in seconds, RlimitAS the address space
+	// limit in bytes, RlimitNofile the open files limit, and RlimitCore the
+	// core file size limit in bytes
+	RlimitCPU    *uint64 `json:"rlimitCPU"`
+	RlimitAS     *uint64 `json:"rlimitAS"`
+	RlimitNofile *uint64 `json:"rlimitNofile"`
+	RlimitCore   *uint64 `json:"rlimitCore"`
+	// CgroupMemory is the memory limit in bytes, and CgroupCPU the number of
+	// CPUs, of the cgroup v2 group created for each job. A zero value is
+	// unlimited. If cgroup v2 is not writable, the limits are not applied
+	CgroupMemory int64   `json:"cgroupMemory"`
+	CgroupCPU    float64 `json:"cgroupCPU"`
+}
+
+// cgroupEnabled returns true if a cgroup is to be created for each job.
+func (lm *Limits) cgroupEnabled() bool {
+	return lm != nil && (lm.CgroupMemory > 0 || lm.CgroupCPU > 0)
+}
+
+// Usage is the resources used by a single job's process.
+type Usage struct {
+	UserTime   time.Duration `json:"userTime"`
+	SystemTime time.Duration `json:"systemTime"`
+	// MaxRSS is the peak resident set size in bytes
+	MaxRSS int64 `json:"maxRSS"`
+	// CgroupMemoryPeak and CgroupCPUTime are read from the job's cgroup, and
+	// include any processes started by the process
+	CgroupMemoryPeak int64         `json:"cgroupMemoryPeak,omitempty"`
+	CgroupCPUTime    time.Duration `json:"cgroupCPUTime,omitempty"`
+}
+
+// Usage returns the resources used by the last process executed, or nil if
+// no process has been executed.
+func (j *ProcX) Usage() *Usage {
+	return j.usage
+}
+
+// recordUsage records the resources used by the exited process, and logs
+// them at debug level.
+func (j *ProcX) recordUsage(ps *os.ProcessState, cg *cgroup) {
+	l := log.WithFields(log.Fields{
+		"fn":     "recordUsage",
+		"driver": j.DriverName,
+		"job":    j.jobID,
+	})
+	if ps == nil {
+		return
+	}
+	u := &Usage{
+		UserTime:   ps.UserTime(),
+		SystemTime: ps.SystemTime(),
+		MaxRSS:     maxRSS(ps),
+	}
+	if cg != nil {
+		cg.usage(u)
+	}
+	j.usage = u
+	l.WithFields(log.Fields{
+		"user_ms":     u.UserTime.Milliseconds(),
+		"system_ms":   u.SystemTime.Milliseconds(),
+		"max_rss":     u.MaxRSS,
+		"cgroup_peak": u.CgroupMemoryPeak,
+		"cgroup_cpu":  u.CgroupCPUTime.Milliseconds(),
+	}).Debug("job resource usage")
+}
@@ -0,0 +1,285 @@
+//go:build linux
+
+package procx
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const cgroupMount = "/sys/fs/cgroup"
+
+// cgroupParent is the cgroup v2 group job cgroups are created in. It is set
+// up once, the first time a job cgroup is created.
+var cgroupParent struct {
+	once sync.Once
+	dir  string
+	err  error
+}
+
+// cgroup is the cgroup v2 group of a single job.
+type cgroup struct {
+	dir string
+	// f is the open cgroup directory, which the process is started in if the
+	// platform supports it, in which case placed is set
+	f      *os.File
+	placed bool
+}
+
+// sysProcAttr returns the process attributes for the identity and process
+// group limits, which start the process in cg if it is set.
+func (lm *Limits) sysProcAttr(cg *cgroup) (*syscall.SysProcAttr, error) {
+	if lm == nil {
+		return nil, nil
+	}
+	a := &syscall.SysProcAttr{
+		Setsid: lm.Setsid,
+		// a session leader is already the leader of its own process group
+		Setpgid: lm.Setpgid && !lm.Setsid,
+	}
+	if lm.UID != nil || lm.GID != nil {
+		a.Credential = &syscall.Credential{
+			Uid: uint32(os.Getuid()),
+			Gid: uint32(os.Getgid()),
+		}
+		if lm.UID != nil {
+			a.Credential.Uid = *lm.UID
+		}
+		if lm.GID != nil {
+			a.Credential.Gid = *lm.GID
+		}
+	}
+	if cg != nil && cg.f != nil {
+		cg.placed = useCgroupFD(a, int(cg.f.Fd()))
+	}
+	return a, nil
+}
+
+// newCgroup creates the cgroup for a job, if cgroup limits are set, before
+// its process is started, so that the process can be started in it and
+// anything it starts is limited.
+func (lm *Limits) newCgroup() (*cgroup, error) {
+	l := log.WithFields(log.Fields{
+		"fn": "newCgroup",
+	})
+	if !lm.cgroupEnabled() {
+		return nil, nil
+	}
+	parent, err := setupCgroupParent()
+	if err != nil {
+		l.WithError(err).Debug("cgroup limits not applied")
+		return nil, nil
+	}
+	cg := &cgroup{dir: filepath.Join(parent, "job-"+uuid.New().String())}
+	if err := os.Mkdir(cg.dir, 0755); err != nil {
+		l.WithError(err).Error("failed to create job cgroup")
+		return nil, err
+	}
+	if lm.CgroupMemory > 0 {
+		if err := cg.write("memory.max", strconv.FormatInt(lm.CgroupMemory, 10)); err != nil {
+			cg.remove()
+			return nil, err
+		}
+	}
+	if lm.CgroupCPU > 0 {
+		period := 100000
+		quota := int(lm.CgroupCPU * float64(period))
+		if err := cg.write("cpu.max", fmt.Sprintf("%d %d", quota, period)); err != nil {
+			cg.remove()
+			return nil, err
+		}
+	}
+	if cg.f, err = os.Open(cg.dir); err != nil {
+		cg.remove()
+		return nil, err
+	}
+	return cg, nil
+}
+
+// apply sets the rlimits of the started process pid, and moves it into cg if
+// it was not started in it. rlimits cannot be set on a child before exec with
+// os/exec, so they are applied once the process has started, and anything it
+// starts before then does not inherit them. Likewise, if the platform cannot
+// start the process in its cgroup, anything it starts before it is moved
+// stays in the procx cgroup.
+func (lm *Limits) apply(pid int, cg *cgroup) error {
+	l := log.WithFields(log.Fields{
+		"fn":  "apply",
+		"pid": pid,
+	})
+	l.Debug("applying limits")
+	if lm == nil {
+		return nil
+	}
+	rlimits := []struct {
+		resource int
+		v        *uint64
+	}{
+		{unix.RLIMIT_CPU, lm.RlimitCPU},
+		{unix.RLIMIT_AS, lm.RlimitAS},
+		{unix.RLIMIT_NOFILE, lm.RlimitNofile},
+		{unix.RLIMIT_CORE, lm.RlimitCore},
+	}
+	for _, r := range rlimits {
+		if r.v == nil {
+			continue
+		}
+		rl := &unix.Rlimit{Cur: *r.v, Max: *r.v}
+		if err := unix.Prlimit(pid, r.resource, rl, nil); err != nil {
+			l.WithError(err).Errorf("failed to set rlimit %d", r.resource)
+			return err
+		}
+	}
+	if cg == nil || cg.placed {
+		return nil
+	}
+	return cg.write("cgroup.procs", strconv.Itoa(pid))
+}
+
+// setupCgroupParent prepares the cgroup procx is running in to hold job
+// cgroups. Processes cannot be in a group which delegates controllers to its
+// children, so the processes in the group are moved into a "procx" leaf group
+// alongside the job groups.
+func setupCgroupParent() (string, error) {
+	cgroupParent.once.Do(func() {
+		l := log.WithFields(log.Fields{
+			"fn": "setupCgroupParent",
+		})
+		l.Debug("setting up cgroup parent")
+		defer func() {
+			if cgroupParent.err != nil {
+				l.WithError(cgroupParent.err).Warn("cgroup v2 is not writable, cgroup limits will not be applied")
+			}
+		}()
+		dir, err := selfCgroup()
+		if err != nil {
+			cgroupParent.err = err
+			return
+		}
+		leaf := filepath.Join(dir, "procx")
+		if err := os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+			cgroupParent.err = err
+			return
+		}
+		procs, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+		if err != nil {
+			cgroupParent.err = err
+			return
+		}
+		for _, p := range strings.Fields(string(procs)) {
+			if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(p), 0644); err != nil {
+				l.WithError(err).Debugf("failed to move process %s", p)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+memory +cpu"), 0644); err != nil {
+			cgroupParent.err = err
+			return
+		}
+		cgroupParent.dir = dir
+	})
+	return cgroupParent.dir, cgroupParent.err
+}
+
+// selfCgroup returns the cgroup v2 directory procx is running in.
+func selfCgroup() (string, error) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if p := strings.TrimPrefix(s.Text(), "0::"); p != s.Text() {
+			dir := filepath.Join(cgroupMount, p)
+			if _, err := os.Stat(filepath.Join(dir, "cgroup.controllers")); err != nil {
+				return "", err
+			}
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup v2 hierarchy")
+}
+
+func (c *cgroup) write(file, v string) error {
+	return os.WriteFile(filepath.Join(c.dir, file), []byte(v), 0644)
+}
+
+// usage adds the memory peak and CPU time of the cgroup to u.
+func (c *cgroup) usage(u *Usage) {
+	if b, err := os.ReadFile(filepath.Join(c.dir, "memory.peak")); err == nil {
+		u.CgroupMemoryPeak, _ = strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	}
+	b, err := os.ReadFile(filepath.Join(c.dir, "cpu.stat"))
+	if err != nil {
+		return
+	}
+	for _, ln := range strings.Split(string(b), "\n") {
+		f := strings.Fields(ln)
+		if len(f) == 2 && f[0] == "usage_usec" {
+			us, _ := strconv.ParseInt(f[1], 10, 64)
+			u.CgroupCPUTime = time.Duration(us) * time.Microsecond
+		}
+	}
+}
+
+// closeDir closes the open cgroup directory, once the process has started.
+func (c *cgroup) closeDir() {
+	if c == nil || c.f == nil {
+		return
+	}
+	c.f.Close()
+	c.f = nil
+}
+
+// remove kills any processes left in the cgroup and removes it.
+func (c *cgroup) remove() {
+	l := log.WithFields(log.Fields{
+		"fn":     "remove",
+		"cgroup": c.dir,
+	})
+	c.closeDir()
+	if _, err := os.Stat(filepath.Join(c.dir, "cgroup.kill")); err == nil {
+		if err := c.write("cgroup.kill", "1"); err != nil {
+			l.WithError(err).Error("failed to kill cgroup")
+		}
+	}
+	// the cgroup cannot be removed until the killed processes have exited
+	var err error
+	for i := 0; i < 10; i++ {
+		if err = os.Remove(c.dir); err == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	l.WithError(err).Error("failed to remove job cgroup")
+}
+
+// killGroup kills any processes left in the process group of pid.
+func (lm *Limits) killGroup(pid int) {
+	if lm == nil || (!lm.Setpgid && !lm.Setsid) {
+		return
+	}
+	if err := syscall.Kill(-pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		log.WithError(err).Error("failed to kill process group")
+	}
+}
+
+// maxRSS returns the peak resident set size of the exited process in bytes.
+func maxRSS(ps *os.ProcessState) int64 {
+	if ru, ok := ps.SysUsage().(*syscall.Rusage); ok {
+		// linux reports maxrss in kilobytes
+		return ru.Maxrss * 1024
+	}
+	return 0
+}
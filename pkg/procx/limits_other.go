@@ -0,0 +1,37 @@
+//go:build !linux
+
+package procx
+
+import (
+	"os"
+	"syscall"
+)
+
+type cgroup struct{}
+
+func (lm *Limits) sysProcAttr(cg *cgroup) (*syscall.SysProcAttr, error) {
+	if lm == nil {
+		return nil, nil
+	}
+	return nil, ErrLimitsUnsupported
+}
+
+func (lm *Limits) newCgroup() (*cgroup, error) {
+	return nil, nil
+}
+
+func (lm *Limits) apply(pid int, cg *cgroup) error {
+	return nil
+}
+
+func (c *cgroup) closeDir() {}
+
+func (c *cgroup) usage(u *Usage) {}
+
+func (c *cgroup) remove() {}
+
+func (lm *Limits) killGroup(pid int) {}
+
+func maxRSS(ps *os.ProcessState) int64 {
+	return 0
+}
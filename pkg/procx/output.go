@@ -0,0 +1,480 @@
+package procx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type OutputFormat string
+
+var (
+	OutputFormatRaw  OutputFormat = "raw"
+	OutputFormatJSON OutputFormat = "json"
+)
+
+// Output configures where the process output is written. The zero value
+// passes the output through to the writers given to Exec as-is.
+type Output struct {
+	// Dir, if set, captures the stdout and stderr of each job to files in a
+	// directory per job in Dir, rather than the writers given to Exec
+	Dir string `json:"dir"`
+	// Tee also writes the output to the writers given to Exec when Dir is set
+	Tee bool `json:"tee"`
+	// MaxSize caps the size in bytes of each output file. When a file reaches
+	// MaxSize it is rotated to <file>.1, replacing any previous rotation, so
+	// the most recent output is kept. 0 is unlimited
+	MaxSize int64 `json:"maxSize"`
+	// Retain is the number of job output directories kept in Dir, the oldest
+	// being removed first. 0 keeps all
+	Retain int `json:"retain"`
+	// Format is the format the output is written to the writers given to Exec
+	// in. OutputFormatJSON writes each line as a JSON object with the job ID
+	Format OutputFormat `json:"format"`
+	// StderrTail is the number of bytes from the end of stderr kept to be
+	// logged and returned in an ExecError when the process fails
+	StderrTail int `json:"stderrTail"`
+	// Capture keeps stdout as the job result, which drivers replace in their
+	// clear and fail templates as {{procx_output}}
+	Capture bool `json:"capture"`
+	// CaptureMaxSize caps the size in bytes of the captured stdout. Output
+	// beyond it is passed through but not captured. 0 is unlimited
+	CaptureMaxSize int64 `json:"captureMaxSize"`
+	// CaptureJSON fails the job if the captured stdout of a process which
+	// exited successfully is not valid JSON
+	CaptureJSON bool `json:"captureJSON"`
+}
+
+var (
+	ErrInvalidOutput = errors.New("process output is not valid JSON")
+)
+
+// ExecError is returned by Exec when the process fails.
+type ExecError struct {
+	Err error
+	// JobID is the ID of the failed job
+	JobID string
+	// Stderr is the tail of the process stderr, up to Output.StderrTail bytes
+	Stderr string
+}
+
+func (e *ExecError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// StderrTail returns the tail of the stderr of the process if err is an
+// ExecError, or an empty string otherwise.
+func StderrTail(err error) string {
+	var ee *ExecError
+	if errors.As(err, &ee) {
+		return ee.Stderr
+	}
+	return ""
+}
+
+// jobOutput holds the writers for a single job's output.
+type jobOutput struct {
+	stdout  io.Writer
+	stderr  io.Writer
+	tail    *tailBuffer
+	capture *headBuffer
+	close   []func() error
+}
+
+// newOutput returns the writers to pass the output of the current job's
+// process to.
+func (j *ProcX) newOutput(stdout, stderr io.Writer) (*jobOutput, error) {
+	o := &jobOutput{}
+	if j.Output.Format == OutputFormatJSON {
+		// stdout and stderr may be the same writer, so lines are written
+		// under a shared lock
+		mu := &sync.Mutex{}
+		max := maxLineSize
+		if j.Output.CaptureMaxSize > 0 && j.Output.CaptureMaxSize < int64(max) {
+			max = int(j.Output.CaptureMaxSize)
+		}
+		so := &lineWriter{w: stdout, jobID: j.jobID, stream: "stdout", mu: mu, max: max}
+		se := &lineWriter{w: stderr, jobID: j.jobID, stream: "stderr", mu: mu, max: max}
+		o.close = append(o.close, so.Flush, se.Flush)
+		stdout, stderr = so, se
+	}
+	if j.Output.Dir != "" {
+		dir := filepath.Join(j.Output.Dir, fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102T150405.000000000Z"), j.jobID))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		activeOutput.add(dir)
+		fo, err := newCapFile(filepath.Join(dir, "stdout"), j.Output.MaxSize)
+		if err != nil {
+			activeOutput.remove(dir)
+			return nil, err
+		}
+		fe, err := newCapFile(filepath.Join(dir, "stderr"), j.Output.MaxSize)
+		if err != nil {
+			fo.Close()
+			activeOutput.remove(dir)
+			return nil, err
+		}
+		done := func() error {
+			activeOutput.remove(dir)
+			return nil
+		}
+		o.close = append(o.close, fo.Close, fe.Close, done, j.pruneOutput)
+		if j.Output.Tee {
+			stdout, stderr = io.MultiWriter(stdout, fo), io.MultiWriter(stderr, fe)
+		} else {
+			stdout, stderr = fo, fe
+		}
+	}
+	if j.Output.StderrTail > 0 {
+		o.tail = &tailBuffer{max: j.Output.StderrTail}
+		stderr = io.MultiWriter(stderr, o.tail)
+	}
+	if j.Output.Capture || j.publishes() {
+		o.capture = &headBuffer{max: j.Output.CaptureMaxSize}
+		stdout = io.MultiWriter(stdout, o.capture)
+	}
+	o.stdout, o.stderr = stdout, stderr
+	return o, nil
+}
+
+// Close flushes and closes the job's output.
+func (o *jobOutput) Close() error {
+	var err error
+	for _, c := range o.close {
+		if cerr := c(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Tail returns the tail of the job's stderr.
+func (o *jobOutput) Tail() string {
+	if o.tail == nil {
+		return ""
+	}
+	return string(o.tail.b)
+}
+
+// Captured returns the captured stdout of the job, or nil if it is not
+// captured.
+func (o *jobOutput) Captured() []byte {
+	if o.capture == nil {
+		return nil
+	}
+	if o.capture.truncated {
+		log.WithFields(log.Fields{
+			"fn":  "Captured",
+			"max": o.capture.max,
+		}).Warn("captured output truncated")
+	}
+	return o.capture.b
+}
+
+// activeOutput is the output directories of the jobs in progress, which
+// are not pruned by the jobs of other workers sharing the output dir.
+var activeOutput = &outputDirs{dirs: make(map[string]bool)}
+
+type outputDirs struct {
+	mu   sync.Mutex
+	dirs map[string]bool
+}
+
+func (o *outputDirs) add(dir string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.dirs[dir] = true
+}
+
+func (o *outputDirs) remove(dir string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.dirs, dir)
+}
+
+func (o *outputDirs) active(dir string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.dirs[dir]
+}
+
+// pruneOutput removes the oldest job output directories beyond Retain. The
+// directories of jobs in progress are kept, and do not count towards Retain.
+func (j *ProcX) pruneOutput() error {
+	l := log.WithFields(log.Fields{
+		"fn":  "pruneOutput",
+		"dir": j.Output.Dir,
+	})
+	if j.Output.Retain <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(j.Output.Dir)
+	if err != nil {
+		l.WithError(err).Error("failed to read output dir")
+		return err
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() && !activeOutput.active(filepath.Join(j.Output.Dir, e.Name())) {
+			dirs = append(dirs, e.Name())
+		}
+	}
+	sort.Strings(dirs)
+	for i := 0; i < len(dirs)-j.Output.Retain; i++ {
+		l.Debugf("removing job output %s", dirs[i])
+		if err := os.RemoveAll(filepath.Join(j.Output.Dir, dirs[i])); err != nil {
+			l.WithError(err).Error("failed to remove job output")
+		}
+	}
+	return nil
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	b   []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.b = append(t.b, p...)
+	if len(t.b) > t.max {
+		t.b = append([]byte{}, t.b[len(t.b)-t.max:]...)
+	}
+	return len(p), nil
+}
+
+// headBuffer keeps the first max bytes written to it, or all if max is 0.
+type headBuffer struct {
+	mu        sync.Mutex
+	max       int64
+	b         []byte
+	truncated bool
+}
+
+func (h *headBuffer) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := len(p)
+	if h.max > 0 && int64(len(h.b)+n) > h.max {
+		p = p[:h.max-int64(len(h.b))]
+		h.truncated = true
+	}
+	h.b = append(h.b, p...)
+	return n, nil
+}
+
+// capFile is a file which is rotated to <path>.1 when it reaches max bytes.
+type capFile struct {
+	path string
+	max  int64
+	n    int64
+	f    *os.File
+}
+
+func newCapFile(path string, max int64) (*capFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &capFile{path: path, max: max, f: f}, nil
+}
+
+func (c *capFile) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		if c.max > 0 && c.n >= c.max {
+			if err := c.rotate(); err != nil {
+				return 0, err
+			}
+		}
+		chunk := p
+		if c.max > 0 && int64(len(chunk)) > c.max-c.n {
+			chunk = chunk[:c.max-c.n]
+		}
+		n, err := c.f.Write(chunk)
+		c.n += int64(n)
+		if err != nil {
+			return 0, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (c *capFile) rotate() error {
+	if err := c.f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(c.path, c.path+".1"); err != nil {
+		return err
+	}
+	f, err := os.Create(c.path)
+	if err != nil {
+		return err
+	}
+	c.f = f
+	c.n = 0
+	return nil
+}
+
+func (c *capFile) Close() error {
+	return c.f.Close()
+}
+
+// maxLineSize is the default length at which a line is written by a
+// lineWriter before its end is seen.
+const maxLineSize = 1 << 20
+
+// lineWriter writes each line written to it to w as a JSON object. Lines
+// longer than max are split, so that a process which never writes a newline
+// is not buffered without limit.
+type lineWriter struct {
+	w      io.Writer
+	jobID  string
+	stream string
+	mu     *sync.Mutex
+	max    int
+	buf    []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := lw.writeLines(lw.buf[:i]); err != nil {
+			return 0, err
+		}
+		lw.buf = lw.buf[i+1:]
+	}
+	for lw.max > 0 && len(lw.buf) >= lw.max {
+		if err := lw.writeLine(lw.buf[:lw.max]); err != nil {
+			return 0, err
+		}
+		lw.buf = lw.buf[lw.max:]
+	}
+	return len(p), nil
+}
+
+// writeLines writes line, split into lines of up to max bytes.
+func (lw *lineWriter) writeLines(line []byte) error {
+	for lw.max > 0 && len(line) > lw.max {
+		if err := lw.writeLine(line[:lw.max]); err != nil {
+			return err
+		}
+		line = line[lw.max:]
+	}
+	return lw.writeLine(line)
+}
+
+// Flush writes any remaining partial line.
+func (lw *lineWriter) Flush() error {
+	if len(lw.buf) == 0 {
+		return nil
+	}
+	err := lw.writeLine(lw.buf)
+	lw.buf = nil
+	return err
+}
+
+func (lw *lineWriter) writeLine(line []byte) error {
+	b, err := json.Marshal(struct {
+		Time   string `json:"time"`
+		Job    string `json:"job"`
+		Stream string `json:"stream"`
+		Msg    string `json:"msg"`
+	}{
+		Time:   time.Now().Format(time.RFC3339),
+		Job:    lw.jobID,
+		Stream: lw.stream,
+		Msg:    string(line),
+	})
+	if err != nil {
+		return err
+	}
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	_, err = lw.w.Write(append(b, '\n'))
+	return err
+}
+
+// outputWaitDelay is how long exec waits, after the process exits, for the
+// output it wrote to be copied, before giving up on any processes it left
+// running in the background which still hold its output open.
+const outputWaitDelay = 100 * time.Millisecond
+
+// outputPipe passes the output of a process to a writer which is not a file.
+// Given a writer which is not a file, exec.Cmd copies to it in a goroutine
+// which Wait waits for, so Wait blocks, and a timeout cannot end it, until
+// everything the process started closes its output. The process writes to
+// the pipe instead, and the copy is waited for only up to outputWaitDelay
+// once the process exits.
+type outputPipe struct {
+	r, w *os.File
+	done chan struct{}
+}
+
+// newOutputPipe returns the file for a process to write the output it
+// passes to w to, which is w itself if w is nil or a file, and p is nil.
+func newOutputPipe(w io.Writer) (f io.Writer, p *outputPipe, err error) {
+	if _, ok := w.(*os.File); ok || w == nil {
+		return w, nil, nil
+	}
+	p = &outputPipe{done: make(chan struct{})}
+	if p.r, p.w, err = os.Pipe(); err != nil {
+		return nil, nil, err
+	}
+	go func() {
+		defer close(p.done)
+		io.Copy(w, p.r)
+	}()
+	return p.w, p, nil
+}
+
+// started closes the write end of the pipe, which the process now holds.
+func (p *outputPipe) started() {
+	if p == nil {
+		return
+	}
+	p.w.Close()
+}
+
+// wait waits for the output to be copied, up to outputWaitDelay, and then
+// closes the pipe.
+func (p *outputPipe) wait() {
+	if p == nil {
+		return
+	}
+	// the write end is already closed if the process was started
+	p.w.Close()
+	t := time.NewTimer(outputWaitDelay)
+	defer t.Stop()
+	select {
+	case <-p.done:
+	case <-t.C:
+		log.WithFields(log.Fields{
+			"fn": "wait",
+		}).Debug("process output still open after exit, closing")
+	}
+	p.r.Close()
+	<-p.done
+}
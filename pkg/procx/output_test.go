@@ -0,0 +1,123 @@
+package procx
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExecBackground(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	tests := []struct {
+		name    string
+		script  string
+		timeout time.Duration
+		wantOut string
+		wantErr bool
+	}{
+		{"background process holds output", "sleep 5 & echo out; echo err >&2", 0, "out\n", false},
+		{"timeout with background process", "sleep 5 & echo out; sleep 5", 100 * time.Millisecond, "out\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the tail and capture pass the output through writers which are
+			// not files
+			j := &ProcX{
+				Bin:     "sh",
+				Args:    []string{"-c", tt.script},
+				Timeout: tt.timeout,
+				Output:  Output{StderrTail: 4096, Capture: true},
+			}
+			j.work = &bytes.Buffer{}
+			start := time.Now()
+			err := j.Exec(&bytes.Buffer{}, &bytes.Buffer{})
+			if d := time.Since(start); d > 2*time.Second {
+				t.Errorf("Exec() took %s, want the background process not waited for", d)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := string(j.captured); got != tt.wantOut {
+				t.Errorf("captured %q, want %q", got, tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		max    int
+		writes []string
+		want   []string
+	}{
+		{"lines", 0, []string{"a\nb", "c\n", "d"}, []string{"a", "bc", "d"}},
+		{"long line split", 3, []string{"abcdefg\n"}, []string{"abc", "def", "g"}},
+		{"long partial line flushed", 3, []string{"ab", "cd", "ef"}, []string{"abc", "def"}},
+		{"line at max", 3, []string{"abc\nd\n"}, []string{"abc", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			lw := &lineWriter{w: out, jobID: "job", stream: "stdout", mu: &sync.Mutex{}, max: tt.max}
+			for _, w := range tt.writes {
+				if _, err := lw.Write([]byte(w)); err != nil {
+					t.Fatal(err)
+				}
+				if tt.max > 0 && len(lw.buf) >= tt.max {
+					t.Fatalf("buffered %d bytes, want less than %d", len(lw.buf), tt.max)
+				}
+			}
+			if err := lw.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			dec := json.NewDecoder(out)
+			for dec.More() {
+				var ln struct{ Msg string }
+				if err := dec.Decode(&ln); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, ln.Msg)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneOutput(t *testing.T) {
+	dir := t.TempDir()
+	for _, d := range []string{"1", "2", "3", "4"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the oldest directory belongs to a job still running on another worker
+	activeOutput.add(filepath.Join(dir, "1"))
+	defer activeOutput.remove(filepath.Join(dir, "1"))
+	j := &ProcX{Output: Output{Dir: dir, Retain: 2}}
+	if err := j.pruneOutput(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	if want := "1 3 4"; strings.Join(got, " ") != want {
+		t.Errorf("kept %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+package procx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/robertlestak/procx/pkg/drivers"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrPeekNotSupported = errors.New("peek is not supported by the driver, as it can neither preview nor release work")
+)
+
+// DefaultPeekTimeout is how long Peek waits for work.
+const DefaultPeekTimeout = 10 * time.Second
+
+// PeekResult describes the work retrieved by Peek, how it would be passed to
+// the process, and the actions the driver would take when the process
+// completes or fails.
+type PeekResult struct {
+	Driver           drivers.DriverName `json:"driver"`
+	Payload          string             `json:"payload"`
+	Bin              string             `json:"bin,omitempty"`
+	Args             []string           `json:"args,omitempty"`
+	ArgsError        string             `json:"argsError,omitempty"`
+	Env              []string           `json:"env,omitempty"`
+	PayloadFile      string             `json:"payloadFile,omitempty"`
+	PassWorkAsStdin  bool               `json:"passWorkAsStdin"`
+	PreviewSupported bool               `json:"previewSupported"`
+	ClearWork        map[string]any     `json:"clearWork"`
+	HandleFailure    map[string]any     `json:"handleFailure"`
+	Released         bool               `json:"released"`
+}
+
+// Peek retrieves the next work item without executing the process, clearing
+// the work, or handling a failure, waiting up to DefaultPeekTimeout for work.
+// If the driver implements drivers.Releaser, the work is returned to the
+// source before Peek returns. A nil result with a nil error means there was
+// no work.
+func (j *ProcX) Peek() (*PeekResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultPeekTimeout)
+	defer cancel()
+	return j.PeekContext(ctx)
+}
+
+// PeekContext is Peek, waiting for work until ctx is done. Drivers which can
+// neither preview nor release work, and so may consume it on retrieval, are
+// refused with ErrPeekNotSupported.
+func (j *ProcX) PeekContext(ctx context.Context) (*PeekResult, error) {
+	l := log.WithFields(log.Fields{
+		"fn":     "Peek",
+		"driver": j.DriverName,
+	})
+	l.Debug("Peek")
+	if !drivers.Peekable(j.Driver) {
+		l.Error(ErrPeekNotSupported)
+		return nil, ErrPeekNotSupported
+	}
+	work, err := drivers.AsV2(j.Driver).GetWorkContext(ctx)
+	if err == io.EOF {
+		l.Debug("driver has no more work")
+		return nil, nil
+	}
+	if err != nil && ctx.Err() != nil {
+		l.Debug("no work before timeout")
+		return nil, nil
+	}
+	if err != nil {
+		l.Error(err)
+		return nil, err
+	}
+	if work == nil {
+		l.Debug("no work")
+		return nil, nil
+	}
+	j.work = work
+	r := &PeekResult{
+		Driver:          j.DriverName,
+		Payload:         j.PayloadString(),
+		PayloadFile:     j.PayloadFile,
+		PassWorkAsStdin: j.PassWorkAsStdin,
+	}
+	if j.Bin != "" {
+		// a job which would fail to render its args is still previewed, and
+		// the work released
+		if r.Bin, r.Args, err = j.command(); err != nil {
+			l.WithError(err).Warn("failed to render args")
+			r.Bin, r.Args, r.ArgsError = j.Bin, j.Args, err.Error()
+		}
+	}
+	r.Env = j.renderEnv()
+	if j.exportsPayload() {
+		r.Env = append(r.Env, "PROCX_PAYLOAD="+r.Payload)
+	}
+	if p, ok := j.Driver.(drivers.Previewer); ok {
+		r.PreviewSupported = true
+		if r.ClearWork, err = p.PreviewClearWork(); err != nil {
+			l.WithError(err).Error("PreviewClearWork")
+			return nil, err
+		}
+		if r.HandleFailure, err = p.PreviewHandleFailure(); err != nil {
+			l.WithError(err).Error("PreviewHandleFailure")
+			return nil, err
+		}
+	} else {
+		l.Warn("driver does not support previewing clear and failure actions")
+	}
+	if rl, ok := j.Driver.(drivers.Releaser); ok {
+		if err := rl.ReleaseWork(); err != nil {
+			l.WithError(err).Error("ReleaseWork")
+			return r, err
+		}
+		r.Released = true
+	}
+	return r, nil
+}
@@ -2,14 +2,45 @@ package procx
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/robertlestak/procx/pkg/breaker"
+	"github.com/robertlestak/procx/pkg/delivery"
 	"github.com/robertlestak/procx/pkg/drivers"
 	"github.com/robertlestak/procx/pkg/flags"
+	"github.com/robertlestak/procx/pkg/ratelimit"
+	"github.com/robertlestak/procx/pkg/record"
+	"github.com/robertlestak/procx/pkg/schema"
+	"github.com/robertlestak/procx/pkg/webhook"
 	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+type KeyConcurrencyOp string
+
+var (
+	KeyConcurrencyOpWait KeyConcurrencyOp = "wait"
+	KeyConcurrencyOpFail KeyConcurrencyOp = "fail"
+)
+
+// keyWait is the longest a worker waits for a slot for the key of work it
+// released with KeyConcurrencyOpWait before retrieving work again.
+const keyWait = time.Second
+
+var (
+	ErrKeyConcurrencyRelease = errors.New("key-concurrency-op fail requires a driver which can release work, or whose failure handling redelivers it")
 )
 
 type ProcX struct {
@@ -22,7 +53,95 @@ type ProcX struct {
 	HostEnv         bool               `json:"hostEnv"`
 	Bin             string             `json:"bin"`
 	Args            []string           `json:"args"`
-	work            io.Reader          `json:"-"`
+	RecordDir       string             `json:"recordDir"`
+	// Workdir runs each process in a fresh temporary directory created in
+	// WorkdirBase, or the system temp directory if WorkdirBase is not set. A
+	// relative PayloadFile is written inside the directory. The directory is
+	// removed once the process exits, unless it failed and KeepFailedWorkdir
+	// is set
+	Workdir           bool   `json:"workdir"`
+	WorkdirBase       string `json:"workdirBase"`
+	KeepFailedWorkdir bool   `json:"keepFailedWorkdir"`
+	// Timeout, if set, kills the process if it has not exited after the
+	// duration
+	Timeout time.Duration `json:"timeout"`
+	// Output configures the capture and format of the process output
+	Output Output `json:"output"`
+	// Limits, if set, are the identity and resource limits the process is
+	// run with
+	Limits *Limits `json:"limits"`
+	// Shell, if set, runs Bin and Args as a script with Shell -c
+	Shell string `json:"shell"`
+	// ArgsAllowMissing renders missing {{mustache}} payload fields in Args as
+	// empty, rather than failing the job
+	ArgsAllowMissing bool `json:"argsAllowMissing"`
+	// HostEnvAllow and HostEnvRegex select host environment variables to
+	// pass to the process when HostEnv is not set
+	HostEnvAllow []string       `json:"hostEnvAllow"`
+	HostEnvRegex *regexp.Regexp `json:"-"`
+	// Env is a list of KEY=VAL environment variables to pass to the process.
+	// Values may use {{mustache}} payload fields
+	Env []string `json:"env"`
+	// Handler, if set, is called in process with each unit of work in place
+	// of executing Bin
+	Handler Handler `json:"-"`
+	// Control, if set, pauses and resumes work retrieval
+	Control *Control `json:"-"`
+	// PollTimeout, if set, is the longest DoWorkContext waits for work from
+	// the driver, after which it returns without work
+	PollTimeout time.Duration `json:"pollTimeout"`
+	// EmptyEOF, if set, returns io.EOF from DoWorkContext when the driver has
+	// no work, or PollTimeout passes without work, rather than nil
+	EmptyEOF bool `json:"emptyEOF"`
+	// Webhook, if set, is sent job lifecycle events
+	Webhook *webhook.Webhook `json:"-"`
+	// ResultDriver, if set, is published the output of each successful job,
+	// and ResultFailDriver that of each failed job. The output is the file
+	// the process wrote to PROCX_RESULT_FILE, or its stdout if it wrote none.
+	// ResultCorrelationPath is the gjson path of the payload field published
+	// as the correlation ID, and ResultEnvelope wraps the output in JSON with
+	// its metadata
+	ResultDriver          drivers.DriverName `json:"resultDriver"`
+	ResultFailDriver      drivers.DriverName `json:"resultFailDriver"`
+	ResultCorrelationPath string             `json:"resultCorrelationPath"`
+	ResultEnvelope        bool               `json:"resultEnvelope"`
+	// MaxDeliveries, if set, is the number of times work may be delivered
+	// before PoisonOp is taken on it rather than executing it again. The
+	// delivery count is reported by the driver where its source supports it,
+	// and counted in Deliveries otherwise. PoisonDriver is the driver work is
+	// published to with PoisonOpPublish
+	MaxDeliveries int                `json:"maxDeliveries"`
+	PoisonOp      PoisonOp           `json:"poisonOp"`
+	PoisonDriver  drivers.DriverName `json:"poisonDriver"`
+	Deliveries    *delivery.Counter  `json:"-"`
+	// Breaker, if set, is waited on before each work retrieval, and records
+	// the result of each job. Failed jobs are returned as a JobError
+	Breaker *breaker.Breaker `json:"-"`
+	// RateLimiter, if set, is waited on before each work retrieval, and a
+	// token is taken for each unit of work retrieved
+	RateLimiter *ratelimit.Limiter `json:"-"`
+	// KeyLimiter, if set, caps the concurrent jobs sharing the payload value
+	// at KeyConcurrencyPath
+	KeyLimiter         *ratelimit.KeyLimiter `json:"-"`
+	KeyConcurrencyPath string                `json:"keyConcurrencyPath"`
+	KeyConcurrencyOp   KeyConcurrencyOp      `json:"keyConcurrencyOp"`
+	work               io.Reader             `json:"-"`
+	jobID              string
+	driverConfig       []byte
+	workdir            string
+	usage              *Usage
+	captured           []byte
+	resultFile         []byte
+	correlationID      string
+	resultPub          drivers.Publisher
+	resultFailPub      drivers.Publisher
+	cliProcess         bool
+	status             Status
+	interval           time.Duration
+	trial              bool
+	deliveryCount      int
+	deliveryKey        string
+	poisonPub          drivers.Publisher
 }
 
 func (j *ProcX) ParseArgs(args []string) {
@@ -51,6 +170,7 @@ func (j *ProcX) Init(envKeyPrefix string) error {
 		return drivers.ErrDriverNotFound
 	}
 	j.ParseArgs(flags.FlagSet.Args())
+	j.cliProcess = j.Bin != ""
 	if err := j.Driver.LoadFlags(); err != nil {
 		l.WithError(err).Error("LoadFlags")
 		return err
@@ -59,57 +179,279 @@ func (j *ProcX) Init(envKeyPrefix string) error {
 		l.WithError(err).Error("LoadEnv")
 		return err
 	}
+	j.driverConfig = driverConfig(j.Driver)
 	if err := j.Driver.Init(); err != nil {
 		l.WithError(err).Error("Init")
 		return err
 	}
+	if err := j.initPublishers(envKeyPrefix); err != nil {
+		l.WithError(err).Error("initPublishers")
+		return err
+	}
+	if err := j.checkKeyConcurrency(); err != nil {
+		l.Error(err)
+		return err
+	}
+	j.checkDeliveries()
+	return nil
+}
+
+// releaseWork returns the current work to the source untouched, with the
+// driver's Releaser, or its failure handling if that redelivers the work.
+func (j *ProcX) releaseWork() error {
+	if rl, ok := j.Driver.(drivers.Releaser); ok {
+		return rl.ReleaseWork()
+	}
+	if !drivers.Redelivers(j.Driver) {
+		return ErrKeyConcurrencyRelease
+	}
+	j.setResult(nil)
+	return j.Driver.HandleFailure()
+}
+
+// checkKeyConcurrency returns an error if the key concurrency op is fail, and
+// the driver cannot return work to the source without failing it.
+func (j *ProcX) checkKeyConcurrency() error {
+	if j.KeyLimiter == nil || j.KeyConcurrencyOp != KeyConcurrencyOpFail {
+		return nil
+	}
+	if !drivers.Releasable(j.Driver) {
+		return ErrKeyConcurrencyRelease
+	}
 	return nil
 }
 
 func (j *ProcX) DoWork() error {
+	return j.DoWorkContext(context.Background())
+}
+
+// DoWorkContext retrieves a single unit of work from the driver and passes it
+// to the Handler, or the process if no Handler is set. The work is cleared on
+// success, and failed with the driver if the Handler or process fails.
+// Cancelling ctx stops waiting for work, and is passed to the Handler.
+func (j *ProcX) DoWorkContext(ctx context.Context) error {
 	l := log.WithFields(log.Fields{
 		"fn":     "DoWork",
 		"driver": j.DriverName,
 	})
 	l.Debug("DoWork")
-	work, err := j.Driver.GetWork()
+	if j.Control != nil {
+		if err := j.Control.wait(ctx); err != nil {
+			return err
+		}
+	}
+	if j.Breaker != nil {
+		trial, err := j.Breaker.Wait(ctx)
+		if err != nil {
+			return err
+		}
+		// a trial which runs no job is given up to the next retrieval
+		j.trial = trial
+		defer func() {
+			j.Breaker.Abort(j.trial)
+			j.trial = false
+		}()
+	}
+	if j.RateLimiter != nil {
+		if err := j.RateLimiter.Wait(ctx); err != nil {
+			l.WithError(err).Error("RateLimiter")
+			return err
+		}
+	}
+	// ctx cancels waiting for work, however once work is received it is
+	// processed, and cleared or failed, to completion
+	fctx := ctx
+	if j.Control != nil {
+		var cancel context.CancelFunc
+		fctx, cancel = j.Control.fetchContext(fctx)
+		defer cancel()
+	}
+	if j.PollTimeout > 0 {
+		var cancel context.CancelFunc
+		fctx, cancel = context.WithTimeout(fctx, j.PollTimeout)
+		defer cancel()
+	}
+	work, err := drivers.AsV2(j.Driver).GetWorkContext(fctx)
+	if err == io.EOF {
+		l.Debug("driver has no more work")
+		return err
+	}
+	if err != nil && ctx.Err() == nil && fctx.Err() != nil {
+		l.Debug("work retrieval paused or timed out")
+		if j.EmptyEOF && fctx.Err() == context.DeadlineExceeded {
+			return io.EOF
+		}
+		return nil
+	}
 	if err != nil {
 		l.Error(err)
+		j.setStatus(func(s *Status) { s.setError(err) })
 		return err
 	}
 	if work == nil {
 		l.Debug("no work")
+		if j.EmptyEOF {
+			return io.EOF
+		}
 		return nil
 	}
+	// only retrievals which return work count towards the rate
+	if j.RateLimiter != nil {
+		j.RateLimiter.Take()
+	}
 	j.work = work
+	j.jobID = uuid.New().String()
+	l = l.WithField("job", j.jobID)
 	l.Debug("work received")
+	j.captured = nil
+	j.resultFile = nil
+	j.jobStarted()
+	defer j.jobEnded()
+	if rp, ok := j.Driver.(drivers.Replayer); ok {
+		j.applyRecord(rp.ReplayRecord())
+	}
+	j.countDelivery()
+	if j.poisoned() {
+		return j.poison()
+	}
+	if j.RecordDir != "" {
+		if err := j.record(); err != nil {
+			l.WithError(err).Error("failed to record work")
+		}
+	}
+	if j.KeyLimiter != nil {
+		key := gjson.Get(j.PayloadString(), j.KeyConcurrencyPath).String()
+		l = l.WithField("key", key)
+		if j.KeyConcurrencyOp == KeyConcurrencyOpFail {
+			if !j.KeyLimiter.TryAcquire(key) {
+				l.Debug("key at concurrency limit, releasing work")
+				if err := j.releaseWork(); err != nil {
+					l.Error(err)
+					return err
+				}
+				return nil
+			}
+		} else if !j.KeyLimiter.TryAcquire(key) {
+			if drivers.Releasable(j.Driver) {
+				// the work is not held while waiting, so a noisy key does
+				// not hold the workers' leases. the worker waits for a slot
+				// before retrieving work again, up to keyWait so that work
+				// for other keys is retrieved meanwhile
+				l.Debug("key at concurrency limit, releasing work until a slot is free")
+				if err := j.releaseWork(); err != nil {
+					l.Error(err)
+					return err
+				}
+				wctx, cancel := context.WithTimeout(ctx, keyWait)
+				defer cancel()
+				j.KeyLimiter.Wait(wctx, key)
+				return nil
+			}
+			l.Debug("waiting for key concurrency slot")
+			// work which cannot be released waits for the jobs holding the
+			// key, which complete on shutdown, rather than being dropped
+			j.KeyLimiter.Acquire(context.Background(), key)
+		}
+		defer j.KeyLimiter.Release(key)
+	}
+	if j.publishes() && j.ResultCorrelationPath != "" {
+		// the payload may be consumed by the process
+		j.correlationID = gjson.Get(j.PayloadString(), j.ResultCorrelationPath).String()
+	}
+	j.notify(webhook.EventStart, nil)
 	// execute
-	if j.Bin == "" {
+	if j.Handler == nil && j.Bin == "" {
 		// print work to stdout
-		if _, err := io.Copy(os.Stdout, work); err != nil {
+		if _, err := io.Copy(os.Stdout, j.work); err != nil {
 			l.WithError(err).Error("Copy")
 			return err
 		}
 	} else {
-		err = j.Exec(os.Stdout, os.Stderr)
+		if j.Handler != nil {
+			var res Result
+			res, err = j.handle(ctx)
+			j.captured = res.Output
+		} else {
+			err = j.Exec(os.Stdout, os.Stderr)
+			if err == nil && j.Output.Capture && j.Output.CaptureJSON && !json.Valid(j.captured) {
+				err = ErrInvalidOutput
+			}
+		}
+		if err == nil {
+			err = j.publish(nil)
+		}
 		if err != nil {
 			l.Error(err)
-			if err := j.Driver.HandleFailure(); err != nil {
-				l.Error(err)
+			j.jobResult(err)
+			j.notify(webhook.EventFailure, err)
+			j.publish(err)
+			j.setResult(j.result(err))
+			// the work is only retried if the failure handler leaves it to be
+			// delivered again, rather than moving or deleting it
+			retry := drivers.Redelivers(j.Driver)
+			if herr := j.Driver.HandleFailure(); herr != nil {
+				l.Error(herr)
+			} else if retry {
+				j.notify(webhook.EventRetry, err)
 			}
-			return err
+			return j.jobError(err)
 		}
 	}
 	l.Debug("work completed")
+	j.setResult(j.result(nil))
 	err = j.Driver.ClearWork()
 	if err != nil {
 		l.Error(err)
-		return err
+		j.jobResult(err)
+		j.notify(webhook.EventFailure, err)
+		return j.jobError(err)
 	}
 	l.Debug("work cleared")
+	j.resetDelivery()
+	j.jobResult(nil)
+	j.notify(webhook.EventSuccess, nil)
 	return nil
 }
 
+// record writes the current work and the process invocation to RecordDir.
+func (j *ProcX) record() error {
+	r := &record.Record{
+		ID:              j.jobID,
+		Time:            time.Now(),
+		Driver:          string(j.DriverName),
+		Payload:         j.PayloadString(),
+		Bin:             j.Bin,
+		Args:            j.Args,
+		Shell:           j.Shell,
+		PassWorkAsArg:   j.PassWorkAsArg,
+		PassWorkAsStdin: j.PassWorkAsStdin,
+		PayloadFile:     j.PayloadFile,
+		Env:             j.renderEnv(),
+	}
+	_, err := record.Write(j.RecordDir, r)
+	return err
+}
+
+// applyRecord sets the process invocation to the one the replayed work was
+// recorded with. A process passed on the command line takes precedence.
+func (j *ProcX) applyRecord(r *record.Record) {
+	if r == nil || j.cliProcess {
+		return
+	}
+	j.Bin = r.Bin
+	j.Args = r.Args
+	j.Shell = r.Shell
+	j.PassWorkAsArg = r.PassWorkAsArg
+	j.PassWorkAsStdin = r.PassWorkAsStdin
+	j.PayloadFile = r.PayloadFile
+	j.Env = r.Env
+}
+
+// JobID returns the ID of the current job.
+func (j *ProcX) JobID() string {
+	return j.jobID
+}
+
 func (j *ProcX) PayloadString() string {
 	d, err := ioutil.ReadAll(j.work)
 	if err != nil {
@@ -119,9 +461,95 @@ func (j *ProcX) PayloadString() string {
 	return string(d)
 }
 
+// hostEnv returns the host environment variables passed to the process.
+func (j *ProcX) hostEnv() []string {
+	if j.HostEnv {
+		return os.Environ()
+	}
+	if len(j.HostEnvAllow) == 0 && j.HostEnvRegex == nil {
+		return nil
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		k := strings.SplitN(kv, "=", 2)[0]
+		if j.HostEnvRegex != nil && j.HostEnvRegex.MatchString(k) {
+			env = append(env, kv)
+			continue
+		}
+		for _, a := range j.HostEnvAllow {
+			if k == a {
+				env = append(env, kv)
+				break
+			}
+		}
+	}
+	return env
+}
+
+// renderEnv returns Env with {{mustache}} values replaced by fields from the
+// payload.
+func (j *ProcX) renderEnv() []string {
+	if len(j.Env) == 0 {
+		return nil
+	}
+	bd := []byte(j.PayloadString())
+	var env []string
+	for _, kv := range j.Env {
+		p := strings.SplitN(kv, "=", 2)
+		if len(p) != 2 {
+			continue
+		}
+		env = append(env, p[0]+"="+schema.ReplaceParamsString(bd, p[1]))
+	}
+	return env
+}
+
+// command returns the process and arguments to execute for the current work.
+// {{mustache}} payload fields in Bin and Args are replaced with their values, and the
+// job fails if a field is missing unless ArgsAllowMissing is set. If Shell is
+// set, the command is joined into a script run with Shell -c, and the
+// replaced values are shell quoted.
+func (j *ProcX) command() (string, []string, error) {
+	bd := []byte(j.PayloadString())
+	var quote func(string) string
+	if j.Shell != "" {
+		quote = schema.ShellQuote
+	}
+	bin, err := schema.RenderTemplate(bd, j.Bin, !j.ArgsAllowMissing, quote)
+	if err != nil {
+		return "", nil, err
+	}
+	var args []string
+	for _, a := range j.Args {
+		v, err := schema.RenderTemplate(bd, a, !j.ArgsAllowMissing, quote)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, v)
+	}
+	if j.PassWorkAsArg {
+		p := string(bd)
+		if quote != nil {
+			p = quote(p)
+		}
+		args = append(args, p)
+	}
+	if j.Shell == "" {
+		return bin, args, nil
+	}
+	script := strings.Join(append([]string{bin}, args...), " ")
+	return j.Shell, []string{"-c", script}, nil
+}
+
+// exportsPayload returns true if the payload is passed to the process as the
+// PROCX_PAYLOAD environment variable.
+func (j *ProcX) exportsPayload() bool {
+	return j.PayloadFile == "" && !j.PassWorkAsArg && !j.PassWorkAsStdin
+}
+
 // Exec will execute the given script, streaming the output to the provided
-// io.Writers. If the script exits with a non-zero exit code, an error will be
-// returned. If the script exits with a zero exit code, no error will be
+// io.Writers. If the script exits with a non-zero exit code, an *ExecError will
+// be returned. If the script exits with a zero exit code, no error will be
 // returned.
 func (j *ProcX) Exec(stdout, stderr io.Writer) error {
 	l := log.WithFields(log.Fields{
@@ -129,29 +557,125 @@ func (j *ProcX) Exec(stdout, stderr io.Writer) error {
 		"driver": j.DriverName,
 	})
 	l.Debug("Exec")
-	// if passing work as arg, add it to args
-	if j.PassWorkAsArg {
-		l.Debug("passing work as arg")
-		j.Args = append(j.Args, j.PayloadString())
+	if j.jobID == "" {
+		j.jobID = uuid.New().String()
+	}
+	l = l.WithField("job", j.jobID)
+	o, err := j.newOutput(stdout, stderr)
+	if err != nil {
+		l.WithError(err).Error("failed to create job output")
+		return err
+	}
+	err = j.execWorkdir(o.stdout, o.stderr)
+	if cerr := o.Close(); cerr != nil {
+		l.WithError(cerr).Error("failed to close job output")
+	}
+	j.captured = o.Captured()
+	if err != nil {
+		tail := o.Tail()
+		if tail != "" {
+			l.WithField("stderr", tail).Error("job failed")
+		}
+		return &ExecError{Err: err, JobID: j.jobID, Stderr: tail}
+	}
+	return nil
+}
+
+// execWorkdir executes the process in a new workdir, if Workdir is set.
+func (j *ProcX) execWorkdir(stdout, stderr io.Writer) error {
+	l := log.WithFields(log.Fields{
+		"fn":     "execWorkdir",
+		"driver": j.DriverName,
+		"job":    j.jobID,
+	})
+	if !j.Workdir {
+		return j.exec(stdout, stderr)
+	}
+	dir, err := os.MkdirTemp(j.WorkdirBase, "procx-job-")
+	if err != nil {
+		l.WithError(err).Error("failed to create workdir")
+		return err
+	}
+	j.workdir = dir
+	defer func() { j.workdir = "" }()
+	l = l.WithField("workdir", dir)
+	err = j.exec(stdout, stderr)
+	if err != nil && j.KeepFailedWorkdir {
+		l.Info("job failed, keeping workdir")
+		return err
+	}
+	l.Debug("removing workdir")
+	if rerr := os.RemoveAll(dir); rerr != nil {
+		l.WithError(rerr).Error("failed to remove workdir")
+	}
+	return err
+}
+
+// payloadFile returns the path the payload file is written to. A relative
+// path is placed inside the job's workdir, if there is one.
+func (j *ProcX) payloadFile() string {
+	if j.workdir == "" || filepath.IsAbs(j.PayloadFile) {
+		return j.PayloadFile
+	}
+	return filepath.Join(j.workdir, j.PayloadFile)
+}
+
+// exec executes the process for the current work in the job's workdir, if
+// there is one.
+func (j *ProcX) exec(stdout, stderr io.Writer) error {
+	l := log.WithFields(log.Fields{
+		"fn":     "exec",
+		"driver": j.DriverName,
+	})
+	// render the args for this job. the configured args are not modified so
+	// the payload is not carried over to the next job in daemon mode
+	bin, args, err := j.command()
+	if err != nil {
+		l.Error(err)
+		return err
+	}
+	if j.workdir != "" && strings.Contains(bin, string(filepath.Separator)) && !filepath.IsAbs(bin) {
+		// relative paths are resolved against cmd.Dir, keep them relative
+		// to the procx working directory
+		if bin, err = filepath.Abs(bin); err != nil {
+			l.Error(err)
+			return err
+		}
 	}
-	cmd := exec.Command(j.Bin, j.Args...)
+	cmd := exec.Command(bin, args...)
 	// set the stdout and stderr pipes
-	cmd.Stdout = stdout
-	cmd.Stderr = stderr
-	if j.HostEnv {
-		l.Debug("setting host env")
-		cmd.Env = os.Environ()
+	var po, pe *outputPipe
+	if cmd.Stdout, po, err = newOutputPipe(stdout); err != nil {
+		l.Error(err)
+		return err
 	}
+	defer po.wait()
+	if cmd.Stderr, pe, err = newOutputPipe(stderr); err != nil {
+		l.Error(err)
+		return err
+	}
+	defer pe.wait()
+	// a nil Env inherits the whole host environment, so the process is given
+	// an empty one if nothing is passed to it
+	cmd.Env = append(append([]string{}, j.hostEnv()...), j.renderEnv()...)
+	if j.workdir != "" {
+		cmd.Dir = j.workdir
+		cmd.Env = append(cmd.Env, "PROCX_WORKDIR="+j.workdir)
+	}
+	// the payload is read once before the process starts, so that it is not
+	// read by the stdin copy while the job's webhook events and results read
+	// it, and it is still there to be read once it is written to the file
+	payload := j.PayloadString()
 	// if the payload file is set, set the work to the file contents
 	if j.PayloadFile != "" {
 		l.Debug("writing payload to file")
-		f, err := os.Create(j.PayloadFile)
+		f, err := os.Create(j.payloadFile())
 		if err != nil {
 			l.Error(err)
 			return err
 		}
 		defer f.Close()
-		_, err = io.Copy(f, j.work)
+		_, err = io.WriteString(f, payload)
 		if err != nil {
 			l.Error(err)
 			return err
@@ -165,24 +689,80 @@ func (j *ProcX) Exec(stdout, stderr io.Writer) error {
 		}
 		go func() {
 			defer stdin.Close()
-			io.Copy(stdin, j.work)
+			io.WriteString(stdin, payload)
 		}()
 	}
 	// if there is no payload file, and the payload is not passed as an arg nor stdin,
 	// pass the payload as env var
-	if j.PayloadFile == "" && !j.PassWorkAsArg && !j.PassWorkAsStdin {
+	if j.exportsPayload() {
 		l.Debug("exporting work")
 		// do not export payload to environment if output is file
 		// to prevent buffer overflow in the environment on large payloads
-		cmd.Env = append(cmd.Env, "PROCX_PAYLOAD="+j.PayloadString())
+		cmd.Env = append(cmd.Env, "PROCX_PAYLOAD="+payload)
+	}
+	if j.deliveryCount > 0 {
+		cmd.Env = append(cmd.Env, "PROCX_DELIVERY_COUNT="+strconv.Itoa(j.deliveryCount))
+	}
+	if j.publishes() {
+		rf, err := j.newResultFile()
+		if err != nil {
+			l.Error(err)
+			return err
+		}
+		defer j.readResultFile(rf)
+		cmd.Env = append(cmd.Env, "PROCX_RESULT_FILE="+rf)
+	}
+	// the job cgroup is created before the process is started in it
+	cg, err := j.Limits.newCgroup()
+	if err != nil {
+		l.Error(err)
+		return err
+	}
+	if cmd.SysProcAttr, err = j.Limits.sysProcAttr(cg); err != nil {
+		l.Error(err)
+		if cg != nil {
+			cg.remove()
+		}
+		return err
 	}
 	// execute the command
-	err := cmd.Start()
+	err = cmd.Start()
+	cg.closeDir()
+	po.started()
+	pe.started()
 	if err != nil {
 		l.Error(err)
+		if cg != nil {
+			cg.remove()
+		}
+		return err
+	}
+	pid := cmd.Process.Pid
+	j.setStatus(func(s *Status) { s.PID = pid })
+	defer j.setStatus(func(s *Status) { s.PID = 0 })
+	if err := j.Limits.apply(cmd.Process.Pid, cg); err != nil {
+		l.Error(err)
+		cmd.Process.Kill()
+		cmd.Wait()
+		j.Limits.killGroup(cmd.Process.Pid)
+		if cg != nil {
+			cg.remove()
+		}
 		return err
 	}
+	if j.Timeout > 0 {
+		t := time.AfterFunc(j.Timeout, func() {
+			l.Errorf("process timed out after %s, killing", j.Timeout)
+			cmd.Process.Kill()
+		})
+		defer t.Stop()
+	}
 	err = cmd.Wait()
+	j.Limits.killGroup(cmd.Process.Pid)
+	j.recordUsage(cmd.ProcessState, cg)
+	if cg != nil {
+		cg.remove()
+	}
 	if err != nil {
 		l.Error(err)
 		return err
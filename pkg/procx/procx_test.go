@@ -0,0 +1,221 @@
+package procx
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robertlestak/procx/pkg/ratelimit"
+	"github.com/robertlestak/procx/pkg/webhook"
+)
+
+func TestExecEnv(t *testing.T) {
+	bin, err := exec.LookPath("env")
+	if err != nil {
+		t.Skip("no env")
+	}
+	t.Setenv("PROCX_TEST_SECRET", "secret")
+	t.Setenv("PROCX_TEST_ALLOWED", "allowed")
+	tests := []struct {
+		name  string
+		allow []string
+		regex *regexp.Regexp
+		env   []string
+		want  []string
+	}{
+		{"nothing passed", nil, nil, nil, nil},
+		{"allowlist matches nothing", []string{"PROCX_TEST_UNSET"}, nil, nil, nil},
+		{"regex matches nothing", nil, regexp.MustCompile("^PROCX_TEST_UNSET$"), nil, nil},
+		{"allowlist", []string{"PROCX_TEST_ALLOWED"}, nil, nil, []string{"PROCX_TEST_ALLOWED=allowed"}},
+		{"env", nil, nil, []string{"A=b"}, []string{"A=b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the payload is passed on stdin, so it is not exported
+			j := &ProcX{
+				Bin:             bin,
+				HostEnvAllow:    tt.allow,
+				HostEnvRegex:    tt.regex,
+				Env:             tt.env,
+				PassWorkAsStdin: true,
+				Output:          Output{Capture: true},
+			}
+			j.work = &bytes.Buffer{}
+			if err := j.Exec(&bytes.Buffer{}, &bytes.Buffer{}); err != nil {
+				t.Fatal(err)
+			}
+			got := strings.Fields(string(j.captured))
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("process env = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// releaseDriver is a fakeDriver which can release its work.
+type releaseDriver struct {
+	fakeDriver
+	released int
+}
+
+func (d *releaseDriver) ReleaseWork() error {
+	d.released++
+	return nil
+}
+
+func TestKeyConcurrencyWait(t *testing.T) {
+	kl := ratelimit.NewKeyLimiter(1)
+	kl.TryAcquire("a")
+	d := &releaseDriver{fakeDriver: fakeDriver{payload: `{"key":"a"}`}}
+	var calls int
+	j, err := New(d,
+		WithHandler(func(ctx context.Context, w Work) (Result, error) {
+			calls++
+			return Result{}, nil
+		}),
+		WithKeyConcurrency(kl, "key", KeyConcurrencyOpWait),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the work is released rather than held while the key is at its limit,
+	// and the worker waits for the slot before retrieving work again
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		kl.Release("a")
+	}()
+	start := time.Now()
+	if err := j.DoWork(); err != nil {
+		t.Fatalf("DoWork() = %v", err)
+	}
+	if el := time.Since(start); el < 50*time.Millisecond || el >= keyWait {
+		t.Errorf("DoWork() returned after %s, want once the slot is free", el)
+	}
+	if d.released != 1 || calls != 0 {
+		t.Errorf("released %d, handled %d, want 1, 0", d.released, calls)
+	}
+	// the worker waits for at most keyWait for a slot
+	kl.TryAcquire("a")
+	start = time.Now()
+	if err := j.DoWork(); err != nil {
+		t.Fatalf("DoWork() = %v", err)
+	}
+	if el := time.Since(start); el < keyWait {
+		t.Errorf("DoWork() returned after %s, want %s", el, keyWait)
+	}
+	kl.Release("a")
+	if err := j.DoWork(); err != nil {
+		t.Fatalf("DoWork() = %v", err)
+	}
+	if d.released != 2 || calls != 1 {
+		t.Errorf("released %d, handled %d, want 2, 1", d.released, calls)
+	}
+}
+
+// emptyDriver has no work, and blocks until ctx is done if block is set.
+type emptyDriver struct {
+	fakeDriver
+	block bool
+}
+
+func (d *emptyDriver) GetWorkContext(ctx context.Context) (io.Reader, error) {
+	if !d.block {
+		return nil, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (d *emptyDriver) InitContext(ctx context.Context) error          { return nil }
+func (d *emptyDriver) ClearWorkContext(ctx context.Context) error     { return nil }
+func (d *emptyDriver) HandleFailureContext(ctx context.Context) error { return nil }
+func (d *emptyDriver) CleanupContext(ctx context.Context) error       { return nil }
+
+func TestEmptyEOF(t *testing.T) {
+	tests := []struct {
+		name     string
+		block    bool
+		emptyEOF bool
+		want     error
+	}{
+		{"no work", false, false, nil},
+		{"no work EOF", false, true, io.EOF},
+		{"poll timeout", true, false, nil},
+		{"poll timeout EOF", true, true, io.EOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &ProcX{
+				Driver:      &emptyDriver{block: tt.block},
+				PollTimeout: 10 * time.Millisecond,
+				EmptyEOF:    tt.emptyEOF,
+			}
+			if err := j.DoWork(); err != tt.want {
+				t.Errorf("DoWork() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecWebhookPayload(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh")
+	}
+	payload := `{"id":"` + strings.Repeat("a", 1<<20) + `"}`
+	tests := []struct {
+		name string
+		opts func(j *ProcX)
+	}{
+		{"stdin", func(j *ProcX) { j.PassWorkAsStdin = true }},
+		{"file", func(j *ProcX) { j.PayloadFile = filepath.Join(t.TempDir(), "payload") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var bodies []string
+			s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				defer mu.Unlock()
+				bodies = append(bodies, string(b))
+			}))
+			defer s.Close()
+			wh := &webhook.Webhook{URL: s.URL, ContentType: "text/plain", Body: "{{procx_payload}}", Timeout: time.Second}
+			if err := wh.Init(); err != nil {
+				t.Fatal(err)
+			}
+			// the process fails without reading its stdin
+			j := &ProcX{
+				Driver:  &fakeDriver{payload: payload},
+				Bin:     sh,
+				Args:    []string{"-c", "exit 1"},
+				Webhook: wh,
+			}
+			tt.opts(j)
+			if err := j.DoWork(); err == nil {
+				t.Fatal("DoWork() = nil, want the job to fail")
+			}
+			wh.Close(context.Background())
+			mu.Lock()
+			defer mu.Unlock()
+			if len(bodies) != 2 {
+				t.Fatalf("sent %d events, want start and failure", len(bodies))
+			}
+			for i, b := range bodies {
+				if b != payload {
+					t.Errorf("event %d payload is %d bytes, want %d", i, len(b), len(payload))
+				}
+			}
+		})
+	}
+}
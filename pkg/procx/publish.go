@@ -0,0 +1,198 @@
+package procx
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/robertlestak/procx/pkg/drivers"
+	log "github.com/sirupsen/logrus"
+)
+
+// resultEnvelope is the message published with ResultEnvelope.
+type resultEnvelope struct {
+	Metadata map[string]string `json:"metadata"`
+	// Output is the job output, embedded as is if it is valid JSON
+	Output any `json:"output"`
+}
+
+// publishes returns true if the output of jobs is published.
+func (j *ProcX) publishes() bool {
+	return j.ResultDriver != "" || j.ResultFailDriver != ""
+}
+
+// newPublisher creates and initializes a publisher for the driver name. The
+// driver loads its flags and the environment with envKeyPrefix, overridden by
+// the environment with envKeyPrefix followed by sub.
+func newPublisher(name drivers.DriverName, envKeyPrefix, sub string) (drivers.Publisher, error) {
+	l := log.WithFields(log.Fields{
+		"fn":     "newPublisher",
+		"driver": name,
+	})
+	l.Debug("newPublisher")
+	d := drivers.GetDriver(name)
+	if d == nil {
+		l.Error("driver not found")
+		return nil, drivers.ErrDriverNotFound
+	}
+	p, ok := d.(drivers.Publisher)
+	if !ok {
+		l.Error(drivers.ErrPublishNotSupported)
+		return nil, drivers.ErrPublishNotSupported
+	}
+	if err := d.LoadFlags(); err != nil {
+		l.WithError(err).Error("LoadFlags")
+		return nil, err
+	}
+	if err := d.LoadEnv(envKeyPrefix); err != nil {
+		l.WithError(err).Error("LoadEnv")
+		return nil, err
+	}
+	if err := d.LoadEnv(envKeyPrefix + sub); err != nil {
+		l.WithError(err).Error("LoadEnv")
+		return nil, err
+	}
+	if err := p.InitPublisher(); err != nil {
+		l.WithError(err).Error("InitPublisher")
+		return nil, err
+	}
+	return p, nil
+}
+
+// initPublishers initializes the publishers of ResultDriver,
+// ResultFailDriver and PoisonDriver, if set.
+func (j *ProcX) initPublishers(envKeyPrefix string) error {
+	if j.ResultDriver != "" {
+		p, err := newPublisher(j.ResultDriver, envKeyPrefix, "RESULT_")
+		if err != nil {
+			return err
+		}
+		j.resultPub = p
+	}
+	if j.ResultFailDriver != "" {
+		p, err := newPublisher(j.ResultFailDriver, envKeyPrefix, "RESULT_FAIL_")
+		if err != nil {
+			j.closePublishers()
+			return err
+		}
+		j.resultFailPub = p
+	}
+	if j.PoisonDriver != "" {
+		p, err := newPublisher(j.PoisonDriver, envKeyPrefix, "POISON_")
+		if err != nil {
+			j.closePublishers()
+			return err
+		}
+		j.poisonPub = p
+	}
+	return nil
+}
+
+// closePublishers closes the publishers, if any.
+func (j *ProcX) closePublishers() {
+	l := log.WithFields(log.Fields{
+		"fn": "closePublishers",
+	})
+	for _, p := range []drivers.Publisher{j.resultPub, j.resultFailPub, j.poisonPub} {
+		if p == nil {
+			continue
+		}
+		if err := p.ClosePublisher(); err != nil {
+			l.WithError(err).Error("ClosePublisher")
+		}
+	}
+	j.resultPub, j.resultFailPub, j.poisonPub = nil, nil, nil
+}
+
+// newResultFile creates the empty file the process may write its result to,
+// in the job's workdir if there is one.
+func (j *ProcX) newResultFile() (string, error) {
+	f, err := os.CreateTemp(j.workdir, "procx-result-")
+	if err != nil {
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// readResultFile keeps the contents of the result file as the job output,
+// and removes it.
+func (j *ProcX) readResultFile(name string) {
+	l := log.WithFields(log.Fields{
+		"fn":  "readResultFile",
+		"job": j.jobID,
+	})
+	bd, err := os.ReadFile(name)
+	if err != nil {
+		l.WithError(err).Error("failed to read result file")
+	}
+	j.resultFile = bd
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		l.WithError(err).Error("failed to remove result file")
+	}
+}
+
+// resultMeta returns the correlation metadata of the output of the current
+// job, given the error it failed with, if any.
+func (j *ProcX) resultMeta(err error) map[string]string {
+	meta := map[string]string{
+		"procx-job-id": j.jobID,
+		"procx-driver": string(j.DriverName),
+		"procx-status": "success",
+	}
+	if j.ResultCorrelationPath != "" {
+		meta["procx-correlation-id"] = j.correlationID
+	}
+	if code := j.exitCode(err); code != nil {
+		meta["procx-exit-code"] = strconv.Itoa(*code)
+	}
+	if j.deliveryCount > 0 {
+		meta["procx-delivery-count"] = strconv.Itoa(j.deliveryCount)
+	}
+	if err != nil {
+		meta["procx-status"] = "failure"
+		// header values must be a single line
+		meta["procx-error"] = strings.Join(strings.Fields(err.Error()), " ")
+	}
+	return meta
+}
+
+// publish publishes the output of the current job to ResultDriver if it
+// succeeded, or ResultFailDriver if it failed with err. The output is the
+// result file written by the process, or its stdout if it wrote none.
+func (j *ProcX) publish(err error) error {
+	l := log.WithFields(log.Fields{
+		"fn":  "publish",
+		"job": j.jobID,
+	})
+	p, dn := j.resultPub, j.ResultDriver
+	if err != nil {
+		p, dn = j.resultFailPub, j.ResultFailDriver
+	}
+	if p == nil {
+		return nil
+	}
+	l = l.WithField("resultDriver", dn)
+	body := j.resultFile
+	if len(body) == 0 {
+		body = j.captured
+	}
+	meta := j.resultMeta(err)
+	if j.ResultEnvelope {
+		e := resultEnvelope{Metadata: meta, Output: string(body)}
+		if json.Valid(body) {
+			e.Output = json.RawMessage(body)
+		}
+		var merr error
+		if body, merr = json.Marshal(e); merr != nil {
+			l.WithError(merr).Error("failed to create result envelope")
+			return merr
+		}
+	}
+	l.Debug("publishing job output")
+	if perr := p.Publish(body, meta); perr != nil {
+		l.WithError(perr).Error("failed to publish job output")
+		return perr
+	}
+	return nil
+}
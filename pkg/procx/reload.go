@@ -0,0 +1,104 @@
+package procx
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/robertlestak/procx/pkg/drivers"
+	"github.com/robertlestak/procx/pkg/flags"
+	log "github.com/sirupsen/logrus"
+)
+
+// driverConfig returns the settings of a loaded driver, before it has been
+// initialized, to detect changes on reload. nil is returned if the settings
+// cannot be serialized, in which case they are always treated as changed.
+func driverConfig(d drivers.Driver) []byte {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// Reload applies the configuration of n, created from the reloaded flags, to
+// j for the next job. If the driver or its settings changed, the current
+// driver is cleaned up and the new driver initialized in its place. The
+// result publishers are always reinitialized. The Handler and limiters of j
+// are kept. Reload must not be called while j is
+// processing work.
+func (j *ProcX) Reload(n *ProcX, envKeyPrefix string) error {
+	l := log.WithFields(log.Fields{
+		"fn":     "Reload",
+		"driver": n.DriverName,
+	})
+	l.Debug("Reload")
+	d := drivers.GetDriver(n.DriverName)
+	if d == nil {
+		l.Error("driver not found")
+		return drivers.ErrDriverNotFound
+	}
+	if err := d.LoadFlags(); err != nil {
+		l.WithError(err).Error("LoadFlags")
+		return err
+	}
+	if err := d.LoadEnv(envKeyPrefix); err != nil {
+		l.WithError(err).Error("LoadEnv")
+		return err
+	}
+	cfg := driverConfig(d)
+	if err := n.initPublishers(envKeyPrefix); err != nil {
+		l.WithError(err).Error("failed to initialize reloaded result publishers, keeping current settings")
+		return err
+	}
+	if n.DriverName == j.DriverName && cfg != nil && bytes.Equal(cfg, j.driverConfig) {
+		l.Debug("driver settings unchanged")
+		n.Driver = j.Driver
+	} else {
+		l.Info("driver settings changed, reinitializing driver")
+		if err := j.Driver.Cleanup(); err != nil {
+			l.WithError(err).Error("Cleanup")
+		}
+		if err := d.Init(); err != nil {
+			l.WithError(err).Error("failed to initialize reloaded driver, keeping current settings")
+			n.closePublishers()
+			if err := j.Driver.Init(); err != nil {
+				l.WithError(err).Error("Init")
+				return err
+			}
+			return err
+		}
+		n.Driver = d
+	}
+	n.KeyLimiter = j.KeyLimiter
+	if err := n.checkKeyConcurrency(); err != nil {
+		l.WithError(err).Error("keeping current settings")
+		n.closePublishers()
+		if n.Driver != j.Driver {
+			n.Driver.Cleanup()
+			if err := j.Driver.Init(); err != nil {
+				l.WithError(err).Error("Init")
+				return err
+			}
+		}
+		return err
+	}
+	n.driverConfig = cfg
+	n.ParseArgs(flags.FlagSet.Args())
+	n.cliProcess = n.Bin != ""
+	n.Handler = j.Handler
+	n.RateLimiter = j.RateLimiter
+	n.Breaker = j.Breaker
+	n.Deliveries = j.Deliveries
+	n.interval = j.interval
+	n.Control = j.Control
+	j.closePublishers()
+	if j.Webhook != n.Webhook {
+		go closeWebhook(j.Webhook)
+	}
+	// the status is read while work is in progress
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	n.status = j.status
+	*j = *n
+	return nil
+}
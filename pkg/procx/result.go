@@ -0,0 +1,73 @@
+package procx
+
+import (
+	"errors"
+	"os/exec"
+	"time"
+
+	"github.com/robertlestak/procx/pkg/drivers"
+	"github.com/robertlestak/procx/pkg/schema"
+)
+
+// exitCode returns the exit code of the current job's process, given the
+// error the job completed with, or nil if no process exited.
+func (j *ProcX) exitCode(err error) *int {
+	if err == nil {
+		if j.Handler != nil || j.Bin == "" {
+			return nil
+		}
+		code := 0
+		return &code
+	}
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		code := ee.ExitCode()
+		return &code
+	}
+	return nil
+}
+
+// result returns the result of the current job, given the error it
+// completed with.
+func (j *ProcX) result(err error) *schema.JobResult {
+	r := &schema.JobResult{
+		Output:   j.captured,
+		ExitCode: j.exitCode(err),
+		Stderr:   StderrTail(err),
+	}
+	if s := j.Status(); s.JobStarted != nil {
+		r.Duration = time.Since(*s.JobStarted)
+	}
+	return r
+}
+
+// setResult passes r to the driver, if it replaces the job result in its
+// clear and fail templates.
+func (j *ProcX) setResult(r *schema.JobResult) {
+	if rs, ok := j.Driver.(drivers.ResultSetter); ok {
+		rs.SetResult(r)
+	}
+}
+
+// JobError is returned by DoWorkContext when a Breaker is set and the job
+// failed, so that the caller can keep retrieving work while the Breaker
+// decides whether to stop.
+type JobError struct {
+	Err error
+}
+
+func (e *JobError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *JobError) Unwrap() error {
+	return e.Err
+}
+
+// jobError returns err as a JobError if a Breaker is set.
+func (j *ProcX) jobError(err error) error {
+	if j.Breaker == nil {
+		return err
+	}
+	return &JobError{Err: err}
+}
@@ -0,0 +1,54 @@
+package procx
+
+import (
+	"context"
+	"time"
+
+	"github.com/robertlestak/procx/pkg/webhook"
+)
+
+// webhookCloseTimeout is how long queued webhook events are given to be sent
+// when procx is closed.
+const webhookCloseTimeout = 10 * time.Second
+
+// notify queues the Webhook event ev for the current job, if a Webhook is set.
+// err is the error the job failed with, if any. Delivery failures are logged
+// by the Webhook and do not fail the job.
+func (j *ProcX) notify(ev webhook.Event, err error) {
+	if j.Webhook == nil || !j.Webhook.Enabled(ev) {
+		return
+	}
+	now := time.Now()
+	e := &webhook.Job{
+		Event:         ev,
+		Time:          now,
+		JobID:         j.jobID,
+		Driver:        string(j.DriverName),
+		Payload:       []byte(j.PayloadString()),
+		DeliveryCount: j.deliveryCount,
+	}
+	if s := j.Status(); s.JobStarted != nil {
+		e.Duration = now.Sub(*s.JobStarted).Milliseconds()
+	}
+	if err != nil {
+		e.Error = err.Error()
+		e.Stderr = StderrTail(err)
+	}
+	if ev != webhook.EventStart {
+		e.ExitCode = j.exitCode(err)
+	}
+	// events are sent in the background, so a slow endpoint does not hold up
+	// the job
+	j.Webhook.Enqueue(e)
+}
+
+// closeWebhook waits up to webhookCloseTimeout for the queued events of wh to
+// be sent, then closes it.
+func closeWebhook(wh *webhook.Webhook) {
+	if wh == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), webhookCloseTimeout)
+	defer cancel()
+	wh.Close(ctx)
+}
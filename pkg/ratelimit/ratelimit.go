@@ -0,0 +1,205 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrInvalidRate = errors.New("invalid rate, expected format is <count>/<s|m|h>")
+)
+
+// ParseRate parses a rate string such as "20/s", "100/m" or "5/h" and
+// returns the number of events allowed per second. A bare number is
+// interpreted as events per second.
+func ParseRate(s string) (float64, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "ratelimit",
+		"fn":  "ParseRate",
+	})
+	l.Debug("Parsing rate")
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidRate
+	}
+	parts := strings.SplitN(s, "/", 2)
+	n, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || n <= 0 {
+		l.WithError(err).Error("Failed to parse rate count")
+		return 0, ErrInvalidRate
+	}
+	if len(parts) == 1 {
+		return n, nil
+	}
+	switch parts[1] {
+	case "s", "sec", "second":
+		return n, nil
+	case "m", "min", "minute":
+		return n / 60, nil
+	case "h", "hour":
+		return n / 3600, nil
+	}
+	l.Errorf("unknown rate unit %s", parts[1])
+	return 0, ErrInvalidRate
+}
+
+// Limiter is a token bucket rate limiter. Tokens are refilled continuously
+// at Rate per second, up to Burst tokens.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewLimiter returns a full token bucket which refills at rate tokens per
+// second and holds at most burst tokens.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// refill adds the tokens accrued since the last refill. r.mu must be held.
+func (r *Limiter) refill() {
+	now := r.now()
+	r.tokens += now.Sub(r.last).Seconds() * r.rate
+	if r.tokens > r.burst {
+		r.tokens = r.burst
+	}
+	r.last = now
+}
+
+// delay returns how long the caller must wait before a token is available.
+func (r *Limiter) delay() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refill()
+	if r.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - r.tokens) / r.rate * float64(time.Second))
+}
+
+// Wait blocks until a token is available or the context is done. The token
+// is not taken, so that retrievals which return no work do not use up the
+// rate: call Take once work has been retrieved.
+func (r *Limiter) Wait(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"pkg": "ratelimit",
+		"fn":  "Wait",
+	})
+	for {
+		d := r.delay()
+		if d == 0 {
+			return nil
+		}
+		l.Debugf("rate limited, waiting %s", d)
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Take takes a token from the bucket. If workers waited for the same token,
+// the bucket goes into debt, which delays the next Wait until it is repaid.
+func (r *Limiter) Take() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refill()
+	r.tokens--
+}
+
+// KeyLimiter caps the number of concurrent holders of each key.
+type KeyLimiter struct {
+	mu     sync.Mutex
+	max    int
+	active map[string]int
+	// released is closed when a slot is released
+	released chan struct{}
+}
+
+// NewKeyLimiter returns a KeyLimiter which allows at most max concurrent
+// holders of any single key.
+func NewKeyLimiter(max int) *KeyLimiter {
+	return &KeyLimiter{
+		max:      max,
+		active:   make(map[string]int),
+		released: make(chan struct{}),
+	}
+}
+
+// TryAcquire takes a slot for key if one is free, and reports whether it did.
+func (k *KeyLimiter) TryAcquire(key string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.active[key] >= k.max {
+		return false
+	}
+	k.active[key]++
+	return true
+}
+
+// Acquire blocks until a slot for key is free and takes it, or until ctx is
+// done.
+func (k *KeyLimiter) Acquire(ctx context.Context, key string) error {
+	return k.wait(ctx, key, true)
+}
+
+// Wait blocks until a slot for key is free, without taking it, or until ctx
+// is done.
+func (k *KeyLimiter) Wait(ctx context.Context, key string) error {
+	return k.wait(ctx, key, false)
+}
+
+func (k *KeyLimiter) wait(ctx context.Context, key string, take bool) error {
+	for {
+		k.mu.Lock()
+		if k.active[key] < k.max {
+			if take {
+				k.active[key]++
+			}
+			k.mu.Unlock()
+			return nil
+		}
+		released := k.released
+		k.mu.Unlock()
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release frees a slot previously taken for key.
+func (k *KeyLimiter) Release(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.active[key] <= 1 {
+		delete(k.active, key)
+	} else {
+		k.active[key]--
+	}
+	close(k.released)
+	k.released = make(chan struct{})
+}
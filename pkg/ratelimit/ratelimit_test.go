@@ -0,0 +1,231 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock which only moves when advanced.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// newLimiter returns a Limiter on a fake clock.
+func newLimiter(rate float64, burst int) (*Limiter, *fakeClock) {
+	c := &fakeClock{t: time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)}
+	r := NewLimiter(rate, burst)
+	r.last = c.t
+	r.now = c.now
+	return r, c
+}
+
+// done returns a context which is already done, so Wait returns at once
+// unless a token is available.
+func done() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    float64
+		wantErr bool
+	}{
+		{"20/s", 20, false},
+		{"20", 20, false},
+		{" 120/m ", 2, false},
+		{"7200/hour", 2, false},
+		{"", 0, true},
+		{"0/s", 0, true},
+		{"-1/s", 0, true},
+		{"x/s", 0, true},
+		{"1/d", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRate(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRate(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		// takes are the tokens taken, after which the clock is advanced by
+		// after
+		takes int
+		after time.Duration
+		want  time.Duration
+	}{
+		{"full", 10, 2, 0, 0, 0},
+		{"within burst", 10, 2, 1, 0, 0},
+		{"empty", 10, 2, 2, 0, 100 * time.Millisecond},
+		{"refilled", 10, 2, 2, 100 * time.Millisecond, 0},
+		{"partly refilled", 10, 2, 2, 50 * time.Millisecond, 50 * time.Millisecond},
+		// tokens taken by workers which waited for the same token are
+		// repaid before the next token
+		{"debt", 10, 1, 3, 0, 300 * time.Millisecond},
+		{"debt partly repaid", 10, 1, 3, 150 * time.Millisecond, 150 * time.Millisecond},
+		{"refill capped at burst", 10, 2, 2, time.Hour, 0},
+		{"burst below one", 10, 0, 1, 0, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, c := newLimiter(tt.rate, tt.burst)
+			for i := 0; i < tt.takes; i++ {
+				r.Take()
+			}
+			c.advance(tt.after)
+			if got := r.delay(); got != tt.want {
+				t.Errorf("delay() = %s, want %s", got, tt.want)
+			}
+			err := r.Wait(done())
+			if (err == nil) != (tt.want == 0) {
+				t.Errorf("Wait() = %v, want a token available %v", err, tt.want == 0)
+			}
+		})
+	}
+}
+
+func TestLimiterWait(t *testing.T) {
+	// Wait does not take the token
+	r, _ := newLimiter(1, 1)
+	for i := 0; i < 2; i++ {
+		if err := r.Wait(done()); err != nil {
+			t.Fatalf("Wait() = %v, want nil", err)
+		}
+	}
+	// Wait returns once the debt is repaid
+	r = NewLimiter(100, 1)
+	r.Take()
+	r.Take()
+	start := time.Now()
+	if err := r.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() = %v, want nil", err)
+	}
+	if d := time.Since(start); d < 10*time.Millisecond {
+		t.Errorf("Wait() returned after %s, want at least 10ms", d)
+	}
+}
+
+func TestKeyLimiterTryAcquire(t *testing.T) {
+	k := NewKeyLimiter(2)
+	for i, want := range []bool{true, true, false} {
+		if got := k.TryAcquire("a"); got != want {
+			t.Errorf("TryAcquire(a) %d = %v, want %v", i, got, want)
+		}
+	}
+	// keys are limited separately
+	if !k.TryAcquire("b") {
+		t.Error("TryAcquire(b) = false, want true")
+	}
+	k.Release("a")
+	if !k.TryAcquire("a") {
+		t.Error("TryAcquire(a) after Release = false, want true")
+	}
+}
+
+func TestKeyLimiterRelease(t *testing.T) {
+	k := NewKeyLimiter(1)
+	k.TryAcquire("a")
+	k.Release("a")
+	if _, ok := k.active["a"]; ok {
+		t.Error("released key still tracked")
+	}
+	// releasing a key which is not held does not go below zero
+	k.Release("b")
+	k.TryAcquire("b")
+	if k.TryAcquire("b") {
+		t.Error("TryAcquire(b) over limit = true, want false")
+	}
+}
+
+func TestKeyLimiterAcquire(t *testing.T) {
+	k := NewKeyLimiter(1)
+	if err := k.Acquire(done(), "a"); err != nil {
+		t.Fatalf("Acquire() with a free slot = %v, want nil", err)
+	}
+	if err := k.Acquire(done(), "a"); err != context.Canceled {
+		t.Fatalf("Acquire() with ctx done = %v, want %v", err, context.Canceled)
+	}
+	if err := k.Wait(done(), "a"); err != context.Canceled {
+		t.Fatalf("Wait() with ctx done = %v, want %v", err, context.Canceled)
+	}
+	acquired := make(chan error)
+	go func() {
+		acquired <- k.Acquire(context.Background(), "a")
+	}()
+	select {
+	case err := <-acquired:
+		t.Fatalf("Acquire() returned %v while the key is held", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	// a release of another key does not free the slot
+	k.TryAcquire("b")
+	k.Release("b")
+	select {
+	case err := <-acquired:
+		t.Fatalf("Acquire() returned %v after another key was released", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	k.Release("a")
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("Acquire() = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Acquire() not woken by Release")
+	}
+	if k.TryAcquire("a") {
+		t.Error("TryAcquire(a) = true, want the slot taken by Acquire")
+	}
+}
+
+func TestKeyLimiterWait(t *testing.T) {
+	k := NewKeyLimiter(1)
+	// Wait does not take the slot
+	for i := 0; i < 2; i++ {
+		if err := k.Wait(done(), "a"); err != nil {
+			t.Fatalf("Wait() = %v, want nil", err)
+		}
+	}
+	k.TryAcquire("a")
+	waited := make(chan error)
+	go func() {
+		waited <- k.Wait(context.Background(), "a")
+	}()
+	k.Release("a")
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Fatalf("Wait() = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait() not woken by Release")
+	}
+}
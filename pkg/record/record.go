@@ -0,0 +1,107 @@
+package record
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Record is a single fetched work item, along with the process invocation
+// it was passed to, as written to a record directory.
+type Record struct {
+	ID              string    `json:"id"`
+	Time            time.Time `json:"time"`
+	Driver          string    `json:"driver"`
+	Payload         string    `json:"payload"`
+	Bin             string    `json:"bin,omitempty"`
+	Args            []string  `json:"args,omitempty"`
+	Shell           string    `json:"shell,omitempty"`
+	Env             []string  `json:"env,omitempty"`
+	PassWorkAsArg   bool      `json:"passWorkAsArg"`
+	PassWorkAsStdin bool      `json:"passWorkAsStdin"`
+	PayloadFile     string    `json:"payloadFile,omitempty"`
+}
+
+// filename returns the name of the file for the record. Names sort in the
+// order the records were fetched.
+func (r *Record) filename() string {
+	return fmt.Sprintf("%s-%s.json", r.Time.UTC().Format("20060102T150405.000000000Z"), r.ID)
+}
+
+// Write writes the record to dir, creating dir if it does not exist, and
+// returns the path of the written file.
+func Write(dir string, r *Record) (string, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "record",
+		"fn":  "Write",
+		"id":  r.ID,
+	})
+	l.Debug("Writing record")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		l.WithError(err).Error("Failed to create record dir")
+		return "", err
+	}
+	jd, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		l.WithError(err).Error("Failed to marshal record")
+		return "", err
+	}
+	p := filepath.Join(dir, r.filename())
+	if err := os.WriteFile(p, jd, 0600); err != nil {
+		l.WithError(err).Error("Failed to write record")
+		return "", err
+	}
+	return p, nil
+}
+
+// List returns the paths of the records in dir, in the order they were
+// recorded.
+func List(dir string) ([]string, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "record",
+		"fn":  "List",
+		"dir": dir,
+	})
+	l.Debug("Listing records")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		l.WithError(err).Error("Failed to read record dir")
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Read reads the record at path.
+func Read(path string) (*Record, error) {
+	l := log.WithFields(log.Fields{
+		"pkg":  "record",
+		"fn":   "Read",
+		"path": path,
+	})
+	l.Debug("Reading record")
+	bd, err := os.ReadFile(path)
+	if err != nil {
+		l.WithError(err).Error("Failed to read record")
+		return nil, err
+	}
+	r := &Record{}
+	if err := json.Unmarshal(bd, r); err != nil {
+		l.WithError(err).Error("Failed to unmarshal record")
+		return nil, err
+	}
+	return r, nil
+}
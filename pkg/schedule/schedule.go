@@ -0,0 +1,245 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// the runtime image has no zoneinfo database
+	_ "time/tzdata"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrInvalidSchedule = errors.New("invalid schedule")
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar are set if the day of month or day of week field
+	// is *. If neither is, a day matches if either field matches
+	domStar, dowStar bool
+	loc              *time.Location
+}
+
+type field struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{min: 0, max: 59}
+	hourField   = field{min: 0, max: 23}
+	domField    = field{min: 1, max: 31}
+	monthField  = field{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is also accepted for Sunday
+	dowField = field{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a standard five field cron expression (minute, hour, day of
+// month, month, day of week), or one of the @yearly, @monthly, @weekly,
+// @daily and @hourly descriptors. Times are in loc, or the local time zone if
+// loc is nil, unless the expression is prefixed with CRON_TZ=<zone>.
+func Parse(expr string, loc *time.Location) (*Schedule, error) {
+	l := log.WithFields(log.Fields{
+		"pkg":  "schedule",
+		"fn":   "Parse",
+		"expr": expr,
+	})
+	l.Debug("Parse")
+	if loc == nil {
+		loc = time.Local
+	}
+	expr = strings.TrimSpace(expr)
+	for _, p := range []string{"CRON_TZ=", "TZ="} {
+		if !strings.HasPrefix(expr, p) {
+			continue
+		}
+		i := strings.IndexAny(expr, " \t")
+		if i < 0 {
+			return nil, ErrInvalidSchedule
+		}
+		var err error
+		if loc, err = time.LoadLocation(expr[len(p):i]); err != nil {
+			l.WithError(err).Error("invalid time zone")
+			return nil, err
+		}
+		expr = strings.TrimSpace(expr[i:])
+	}
+	if d, ok := descriptors[strings.ToLower(expr)]; ok {
+		expr = d
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields, got %d", ErrInvalidSchedule, len(fields))
+	}
+	s := &Schedule{
+		domStar: fields[2] == "*" || fields[2] == "?",
+		dowStar: fields[4] == "*" || fields[4] == "?",
+		loc:     loc,
+	}
+	for i, f := range []struct {
+		dst *uint64
+		f   field
+	}{
+		{&s.minute, minuteField},
+		{&s.hour, hourField},
+		{&s.dom, domField},
+		{&s.month, monthField},
+		{&s.dow, dowField},
+	} {
+		bits, err := f.f.parse(fields[i])
+		if err != nil {
+			l.WithError(err).Error("invalid field")
+			return nil, err
+		}
+		*f.dst = bits
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parse parses a comma separated list of values, ranges and steps into a
+// bitset of the matching values.
+func (f field) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rng = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("%w: invalid step %q", ErrInvalidSchedule, part)
+			}
+		}
+		lo, hi := f.min, f.max
+		if rng != "*" && rng != "?" {
+			var err error
+			bounds := strings.SplitN(rng, "-", 2)
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = f.value(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// a single value with a step runs to the end of the range
+				hi = f.max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("%w: invalid range %q", ErrInvalidSchedule, part)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value parses a single value or name of the field.
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%w: invalid value %q", ErrInvalidSchedule, s)
+	}
+	return v, nil
+}
+
+// Location returns the time zone the schedule is evaluated in.
+func (s *Schedule) Location() *time.Location {
+	return s.loc
+}
+
+// Next returns the first time after t which matches the schedule, or the
+// zero time if there is none within five years. Wall clock times skipped by a
+// daylight saving transition do not match, and times repeated by one match
+// each time they occur.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.In(s.loc)
+	// truncate in absolute time, as the wall clock minute may be ambiguous
+	t = t.Add(-time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond())).Add(time.Minute)
+	limit := t.Year() + 5
+wrap:
+	if t.Year() > limit {
+		return time.Time{}
+	}
+	for s.month&(1<<uint(t.Month())) == 0 {
+		t = date(t.Year(), t.Month()+1, 1, 0, s.loc)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+	for !s.dayMatches(t) {
+		month := t.Month()
+		t = date(t.Year(), t.Month(), t.Day()+1, 0, s.loc)
+		if t.Month() != month {
+			goto wrap
+		}
+	}
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		day := t.Day()
+		t = date(t.Year(), t.Month(), t.Day(), t.Hour()+1, s.loc)
+		if t.Day() != day {
+			goto wrap
+		}
+	}
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		hour := t.Hour()
+		t = t.Add(time.Minute)
+		if t.Hour() != hour {
+			goto wrap
+		}
+	}
+	return t
+}
+
+// date returns the start of the hour in loc. Unlike time.Date, a time skipped
+// by a daylight saving transition resolves to the end of the transition
+// rather than the start, so Next never moves backwards.
+func date(year int, month time.Month, day, hour int, loc *time.Location) time.Time {
+	t := time.Date(year, month, day, hour, 0, 0, 0, loc)
+	want := time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+	got := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+	if got.Before(want) {
+		t = t.Add(want.Sub(got))
+	}
+	return t
+}
+
+// dayMatches returns true if the day of t matches the day of month and day
+// of week fields.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,216 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestNext(t *testing.T) {
+	utc := time.UTC
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *",
+			time.Date(2022, 5, 10, 12, 30, 45, 0, utc),
+			time.Date(2022, 5, 10, 12, 31, 0, 0, utc)},
+		{"strictly after", "30 12 * * *",
+			time.Date(2022, 5, 10, 12, 30, 0, 0, utc),
+			time.Date(2022, 5, 11, 12, 30, 0, 0, utc)},
+		{"range", "10-12 * * * *",
+			time.Date(2022, 5, 10, 12, 11, 0, 0, utc),
+			time.Date(2022, 5, 10, 12, 12, 0, 0, utc)},
+		{"range wraps to next hour", "10-12 * * * *",
+			time.Date(2022, 5, 10, 12, 12, 0, 0, utc),
+			time.Date(2022, 5, 10, 13, 10, 0, 0, utc)},
+		{"step", "*/15 * * * *",
+			time.Date(2022, 5, 10, 12, 31, 0, 0, utc),
+			time.Date(2022, 5, 10, 12, 45, 0, 0, utc)},
+		{"range with step", "0 8-18/4 * * *",
+			time.Date(2022, 5, 10, 12, 0, 0, 0, utc),
+			time.Date(2022, 5, 10, 16, 0, 0, 0, utc)},
+		{"value with step runs to the end", "0 20/2 * * *",
+			time.Date(2022, 5, 10, 20, 0, 0, 0, utc),
+			time.Date(2022, 5, 10, 22, 0, 0, 0, utc)},
+		{"list", "0 0 1,15 * *",
+			time.Date(2022, 5, 2, 0, 0, 0, 0, utc),
+			time.Date(2022, 5, 15, 0, 0, 0, 0, utc)},
+		{"names", "0 0 * feb mon",
+			time.Date(2022, 5, 10, 0, 0, 0, 0, utc),
+			time.Date(2023, 2, 6, 0, 0, 0, 0, utc)},
+		{"sunday as 7", "0 0 * * 7",
+			time.Date(2022, 5, 10, 0, 0, 0, 0, utc),
+			time.Date(2022, 5, 15, 0, 0, 0, 0, utc)},
+		// 2022-05-13 is a Friday, before the 15th
+		{"dom or dow", "0 0 15 * fri",
+			time.Date(2022, 5, 10, 0, 0, 0, 0, utc),
+			time.Date(2022, 5, 13, 0, 0, 0, 0, utc)},
+		{"dom or dow matches dom", "0 0 15 * fri",
+			time.Date(2022, 5, 13, 0, 0, 0, 0, utc),
+			time.Date(2022, 5, 15, 0, 0, 0, 0, utc)},
+		{"dom with dow star", "0 0 15 * *",
+			time.Date(2022, 5, 13, 0, 0, 0, 0, utc),
+			time.Date(2022, 5, 15, 0, 0, 0, 0, utc)},
+		{"dow with dom star", "0 0 * * fri",
+			time.Date(2022, 5, 13, 0, 0, 0, 0, utc),
+			time.Date(2022, 5, 20, 0, 0, 0, 0, utc)},
+		{"month rollover", "0 0 1 * *",
+			time.Date(2022, 1, 31, 12, 0, 0, 0, utc),
+			time.Date(2022, 2, 1, 0, 0, 0, 0, utc)},
+		{"year rollover", "0 0 1 1 *",
+			time.Date(2022, 12, 31, 23, 59, 0, 0, utc),
+			time.Date(2023, 1, 1, 0, 0, 0, 0, utc)},
+		{"skips short months", "0 0 31 * *",
+			time.Date(2022, 1, 31, 0, 0, 0, 0, utc),
+			time.Date(2022, 3, 31, 0, 0, 0, 0, utc)},
+		{"leap day", "0 0 29 2 *",
+			time.Date(2022, 3, 1, 0, 0, 0, 0, utc),
+			time.Date(2024, 2, 29, 0, 0, 0, 0, utc)},
+		{"never", "0 0 30 2 *",
+			time.Date(2022, 3, 1, 0, 0, 0, 0, utc),
+			time.Time{}},
+		{"descriptor", "@monthly",
+			time.Date(2022, 5, 10, 0, 0, 0, 0, utc),
+			time.Date(2022, 6, 1, 0, 0, 0, 0, utc)},
+		{"time zone prefix", "CRON_TZ=Asia/Tokyo 0 9 * * *",
+			time.Date(2022, 5, 10, 0, 0, 0, 0, utc),
+			time.Date(2022, 5, 11, 0, 0, 0, 0, utc)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr, utc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		// 02:00 to 02:59 does not exist on 2022-03-13
+		{"spring forward skips missing time", "30 2 * * *",
+			time.Date(2022, 3, 13, 0, 0, 0, 0, ny),
+			time.Date(2022, 3, 14, 2, 30, 0, 0, ny)},
+		{"spring forward hourly", "0 * * * *",
+			time.Date(2022, 3, 13, 1, 30, 0, 0, ny),
+			time.Date(2022, 3, 13, 3, 0, 0, 0, ny)},
+		{"spring forward daily", "0 12 * * *",
+			time.Date(2022, 3, 12, 12, 0, 0, 0, ny),
+			time.Date(2022, 3, 13, 12, 0, 0, 0, ny)},
+		// 01:00 to 01:59 happens twice on 2022-11-06, first in EDT (UTC-4)
+		// then in EST (UTC-5)
+		{"fall back first", "30 1 * * *",
+			time.Date(2022, 11, 6, 0, 0, 0, 0, ny),
+			time.Date(2022, 11, 6, 5, 30, 0, 0, time.UTC)},
+		{"fall back repeats", "30 1 * * *",
+			time.Date(2022, 11, 6, 5, 30, 0, 0, time.UTC).In(ny),
+			time.Date(2022, 11, 6, 6, 30, 0, 0, time.UTC)},
+		{"fall back after repeat", "30 1 * * *",
+			time.Date(2022, 11, 6, 6, 30, 0, 0, time.UTC).In(ny),
+			time.Date(2022, 11, 7, 1, 30, 0, 0, ny)},
+		{"fall back daily", "0 12 * * *",
+			time.Date(2022, 11, 5, 12, 0, 0, 0, ny),
+			time.Date(2022, 11, 6, 12, 0, 0, 0, ny)},
+		// midnight does not exist on 2022-09-11 in Santiago
+		{"midnight gap", "0 * 11 9 *",
+			time.Date(2022, 9, 10, 23, 0, 0, 0, mustLoad(t, "America/Santiago")),
+			time.Date(2022, 9, 11, 4, 0, 0, 0, time.UTC)},
+		// Lord Howe Island moves forward 30 minutes at 02:00
+		{"half hour gap", "*/15 2 * * *",
+			time.Date(2022, 10, 1, 12, 0, 0, 0, mustLoad(t, "Australia/Lord_Howe")),
+			time.Date(2022, 10, 1, 15, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr, tt.from.Location())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{"five fields", "0 0 * * *", false},
+		{"descriptor", "@daily", false},
+		{"descriptor case", "@Hourly", false},
+		{"question mark", "0 0 ? * mon", false},
+		{"time zone", "TZ=UTC 0 0 * * *", false},
+		{"too few fields", "0 0 * *", true},
+		{"too many fields", "0 0 0 * * *", true},
+		{"empty", "", true},
+		{"minute out of range", "60 * * * *", true},
+		{"hour out of range", "0 24 * * *", true},
+		{"dom zero", "0 0 0 * *", true},
+		{"month out of range", "0 0 * 13 *", true},
+		{"dow out of range", "0 0 * * 8", true},
+		{"inverted range", "0 0 * * 5-1", true},
+		{"zero step", "*/0 * * * *", true},
+		{"negative step", "*/-1 * * * *", true},
+		{"bad step", "*/x * * * *", true},
+		{"bad name", "0 0 * foo *", true},
+		{"empty list item", "1,,2 * * * *", true},
+		{"unknown descriptor", "@reboot", true},
+		{"unknown time zone", "CRON_TZ=Nowhere/Nothing 0 0 * * *", true},
+		{"time zone only", "CRON_TZ=UTC", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.expr, time.UTC)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	tokyo := mustLoad(t, "Asia/Tokyo")
+	s, err := Parse("0 0 * * *", tokyo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Location() != tokyo {
+		t.Errorf("Location() = %v, want %v", s.Location(), tokyo)
+	}
+	s, err = Parse("CRON_TZ=UTC 0 0 * * *", tokyo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Location().String() != "UTC" {
+		t.Errorf("Location() = %v, want UTC", s.Location())
+	}
+	if _, err := Parse("61 * * * *", nil); !errors.Is(err, ErrInvalidSchedule) {
+		t.Errorf("Parse error = %v, want ErrInvalidSchedule", err)
+	}
+}
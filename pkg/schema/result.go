@@ -0,0 +1,123 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// JobResult is the result of a job, which drivers replace in their clear and
+// fail templates as {{procx_output}}, {{procx_exit_code}},
+// {{procx_duration_ms}} and {{procx_stderr}}. Fields of a JSON output are
+// selected with {{procx_output.<gjson path>}}.
+type JobResult struct {
+	Output []byte
+	// ExitCode is the exit code of the process, or nil if it did not exit
+	ExitCode *int
+	Duration time.Duration
+	// Stderr is the tail of the process stderr, if it failed
+	Stderr string
+}
+
+// IsResultKey returns true if the {{mustache}} key k is a job result variable
+// rather than a payload field.
+func IsResultKey(k string) bool {
+	switch k {
+	case "procx_output", "procx_exit_code", "procx_duration_ms", "procx_stderr":
+		return true
+	}
+	return strings.HasPrefix(k, "procx_output.")
+}
+
+// value returns the value of the job result variable k.
+func (r *JobResult) value(k string) string {
+	switch k {
+	case "procx_output":
+		return string(r.Output)
+	case "procx_exit_code":
+		if r.ExitCode == nil {
+			return ""
+		}
+		return strconv.Itoa(*r.ExitCode)
+	case "procx_duration_ms":
+		return strconv.FormatInt(r.Duration.Milliseconds(), 10)
+	case "procx_stderr":
+		return r.Stderr
+	}
+	return gjson.GetBytes(r.Output, strings.TrimPrefix(k, "procx_output.")).String()
+}
+
+// ReplaceString replaces the job result variables in s. A nil r replaces them
+// as empty.
+func (r *JobResult) ReplaceString(s string) string {
+	if r == nil {
+		r = &JobResult{}
+	}
+	var rep []string
+	for _, k := range ExtractMustacheKeys(s) {
+		if IsResultKey(k) {
+			rep = append(rep, "{{"+k+"}}", r.value(k))
+		}
+	}
+	if len(rep) == 0 {
+		return s
+	}
+	return strings.NewReplacer(rep...).Replace(s)
+}
+
+// ReplaceMap returns a copy of m with the job result variables replaced in
+// its string values, including those of nested maps and slices.
+func (r *JobResult) ReplaceMap(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+	n := make(map[string]any, len(m))
+	for k, v := range m {
+		n[k] = r.replaceValue(v)
+	}
+	return n
+}
+
+func (r *JobResult) replaceValue(v any) any {
+	switch tv := v.(type) {
+	case string:
+		return r.ReplaceString(tv)
+	case map[string]any:
+		return r.ReplaceMap(tv)
+	case []any:
+		s := make([]any, len(tv))
+		for i, sv := range tv {
+			s[i] = r.replaceValue(sv)
+		}
+		return s
+	}
+	return v
+}
+
+// ReplaceParams replaces the params which are a job result variable with its
+// value. A nil r replaces them as empty.
+func (r *JobResult) ReplaceParams(params []any) []any {
+	if r == nil {
+		r = &JobResult{}
+	}
+	for i, v := range params {
+		sv := fmt.Sprintf("%s", v)
+		if strings.Contains(sv, "{{") {
+			if k := ExtractMustacheKey(sv); IsResultKey(k) {
+				params[i] = r.value(k)
+			}
+		}
+	}
+	return params
+}
+
+// RenderParams returns the params of q with the fields of data and the job
+// result r replaced, without modifying q.
+func (q *SqlQuery) RenderParams(data []map[string]any, r *JobResult) []any {
+	params := make([]any, len(q.Params))
+	copy(params, q.Params)
+	return r.ReplaceParams(ReplaceParamsSliceMap(data, params))
+}
@@ -162,7 +162,7 @@ func ReplaceParams(bd []byte, params []any) []any {
 		sv := fmt.Sprintf("%s", v)
 		if sv == "{{procx_payload}}" {
 			params[i] = bd
-		} else if strings.Contains(sv, "{{") {
+		} else if strings.Contains(sv, "{{") && !IsResultKey(ExtractMustacheKey(sv)) {
 			key := ExtractMustacheKey(sv)
 			params[i] = gjson.GetBytes(bd, key).String()
 		}
@@ -179,7 +179,7 @@ func ReplaceParamsMap(data map[string]any, params []any) []any {
 				log.Error(err)
 			}
 			params[i] = jd
-		} else if strings.Contains(sv, "{{") {
+		} else if strings.Contains(sv, "{{") && !IsResultKey(ExtractMustacheKey(sv)) {
 			key := ExtractMustacheKey(sv)
 			params[i] = data[key]
 		}
@@ -206,7 +206,7 @@ func ReplaceParamsSliceMap(data []map[string]any, params []any) []any {
 				l.Error(err)
 			}
 			params[i] = jd
-		} else if strings.Contains(sv, "{{") {
+		} else if strings.Contains(sv, "{{") && !IsResultKey(ExtractMustacheKey(sv)) {
 			key := ExtractMustacheKey(sv)
 			l.Debug("Replacing mustache params")
 			params[i] = gjson.GetBytes(bd, key).String()
@@ -236,6 +236,10 @@ func ReplaceParamsString(bd []byte, params string) string {
 	s := strings.ReplaceAll(params, "{{procx_payload}}", string(bd))
 	keys := ExtractMustacheKeys(s)
 	for _, k := range keys {
+		if IsResultKey(k) {
+			// replaced with the job result by the driver
+			continue
+		}
 		jv := gjson.GetBytes(bd, k)
 		s = ReplaceJSONKey(s, k, jv.String())
 	}
@@ -257,6 +261,9 @@ func ReplaceParamsMapString(data map[string]any, params string) string {
 	s := strings.ReplaceAll(params, "{{procx_payload}}", string(jd))
 	keys := ExtractMustacheKeys(s)
 	for _, k := range keys {
+		if IsResultKey(k) {
+			continue
+		}
 		jv := gjson.GetBytes(jd, k)
 		s = ReplaceJSONKey(s, k, jv.String())
 	}
@@ -278,9 +285,30 @@ func ReplaceParamsSliceMapString(data []map[string]any, params string) string {
 	s := strings.ReplaceAll(params, "{{procx_payload}}", string(jd))
 	keys := ExtractMustacheKeys(s)
 	for _, k := range keys {
+		if IsResultKey(k) {
+			continue
+		}
 		jv := gjson.GetBytes(jd, k)
 		s = ReplaceJSONKey(s, k, jv.String())
 	}
 	l.Debug("Replaced params map string: ", s)
 	return s
 }
+
+// PreviewSqlQuery renders the params of q against data without modifying q,
+// and returns the query and params as they would be executed.
+func PreviewSqlQuery(data []map[string]any, q *SqlQuery) map[string]any {
+	if q == nil || q.Query == "" {
+		return nil
+	}
+	params := make([]any, len(q.Params))
+	copy(params, q.Params)
+	params = ReplaceParamsSliceMap(data, params)
+	for i, v := range params {
+		params[i] = HandleField(v)
+	}
+	return map[string]any{
+		"query":  q.Query,
+		"params": params,
+	}
+}
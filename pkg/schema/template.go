@@ -0,0 +1,52 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+var (
+	ErrMissingField = errors.New("missing field")
+)
+
+// RenderTemplate replaces the {{mustache}} keys in s with the fields of the
+// JSON payload bd, using the same syntax as ReplaceParamsString. If strict is
+// set, an error is returned if a field does not exist in the payload. If
+// quote is not nil, it is applied to each replaced value.
+func RenderTemplate(bd []byte, s string, strict bool, quote func(string) string) (string, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "schema",
+		"fn":  "RenderTemplate",
+	})
+	l.Debug("Rendering template")
+	if quote == nil {
+		quote = func(v string) string { return v }
+	}
+	var rep []string
+	for _, k := range ExtractMustacheKeys(s) {
+		var v string
+		if k == "procx_payload" {
+			v = string(bd)
+		} else {
+			jv := gjson.GetBytes(bd, k)
+			if strict && !jv.Exists() {
+				l.Errorf("missing field %s", k)
+				return "", fmt.Errorf("%w: %s", ErrMissingField, k)
+			}
+			v = jv.String()
+		}
+		rep = append(rep, "{{"+k+"}}", quote(v))
+	}
+	// a single pass, so {{keys}} in the replaced values are not replaced in
+	// turn
+	return strings.NewReplacer(rep...).Replace(s), nil
+}
+
+// ShellQuote quotes s to be passed as a single word to a POSIX shell.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,80 @@
+package schema
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		s       string
+		strict  bool
+		quote   func(string) string
+		want    string
+		wantErr error
+	}{
+		{"field", `{"a":"x"}`, "echo {{a}}", false, nil, "echo x", nil},
+		{"nested field", `{"a":{"b":"x"}}`, "echo {{a.b}}", false, nil, "echo x", nil},
+		{"repeated field", `{"a":"x"}`, "{{a}} {{a}}", false, nil, "x x", nil},
+		{"payload", `{"a":"x"}`, "{{procx_payload}}", false, nil, `{"a":"x"}`, nil},
+		{"missing field", `{}`, "echo {{a}}", false, nil, "echo ", nil},
+		{"missing field strict", `{}`, "echo {{a}}", true, nil, "", ErrMissingField},
+		{"no placeholders", `{"a":"x"}`, "echo a", false, nil, "echo a", nil},
+		// values are not rendered in turn
+		{"placeholder in value", `{"a":"{{b}}","b":"x"}`, "{{a}} {{b}}", false, nil, "{{b}} x", nil},
+		{"placeholder in value quoted", `{"a":"{{b}}","b":"x; touch /tmp/pwned #"}`, "echo {{a}}", false, ShellQuote,
+			"echo '{{b}}'", nil},
+		{"placeholder in later value quoted", `{"a":"x; touch /tmp/pwned #","b":"{{a}}"}`, "echo {{b}} {{a}}", false, ShellQuote,
+			"echo '{{a}}' 'x; touch /tmp/pwned #'", nil},
+		{"payload with placeholder", `{"a":"{{b}}","b":"x"}`, "{{procx_payload}}", false, ShellQuote,
+			`'{"a":"{{b}}","b":"x"}'`, nil},
+		{"quote in value", `{"a":"it's"}`, "echo {{a}}", false, ShellQuote, `echo 'it'\''s'`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderTemplate([]byte(tt.payload), tt.s, tt.strict, tt.quote)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RenderTemplate() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RenderTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"", "''"},
+		{"a b", "'a b'"},
+		{"it's", `'it'\''s'`},
+		{"''", `''\'''\'''`},
+		{"$(id) `id` $HOME", "'$(id) `id` $HOME'"},
+		{"a\nb", "'a\nb'"},
+	}
+	for _, tt := range tests {
+		if got := ShellQuote(tt.s); got != tt.want {
+			t.Errorf("ShellQuote(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+	// the shell sees each quoted value as the original word
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh")
+	}
+	for _, tt := range tests {
+		out, err := exec.Command(sh, "-c", "printf %s "+ShellQuote(tt.s)).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != tt.s {
+			t.Errorf("sh -c printf %%s %s = %q, want %q", ShellQuote(tt.s), out, tt.s)
+		}
+	}
+}
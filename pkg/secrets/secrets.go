@@ -0,0 +1,176 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrSecretNotFound = errors.New("secret not found")
+)
+
+// Provider resolves secret references for a scheme. ref is the part of the
+// reference after "<scheme>://".
+type Provider interface {
+	Resolve(ref string) (string, error)
+}
+
+// ProviderFunc adapts a function to a Provider.
+type ProviderFunc func(ref string) (string, error)
+
+func (f ProviderFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Provider{
+		"file": ProviderFunc(resolveFile),
+		"env":  ProviderFunc(resolveEnv),
+		"exec": ProviderFunc(resolveExec),
+	}
+)
+
+// Register registers a Provider for references of the form
+// "<scheme>://<ref>", replacing any existing provider for scheme.
+func Register(scheme string, p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[scheme] = p
+}
+
+// provider returns the provider and reference of v, or nil if v is not a
+// reference for a registered scheme.
+func provider(v string) (Provider, string) {
+	i := strings.Index(v, "://")
+	if i < 1 {
+		return nil, ""
+	}
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[v[:i]]
+	if !ok {
+		return nil, ""
+	}
+	return p, v[i+3:]
+}
+
+// IsRef returns true if v is a reference for a registered scheme.
+func IsRef(v string) bool {
+	p, _ := provider(v)
+	return p != nil
+}
+
+// Resolve returns the secret v refers to, or v as-is if it is not a
+// reference for a registered scheme. References are resolved each time
+// Resolve is called, so rotated secrets are picked up.
+func Resolve(v string) (string, error) {
+	l := log.WithFields(log.Fields{
+		"pkg": "secrets",
+		"fn":  "Resolve",
+	})
+	p, ref := provider(v)
+	if p == nil {
+		return v, nil
+	}
+	l.Debug("resolving secret")
+	s, err := p.Resolve(ref)
+	if err != nil {
+		// the reference is logged rather than the secret
+		l.WithError(err).Errorf("failed to resolve secret %s", v)
+		return "", err
+	}
+	return s, nil
+}
+
+// ResolvePtr resolves the secret v points to, if v is not nil.
+func ResolvePtr(v *string) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	s, err := Resolve(*v)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// resolveFile reads the secret from a file, such as a mounted secret volume.
+// A trailing newline is removed.
+func resolveFile(ref string) (string, error) {
+	b, err := os.ReadFile(ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// resolveEnv reads the secret from an environment variable.
+func resolveEnv(ref string) (string, error) {
+	v, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("%w: env %s", ErrSecretNotFound, ref)
+	}
+	return v, nil
+}
+
+// resolveExec runs a helper command and reads the secret from its stdout.
+// The command is split on whitespace, and a trailing newline is removed.
+func resolveExec(ref string) (string, error) {
+	args := strings.Fields(ref)
+	if len(args) == 0 {
+		return "", fmt.Errorf("%w: empty exec command", ErrSecretNotFound)
+	}
+	var stdout bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
+
+// connector is a database/sql connector which builds the data source name
+// for each new connection.
+type connector struct {
+	driver driver.Driver
+	dsn    func() (string, error)
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	dsn, err := c.dsn()
+	if err != nil {
+		return nil, err
+	}
+	return c.driver.Open(dsn)
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// OpenDB opens a database/sql pool for driverName, calling dsn for the data
+// source name of each new connection, so secrets used in the data source
+// name are resolved again when the pool reconnects.
+func OpenDB(driverName string, dsn func() (string, error)) (*sql.DB, error) {
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	if err := db.Close(); err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(&connector{driver: drv, dsn: dsn}), nil
+}
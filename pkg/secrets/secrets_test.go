@@ -0,0 +1,168 @@
+package secrets
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// errExit marks a test which expects the exec helper to exit non-zero.
+var errExit = errors.New("exit error")
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "secret")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PROCX_TEST_SECRET", "from-env")
+	t.Setenv("PROCX_TEST_EMPTY_SECRET", "")
+	_, noSh := exec.LookPath("sh")
+	tests := []struct {
+		name    string
+		v       string
+		want    string
+		wantErr error
+		needSh  bool
+	}{
+		{"plain value", "value", "value", nil, false},
+		{"unknown scheme", "vault://secret/db", "vault://secret/db", nil, false},
+		{"url", "postgres://user@host/db", "postgres://user@host/db", nil, false},
+		{"no scheme", "://x", "://x", nil, false},
+		{"file", "file://" + file, "from-file", nil, false},
+		{"file missing", "file://" + filepath.Join(dir, "missing"), "", os.ErrNotExist, false},
+		{"env", "env://PROCX_TEST_SECRET", "from-env", nil, false},
+		{"env empty", "env://PROCX_TEST_EMPTY_SECRET", "", nil, false},
+		{"env missing", "env://PROCX_TEST_MISSING_SECRET", "", ErrSecretNotFound, false},
+		{"exec", "exec://echo from-exec", "from-exec", nil, false},
+		{"exec empty", "exec://", "", ErrSecretNotFound, false},
+		{"exec not found", "exec://procx-test-missing-helper", "", exec.ErrNotFound, false},
+		{"exec fails", "exec://sh -c false", "", errExit, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.needSh && noSh != nil {
+				t.Skip("no sh")
+			}
+			got, err := Resolve(tt.v)
+			if tt.wantErr == errExit {
+				var ee *exec.ExitError
+				if !errors.As(err, &ee) {
+					t.Fatalf("Resolve(%q) error = %v, want an exit error", tt.v, err)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Resolve(%q) error = %v, want %v", tt.v, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.v, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if IsRef("test://a") {
+		t.Fatal("IsRef(test://a) = true before the scheme is registered")
+	}
+	Register("test", ProviderFunc(func(ref string) (string, error) {
+		return "secret-" + ref, nil
+	}))
+	t.Cleanup(func() {
+		providersMu.Lock()
+		defer providersMu.Unlock()
+		delete(providers, "test")
+	})
+	if !IsRef("test://a") {
+		t.Error("IsRef(test://a) = false, want true")
+	}
+	v := "test://a"
+	got, err := ResolvePtr(&v)
+	if err != nil || got == nil || *got != "secret-a" {
+		t.Errorf("ResolvePtr(test://a) = %v, %v, want secret-a", got, err)
+	}
+	if got, err := ResolvePtr(nil); got != nil || err != nil {
+		t.Errorf("ResolvePtr(nil) = %v, %v, want nil, nil", got, err)
+	}
+}
+
+// testDriver is a database/sql driver which records the data source names
+// it opens connections with.
+type testDriver struct {
+	mu   sync.Mutex
+	dsns []string
+}
+
+func (d *testDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dsns = append(d.dsns, dsn)
+	return testConn{}, nil
+}
+
+type testConn struct{}
+
+func (testConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not implemented") }
+func (testConn) Close() error                              { return nil }
+func (testConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not implemented") }
+
+var testDrv = &testDriver{}
+
+func init() {
+	sql.Register("secretstest", testDrv)
+}
+
+func TestOpenDB(t *testing.T) {
+	// the password is rotated between connections
+	var n int
+	db, err := OpenDB("secretstest", func() (string, error) {
+		n++
+		return "password=" + strconv.Itoa(n), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// connections are not reused, so each is connected again
+	db.SetMaxIdleConns(0)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := db.PingContext(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testDrv.mu.Lock()
+	defer testDrv.mu.Unlock()
+	want := []string{"password=1", "password=2"}
+	if len(testDrv.dsns) != len(want) {
+		t.Fatalf("connected with %v, want %v", testDrv.dsns, want)
+	}
+	for i := range want {
+		if testDrv.dsns[i] != want[i] {
+			t.Errorf("connected with %v, want %v", testDrv.dsns, want)
+		}
+	}
+}
+
+func TestOpenDBError(t *testing.T) {
+	errDSN := errors.New("secret unavailable")
+	db, err := OpenDB("secretstest", func() (string, error) {
+		return "", errDSN
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Ping(); !errors.Is(err, errDSN) {
+		t.Errorf("Ping() = %v, want %v", err, errDSN)
+	}
+	if _, err := OpenDB("secretstest-missing", nil); err == nil {
+		t.Error("OpenDB() with an unknown driver = nil, want an error")
+	}
+}
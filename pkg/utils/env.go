@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ReadEnvFile reads a file of KEY=VAL lines and returns them as KEY=VAL
+// strings. Blank lines, lines starting with #, and an "export " prefix are
+// ignored, and values may be wrapped in single or double quotes.
+func ReadEnvFile(path string) ([]string, error) {
+	l := log.WithFields(log.Fields{
+		"pkg":  "utils",
+		"fn":   "ReadEnvFile",
+		"path": path,
+	})
+	l.Debug("Reading env file")
+	f, err := os.Open(path)
+	if err != nil {
+		l.WithError(err).Error("Failed to open env file")
+		return nil, err
+	}
+	defer f.Close()
+	var env []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			l.Warnf("skipping invalid env line: %s", line)
+			continue
+		}
+		k := strings.TrimSpace(kv[0])
+		v := strings.TrimSpace(kv[1])
+		if len(v) > 1 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		env = append(env, k+"="+v)
+	}
+	if err := s.Err(); err != nil {
+		l.WithError(err).Error("Failed to read env file")
+		return nil, err
+	}
+	return env, nil
+}
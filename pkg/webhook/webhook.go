@@ -0,0 +1,344 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robertlestak/procx/pkg/schema"
+	"github.com/robertlestak/procx/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+type Event string
+
+var (
+	EventStart      Event = "start"
+	EventSuccess    Event = "success"
+	EventFailure    Event = "failure"
+	EventRetry      Event = "retry"
+	EventDeadLetter Event = "dead-letter"
+)
+
+var (
+	ErrInvalidEvent = errors.New("invalid webhook event")
+	ErrDelivery     = errors.New("webhook delivery failed")
+)
+
+// DefaultQueueSize is the default number of events buffered for delivery.
+const DefaultQueueSize = 100
+
+// Job is a job lifecycle event.
+type Job struct {
+	Event  Event     `json:"event"`
+	Time   time.Time `json:"time"`
+	JobID  string    `json:"jobID"`
+	Driver string    `json:"driver"`
+	// ExitCode is the exit code of the process, if it ran and exited
+	ExitCode *int `json:"exitCode,omitempty"`
+	// Duration is the time in milliseconds since the job started
+	Duration int64  `json:"durationMs"`
+	Error    string `json:"error,omitempty"`
+	// Stderr is the tail of the process stderr, if it failed
+	Stderr string `json:"stderr,omitempty"`
+	// DeliveryCount is the number of times the work has been delivered, if
+	// known
+	DeliveryCount int `json:"deliveryCount,omitempty"`
+	// Fields are the payload fields selected by Webhook.Fields
+	Fields  map[string]any `json:"fields,omitempty"`
+	Payload []byte         `json:"-"`
+}
+
+// Webhook sends job lifecycle events to an HTTP endpoint.
+type Webhook struct {
+	URL         string
+	Method      string
+	ContentType string
+	Headers     map[string]string
+	// Body is the body template. If empty, the Job is sent as JSON
+	Body string
+	// Fields are the gjson paths of the payload fields included in the
+	// default body
+	Fields []string
+	// Events are the events sent. All events are sent if empty
+	Events []Event
+	// Timeout is the timeout of each request
+	Timeout time.Duration
+	// Retries is the number of times a failed request is retried, waiting
+	// RetryInterval before the first retry and doubling it on each retry
+	Retries       int
+	RetryInterval time.Duration
+	EnableTLS     *bool
+	TLSInsecure   *bool
+	TLSCA         *string
+	TLSCert       *string
+	TLSKey        *string
+	Client        *http.Client
+	// QueueSize is the number of events buffered for delivery by Enqueue.
+	// Events enqueued while the queue is full are dropped. Defaults to
+	// DefaultQueueSize
+	QueueSize int
+
+	mu     sync.Mutex
+	queue  chan *Job
+	closed bool
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// ParseEvents parses a comma separated list of events.
+func ParseEvents(s string) ([]Event, error) {
+	var evs []Event
+	for _, v := range strings.Split(s, ",") {
+		ev := Event(strings.TrimSpace(v))
+		switch ev {
+		case "":
+			continue
+		case EventStart, EventSuccess, EventFailure, EventRetry, EventDeadLetter:
+			evs = append(evs, ev)
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrInvalidEvent, ev)
+		}
+	}
+	return evs, nil
+}
+
+// ParseHeaders parses a newline separated list of key:value headers. Header
+// values may contain commas, so they are not used as a separator.
+func ParseHeaders(s string) map[string]string {
+	r := make(map[string]string)
+	for _, v := range strings.Split(s, "\n") {
+		kv := strings.SplitN(v, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		r[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return r
+}
+
+func (w *Webhook) Init() error {
+	l := log.WithFields(log.Fields{
+		"pkg": "webhook",
+		"fn":  "Init",
+	})
+	l.Debug("Initializing webhook")
+	if w.Method == "" {
+		w.Method = http.MethodPost
+	}
+	tc, err := utils.TlsConfig(w.EnableTLS, w.TLSInsecure, w.TLSCA, w.TLSCert, w.TLSKey)
+	if err != nil {
+		return err
+	}
+	w.Client = &http.Client{
+		Timeout: w.Timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: tc,
+		},
+	}
+	if w.QueueSize < 1 {
+		w.QueueSize = DefaultQueueSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.queue = make(chan *Job, w.QueueSize)
+	w.cancel = cancel
+	w.done = make(chan struct{})
+	go w.deliver(ctx)
+	return nil
+}
+
+// deliver sends the enqueued events in order until the queue is closed, or
+// ctx is cancelled.
+func (w *Webhook) deliver(ctx context.Context) {
+	defer close(w.done)
+	for j := range w.queue {
+		if ctx.Err() != nil {
+			continue
+		}
+		w.Send(ctx, j)
+	}
+}
+
+// Enqueue queues the event j to be sent in the background, so the job is not
+// held up by a slow or unavailable endpoint. Events are sent in the order
+// they are enqueued. If the queue is full, or the Webhook is closed, the
+// event is dropped.
+func (w *Webhook) Enqueue(j *Job) {
+	l := log.WithFields(log.Fields{
+		"pkg":   "webhook",
+		"fn":    "Enqueue",
+		"event": j.Event,
+		"job":   j.JobID,
+	})
+	if !w.Enabled(j.Event) {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed || w.queue == nil {
+		l.Warn("webhook is closed, dropping event")
+		return
+	}
+	select {
+	case w.queue <- j:
+	default:
+		l.Warn("webhook queue is full, dropping event")
+	}
+}
+
+// Close stops accepting events, and waits for the queued events to be sent
+// until ctx is done, when any delivery in progress is cancelled and the
+// remaining events are dropped.
+func (w *Webhook) Close(ctx context.Context) {
+	w.mu.Lock()
+	if w.closed || w.queue == nil {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		w.cancel()
+		<-w.done
+	}
+	w.cancel()
+}
+
+// Enabled returns true if ev is sent.
+func (w *Webhook) Enabled(ev Event) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == ev {
+			return true
+		}
+	}
+	return false
+}
+
+// Send sends the event j, retrying failed requests. Events which are not
+// enabled are ignored.
+func (w *Webhook) Send(ctx context.Context, j *Job) error {
+	l := log.WithFields(log.Fields{
+		"pkg":   "webhook",
+		"fn":    "Send",
+		"event": j.Event,
+		"job":   j.JobID,
+	})
+	if !w.Enabled(j.Event) {
+		return nil
+	}
+	l.Debug("Sending webhook")
+	bd, err := w.render(j)
+	if err != nil {
+		l.WithError(err).Error("failed to render webhook body")
+		return err
+	}
+	wait := w.RetryInterval
+	for i := 0; ; i++ {
+		err = w.do(ctx, bd)
+		if err == nil {
+			l.Debug("webhook sent")
+			return nil
+		}
+		if i >= w.Retries {
+			break
+		}
+		l.WithError(err).Warnf("webhook failed, retrying in %s", wait)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrDelivery, ctx.Err())
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+	l.WithError(err).Error("webhook failed")
+	return fmt.Errorf("%w: %v", ErrDelivery, err)
+}
+
+// do sends a single request with body bd.
+func (w *Webhook) do(ctx context.Context, bd []byte) error {
+	req, err := http.NewRequestWithContext(ctx, w.Method, w.URL, bytes.NewReader(bd))
+	if err != nil {
+		return err
+	}
+	for k, v := range w.Headers {
+		req.Header.Add(k, v)
+	}
+	if w.ContentType != "" {
+		req.Header.Set("Content-Type", w.ContentType)
+	}
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// render returns the request body for j.
+func (w *Webhook) render(j *Job) ([]byte, error) {
+	if w.Body == "" {
+		if len(w.Fields) > 0 {
+			j.Fields = make(map[string]any)
+			for _, f := range w.Fields {
+				j.Fields[f] = gjson.GetBytes(j.Payload, f).Value()
+			}
+		}
+		return json.Marshal(j)
+	}
+	// values are escaped to be placed in a JSON string in a JSON body
+	quote := func(v string) string { return v }
+	if strings.Contains(w.ContentType, "json") {
+		quote = func(v string) string {
+			b, _ := json.Marshal(v)
+			return string(b[1 : len(b)-1])
+		}
+	}
+	vars := j.Vars()
+	var rep []string
+	for _, k := range schema.ExtractMustacheKeys(w.Body) {
+		v, ok := vars[k]
+		if !ok {
+			v = gjson.GetBytes(j.Payload, k).String()
+		}
+		rep = append(rep, "{{"+k+"}}", quote(v))
+	}
+	// a single pass, so values are not replaced in turn
+	return []byte(strings.NewReplacer(rep...).Replace(w.Body)), nil
+}
+
+// Vars returns the {{procx_*}} template variables of j.
+func (j *Job) Vars() map[string]string {
+	v := map[string]string{
+		"procx_event":          string(j.Event),
+		"procx_time":           j.Time.Format(time.RFC3339),
+		"procx_job_id":         j.JobID,
+		"procx_driver":         j.Driver,
+		"procx_exit_code":      "",
+		"procx_duration_ms":    strconv.FormatInt(j.Duration, 10),
+		"procx_error":          j.Error,
+		"procx_stderr":         j.Stderr,
+		"procx_payload":        string(j.Payload),
+		"procx_delivery_count": strconv.Itoa(j.DeliveryCount),
+	}
+	if j.ExitCode != nil {
+		v["procx_exit_code"] = strconv.Itoa(*j.ExitCode)
+	}
+	return v
+}
@@ -0,0 +1,211 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		s    string
+		want map[string]string
+	}{
+		{"", map[string]string{}},
+		{"Authorization:Bearer xxx", map[string]string{"Authorization": "Bearer xxx"}},
+		{"A: 1\nB:2\n", map[string]string{"A": "1", "B": "2"}},
+		{"Accept: a, b", map[string]string{"Accept": "a, b"}},
+		{"X-Time:12:00", map[string]string{"X-Time": "12:00"}},
+		{"invalid\nA:1", map[string]string{"A": "1"}},
+	}
+	for _, tt := range tests {
+		got := ParseHeaders(tt.s)
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseHeaders(%q) = %v, want %v", tt.s, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("ParseHeaders(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	exit := 3
+	j := &Job{
+		Event:    EventFailure,
+		JobID:    "job",
+		ExitCode: &exit,
+		Error:    `exit "3"`,
+		Payload:  []byte(`{"a":"say \"hi\"\n","b":"{{procx_job_id}}","c":{"d":1}}`),
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		fields      []string
+		want        string
+	}{
+		{"json escaped", "application/json", `{"a":"{{a}}","err":"{{procx_error}}","exit":{{procx_exit_code}}}`, nil,
+			`{"a":"say \"hi\"\n","err":"exit \"3\"","exit":3}`},
+		{"text as-is", "text/plain", `{{a}}{{procx_error}}`, nil, "say \"hi\"\n" + `exit "3"`},
+		// values are not rendered in turn
+		{"placeholder in value", "text/plain", `{{b}} {{procx_job_id}}`, nil, "{{procx_job_id}} job"},
+		{"missing field", "text/plain", `[{{x}}]`, nil, "[]"},
+		{"default body fields", "application/json", "", []string{"c.d"},
+			`{"event":"failure","time":"0001-01-01T00:00:00Z","jobID":"job","driver":"","exitCode":3,"durationMs":0,"error":"exit \"3\"","fields":{"c.d":1}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &Webhook{ContentType: tt.contentType, Body: tt.body, Fields: tt.fields}
+			got, err := w.render(j)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("render() = %s, want %s", got, tt.want)
+			}
+			if tt.contentType == "application/json" && !json.Valid(got) {
+				t.Errorf("render() = %s, want valid JSON", got)
+			}
+		})
+	}
+}
+
+// server returns a test server which fails the first fail requests, and
+// records the time of each request.
+func server(t *testing.T, fail int) (*httptest.Server, func() []time.Time) {
+	t.Helper()
+	var mu sync.Mutex
+	var times []time.Time
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		times = append(times, time.Now())
+		if len(times) <= fail {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s, func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Time{}, times...)
+	}
+}
+
+func TestSendRetry(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	tests := []struct {
+		name    string
+		fail    int
+		retries int
+		wantErr bool
+	}{
+		{"success", 0, 2, false},
+		{"retried", 2, 2, false},
+		{"retries exhausted", 3, 2, true},
+		{"no retries", 1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, times := server(t, tt.fail)
+			w := &Webhook{URL: s.URL, Retries: tt.retries, RetryInterval: interval, Timeout: time.Second}
+			if err := w.Init(); err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close(context.Background())
+			err := w.Send(context.Background(), &Job{Event: EventStart})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrDelivery) {
+				t.Errorf("Send() = %v, want %v", err, ErrDelivery)
+			}
+			ts := times()
+			want := tt.fail + 1
+			if want > tt.retries+1 {
+				want = tt.retries + 1
+			}
+			if len(ts) != want {
+				t.Fatalf("sent %d requests, want %d", len(ts), want)
+			}
+			// the wait doubles on each retry
+			for i := 1; i < len(ts); i++ {
+				min := interval << (i - 1)
+				if d := ts[i].Sub(ts[i-1]); d < min {
+					t.Errorf("retry %d after %s, want at least %s", i, d, min)
+				}
+			}
+		})
+	}
+}
+
+func TestSendRetryCancelled(t *testing.T) {
+	s, times := server(t, 10)
+	w := &Webhook{URL: s.URL, Retries: 5, RetryInterval: time.Hour, Timeout: time.Second}
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := w.Send(ctx, &Job{Event: EventStart}); !errors.Is(err, ErrDelivery) {
+		t.Fatalf("Send() = %v, want %v", err, ErrDelivery)
+	}
+	if n := len(times()); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	// no events are delivered, so the queue fills up
+	w := &Webhook{queue: make(chan *Job, 2)}
+	for _, id := range []string{"1", "2", "3"} {
+		w.Enqueue(&Job{Event: EventStart, JobID: id})
+	}
+	if n := len(w.queue); n != 2 {
+		t.Fatalf("queued %d events, want 2", n)
+	}
+	for _, want := range []string{"1", "2"} {
+		if j := <-w.queue; j.JobID != want {
+			t.Errorf("dequeued %s, want %s", j.JobID, want)
+		}
+	}
+	// disabled events are not queued
+	w.Events = []Event{EventFailure}
+	w.Enqueue(&Job{Event: EventStart})
+	if n := len(w.queue); n != 0 {
+		t.Errorf("queued %d disabled events, want 0", n)
+	}
+}
+
+func TestEnqueueDelivered(t *testing.T) {
+	s, times := server(t, 0)
+	w := &Webhook{URL: s.URL, Timeout: time.Second, QueueSize: 10}
+	if err := w.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		w.Enqueue(&Job{Event: EventStart})
+	}
+	// Close waits for the queued events to be sent
+	w.Close(context.Background())
+	if n := len(times()); n != 3 {
+		t.Errorf("sent %d events, want 3", n)
+	}
+	// events enqueued once closed are dropped
+	w.Enqueue(&Job{Event: EventStart})
+	if n := len(times()); n != 3 {
+		t.Errorf("sent %d events after Close, want 3", n)
+	}
+}
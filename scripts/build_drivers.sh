@@ -13,6 +13,18 @@ varnameForDriver() {
     awk -F ' ' '{print $1}'
 }
 
+# pkgForDriver returns the package under drivers/ which implements the driver
+pkgForDriver() {
+    case "$1" in
+        replay)
+            echo local
+            ;;
+        *)
+            echo $1 | awk -F'-' '{print $1}'
+            ;;
+    esac
+}
+
 containsElement() {
     local e
     for e in "${@:2}"; do [[ "$e" == "$1" ]] && return 0; done
@@ -44,10 +56,10 @@ disabledDrivers() {
 disabledImports() {
     local DISABLED=()
     for driver in $(disabledDrivers); do
-        sdriver=$(echo $driver | awk -F'-' '{print $1}')
+        sdriver=$(pkgForDriver $driver)
         found="false"
         for edriver in ${DESIRED_DRIVERS[@]}; do
-            sedriver=$(echo $edriver | awk -F'-' '{print $1}')
+            sedriver=$(pkgForDriver $edriver)
             if [ "$sdriver" == "$sedriver" ]; then
                 found="true"
                 break
@@ -1,15 +1,6 @@
 #!/bin/bash
 
-VARS=(`egrep -oh --exclude Makefile \
-    --exclude-dir bin \
-    --exclude-dir scripts \
-    -R 'os.Getenv\(.*?\)' . | \
-    tr -d ' ' | \
-    sort | \
-    uniq | \
-    sed -e 's,os.Getenv(,,g' -e 's,),,g' \
-    -e 's,",,g' \
-    -e 's,prefix+,PROCX_,g'`)
+VARS=(`make -s envvars`)
 for VAR in ${VARS[@]}; do
     echo "- \`$VAR\`"
 done